@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"report"
+)
+
+// runAST prints the parsed syntax tree of a document
+func runAST(args []string) {
+	fs := flag.NewFlagSet("ast", flag.ExitOnError)
+	options := optionFlags(fs)
+	format := fs.String("format", "json", "Output format: json or yaml")
+	fs.Usage = func() {
+		fmt.Println("Usage: report ast [flags] <input.md>")
+		fmt.Println("Use - as input to read from stdin.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	inputPath := fs.Arg(0)
+	opts := options(inputPath)
+
+	var md []byte
+	var err error
+	if inputPath == "-" {
+		md, err = io.ReadAll(os.Stdin)
+	} else {
+		md, err = os.ReadFile(inputPath)
+		opts.BaseDir = filepath.Dir(inputPath)
+	}
+	if err == nil {
+		err = report.DumpAST(os.Stdout, md, *format, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"report"
+)
+
+// batchInput is a document of a batch and the path of its PDF, relative to the
+// output directory
+type batchInput struct {
+	path   string
+	output string
+	// staged is where the PDF is written until the batch is published, or "" to
+	// write it to output directly
+	staged string
+}
+
+// stagedFile is a file written to the staging directory and the output it replaces
+type stagedFile struct {
+	staged, output string
+	// previous is where the output it replaced was moved, "" if there was none
+	previous string
+}
+
+// batchResult is the outcome of converting one document of a batch
+type batchResult struct {
+	done bool
+	err  error
+}
+
+// runBatch converts many documents to one PDF each, several at a time
+func runBatch(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	options := optionFlags(fs)
+	logging := logFlags(fs)
+	outDir := fs.String("out", "", "Directory for the PDFs, mirroring the directories below each pattern (default: next to each input)")
+	jobs := fs.Int("jobs", runtime.NumCPU(), "Number of documents converted at the same time")
+	failFast := fs.Bool("fail-fast", false, "Stop starting conversions after the first failure")
+	continueOnError := fs.Bool("continue-on-error", false, "Convert all documents even if some fail (default)")
+	atomicOut := fs.Bool("atomic", false, "Write the PDFs to a staging directory in -out and publish them only if all documents convert, keeping the previous ones otherwise")
+	fs.Usage = func() {
+		fmt.Println("Usage: report batch [flags] <pattern>...")
+		fmt.Println("Converts every markdown file matching the patterns to a PDF of the same name.")
+		fmt.Println("Patterns are paths or globs, where ** matches any number of directories.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || *jobs < 1 || (*failFast && *continueOnError) || (*atomicOut && *outDir == "") {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	log, err := logging(os.Stdout)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	inputs, err := expandPatterns(fs.Args())
+	if err != nil {
+		log.errorf("%v", err)
+		os.Exit(1)
+	}
+	outputs := map[string]string{}
+	for i, input := range inputs {
+		if *outDir == "" {
+			inputs[i].output = strings.TrimSuffix(input.path, filepath.Ext(input.path)) + ".pdf"
+		} else {
+			inputs[i].output = filepath.Join(*outDir, input.output)
+		}
+		if other, ok := outputs[inputs[i].output]; ok {
+			log.errorf("%s and %s would both be written to %s", other, input.path, inputs[i].output)
+			os.Exit(1)
+		}
+		outputs[inputs[i].output] = input.path
+	}
+
+	stagingDir := ""
+	if *atomicOut {
+		if stagingDir, err = stageOutputs(inputs, *outDir); err != nil {
+			log.errorf("%v", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(stagingDir)
+	}
+
+	opts := options(inputs[0].path)
+	start := time.Now()
+	results := convertBatch(inputs, opts, *jobs, *failFast, log)
+
+	var failed []string
+	converted, skipped := 0, 0
+	for i, result := range results {
+		switch {
+		case !result.done:
+			skipped++
+		case result.err != nil:
+			failed = append(failed, fmt.Sprintf("  %s: %v", inputs[i].path, result.err))
+		default:
+			converted++
+		}
+	}
+	summary := fmt.Sprintf("Converted %d of %d files in %s", converted, len(inputs), time.Since(start).Round(10*time.Millisecond))
+	if len(failed) > 0 {
+		summary += fmt.Sprintf(", %d failed", len(failed))
+	}
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	log.infof("%s", summary)
+	for _, line := range failed {
+		log.infof("%s", line)
+	}
+	if *atomicOut {
+		if len(failed) > 0 || skipped > 0 {
+			log.infof("Kept the previous PDFs in %s", *outDir)
+		} else if err := publish(inputs, stagingDir, opts.Finalize); err != nil {
+			log.errorf("%v; kept the previous PDFs in %s", err, *outDir)
+			os.RemoveAll(stagingDir)
+			os.Exit(1)
+		} else {
+			log.infof("Published %d files to %s", len(inputs), *outDir)
+		}
+	}
+	if len(failed) > 0 {
+		os.RemoveAll(stagingDir)
+		os.Exit(1)
+	}
+}
+
+// stageOutputs creates a staging directory in outDir and points the inputs at their
+// place in it, so the PDFs only replace those in outDir once all are written
+func stageOutputs(inputs []batchInput, outDir string) (string, error) {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(outDir, ".staging-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	for i, input := range inputs {
+		rel, err := filepath.Rel(outDir, input.output)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		inputs[i].staged = filepath.Join(dir, rel)
+	}
+	return dir, nil
+}
+
+// publish moves the staged PDFs of the inputs, and their checksums with finalize, to
+// their outputs. The files they replace are kept in the staging directory until all
+// are moved, and put back if one can't be.
+func publish(inputs []batchInput, stagingDir string, finalize bool) error {
+	var files []stagedFile
+	for _, input := range inputs {
+		files = append(files, stagedFile{staged: input.staged, output: input.output})
+		if finalize {
+			files = append(files, stagedFile{staged: input.staged + ".sha256", output: input.output + ".sha256"})
+		}
+	}
+
+	var moved []stagedFile
+	rollback := func() {
+		for _, file := range slices.Backward(moved) {
+			os.Remove(file.output)
+			if file.previous != "" {
+				os.Rename(file.previous, file.output)
+			}
+		}
+	}
+	previousDir := filepath.Join(stagingDir, ".previous")
+	if err := os.Mkdir(previousDir, 0o755); err != nil {
+		return err
+	}
+	for i, file := range files {
+		previous := filepath.Join(previousDir, strconv.Itoa(i))
+		err := os.Rename(file.output, previous)
+		switch {
+		case err == nil:
+			file.previous = previous
+		case !errors.Is(err, fs.ErrNotExist):
+			rollback()
+			return fmt.Errorf("failed to publish %s: %w", file.output, err)
+		}
+		moved = append(moved, file)
+		err = os.MkdirAll(filepath.Dir(file.output), 0o755)
+		if err == nil {
+			err = os.Rename(file.staged, file.output)
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("failed to publish %s: %w", file.output, err)
+		}
+	}
+	return nil
+}
+
+// convertBatch converts the inputs with the given number of workers, logging a
+// line per document. With failFast, no conversion starts after one failed;
+// documents never started are left not done.
+func convertBatch(inputs []batchInput, opts report.Options, jobs int, failFast bool, log *logger) []batchResult {
+	results := make([]batchResult, len(inputs))
+	var stop atomic.Bool
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(jobs, len(inputs)) {
+		wg.Go(func() {
+			for i := range queue {
+				if stop.Load() {
+					continue
+				}
+				input := inputs[i]
+				fileOpts := opts
+				fileOpts.Warn = func(message string) {
+					log.warnf("%s: %s", input.path, message)
+				}
+				fileOpts.Progress = log.progress(input.path)
+
+				output := cmp.Or(input.staged, input.output)
+				err := os.MkdirAll(filepath.Dir(output), 0o755)
+				if err == nil {
+					err = report.ConvertFile(input.path, output, fileOpts)
+				}
+				if err == nil && opts.Finalize {
+					_, err = writeChecksum(output, io.Discard)
+				}
+				results[i] = batchResult{done: true, err: err}
+
+				if err != nil {
+					log.errorf("%s: %v", input.path, err)
+					if failFast {
+						stop.Store(true)
+					}
+				} else {
+					log.infof("%s: %s", input.path, input.output)
+				}
+			}
+		})
+	}
+	for i := range inputs {
+		if stop.Load() {
+			break
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+// expandPatterns lists the markdown files matching the patterns in order, each
+// once. The output of a file is its path below the directories of its pattern
+// without glob characters, with a .pdf extension.
+func expandPatterns(patterns []string) ([]batchInput, error) {
+	var inputs []batchInput
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		pattern = filepath.Clean(pattern)
+		base := patternBase(pattern)
+		matches, err := matchPattern(pattern, base)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, match := range matches {
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+			rel, err := filepath.Rel(base, match)
+			if err != nil {
+				rel = filepath.Base(match)
+			}
+			inputs = append(inputs, batchInput{path: match, output: strings.TrimSuffix(rel, filepath.Ext(rel)) + ".pdf"})
+		}
+	}
+	return inputs, nil
+}
+
+// patternBase returns the leading directories of a pattern without glob characters,
+// or the directory of a plain path
+func patternBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// matchPattern lists the files below base matching pattern, a plain path or a glob
+// where ** matches any number of directories
+func matchPattern(pattern, base string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory; use %s", pattern, filepath.Join(pattern, "**", "*.md"))
+		}
+		return []string{pattern}, nil
+	}
+	if _, err := path.Match(filepath.ToSlash(pattern), ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	var matches []string
+	err := filepath.WalkDir(base, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && matchSegments(segments, strings.Split(filepath.ToSlash(name), "/")) {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchSegments reports whether the path segments of name match those of a glob,
+// where a ** segment matches any number of segments
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := range len(name) + 1 {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous map[string]string // outputs before publishing
+		staged   map[string]string // staged PDFs; outputs without one fail to publish
+		finalize bool
+		wantErr  bool
+		want     map[string]string // outputs afterwards, "" for none
+	}{
+		{
+			name:   "new outputs",
+			staged: map[string]string{"a.pdf": "new a", "sub/b.pdf": "new b"},
+			want:   map[string]string{"a.pdf": "new a", "sub/b.pdf": "new b"},
+		},
+		{
+			name:     "replaces previous outputs",
+			previous: map[string]string{"a.pdf": "old a", "sub/b.pdf": "old b"},
+			staged:   map[string]string{"a.pdf": "new a", "sub/b.pdf": "new b"},
+			want:     map[string]string{"a.pdf": "new a", "sub/b.pdf": "new b"},
+		},
+		{
+			name:     "checksums",
+			staged:   map[string]string{"a.pdf": "new a", "a.pdf.sha256": "sum a"},
+			finalize: true,
+			want:     map[string]string{"a.pdf": "new a", "a.pdf.sha256": "sum a"},
+		},
+		{
+			name:     "rollback restores previous outputs",
+			previous: map[string]string{"a.pdf": "old a", "sub/b.pdf": "old b"},
+			staged:   map[string]string{"a.pdf": "new a"},
+			wantErr:  true,
+			want:     map[string]string{"a.pdf": "old a", "sub/b.pdf": "old b"},
+		},
+		{
+			name:     "rollback removes new outputs",
+			previous: map[string]string{"sub/b.pdf": "old b"},
+			staged:   map[string]string{"a.pdf": "new a"},
+			wantErr:  true,
+			want:     map[string]string{"a.pdf": "", "sub/b.pdf": "old b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			write := func(path, content string) {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, content := range tt.previous {
+				write(filepath.Join(outDir, name), content)
+			}
+			var inputs []batchInput
+			for _, name := range []string{"a.pdf", "sub/b.pdf"} {
+				if _, ok := tt.want[name]; ok {
+					inputs = append(inputs, batchInput{path: name, output: filepath.Join(outDir, name)})
+				}
+			}
+			stagingDir, err := stageOutputs(inputs, outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.staged {
+				write(filepath.Join(stagingDir, name), content)
+			}
+
+			err = publish(inputs, stagingDir, tt.finalize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(outDir, name))
+				switch {
+				case want == "" && err == nil:
+					t.Errorf("%s was left behind", name)
+				case want != "" && err != nil:
+					t.Errorf("%s: %v", name, err)
+				case string(got) != want && want != "":
+					t.Errorf("%s holds %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/**/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/sub/deeper/a.md", true},
+		{"docs/**/*.md", "other/a.md", false},
+		{"**/report.md", "a/b/report.md", true},
+		{"docs/?.md", "docs/ab.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.name, "/")); got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
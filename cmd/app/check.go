@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"report"
+)
+
+// runCheck validates documents without rendering them
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	options := optionFlags(fs)
+	format := fs.String("format", "pdf", "Output format whose unsupported constructs are reported: "+strings.Join(report.Formats(), ", "))
+	fs.Usage = func() {
+		fmt.Println("Usage: report check [flags] <input.md>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if !slices.Contains(report.Formats(), *format) {
+		fmt.Printf("Error: unknown output format %q (want %s)\n", *format, strings.Join(report.Formats(), ", "))
+		os.Exit(1)
+	}
+
+	opts := options(fs.Args()...)
+	failed := false
+	for _, inputPath := range fs.Args() {
+		md, err := os.ReadFile(inputPath)
+		var degraded []report.Degradation
+		if err == nil {
+			fileOpts := opts
+			fileOpts.BaseDir = filepath.Dir(inputPath)
+			degraded, err = report.Preflight(md, *format, fileOpts)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", inputPath, err)
+			failed = true
+			continue
+		}
+		if len(degraded) == 0 {
+			fmt.Printf("%s: OK\n", inputPath)
+			continue
+		}
+		// Degraded constructs don't fail the check, they are what the reader won't see
+		fmt.Printf("%s: OK, degraded in %s:\n", inputPath, *format)
+		for _, line := range strings.Split(strings.TrimSuffix(report.FormatDegradations(degraded), "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
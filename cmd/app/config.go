@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configNames are the config file names looked up in each directory, in order
+var configNames = []string{".reportrc", "report.yaml"}
+
+// configPaths are the options whose relative values are resolved against the
+// directory of the config file rather than the working directory
+var configPaths = map[string]bool{
+	"fonts-dir":       true,
+	"schema":          true,
+	"color-profile":   true,
+	"logo":            true,
+	"client-logo":     true,
+	"watermark-image": true,
+	"asset-root":      true,
+	"plantuml-jar":    true,
+	"diagram-cache":   true,
+	"hyphenation":     true,
+	"ca-bundle":       true,
+	"client-cert":     true,
+	"client-key":      true,
+}
+
+// configEntry is a `key: value` setting of a config file
+type configEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+// findConfig returns the first config file in dir or one of its parents, or "" if there is none
+func findConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err == nil && !info.IsDir() {
+				return path, nil
+			}
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads the settings of a config file. It holds one `option: value` line
+// per flag, without the dash, e.g. `toc: true`; values may be quoted, and repeatable
+// options take a list, either `[a, b]` or one `- item` line each.
+func loadConfig(path string) ([]configEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	defer file.Close()
+
+	var entries []configEntry
+	list := "" // key of the block list the following `- item` lines belong to
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok && list != "" {
+			entries = append(entries, configEntry{key: list, value: unquote(strings.TrimSpace(item)), line: n})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected `option: value`", path, n)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		list = ""
+		switch {
+		case value == "":
+			list = key
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for item := range strings.SplitSeq(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					entries = append(entries, configEntry{key: key, value: unquote(item), line: n})
+				}
+			}
+		default:
+			entries = append(entries, configEntry{key: key, value: unquote(value), line: n})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return entries, nil
+}
+
+// stripComment removes a `#` comment outside of quotes from line
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote removes matching single or double quotes around value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// applyConfig sets the options of the config file at path on flags, except those
+// given on the command line. Only the names in allowed can be configured.
+func applyConfig(flags *flag.FlagSet, path string, allowed map[string]bool) error {
+	entries, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	dir := filepath.Dir(path)
+	for _, e := range entries {
+		if !allowed[e.key] {
+			return fmt.Errorf("%s:%d: unknown option %q", path, e.line, e.key)
+		}
+		if explicit[e.key] {
+			continue
+		}
+		value := e.value
+		if configPaths[e.key] && value != "" && !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		if err := flags.Set(e.key, value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, e.line, e.key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"report"
+	"report/internal/pdf"
+)
+
+// corpusTimestamp is the fixed generation time of corpus documents, so their pages
+// don't change with the date in the footer or SOURCE_DATE_EPOCH
+var corpusTimestamp = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// corpusBaseline is the recorded rendering of a corpus, by document path relative
+// to the corpus directory
+type corpusBaseline struct {
+	Documents map[string]corpusResult `json:"documents"`
+}
+
+// corpusResult is the rendering of one corpus document
+type corpusResult struct {
+	Pages    int      `json:"pages"`
+	Hashes   []string `json:"hashes,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// runCorpus runs the regression corpus commands
+func runCorpus(args []string) {
+	if len(args) == 0 || args[0] != "run" {
+		fmt.Println("Usage: report corpus run [flags] <dir>")
+		os.Exit(1)
+	}
+	runCorpusRun(args[1:])
+}
+
+// runCorpusRun renders the documents of a corpus directory and compares them with the baseline
+func runCorpusRun(args []string) {
+	fs := flag.NewFlagSet("corpus run", flag.ExitOnError)
+	options := optionFlags(fs)
+	baselinePath := fs.String("baseline", "", "Baseline file (default: baseline.json in the corpus directory)")
+	update := fs.Bool("update", false, "Record the current rendering as the new baseline instead of comparing")
+	fs.Usage = func() {
+		fmt.Println("Usage: report corpus run [flags] <dir>")
+		fmt.Println("Renders every .md file in dir and compares page counts, page hashes and warnings")
+		fmt.Println("with the baseline, which is recorded on the first run or with -update.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	dir := fs.Arg(0)
+	if *baselinePath == "" {
+		*baselinePath = filepath.Join(dir, "baseline.json")
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil || len(inputs) == 0 {
+		fmt.Printf("Error: no .md files in %s\n", dir)
+		os.Exit(1)
+	}
+
+	opts := options(inputs[0])
+	opts.Timestamp = corpusTimestamp
+	opts.Reproducible = true
+	current := corpusBaseline{Documents: map[string]corpusResult{}}
+	for _, input := range inputs {
+		name, _ := filepath.Rel(dir, input)
+		current.Documents[name] = renderCorpusDocument(input, opts)
+		fmt.Printf("%s: %d pages\n", name, current.Documents[name].Pages)
+	}
+
+	baseline, err := loadBaseline(*baselinePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *update || baseline == nil {
+		if err := saveBaseline(*baselinePath, current); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Baseline written:", *baselinePath)
+		return
+	}
+
+	differences := diffCorpus(baseline, &current)
+	for _, d := range differences {
+		fmt.Println(d)
+	}
+	if len(differences) > 0 {
+		fmt.Printf("%d differences from %s\n", len(differences), *baselinePath)
+		os.Exit(1)
+	}
+	fmt.Println("No differences from", *baselinePath)
+}
+
+// renderCorpusDocument renders the document at path and records the result. Paths
+// in messages are made relative to the corpus, so baselines work in any checkout.
+func renderCorpusDocument(path string, opts report.Options) corpusResult {
+	var result corpusResult
+	dir, _ := filepath.Abs(filepath.Dir(path))
+	relative := strings.NewReplacer(dir+string(filepath.Separator), "")
+	opts.Warn = func(message string) {
+		result.Warnings = append(result.Warnings, relative.Replace(message))
+	}
+
+	var buf bytes.Buffer
+	if err := report.ConvertFilesTo(&buf, []string{path}, opts); err != nil {
+		result.Error = relative.Replace(err.Error())
+		return result
+	}
+	hashes, err := pdf.PageChecksums(buf.Bytes())
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Pages = len(hashes)
+	result.Hashes = hashes
+	return result
+}
+
+// diffCorpus describes how the current rendering differs from the baseline
+func diffCorpus(baseline, current *corpusBaseline) []string {
+	var differences []string
+	names := maps.Clone(baseline.Documents)
+	maps.Copy(names, current.Documents)
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		want, inBaseline := baseline.Documents[name]
+		got, inCorpus := current.Documents[name]
+		switch {
+		case !inBaseline:
+			differences = append(differences, fmt.Sprintf("%s: not in the baseline", name))
+			continue
+		case !inCorpus:
+			differences = append(differences, fmt.Sprintf("%s: missing from the corpus", name))
+			continue
+		}
+
+		if got.Error != want.Error {
+			differences = append(differences, fmt.Sprintf("%s: error %q, was %q", name, got.Error, want.Error))
+		}
+		if got.Pages != want.Pages {
+			differences = append(differences, fmt.Sprintf("%s: %d pages, was %d", name, got.Pages, want.Pages))
+		}
+		for i := range min(len(got.Hashes), len(want.Hashes)) {
+			if got.Hashes[i] != want.Hashes[i] {
+				differences = append(differences, fmt.Sprintf("%s: page %d changed", name, i+1))
+			}
+		}
+		for _, w := range got.Warnings {
+			if !slices.Contains(want.Warnings, w) {
+				differences = append(differences, fmt.Sprintf("%s: new warning: %s", name, w))
+			}
+		}
+		for _, w := range want.Warnings {
+			if !slices.Contains(got.Warnings, w) {
+				differences = append(differences, fmt.Sprintf("%s: warning gone: %s", name, w))
+			}
+		}
+	}
+	return differences
+}
+
+// loadBaseline reads a corpus baseline
+func loadBaseline(path string) (*corpusBaseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var baseline corpusBaseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return &baseline, nil
+}
+
+// saveBaseline writes a corpus baseline
+func saveBaseline(path string, baseline corpusBaseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
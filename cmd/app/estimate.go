@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"report"
+)
+
+// runEstimate reports the expected size of a document without writing the PDF
+func runEstimate(args []string) {
+	fs := flag.NewFlagSet("estimate", flag.ExitOnError)
+	options := optionFlags(fs)
+	manifest := fs.String("manifest", "", "File listing the input files, one per line")
+	fs.Usage = func() {
+		fmt.Println("Usage: report estimate [flags] <input.md>...")
+		fmt.Println("       report estimate [flags] -manifest <inputs.txt>")
+		fmt.Println("Remote images are not downloaded and count as their placeholder.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	inputPaths := fs.Args()
+	if *manifest != "" {
+		files, err := report.LoadManifest(*manifest)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		inputPaths = append(files, inputPaths...)
+	}
+	if len(inputPaths) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := options(inputPaths...)
+	opts.Warn = func(message string) {
+		fmt.Println("Warning:", message)
+	}
+	estimate, err := report.EstimateFiles(inputPaths, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Pages:   %d\nFigures: %d\nTables:  %d\n", estimate.Pages, estimate.Figures, estimate.Tables)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"report/internal/findings"
+)
+
+// runFindings generates markdown sections from a findings export
+func runFindings(args []string) {
+	fs := flag.NewFlagSet("findings", flag.ExitOnError)
+	templatePath := fs.String("template", "", "Go template for the findings markdown (default: built-in)")
+	fs.Usage = func() {
+		fmt.Println("Usage: report findings [flags] <findings.json> [output.md]")
+		fmt.Println("Writes to stdout if no output file is given.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	list, err := findings.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var tmpl string
+	if *templatePath != "" {
+		data, err := os.ReadFile(*templatePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read template: %v\n", err)
+			os.Exit(1)
+		}
+		tmpl = string(data)
+	}
+
+	md, err := findings.Render(list, tmpl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if fs.NArg() < 2 {
+		os.Stdout.Write(md)
+		return
+	}
+	if err := os.WriteFile(fs.Arg(1), md, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Findings written: %s (%d findings)\n", fs.Arg(1), len(list))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+
+	"report"
+)
+
+// logger prints the status messages of a command at the verbosity chosen with -v and
+// -q: errors always, warnings and results unless quiet, progress only when verbose.
+// It is safe for concurrent use.
+type logger struct {
+	out     io.Writer
+	verbose bool
+	quiet   bool
+	mu      sync.Mutex
+}
+
+// logFlags registers -v and -q on fs. The returned function builds the logger
+// writing to out after fs has been parsed, or fails if both flags are set.
+func logFlags(fs *flag.FlagSet) func(out io.Writer) (*logger, error) {
+	verbose := fs.Bool("v", false, "Verbose: also print the progress through the document")
+	quiet := fs.Bool("q", false, "Quiet: print errors only")
+	return func(out io.Writer) (*logger, error) {
+		if *verbose && *quiet {
+			return nil, errors.New("-v and -q can't be combined")
+		}
+		return &logger{out: out, verbose: *verbose, quiet: *quiet}, nil
+	}
+}
+
+// printf prints a line
+func (l *logger) printf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, format+"\n", args...)
+}
+
+// errorf prints an error, whatever the verbosity
+func (l *logger) errorf(format string, args ...any) {
+	l.printf("Error: "+format, args...)
+}
+
+// warnf prints a warning unless quiet
+func (l *logger) warnf(format string, args ...any) {
+	if !l.quiet {
+		l.printf("Warning: "+format, args...)
+	}
+}
+
+// infof prints a result or status message unless quiet
+func (l *logger) infof(format string, args ...any) {
+	if !l.quiet {
+		l.printf(format, args...)
+	}
+}
+
+// debugf prints a progress message if verbose
+func (l *logger) debugf(format string, args ...any) {
+	if l.verbose {
+		l.printf(format, args...)
+	}
+}
+
+// info returns the output for status messages written by other functions, which
+// discards them when quiet
+func (l *logger) info() io.Writer {
+	if l.quiet {
+		return io.Discard
+	}
+	return l.out
+}
+
+// progress returns a progress callback printing the pages and sections of a document
+// if verbose, prefixed with name unless it is empty, or nil otherwise
+func (l *logger) progress(name string) func(report.Progress) {
+	if !l.verbose {
+		return nil
+	}
+	prefix := ""
+	if name != "" {
+		prefix = name + ": "
+	}
+	return func(p report.Progress) {
+		if p.Section == "" {
+			l.debugf("%sPage %d", prefix, p.Pages)
+			return
+		}
+		l.debugf("%sPage %d: %s", prefix, p.Pages, p.Section)
+	}
+}
@@ -1,93 +1,286 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
-	"report/internal/markdown"
-	"report/internal/pdf"
-
-	"github.com/yuin/goldmark/ast"
+	"report"
+	"report/internal/tickets"
 )
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: report <input.md> <output.pdf>")
-		os.Exit(1)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "estimate":
+			runEstimate(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "findings":
+			runFindings(os.Args[2:])
+			return
+		case "ast":
+			runAST(os.Args[2:])
+			return
+		case "corpus":
+			runCorpus(os.Args[2:])
+			return
+		case "batch":
+			runBatch(os.Args[2:])
+			return
+		case "plan":
+			runPlan(os.Args[2:])
+			return
+		}
 	}
+	runConvert(os.Args[1:])
+}
 
-	inputPath := os.Args[1]
-	outputPath := os.Args[2]
+// runConvert converts a markdown file to PDF
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	options := optionFlags(fs)
+	logging := logFlags(fs)
+	manifest := fs.String("manifest", "", "File listing the input files, one per line")
+	errorPDF := fs.Bool("error-pdf", false, "On failure, write a one-page \"Rendering failed\" PDF with the error and a source excerpt to the output")
+	ticketConfig := fs.String("tickets", "", "JSON config for filing the findings as Jira or GitHub issues after rendering")
+	fs.Usage = func() {
+		fmt.Println("Usage: report [flags] <input.md>... <output.pdf>")
+		fmt.Println("       report [flags] -manifest <inputs.txt> <output.pdf>")
+		fmt.Println("       report check [flags] <input.md>...")
+		fmt.Println("       report estimate [flags] <input.md>...")
+		fmt.Println("       report serve [flags]")
+		fmt.Println("       report findings [flags] <findings.json> [output.md]")
+		fmt.Println("       report ast [flags] <input.md>")
+		fmt.Println("       report corpus run [flags] <dir>")
+		fmt.Println("       report batch [flags] <pattern>...")
+		fmt.Println("       report plan [flags] <input.md>...")
+		fmt.Println("Use - as input to read from stdin, or as output to write the PDF to stdout.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
 
-	// Read the Markdown
-	mdBytes, err := os.ReadFile(inputPath)
-	if err != nil {
-		fmt.Printf("Failed to read markdown file: %v\n", err)
+	var inputPaths []string
+	var outputPath string
+	switch {
+	case *manifest != "" && fs.NArg() == 1:
+		outputPath = fs.Arg(0)
+	case *manifest == "" && fs.NArg() >= 2:
+		inputPaths = fs.Args()[:fs.NArg()-1]
+		outputPath = fs.Arg(fs.NArg() - 1)
+	default:
+		fs.Usage()
 		os.Exit(1)
 	}
 
-	// Extract metadata variables from markdown
-	mdContent := string(mdBytes)
+	// Keep stdout clean for the PDF when streaming
+	status := os.Stdout
+	if outputPath == "-" {
+		status = os.Stderr
+	}
+	log, err := logging(status)
+	if err != nil {
+		fmt.Fprintf(status, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Normalize line endings to LF to ensure consistent parsing across platforms
-	mdContent = strings.ReplaceAll(mdContent, "\r\n", "\n")
+	// The config is found next to the manifest when the inputs are listed in one
+	configInputs := inputPaths
+	if *manifest != "" {
+		configInputs = []string{*manifest}
+	}
+	opts := options(configInputs...)
+	opts.Warn = func(message string) {
+		log.warnf("%s", message)
+	}
+	opts.Degraded = func(items []report.Degradation) {
+		log.infof("Degraded constructs:\n%s", strings.TrimSuffix(report.FormatDegradations(items), "\n"))
+	}
+	opts.TableProgress = func(source string, rows int) {
+		log.infof("Table %s: %d rows", source, rows)
+	}
+	opts.Progress = log.progress("")
 
-	// Extract __author__, __date__, __project__ from the content
-	var author, date, project string
+	// Findings are filed once the PDF they refer to is written
+	var ticketCfg *tickets.Config
+	var found []report.Finding
+	if *ticketConfig != "" {
+		if outputPath == "-" {
+			log.errorf("-tickets needs an output file")
+			os.Exit(1)
+		}
+		cfg, err := tickets.LoadConfig(*ticketConfig)
+		if err != nil {
+			log.errorf("%v", err)
+			os.Exit(1)
+		}
+		ticketCfg = cfg
+		opts.Findings = func(items []report.Finding) {
+			found = items
+		}
+	}
 
-	// Use regex to find the variables
-	authorRegex := regexp.MustCompile(`__author__\s*:\s*(.+)`)
-	dateRegex := regexp.MustCompile(`__date__\s*:\s*(.+)`)
-	projectRegex := regexp.MustCompile(`__project__\s*:\s*(.+)`)
+	if *manifest != "" {
+		files, err := report.LoadManifest(*manifest)
+		if err != nil {
+			log.errorf("%v", err)
+			os.Exit(1)
+		}
+		inputPaths = files
+	}
 
-	if matches := authorRegex.FindStringSubmatch(mdContent); len(matches) > 1 {
-		author = strings.TrimSpace(matches[1])
+	// Render markdown → PDF
+	start := time.Now()
+	if len(inputPaths) == 1 {
+		err = convert(inputPaths[0], outputPath, opts)
+	} else {
+		err = convertFiles(inputPaths, outputPath, opts)
 	}
-	if matches := dateRegex.FindStringSubmatch(mdContent); len(matches) > 1 {
-		date = strings.TrimSpace(matches[1])
+	if err != nil {
+		log.errorf("%v", err)
+		if *errorPDF {
+			writeErrorDocument(err, inputPaths, outputPath, status)
+		}
+		os.Exit(exitStatus(err))
 	}
-	if matches := projectRegex.FindStringSubmatch(mdContent); len(matches) > 1 {
-		project = strings.TrimSpace(matches[1])
+	log.debugf("Rendered in %s", time.Since(start).Round(10*time.Millisecond))
+
+	if outputPath != "-" {
+		log.infof("PDF generated: %s", filepath.Base(outputPath))
+		if opts.Finalize {
+			digest, err := writeChecksum(outputPath, log.info())
+			if err == nil && *manifest != "" {
+				err = report.RecordDigest(*manifest, outputPath, digest)
+			}
+			if err != nil {
+				log.errorf("%v", err)
+				os.Exit(1)
+			}
+		}
 	}
 
-	// Convert back to []byte for parsing (using normalized content)
-	mdBytes = []byte(mdContent)
+	if ticketCfg != nil && !fileTickets(ticketCfg, opts.HTTPClient, found, outputPath, log) {
+		os.Exit(1)
+	}
+}
+
+// convert renders inputPath to outputPath, where "-" stands for stdin and stdout
+func convert(inputPath, outputPath string, opts report.Options) error {
+	if inputPath != "-" && outputPath != "-" {
+		return report.ConvertFile(inputPath, outputPath, opts)
+	}
 
-	// Parse markdown AST
-	doc, err := markdown.ParseMarkdown(mdBytes)
+	var md []byte
+	var err error
+	if inputPath == "-" {
+		md, err = io.ReadAll(os.Stdin)
+	} else {
+		md, err = os.ReadFile(inputPath)
+		opts.BaseDir = filepath.Dir(inputPath)
+	}
 	if err != nil {
-		fmt.Printf("Markdown parsing error: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to read markdown file: %w", err)
 	}
 
-	// Type check: ensure doc is an AST document
-	_, ok := doc.(*ast.Document)
-	if !ok {
-		fmt.Println("Parsed markdown root node is not a Document")
-		os.Exit(1)
+	if outputPath == "-" {
+		return report.ConvertTo(os.Stdout, md, opts)
 	}
 
-	// Prepare PDF writer
-	w := pdf.NewWriter()
+	pdfBytes, err := report.Convert(md, opts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, pdfBytes, 0o644)
+}
 
-	// Set PDF metadata
-	w.SetMetadata(author, date, project)
+// writeErrorDocument writes the error document of a failed conversion to outputPath.
+// The excerpt comes from the input the error names, or the only input.
+func writeErrorDocument(convErr error, inputPaths []string, outputPath string, status io.Writer) {
+	var name string
+	for _, path := range inputPaths {
+		if len(inputPaths) == 1 || strings.Contains(convErr.Error(), path+":") {
+			name = path
+			break
+		}
+	}
+	// Stdin has been read by the conversion
+	var md []byte
+	if name == "-" {
+		name = ""
+	} else if name != "" {
+		md, _ = os.ReadFile(name)
+		name = filepath.Base(name)
+	}
 
-	// Render markdown → PDF
-	err = markdown.RenderToPDF(doc, w, mdBytes)
+	doc, err := report.ErrorDocument(convErr, name, md)
+	if err == nil {
+		if outputPath == "-" {
+			_, err = os.Stdout.Write(doc)
+		} else {
+			err = os.WriteFile(outputPath, doc, 0o644)
+		}
+	}
 	if err != nil {
-		fmt.Printf("PDF rendering error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(status, "Error: %v\n", err)
+		return
 	}
+	if outputPath != "-" {
+		fmt.Fprintln(status, "Error document generated:", filepath.Base(outputPath))
+	}
+}
 
-	// Save final PDF
-	if err := w.Save(outputPath); err != nil {
-		fmt.Printf("Failed to save PDF: %v\n", err)
-		os.Exit(1)
+// convertFiles renders several input files as one document, where "-" as output stands for stdout
+func convertFiles(inputPaths []string, outputPath string, opts report.Options) error {
+	for _, path := range inputPaths {
+		if path == "-" {
+			return fmt.Errorf("stdin can't be combined with other input files")
+		}
 	}
+	if outputPath == "-" {
+		return report.ConvertFilesTo(os.Stdout, inputPaths, opts)
+	}
+	return report.ConvertFiles(inputPaths, outputPath, opts)
+}
 
-	fmt.Println("PDF generated:", filepath.Base(outputPath))
+// writeChecksum records the SHA-256 of a finalized PDF in <output>.sha256, in the
+// format `sha256sum -c` verifies, and returns it
+func writeChecksum(outputPath string, status io.Writer) (string, error) {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PDF: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	line := fmt.Sprintf("%s  %s\n", digest, filepath.Base(outputPath))
+	if err := os.WriteFile(outputPath+".sha256", []byte(line), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write checksum: %w", err)
+	}
+	fmt.Fprintln(status, "SHA-256:", digest)
+	return digest, nil
+}
+
+// exitStatus returns the exit status for a failed conversion, telling the failure modes apart
+func exitStatus(err error) int {
+	switch {
+	case errors.Is(err, report.ErrFontLoad):
+		return 3
+	case errors.Is(err, report.ErrImageDecode):
+		return 4
+	case errors.Is(err, report.ErrRender):
+		return 5
+	default:
+		return 1
+	}
 }
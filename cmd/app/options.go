@@ -0,0 +1,369 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"report"
+)
+
+// optionFlags registers the conversion flags shared by all subcommands on fs.
+// The returned function builds the options after fs has been parsed, applying the
+// config file found from the directory of the first input upward, or of the working
+// directory without inputs. Flags given on the command line override the config.
+func optionFlags(fs *flag.FlagSet) func(inputPaths ...string) report.Options {
+	configPath := fs.String("config", "", "Config file with default flag values (default: .reportrc or report.yaml next to the first input or in a parent directory; none disables)")
+	typographer := fs.Bool("typographer", false, "Replace straight quotes, dashes and ellipses with typographic forms and keep numbers with their units")
+	fontsDir := fs.String("fonts-dir", "", "Directory with TTF/OTF font families named <Family>-<Style>.ttf")
+	bodyFont := fs.String("body-font", "", "Font family for body text (from -fonts-dir)")
+	headingFont := fs.String("heading-font", "", "Font family for headings (from -fonts-dir)")
+	codeFont := fs.String("code-font", "", "Font family for code (from -fonts-dir)")
+	emojiFont := fs.String("emoji-font", "", "Font family with monochrome emoji glyphs, e.g. Noto Emoji (from -fonts-dir)")
+	fallbackFont := fs.String("fallback-font", "", "Font families tried in order for characters the body, code and heading fonts lack, separated by commas, e.g. CJK (from -fonts-dir)")
+	schemaPath := fs.String("schema", "", "JSON schema describing required metadata variables")
+	shrinkLimit := fs.Float64("shrink-limit", 0.8, "Smallest scale for tables, code and images slightly too large for the page (1 disables)")
+	orphans := fs.Int("orphans", 2, "Minimum lines of a paragraph left at the bottom of a page (1 disables)")
+	widows := fs.Int("widows", 2, "Minimum lines of a paragraph carried over to the top of a page (1 disables)")
+	lineNumbers := fs.Bool("line-numbers", false, "Print line numbers next to code blocks")
+	decodeCodeEntities := fs.Bool("decode-code-entities", false, "Decode HTML entities such as &lt; and &amp; in code blocks, e.g. of content exported from HTML")
+	pageSize := fs.String("page-size", "A4", "Paper size: A4, A3, A5, Letter, Legal or <width>x<height> in mm")
+	orientation := fs.String("orientation", "portrait", "Page orientation: portrait or landscape")
+	columns := fs.Int("columns", 0, "Set the body text in 2 or 3 columns (default: __columns__ or 1)")
+	marginBottom := fs.Float64("margin-bottom", 20, "Distance in mm from the bottom edge where content breaks to a new page, at least the footer height")
+	marginLeft := fs.Float64("margin-left", 20, "Left page margin in mm")
+	marginRight := fs.Float64("margin-right", 20, "Right page margin in mm")
+	marginTop := fs.Float64("margin-top", 30, "Top page margin in mm, below the header")
+	marginTopNoHeader := fs.Float64("margin-top-no-header", 0, "Top margin in mm of pages without a header, e.g. with -no-logo (default: -margin-top)")
+	lineHeight := fs.Float64("line-height", 6, "Line height of body text in mm")
+	paragraphSpacing := fs.Float64("paragraph-spacing", 4, "Space after paragraphs in mm")
+	listItemSpacing := fs.Float64("list-item-spacing", 2, "Space after list items in mm")
+	direction := fs.String("direction", "", "Text direction: rtl for Arabic or Hebrew documents, or ltr (default: __direction__ of the document, else ltr)")
+	justify := fs.Bool("justify", false, "Set paragraphs flush with both margins, breaking words at soft hyphens")
+	hyphenation := fs.String("hyphenation", "", "TeX hyphenation pattern file, or directory of hyph-<lang>.pat.txt files picked by __lang__, for justified paragraphs; implies -justify")
+	footer := fs.String("footer", "", "Footer text replacing \"Report generated on: <date>\"; {date} stands for the date")
+	footerSysinfo := fs.Bool("footer-sysinfo", false, "Name the operating system and machine in the footer")
+	sysinfoSource := fs.String("footer-sysinfo-source", "host", "System information in the footer: host (operating system and machine), ci (CI pipeline and runner) or static; ci and static imply -footer-sysinfo")
+	sysinfoText := fs.String("footer-sysinfo-text", "", "System information in the footer with -footer-sysinfo-source static, e.g. \"Build server 3\"")
+	headingTracking := fs.Float64("heading-tracking", 0, "Space added between heading characters in thousandths of an em, e.g. 50")
+	headingSmallCaps := fs.Bool("heading-small-caps", false, "Set the lowercase letters of headings as smaller capitals")
+	grayscale := fs.Bool("grayscale", false, "Convert all colors and images to gray for cheap printing")
+	colorProfile := fs.String("color-profile", "", "JSON file mapping theme colors (link, info, critical, ...) to hex colors")
+	codeWrapMarker := fs.Bool("code-wrap-marker", false, "Mark the continuation of wrapped code lines with an arrow")
+	logo := fs.String("logo", "", "Image replacing our logo in the page header")
+	logoWidth := fs.Float64("logo-width", 0, "Width of the logo in mm (default 40)")
+	noLogo := fs.Bool("no-logo", false, "Leave our logo out of the page header")
+	logoPosition := fs.String("logo-position", "", "Position of our logo in the page header: right (default), left or center")
+	watermark := fs.String("watermark", "", "Text stamped diagonally across every page, e.g. DRAFT or CONFIDENTIAL")
+	watermarkImage := fs.String("watermark-image", "", "Image stamped in the middle of every page")
+	watermarkOpacity := fs.Float64("watermark-opacity", 0, "Opacity of the watermark from 0 to 1 (default 0.15)")
+	clientLogo := fs.String("client-logo", "", "Client logo shown top-left in the page header")
+	clientLogoWidth := fs.Float64("client-logo-width", 0, "Width of the client logo in mm (default 40)")
+	runningHead := fs.Bool("running-head", false, "Show the title of the current H1/H2 section in the page header, opposite the logo")
+	coverPage := fs.String("cover-page", "same", "Header and footer of the first page: same, no-header, no-footer or plain (neither)")
+	chapterPage := fs.String("chapter-page", "same", "Header and footer of the first page of each input file after the first: same, no-header, no-footer or plain")
+	chapterHeader := fs.String("chapter-header", "", "Text in the header of the first page of each chapter, in place of the running head ({date} is replaced)")
+	chapterFooter := fs.String("chapter-footer", "", "Footer text of the first page of each chapter ({date} is replaced)")
+	qrCode := fs.String("qr-code", "none", "QR code linking to the __url__ of the document: cover (first page), footer (every page) or none")
+	pageInfo := fs.Bool("page-info", false, "Stamp every page with invisible metadata: page number, content checksum and __document_id__")
+	accessible := fs.Bool("accessible", false, "Write a tagged PDF for screen readers, with headings, lists, tables and image alt text in reading order")
+	var pageProperties map[string]string
+	fs.Func("page-property", "Custom name=value property stamped invisibly on every page, implies -page-info (repeatable)", func(property string) error {
+		name, value, ok := strings.Cut(property, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected name=value, got %q", property)
+		}
+		if pageProperties == nil {
+			pageProperties = map[string]string{}
+		}
+		pageProperties[name] = value
+		return nil
+	})
+	expandVars := fs.Bool("expand-vars", false, "Expand {{name}} placeholders in the documents from -var, metadata variables and -template-env, with Go template syntax")
+	var vars map[string]string
+	fs.Func("var", "Template variable name=value for {{name}} placeholders, implies -expand-vars (repeatable)", func(v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected name=value, got %q", v)
+		}
+		if vars == nil {
+			vars = map[string]string{}
+		}
+		vars[name] = value
+		return nil
+	})
+	dataPath := fs.String("data", "", "JSON, CSV or TSV file the documents are executed against as Go templates, implies -expand-vars")
+	var templateEnv []string
+	fs.Func("template-env", "Environment variable templates may read, as {{NAME}} with -expand-vars (repeatable)", func(name string) error {
+		templateEnv = append(templateEnv, name)
+		return nil
+	})
+	fileBreak := fs.String("file-break", "page", "Break between input files: page, odd (next right-hand page) or none")
+	var headingBreaks map[int]string
+	fs.Func("heading-break", "Page break before the headings of a level as level=policy, e.g. 1=page or 2=odd (next right-hand page); auto keeps them with the next block (repeatable)", func(rule string) error {
+		level, policy, ok := strings.Cut(rule, "=")
+		n, err := strconv.Atoi(level)
+		if !ok || err != nil {
+			return fmt.Errorf("expected level=policy, got %q", rule)
+		}
+		if headingBreaks == nil {
+			headingBreaks = map[int]string{}
+		}
+		headingBreaks[n] = policy
+		return nil
+	})
+	finalize := fs.Bool("finalize", false, "Produce the deliverable: drop draft aids and a DRAFT watermark, lock the PDF against changes and record its SHA-256, in the -manifest too")
+	protect := fs.Bool("protect", false, "Encrypt the PDF so it can't be modified without the owner password")
+	userPassword := fs.String("user-password", "", "Password needed to open the PDF, or a secret reference like env:NAME, file:PATH or exec:COMMAND; implies -protect")
+	ownerPassword := fs.String("owner-password", "", "Password unlocking a protected PDF for editing, or a secret reference; implies -protect (default: random)")
+	noPrint := fs.Bool("no-print", false, "Deny printing the PDF, implies -protect")
+	noCopy := fs.Bool("no-copy", false, "Deny copying text from the PDF, implies -protect")
+	sign := fs.String("sign", "", "Digitally sign the PDF with the key and certificate of a PKCS #12 file (.p12, .pfx)")
+	signPassword := fs.String("sign-pass", "", "Password of the -sign file, or a secret reference")
+	signPage := fs.Int("sign-page", 0, "Page of the visible signature box (default: after the content on the last page)")
+	colophon := fs.Bool("colophon", false, "Append a page listing the embedded fonts, images and software of the PDF with their licenses")
+	var attach []string
+	fs.Func("attach", "File embedded in the PDF as an attachment, e.g. the source or raw data (repeatable)", func(path string) error {
+		attach = append(attach, path)
+		return nil
+	})
+	var prepend, appendPDFs []string
+	fs.Func("prepend", "Existing PDF whose pages are merged before the document, e.g. a cover (repeatable)", func(path string) error {
+		prepend = append(prepend, path)
+		return nil
+	})
+	fs.Func("append", "Existing PDF whose pages are merged after the document, e.g. signed forms or datasheets (repeatable)", func(path string) error {
+		appendPDFs = append(appendPDFs, path)
+		return nil
+	})
+	attributionsPath := fs.String("attributions", "", "JSON file mapping image paths or file names to their attribution in the colophon")
+	draft := fs.Bool("draft", false, "Add review aids to the PDF: CriticMarkup comments and changes, and the degradation report")
+	toc := fs.Bool("toc", false, "Insert a table of contents at the start (or at a [TOC] paragraph)")
+	tocDepth := fs.Int("toc-depth", 3, "Deepest heading level listed in the table of contents")
+	tocTitle := fs.String("toc-title", "Contents", "Title of the table of contents")
+	lof := fs.Bool("lof", false, "Insert a list of figures after the table of contents (or at a [LOF] paragraph)")
+	lot := fs.Bool("lot", false, "Insert a list of tables after the table of contents (or at a [LOT] paragraph)")
+	shiftHeadings := fs.Int("shift-headings", 0, "Move all headings down by n levels (up if negative), e.g. 1 to make H1 sections H2")
+	numberHeadings := fs.Bool("number-headings", false, "Number headings (1, 1.1, ...); {-} skips a heading, {.appendix} or <!-- appendix --> starts lettered appendices")
+	numberHeadingsFrom := fs.Int("number-headings-from", 0, "Heading level numbering starts at (default: below a document title, else 1)")
+	numberSeparator := fs.String("number-separator", ".", "Separator between the levels of section numbers")
+	numberFigures := fs.Bool("number-figures", false, "Number images and diagrams (Figure 1: <alt text>) and tables (Table 1: <caption>) in their captions")
+	unsupportedHTML := fs.String("unsupported-html", "ignore", "Handling of raw HTML tags outside the supported subset: ignore or warn")
+	var assetRoots []string
+	fs.Func("asset-root", "Directory tree documents may include files and images from, instead of the input file directories (repeatable)", func(dir string) error {
+		assetRoots = append(assetRoots, dir)
+		return nil
+	})
+	reproducible := fs.Bool("reproducible", false, "Produce byte-identical output for the same input: date from SOURCE_DATE_EPOCH, no machine in the footer")
+	strict := fs.Bool("strict", false, "Fail if an image can't be loaded instead of showing a placeholder")
+	offline := fs.Bool("offline", false, "Don't download remote images, show a placeholder with the URL instead")
+	fetchConcurrency := fs.Int("fetch-concurrency", 4, "Maximum number of remote images downloaded in parallel")
+	fetchRetries := fs.Int("fetch-retries", 2, "Retries of a failed remote image download, with exponential backoff")
+	proxy := fs.String("proxy", "", "Proxy URL for all outbound requests (default: HTTPS_PROXY/HTTP_PROXY/NO_PROXY, \"direct\" ignores them)")
+	caBundle := fs.String("ca-bundle", "", "PEM file with CA certificates trusted in addition to the system's")
+	clientCert := fs.String("client-cert", "", "PEM certificate presented to servers requiring mutual TLS (with -client-key)")
+	clientKey := fs.String("client-key", "", "PEM key of -client-cert")
+	tlsMinVersion := fs.String("tls-min-version", "1.2", "Oldest TLS version accepted for outbound requests: 1.2 or 1.3")
+	httpTimeout := fs.Duration("http-timeout", 0, "Time limit of each outbound request, e.g. 30s (0: none; ticket systems default to 30s)")
+	mermaid := fs.String("mermaid", "mmdc", "Renderer of mermaid diagrams: mmdc, kroki or none to show their source")
+	krokiURL := fs.String("kroki-url", "https://kroki.io", "Kroki server used by -mermaid kroki")
+	plantUML := fs.String("plantuml", "jar", "Renderer of plantuml diagrams: jar, server, kroki or none to show their source")
+	plantUMLJar := fs.String("plantuml-jar", "plantuml.jar", "Path of plantuml.jar used by -plantuml jar")
+	plantUMLServer := fs.String("plantuml-server", "http://localhost:8080", "PlantUML server used by -plantuml server")
+	diagramCache := fs.String("diagram-cache", defaultDiagramCache(), "Directory caching rendered diagrams by source hash (empty disables)")
+	math := fs.String("math", "latex", "Renderer of $...$ and $$...$$ formulas: latex, unicode for simple formulas without TeX, or none to show their source")
+
+	// Only the conversion options can be configured, not those of a single subcommand
+	configurable := map[string]bool{}
+	fs.VisitAll(func(f *flag.Flag) {
+		configurable[f.Name] = f.Name != "config"
+	})
+
+	return func(inputPaths ...string) report.Options {
+		if err := configure(fs, *configPath, inputPaths, configurable); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := report.Options{
+			Typographer:        *typographer,
+			FontsDir:           *fontsDir,
+			BodyFont:           *bodyFont,
+			HeadingFont:        *headingFont,
+			CodeFont:           *codeFont,
+			FallbackFont:       *fallbackFont,
+			EmojiFont:          *emojiFont,
+			ShrinkLimit:        *shrinkLimit,
+			Orphans:            *orphans,
+			Widows:             *widows,
+			LineNumbers:        *lineNumbers,
+			DecodeCodeEntities: *decodeCodeEntities,
+			CodeWrapMarker:     *codeWrapMarker,
+			PageSize:           *pageSize,
+			Orientation:        *orientation,
+			Columns:            *columns,
+			MarginBottom:       *marginBottom,
+			MarginLeft:         *marginLeft,
+			MarginRight:        *marginRight,
+			MarginTop:          *marginTop,
+			MarginTopNoHeader:  *marginTopNoHeader,
+			LineHeight:         *lineHeight,
+			ParagraphSpacing:   *paragraphSpacing,
+			ListItemSpacing:    *listItemSpacing,
+			Justify:            *justify,
+			Direction:          *direction,
+			Hyphenation:        *hyphenation,
+			Footer:             *footer,
+			FooterSystemInfo:   *footerSysinfo,
+			SystemInfo:         *sysinfoSource,
+			SystemInfoText:     *sysinfoText,
+			HeadingTracking:    *headingTracking,
+			HeadingSmallCaps:   *headingSmallCaps,
+			Grayscale:          *grayscale,
+			Logo:               *logo,
+			LogoWidth:          *logoWidth,
+			NoLogo:             *noLogo,
+			LogoPosition:       *logoPosition,
+			Watermark:          *watermark,
+			WatermarkImage:     *watermarkImage,
+			WatermarkOpacity:   *watermarkOpacity,
+			ClientLogo:         *clientLogo,
+			ClientLogoWidth:    *clientLogoWidth,
+			RunningHead:        *runningHead,
+			CoverPage:          *coverPage,
+			ChapterPage:        *chapterPage,
+			ChapterHeader:      *chapterHeader,
+			ChapterFooter:      *chapterFooter,
+			QRCode:             *qrCode,
+			PageInfo:           *pageInfo,
+			PageProperties:     pageProperties,
+			Accessible:         *accessible,
+			FileBreak:          *fileBreak,
+			HeadingBreaks:      headingBreaks,
+			Draft:              *draft,
+			Colophon:           *colophon,
+			Finalize:           *finalize,
+			Protect:            *protect,
+			UserPassword:       *userPassword,
+			OwnerPassword:      *ownerPassword,
+			NoPrint:            *noPrint,
+			NoCopy:             *noCopy,
+			Sign:               *sign,
+			SignPassword:       *signPassword,
+			SignPage:           *signPage,
+			TOC:                *toc,
+			TOCDepth:           *tocDepth,
+			TOCTitle:           *tocTitle,
+			ListOfFigures:      *lof,
+			ListOfTables:       *lot,
+			NumberHeadings:     *numberHeadings,
+			NumberHeadingsFrom: *numberHeadingsFrom,
+			NumberSeparator:    *numberSeparator,
+			ShiftHeadings:      *shiftHeadings,
+			NumberFigures:      *numberFigures,
+			UnsupportedHTML:    *unsupportedHTML,
+			AssetRoots:         assetRoots,
+			Attach:             attach,
+			Prepend:            prepend,
+			Append:             appendPDFs,
+			ExpandVars:         *expandVars,
+			Vars:               vars,
+			TemplateEnv:        templateEnv,
+			Offline:            *offline,
+			Strict:             *strict,
+			Reproducible:       *reproducible,
+			FetchConcurrency:   *fetchConcurrency,
+			FetchRetries:       *fetchRetries,
+			Mermaid:            *mermaid,
+			KrokiURL:           *krokiURL,
+			PlantUML:           *plantUML,
+			PlantUMLJar:        *plantUMLJar,
+			PlantUMLServer:     *plantUMLServer,
+			DiagramCacheDir:    *diagramCache,
+			Math:               *math,
+		}
+
+		if *schemaPath != "" {
+			schema, err := report.LoadSchema(*schemaPath)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Schema = schema
+		}
+		client, err := report.NewHTTPClient(report.HTTPConfig{
+			Proxy:         *proxy,
+			CABundle:      *caBundle,
+			ClientCert:    *clientCert,
+			ClientKey:     *clientKey,
+			MinTLSVersion: *tlsMinVersion,
+			Timeout:       *httpTimeout,
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.HTTPClient = client
+
+		if *attributionsPath != "" {
+			attributions, err := report.LoadAttributions(*attributionsPath)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Attributions = attributions
+		}
+
+		if *dataPath != "" {
+			data, err := report.LoadData(*dataPath)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Data = data
+		}
+
+		if *colorProfile != "" {
+			colors, err := report.LoadColorProfile(*colorProfile)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Colors = colors
+		}
+
+		return opts
+	}
+}
+
+// configure applies the config file at path, or the one discovered for inputPaths
+// if path is empty, to fs
+func configure(fs *flag.FlagSet, path string, inputPaths []string, configurable map[string]bool) error {
+	if path == "none" {
+		return nil
+	}
+	if path == "" {
+		dir := "."
+		if len(inputPaths) > 0 && inputPaths[0] != "-" {
+			dir = filepath.Dir(inputPaths[0])
+		}
+		found, err := findConfig(dir)
+		if err != nil || found == "" {
+			return err
+		}
+		path = found
+	}
+	return applyConfig(fs, path, configurable)
+}
+
+// defaultDiagramCache returns the diagram cache in the user's cache directory, or "" without one
+func defaultDiagramCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "report", "diagrams")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"report"
+)
+
+// runPlan prints the layout of a rendered document as JSON
+func runPlan(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	options := optionFlags(fs)
+	manifest := fs.String("manifest", "", "File listing the input files, one per line")
+	fs.Usage = func() {
+		fmt.Println("Usage: report plan [flags] <input.md>...")
+		fmt.Println("       report plan [flags] -manifest <inputs.txt>")
+		fmt.Println("Prints the blocks of each page of the rendered document with their type and position in mm.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	inputPaths := fs.Args()
+	if *manifest != "" {
+		files, err := report.LoadManifest(*manifest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inputPaths = append(files, inputPaths...)
+	}
+	if len(inputPaths) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Keep stdout clean for the plan
+	opts := options(inputPaths...)
+	opts.Warn = func(message string) {
+		fmt.Fprintln(os.Stderr, "Warning:", message)
+	}
+	plan, err := report.PlanFiles(inputPaths, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitStatus(err))
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(plan); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"report/internal/server"
+)
+
+// runServe starts the HTTP conversion server
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	options := optionFlags(fs)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	maxConcurrent := fs.Int("max-concurrent", 4, "Maximum number of conversions running at the same time")
+	timeout := fs.Duration("timeout", 30*time.Second, "Per-request timeout")
+	maxBody := fs.Int64("max-body", 32<<20, "Maximum request size in bytes")
+	tenantsPath := fs.String("tenants", "", "JSON file mapping tenants to their API keys, logo, fonts, colors and footer; requests then need an API key")
+	errorPDF := fs.Bool("error-pdf", false, "Answer failed conversions with a one-page \"Rendering failed\" PDF (status 200, X-Render-Error header)")
+	var imageHosts []string
+	fs.Func("allow-image-host", "Host remote images in documents may be downloaded from; none by default (repeatable)", func(host string) error {
+		imageHosts = append(imageHosts, host)
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Println("Usage: report serve [flags]")
+		fmt.Println("POST markdown (or a multipart form with a \"markdown\" file and images) to /convert to get a PDF.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts := options()
+	var tenants *server.Tenants
+	if *tenantsPath != "" {
+		var err error
+		tenants, err = server.LoadTenants(*tenantsPath, opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	srv := server.New(server.Config{
+		Options:        opts,
+		Tenants:        tenants,
+		MaxConcurrent:  *maxConcurrent,
+		Timeout:        *timeout,
+		MaxBodySize:    *maxBody,
+		ErrorDocuments: *errorPDF,
+		ImageHosts:     imageHosts,
+	})
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Println("Listening on", *addr)
+	if err := httpServer.ListenAndServe(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
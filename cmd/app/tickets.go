@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"report"
+	"report/internal/tickets"
+)
+
+// fileTickets files the findings of the report at pdfPath as tickets and reports
+// the outcome, returning false if any finding couldn't be filed
+func fileTickets(cfg *tickets.Config, httpClient *http.Client, found []report.Finding, pdfPath string, log *logger) bool {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		log.errorf("%v", err)
+		return false
+	}
+
+	list := make([]tickets.Finding, len(found))
+	for i, f := range found {
+		list[i] = tickets.Finding{
+			ID:        f.ID,
+			Severity:  f.Severity,
+			Title:     f.Title,
+			Markdown:  f.Markdown,
+			FirstPage: f.FirstPage,
+			LastPage:  f.LastPage,
+		}
+	}
+
+	ok := true
+	for _, r := range tickets.Export(context.Background(), cfg, httpClient, list, data, pdfPath) {
+		switch {
+		case r.Err != nil:
+			log.errorf("ticket for %s: %v", r.Finding.ID, r.Err)
+			ok = false
+		case r.Created:
+			log.infof("Ticket created: %s %s (%s)", r.Key, r.Finding.Title, r.URL)
+		default:
+			log.infof("Ticket updated: %s %s (%s)", r.Key, r.Finding.Title, r.URL)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,104 @@
+package report
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+
+	"report/internal/markdown"
+	"report/internal/pdf"
+)
+
+// modulePath is the module path of the converter in the build information
+const modulePath = "report"
+
+// components are the libraries built into the converter, with their licenses
+var components = []struct {
+	path    string
+	license string
+}{
+	{"github.com/jung-kurt/gofpdf", "MIT"},
+	{"github.com/yuin/goldmark", "MIT"},
+	{"github.com/alecthomas/chroma/v2", "MIT"},
+	{"github.com/dlclark/regexp2", "MIT"},
+}
+
+// LoadAttributions reads the credits of a document's assets for the colophon from a
+// JSON object mapping paths, URLs or file names to their attribution:
+//
+//	{"img/network.png": "Diagram by Jane Doe, CC BY 4.0", "client.svg": "© ACME Corp."}
+func LoadAttributions(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attributions: %w", err)
+	}
+	var attributions map[string]string
+	if err := json.Unmarshal(data, &attributions); err != nil {
+		return nil, fmt.Errorf("invalid attributions file %s: %w", path, err)
+	}
+	return attributions, nil
+}
+
+// colophon lists the fonts and images of the document written so far and the software
+// producing it
+func colophon(w *pdf.Writer, theme pdf.Theme, meta markdown.Metadata, opts Options) pdf.Colophon {
+	c := pdf.Colophon{Fonts: w.FontCredits()}
+
+	credit := func(path, use string) {
+		attribution, ok := opts.Attributions[path]
+		if !ok {
+			attribution = opts.Attributions[filepath.Base(path)]
+		}
+		c.Assets = append(c.Assets, pdf.Credit{Name: path, Detail: use, License: attribution})
+	}
+	// Header images read from the built-in logo are ours and not listed
+	headerImages := []struct {
+		loaded          bool
+		option, setting string
+		use             string
+	}{
+		{!theme.NoLogo && theme.Logo.Data != nil, opts.Logo, meta["logo"], "logo"},
+		{theme.ClientLogo.Data != nil, opts.ClientLogo, meta["client_logo"], "client logo"},
+		{theme.Watermark.Image != nil, opts.WatermarkImage, meta["watermark_image"], "watermark"},
+	}
+	for _, image := range headerImages {
+		if image.loaded {
+			credit(cmp.Or(image.option, image.setting), image.use)
+		}
+	}
+	for _, path := range w.Images() {
+		credit(path, "image")
+	}
+
+	c.Software = softwareCredits()
+	return c
+}
+
+// softwareCredits returns the converter, the libraries built into it and the Go
+// runtime with their versions, as far as the build information tells them
+func softwareCredits() []pdf.Credit {
+	version := "unknown"
+	versions := map[string]string{}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		modules := append([]*debug.Module{&info.Main}, info.Deps...)
+		for _, m := range modules {
+			versions[m.Path] = m.Version
+			if m.Replace != nil {
+				versions[m.Path] = m.Replace.Version
+			}
+		}
+		if v := versions[modulePath]; v != "" {
+			version = v
+		}
+	}
+
+	credits := []pdf.Credit{{Name: creator, Detail: version}}
+	for _, component := range components {
+		credits = append(credits, pdf.Credit{Name: component.path, Detail: cmp.Or(versions[component.path], "unknown"), License: component.license})
+	}
+	return append(credits, pdf.Credit{Name: "Go", Detail: runtime.Version(), License: "BSD-3-Clause"})
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"report/internal/pdf"
+)
+
+// degradationGroup collects the locations of one kind of degraded construct
+type degradationGroup struct {
+	construct string
+	effect    string
+	locations []string
+}
+
+// groupDegradations groups degraded constructs by construct and effect, in order of appearance
+func groupDegradations(items []Degradation) []*degradationGroup {
+	var groups []*degradationGroup
+	index := map[string]*degradationGroup{}
+	for _, d := range items {
+		key := d.Construct + "\x00" + d.Effect
+		g, ok := index[key]
+		if !ok {
+			g = &degradationGroup{construct: d.Construct, effect: d.Effect}
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.locations = append(g.locations, degradationLocation(d))
+	}
+	return groups
+}
+
+// degradationLocation formats the location of a degraded construct as file:line
+func degradationLocation(d Degradation) string {
+	switch {
+	case d.File != "" && d.Line > 0:
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	case d.File != "":
+		return d.File
+	case d.Line > 0:
+		return fmt.Sprintf("line %d", d.Line)
+	default:
+		return "unknown location"
+	}
+}
+
+// FormatDegradations summarizes degraded constructs with counts and locations, one kind per line
+func FormatDegradations(items []Degradation) string {
+	var b strings.Builder
+	for _, g := range groupDegradations(items) {
+		fmt.Fprintf(&b, "%s (%s) x%d: %s\n", g.construct, g.effect, len(g.locations), strings.Join(g.locations, ", "))
+	}
+	return b.String()
+}
+
+// writeDegradationAppendix appends the degradation report to a draft PDF
+func writeDegradationAppendix(w *pdf.Writer, items []Degradation) {
+	w.WritePageBreak(false)
+	w.WriteHeading(pdf.Heading{Level: 1, Text: "Degradation Report", ID: "degradation-report"})
+	w.WriteParagraph([]pdf.Span{{Text: "These constructs could not be fully reproduced in the PDF."}})
+
+	table := pdf.Table{
+		Header: []string{"Construct", "Effect", "Count", "Locations"},
+		Align:  []string{"L", "L", "R", "L"},
+	}
+	for _, g := range groupDegradations(items) {
+		table.Rows = append(table.Rows, []string{
+			g.construct, g.effect, w.FormatNumber(len(g.locations)), strings.Join(g.locations, ", "),
+		})
+	}
+	w.WriteTable(table)
+}
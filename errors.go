@@ -0,0 +1,18 @@
+package report
+
+import (
+	"errors"
+
+	"report/internal/pdf"
+)
+
+// Conversion errors wrap one of these, so callers can tell failure modes apart with errors.Is
+var (
+	// ErrFontLoad means a font, embedded or of the font mapping, couldn't be loaded
+	ErrFontLoad = pdf.ErrFontLoad
+	// ErrImageDecode means image data couldn't be decoded; with Strict, it is wrapped
+	// by the error for each broken image
+	ErrImageDecode = pdf.ErrImageDecode
+	// ErrRender means the document couldn't be laid out
+	ErrRender = errors.New("rendering failed")
+)
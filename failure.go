@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"report/internal/markdown"
+	"report/internal/pdf"
+)
+
+// errorLineRegex finds the source line an error names, like "line 12:" or "notes.md:12:"
+var errorLineRegex = regexp.MustCompile(`(?:\bline |:)(\d+):`)
+
+// The error document stays on one page: long errors are cut, and the excerpt shows
+// a few lines around the failing one, or the start of the source
+const (
+	maxErrorLines  = 20
+	excerptContext = 4
+	excerptLines   = 10
+)
+
+// ErrorDocument renders a one-page "Rendering failed" PDF for a conversion that failed
+// with err, showing the error and an excerpt of the source around the line it names.
+// Name identifies the document and may be empty, as may md. Delivered in place of the
+// report, it keeps automated distribution from silently skipping a failed document.
+func ErrorDocument(err error, name string, md []byte) ([]byte, error) {
+	w, werr := pdf.NewWriter(pdf.DefaultTheme())
+	if werr != nil {
+		return nil, werr
+	}
+	w.SetDocumentInfo(pdf.DocumentInfo{Title: name, Creator: creator})
+
+	w.WriteHeading(pdf.Heading{Level: 1, Text: "Rendering failed"})
+	intro := "The document could not be rendered:"
+	if name != "" {
+		intro = fmt.Sprintf("The document %s could not be rendered:", name)
+	}
+	w.WriteParagraph([]pdf.Span{{Text: intro}})
+
+	message := strings.Split(err.Error(), "\n")
+	if len(message) > maxErrorLines {
+		message = append(message[:maxErrorLines], fmt.Sprintf("... %d more lines", len(message)-maxErrorLines))
+	}
+	w.WriteHighlightedCode(strings.Join(message, "\n")+"\n", "", pdf.CodeOptions{})
+
+	if excerpt, first := sourceExcerpt(err, md); excerpt != "" {
+		w.WriteHeading(pdf.Heading{Level: 2, Text: "Source excerpt"})
+		w.WriteHighlightedCode(excerpt, "markdown", pdf.CodeOptions{LineNumbers: true, FirstLine: first})
+	}
+
+	var buf bytes.Buffer
+	if err := w.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write error document: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sourceExcerpt returns the lines of the source around the line an error names,
+// or its first lines, with the number of the first line shown
+func sourceExcerpt(err error, md []byte) (string, int) {
+	if len(bytes.TrimSpace(md)) == 0 {
+		return "", 0
+	}
+	src := strings.ReplaceAll(string(md), "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(src, "\n"), "\n")
+
+	line := 0
+	var fieldErr *markdown.FieldError
+	if errors.As(err, &fieldErr) {
+		line = fieldErr.Line
+	} else if m := errorLineRegex.FindStringSubmatch(err.Error()); m != nil {
+		line, _ = strconv.Atoi(m[1])
+	}
+
+	first, last := 1, min(len(lines), excerptLines)
+	if line > 0 && line <= len(lines) {
+		first, last = max(1, line-excerptContext), min(len(lines), line+excerptContext)
+	}
+	return strings.Join(lines[first-1:last], "\n") + "\n", first
+}
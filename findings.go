@@ -0,0 +1,57 @@
+package report
+
+import (
+	"report/internal/markdown"
+	"report/internal/pdf"
+)
+
+// Finding is a section of the rendered document under a heading with a severity,
+// like `### [High] SQL injection`, see Options.Findings
+type Finding struct {
+	// ID is the heading ID, the anchor of the section
+	ID       string
+	Severity string
+	Title    string
+	// Markdown is the source of the section below its heading
+	Markdown string
+	// FirstPage and LastPage are the 1-based pages the section spans in the PDF
+	FirstPage, LastPage int
+}
+
+// findingPages locates the finding sections of the documents in the rendered PDF
+func findingPages(docs []*document, w *pdf.Writer, theme pdf.Theme) []Finding {
+	parts := make([]markdown.Part, len(docs))
+	for i, doc := range docs {
+		parts[i] = markdown.Part{Root: doc.root, Src: doc.src, BaseDir: doc.baseDir}
+	}
+
+	anchors := w.Anchors()
+	var found []Finding
+	for _, f := range markdown.Findings(parts) {
+		start, ok := anchors[f.ID]
+		if !ok {
+			continue
+		}
+		last := w.PageCount()
+		if f.EndID == "" {
+			// Sections ending the document end before the degradation report of drafts
+			f.EndID = "degradation-report"
+		}
+		if end, ok := anchors[f.EndID]; ok {
+			last = end.Page
+			// A section followed by a heading at the top of a page ends on the page before
+			if end.Y <= theme.Page.MarginTop+0.5 {
+				last--
+			}
+		}
+		found = append(found, Finding{
+			ID:        f.ID,
+			Severity:  f.Severity,
+			Title:     f.Title,
+			Markdown:  f.Markdown,
+			FirstPage: start.Page,
+			LastPage:  max(last, start.Page),
+		})
+	}
+	return found
+}
@@ -6,6 +6,7 @@ require (
 	github.com/alecthomas/chroma/v2 v2.20.0
 	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/yuin/goldmark v1.7.13
+	golang.org/x/text v0.40.0
 )
 
 require github.com/dlclark/regexp2 v1.11.5 // indirect
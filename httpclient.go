@@ -0,0 +1,88 @@
+package report
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTPConfig configures the client of all outbound requests: remote images, Kroki and
+// PlantUML servers, and ticket systems
+type HTTPConfig struct {
+	// Proxy is the URL of the proxy all requests go through. By default the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables apply; "direct"
+	// ignores them.
+	Proxy string
+	// CABundle is a PEM file with CA certificates trusted in addition to the system's,
+	// e.g. the root of a TLS-inspecting corporate proxy
+	CABundle string
+	// ClientCert and ClientKey are PEM files with the certificate and key presented
+	// to servers requiring mutual TLS
+	ClientCert string
+	ClientKey  string
+	// MinTLSVersion is the oldest TLS version accepted: "1.2" (default) or "1.3"
+	MinTLSVersion string
+	// Timeout limits each request, including reading the response; zero means no limit
+	Timeout time.Duration
+}
+
+// tlsVersions are the accepted values of HTTPConfig.MinTLSVersion
+var tlsVersions = map[string]uint16{"": tls.VersionTLS12, "1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// NewHTTPClient returns a client for Options.HTTPClient configured by cfg. It keeps
+// connections open, so a client shared by the conversions of a session, like those of
+// the server, saves a TLS handshake per request.
+func NewHTTPClient(cfg HTTPConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch cfg.Proxy {
+	case "":
+	case "direct":
+		transport.Proxy = nil
+	default:
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	version, ok := tlsVersions[cfg.MinTLSVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q (expected 1.2 or 1.3)", cfg.MinTLSVersion)
+	}
+	transport.TLSClientConfig = &tls.Config{MinVersion: version}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", cfg.CABundle)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, errors.New("a client certificate needs both a certificate and a key file")
+	}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{Transport: transport, Timeout: cfg.Timeout}, nil
+}
@@ -0,0 +1,261 @@
+// Package diagram renders diagram sources, such as Mermaid and PlantUML fences, to PNG images
+package diagram
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"report/internal/parallel"
+)
+
+const (
+	defaultKrokiURL       = "https://kroki.io"
+	defaultPlantUMLServer = "http://localhost:8080"
+	defaultPlantUMLJar    = "plantuml.jar"
+	defaultTimeout        = 30 * time.Second
+	defaultConcurrency    = 4
+	// maxSize limits the size of a rendered image
+	maxSize = 32 << 20
+)
+
+// Options configures diagram rendering
+type Options struct {
+	// Renderers selects the renderer per diagram kind: "mmdc" runs the Mermaid CLI,
+	// "jar" runs PlantUML locally, "server" uses a PlantUML server and "kroki" a
+	// Kroki server. Mermaid defaults to mmdc and PlantUML to jar.
+	Renderers map[string]string
+	// KrokiURL is the Kroki server (default https://kroki.io)
+	KrokiURL string
+	// PlantUMLServer is the PlantUML server (default http://localhost:8080)
+	PlantUMLServer string
+	// PlantUMLJar is the path of plantuml.jar, run with java (default plantuml.jar)
+	PlantUMLJar string
+	// CacheDir, if set, keeps rendered diagrams keyed on the hash of their source,
+	// so unchanged diagrams aren't rendered again
+	CacheDir string
+	// Timeout bounds the rendering of a single diagram (default 30s)
+	Timeout time.Duration
+	// Concurrency is the maximum number of diagrams rendered in parallel (default 4)
+	Concurrency int
+	// Client sends requests to Kroki and PlantUML servers; http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Diagram is the source of a diagram in one of the supported languages
+type Diagram struct {
+	// Kind is the diagram language, "mermaid" or "plantuml"
+	Kind   string
+	Source string
+}
+
+// Result is the outcome of rendering one diagram
+type Result struct {
+	PNG []byte
+	Err error
+}
+
+// RenderAll renders diagrams with bounded parallelism and returns the results by diagram.
+// Duplicate diagrams are rendered once.
+func RenderAll(ctx context.Context, diagrams []Diagram, opts Options) map[Diagram]Result {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+
+	return parallel.Map(diagrams, opts.Concurrency, func(d Diagram) Result {
+		png, err := Render(ctx, d, opts)
+		return Result{PNG: png, Err: err}
+	})
+}
+
+// Render renders a single diagram to PNG, or loads it from the cache
+func Render(ctx context.Context, d Diagram, opts Options) ([]byte, error) {
+	renderer := opts.renderer(d.Kind)
+	cached := ""
+	if opts.CacheDir != "" {
+		sum := sha256.Sum256([]byte(renderer + "\x00" + d.Kind + "\x00" + d.Source))
+		cached = filepath.Join(opts.CacheDir, hex.EncodeToString(sum[:])+".png")
+		if png, err := os.ReadFile(cached); err == nil {
+			return png, nil
+		}
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var png []byte
+	var err error
+	switch {
+	case renderer == "kroki":
+		png, err = renderKroki(ctx, d, opts)
+	case renderer == "mmdc" && d.Kind == "mermaid":
+		png, err = renderMmdc(ctx, d.Source)
+	case renderer == "jar" && d.Kind == "plantuml":
+		png, err = renderPlantUMLJar(ctx, d.Source, opts)
+	case renderer == "server" && d.Kind == "plantuml":
+		png, err = renderPlantUMLServer(ctx, d.Source, opts)
+	default:
+		return nil, fmt.Errorf("renderer %q can't render %s diagrams", renderer, d.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The cache only saves time, so failing to write it is not an error
+	if cached != "" && os.MkdirAll(opts.CacheDir, 0o755) == nil {
+		os.WriteFile(cached, png, 0o644)
+	}
+	return png, nil
+}
+
+// renderer returns the renderer of a diagram kind
+func (opts Options) renderer(kind string) string {
+	if r := opts.Renderers[kind]; r != "" {
+		return r
+	}
+	if kind == "plantuml" {
+		return "jar"
+	}
+	return "mmdc"
+}
+
+// renderMmdc runs the Mermaid CLI on a diagram
+func renderMmdc(ctx context.Context, source string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "report-mermaid-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in, out := filepath.Join(dir, "diagram.mmd"), filepath.Join(dir, "diagram.png")
+	if err := os.WriteFile(in, []byte(source), 0o600); err != nil {
+		return nil, err
+	}
+
+	// Render at twice the size for sharp output once scaled to the page
+	cmd := exec.CommandContext(ctx, "mmdc", "-i", in, "-o", out, "-b", "white", "-s", "2")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("mmdc not found, install @mermaid-js/mermaid-cli or use the kroki renderer")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("mmdc: %s", firstLine(msg))
+		}
+		return nil, fmt.Errorf("mmdc: %w", err)
+	}
+	return os.ReadFile(out)
+}
+
+// plantUMLArgs are the java arguments of plantuml.jar. The sandbox security profile
+// stops !include, !includeurl and the like from reading files and URLs, so diagrams
+// can't pull secrets into the PDF.
+var plantUMLArgs = []string{"-Djava.awt.headless=true", "-DPLANTUML_SECURITY_PROFILE=SANDBOX", "-jar"}
+
+// renderPlantUMLJar runs plantuml.jar on a diagram, passing it through a pipe
+func renderPlantUMLJar(ctx context.Context, source string, opts Options) ([]byte, error) {
+	jar := opts.PlantUMLJar
+	if jar == "" {
+		jar = defaultPlantUMLJar
+	}
+	if _, err := os.Stat(jar); err != nil {
+		return nil, fmt.Errorf("plantuml jar %s not found, download it from plantuml.com or use the server renderer", jar)
+	}
+
+	args := append(slices.Clone(plantUMLArgs), jar, "-tpng", "-pipe", "-failfast2")
+	cmd := exec.CommandContext(ctx, "java", args...)
+	// Older releases only read the profile from the environment
+	cmd.Env = append(os.Environ(), "PLANTUML_SECURITY_PROFILE=SANDBOX")
+	cmd.Stdin = strings.NewReader(source)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("java not found, install a Java runtime to run PlantUML")
+		}
+		// PlantUML reports syntax errors on several lines, e.g. "ERROR", the line number and the message
+		if msg := strings.Join(strings.Fields(stderr.String()), " "); msg != "" {
+			return nil, fmt.Errorf("plantuml: %s", msg)
+		}
+		return nil, fmt.Errorf("plantuml: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// renderPlantUMLServer sends a diagram to a PlantUML server
+func renderPlantUMLServer(ctx context.Context, source string, opts Options) ([]byte, error) {
+	base := strings.TrimRight(opts.PlantUMLServer, "/")
+	if base == "" {
+		base = defaultPlantUMLServer
+	}
+	return post(ctx, "plantuml", base+"/png", source, opts)
+}
+
+// renderKroki sends a diagram to a Kroki server
+func renderKroki(ctx context.Context, d Diagram, opts Options) ([]byte, error) {
+	base := strings.TrimRight(opts.KrokiURL, "/")
+	if base == "" {
+		base = defaultKrokiURL
+	}
+	return post(ctx, "kroki", base+"/"+d.Kind+"/png", d.Source, opts)
+}
+
+// post sends a diagram source to a rendering server and returns the image.
+// Name identifies the server in errors.
+func post(ctx context.Context, name, url, source string, opts Options) ([]byte, error) {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Servers explain syntax errors in the response body
+		if msg := strings.TrimSpace(string(data)); msg != "" && len(msg) < 500 {
+			return nil, fmt.Errorf("%s: %s", name, firstLine(msg))
+		}
+		return nil, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%s: image larger than %d MB", name, maxSize>>20)
+	}
+	return data, nil
+}
+
+// firstLine returns the first line of a message
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,113 @@
+package diagram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRenderAll(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		source, _ := io.ReadAll(r.Body)
+		if string(source) == "broken" {
+			http.Error(w, "syntax error", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(r.URL.Path + ":" + string(source)))
+	}))
+	defer server.Close()
+
+	// Diagrams in the cache are returned at once without a request
+	cache := t.TempDir()
+	cached := Diagram{Kind: "mermaid", Source: "graph TD; A-->B"}
+	sum := sha256.Sum256([]byte("kroki\x00" + cached.Kind + "\x00" + cached.Source))
+	if err := os.WriteFile(filepath.Join(cache, hex.EncodeToString(sum[:])+".png"), []byte("cached"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		diagram Diagram
+		want    string
+		wantErr bool
+	}{
+		{cached, "cached", false},
+		{Diagram{Kind: "mermaid", Source: "graph LR; X-->Y"}, "/mermaid/png:graph LR; X-->Y", false},
+		{Diagram{Kind: "plantuml", Source: "@startuml\nA -> B\n@enduml"}, "/plantuml/png:@startuml\nA -> B\n@enduml", false},
+		{Diagram{Kind: "mermaid", Source: "broken"}, "", true},
+	}
+	var diagrams []Diagram
+	for _, tt := range tests {
+		// Every diagram is listed twice to render duplicates concurrently
+		diagrams = append(diagrams, tt.diagram, tt.diagram)
+	}
+
+	opts := Options{
+		Renderers: map[string]string{"mermaid": "kroki", "plantuml": "kroki"},
+		KrokiURL:  server.URL,
+		CacheDir:  cache,
+	}
+	results := RenderAll(context.Background(), diagrams, opts)
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+	for _, tt := range tests {
+		result := results[tt.diagram]
+		if (result.Err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want error %v", tt.diagram.Source, result.Err, tt.wantErr)
+		}
+		if string(result.PNG) != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.diagram.Source, result.PNG, tt.want)
+		}
+	}
+	if got := requests.Load(); got != int32(len(tests)-1) {
+		t.Errorf("server got %d requests, want %d", got, len(tests)-1)
+	}
+}
+
+func TestRenderPlantUMLJarSandbox(t *testing.T) {
+	// A fake java records how it is run and answers with an image
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" > \"$0.args\"\necho \"$PLANTUML_SECURITY_PROFILE\" > \"$0.env\"\ncat > /dev/null\nprintf png\n"
+	if err := os.WriteFile(filepath.Join(dir, "java"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	jar := filepath.Join(dir, "plantuml.jar")
+	if err := os.WriteFile(jar, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	data, err := renderPlantUMLJar(context.Background(), "@startuml\n!include /etc/passwd\n@enduml\n", Options{PlantUMLJar: jar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "png" {
+		t.Errorf("got %q, want the output of java", data)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "java.args"))
+	env, _ := os.ReadFile(filepath.Join(dir, "java.env"))
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"argument", string(args), "-DPLANTUML_SECURITY_PROFILE=SANDBOX"},
+		{"environment", string(env), "SANDBOX"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(tt.got, tt.want) {
+			t.Errorf("%s %q lacks %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package fetch downloads remote assets, such as images referenced by URL, concurrently
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"report/internal/parallel"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultBackoff     = 500 * time.Millisecond
+	// maxSize limits the size of a single asset
+	maxSize = 32 << 20
+)
+
+// Options configures a download
+type Options struct {
+	// Concurrency is the maximum number of parallel requests (default 4)
+	Concurrency int
+	// Retries is the number of additional attempts after a failed request
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each further one (default 500ms)
+	Backoff time.Duration
+	// Client sends the requests; http.DefaultClient if nil
+	Client *http.Client
+	// Hosts, if not nil, are the only hosts downloads may come from, also after
+	// redirects; URLs on other hosts fail
+	Hosts []string
+}
+
+// Result is the outcome of downloading one URL
+type Result struct {
+	Data []byte
+	Err  error
+}
+
+// All downloads the given URLs with bounded parallelism and returns the results by URL.
+// Duplicate URLs are fetched once.
+func All(ctx context.Context, urls []string, opts Options) map[string]Result {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Hosts != nil {
+		// Redirects may not lead to other hosts either
+		client := *opts.Client
+		checkRedirect := client.CheckRedirect
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if !opts.allowed(req.URL) {
+				return fmt.Errorf("redirect to %s: %w", req.URL.Hostname(), errHostNotAllowed)
+			}
+			if checkRedirect != nil {
+				return checkRedirect(req, via)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+		opts.Client = &client
+	}
+
+	return parallel.Map(urls, opts.Concurrency, func(rawURL string) Result {
+		if u, err := url.Parse(rawURL); err != nil || !opts.allowed(u) {
+			return Result{Err: errHostNotAllowed}
+		}
+		data, err := get(ctx, rawURL, opts)
+		return Result{Data: data, Err: err}
+	})
+}
+
+// errHostNotAllowed is the error of downloads from hosts outside Options.Hosts
+var errHostNotAllowed = errors.New("host not allowed")
+
+// allowed reports whether a URL is on one of the hosts downloads may come from
+func (opts Options) allowed(u *url.URL) bool {
+	return opts.Hosts == nil || slices.ContainsFunc(opts.Hosts, func(host string) bool {
+		return strings.EqualFold(host, u.Hostname())
+	})
+}
+
+// get downloads one URL, retrying network errors and server-side failures with exponential backoff
+func get(ctx context.Context, url string, opts Options) ([]byte, error) {
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		data, retry, err := getOnce(ctx, url, opts.Client)
+		if err == nil || !retry || attempt >= opts.Retries {
+			return data, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// getOnce sends a single request and reports whether a failure is worth retrying
+func getOnce(ctx context.Context, url string, client *http.Client) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil && !errors.Is(err, errHostNotAllowed), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("%s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, true, err
+	}
+	if len(data) > maxSize {
+		return nil, false, fmt.Errorf("larger than %d MB", maxSize>>20)
+	}
+	return data, false, nil
+}
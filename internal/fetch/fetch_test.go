@@ -0,0 +1,115 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAll(t *testing.T) {
+	var requests, flaky atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/flaky":
+			// Fails once, then succeeds on the retry
+			if flaky.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("flaky"))
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"/a.png", "/a.png", false},
+		{"/b.png", "/b.png", false},
+		{"/missing", "", true},
+		{"/flaky", "flaky", false},
+	}
+	var urls []string
+	for _, tt := range tests {
+		// Every URL is listed twice to fetch duplicates concurrently
+		urls = append(urls, server.URL+tt.path, server.URL+tt.path)
+	}
+
+	results := All(context.Background(), urls, Options{Concurrency: 3, Retries: 1, Backoff: time.Millisecond})
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+	for _, tt := range tests {
+		result := results[server.URL+tt.path]
+		if (result.Err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.path, result.Err, tt.wantErr)
+		}
+		if string(result.Data) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, result.Data, tt.want)
+		}
+	}
+	// One request per URL, plus the retry of the flaky one
+	if got := requests.Load(); got != int32(len(tests)+1) {
+		t.Errorf("server got %d requests, want %d", got, len(tests)+1)
+	}
+}
+
+func TestAllHosts(t *testing.T) {
+	var requests atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("internal"))
+	}))
+	defer other.Close()
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, other.URL+"/secret", http.StatusFound)
+			return
+		}
+		w.Write([]byte("public"))
+	}))
+	defer allowed.Close()
+	// Both servers listen on 127.0.0.1, so they are told apart by the name in the URL
+	allowedURL := strings.Replace(allowed.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name    string
+		hosts   []string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"any host", nil, other.URL + "/secret", "internal", false},
+		{"allowed host", []string{"LOCALHOST"}, allowedURL + "/image.png", "public", false},
+		{"other host", []string{"localhost"}, other.URL + "/secret", "", true},
+		{"no hosts", []string{}, allowedURL + "/image.png", "", true},
+		{"redirect to other host", []string{"localhost"}, allowedURL + "/redirect", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			results := All(context.Background(), []string{tt.url}, Options{Hosts: tt.hosts, Retries: 2, Backoff: time.Millisecond})
+			result := results[tt.url]
+			if (result.Err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", result.Err, tt.wantErr)
+			}
+			if string(result.Data) != tt.want {
+				t.Errorf("got %q, want %q", result.Data, tt.want)
+			}
+			if tt.wantErr && requests.Load() != 0 {
+				t.Errorf("disallowed host got %d requests", requests.Load())
+			}
+		})
+	}
+}
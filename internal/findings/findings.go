@@ -0,0 +1,230 @@
+package findings
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed default.md.tmpl
+var defaultTemplate string
+
+// Finding is one entry of a findings export
+type Finding struct {
+	ID             string
+	Title          string
+	Severity       string
+	Status         string
+	CVSS           string
+	Description    string
+	Impact         string
+	Recommendation string
+	Affected       []string
+	References     []string
+}
+
+// SeverityCount is the number of findings of one severity
+type SeverityCount struct {
+	Severity string
+	Count    int
+}
+
+// Report is the data passed to findings templates
+type Report struct {
+	Findings []Finding
+	Counts   []SeverityCount
+}
+
+// severityOrder ranks severities from most to least severe
+var severityOrder = map[string]int{
+	"critical":      0,
+	"high":          1,
+	"medium":        2,
+	"moderate":      2,
+	"low":           3,
+	"info":          4,
+	"informational": 4,
+}
+
+// fieldAliases lists the keys accepted for each finding field, so exports of
+// common scanners can be read without a conversion step
+var fieldAliases = map[string][]string{
+	"id":             {"id", "finding_id", "key", "ref"},
+	"title":          {"title", "name", "summary", "rule"},
+	"severity":       {"severity", "risk", "level", "priority"},
+	"status":         {"status", "state"},
+	"cvss":           {"cvss", "cvss_score", "score"},
+	"description":    {"description", "details", "detail", "message"},
+	"impact":         {"impact"},
+	"recommendation": {"recommendation", "remediation", "solution", "fix"},
+	"affected":       {"affected", "locations", "location", "assets", "hosts", "files", "urls"},
+	"references":     {"references", "refs", "links", "see_also"},
+}
+
+// Load reads a findings export: either a JSON array of findings or an object
+// with a "findings" (or "results", "vulnerabilities", "issues") array
+func Load(path string) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read findings: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a findings export, see Load
+func Parse(data []byte) ([]Finding, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid findings JSON: %w", err)
+	}
+
+	var entries []any
+	switch v := raw.(type) {
+	case []any:
+		entries = v
+	case map[string]any:
+		for _, key := range []string{"findings", "results", "vulnerabilities", "issues"} {
+			if list, ok := v[key].([]any); ok {
+				entries = list
+				break
+			}
+		}
+		if entries == nil {
+			return nil, fmt.Errorf("invalid findings JSON: no findings array found")
+		}
+	default:
+		return nil, fmt.Errorf("invalid findings JSON: expected an array or object")
+	}
+
+	findings := make([]Finding, 0, len(entries))
+	for i, entry := range entries {
+		fields, ok := entry.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid findings JSON: entry %d is not an object", i+1)
+		}
+		f := Finding{
+			ID:             stringField(fields, "id"),
+			Title:          stringField(fields, "title"),
+			Severity:       strings.ToLower(stringField(fields, "severity")),
+			Status:         stringField(fields, "status"),
+			CVSS:           stringField(fields, "cvss"),
+			Description:    stringField(fields, "description"),
+			Impact:         stringField(fields, "impact"),
+			Recommendation: stringField(fields, "recommendation"),
+			Affected:       listField(fields, "affected"),
+			References:     listField(fields, "references"),
+		}
+		if f.Title == "" {
+			return nil, fmt.Errorf("invalid findings JSON: entry %d has no title", i+1)
+		}
+		if f.Severity == "" {
+			f.Severity = "info"
+		}
+		if f.ID == "" {
+			f.ID = fmt.Sprintf("F-%02d", i+1)
+		}
+		findings = append(findings, f)
+	}
+
+	// Most severe first, then by ID
+	sort.SliceStable(findings, func(i, j int) bool {
+		ri, rj := severityRank(findings[i].Severity), severityRank(findings[j].Severity)
+		if ri != rj {
+			return ri < rj
+		}
+		return findings[i].ID < findings[j].ID
+	})
+	return findings, nil
+}
+
+func severityRank(severity string) int {
+	if rank, ok := severityOrder[severity]; ok {
+		return rank
+	}
+	return len(severityOrder)
+}
+
+func lookup(fields map[string]any, name string) any {
+	for _, key := range fieldAliases[name] {
+		if v, ok := fields[key]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func stringField(fields map[string]any, name string) string {
+	switch v := lookup(fields, name).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprint(v)
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
+
+func listField(fields map[string]any, name string) []string {
+	switch v := lookup(fields, name).(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// Render generates markdown for the findings. An empty tmpl uses the built-in template,
+// which emits a severity summary and a section per finding with a severity badge.
+func Render(findings []Finding, tmpl string) ([]byte, error) {
+	if tmpl == "" {
+		tmpl = defaultTemplate
+	}
+
+	t, err := template.New("findings").Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"title": func(s string) string {
+			if s == "" {
+				return s
+			}
+			return strings.ToUpper(s[:1]) + s[1:]
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("findings template parse error: %w", err)
+	}
+
+	report := Report{Findings: findings}
+	for _, f := range findings {
+		if n := len(report.Counts); n > 0 && report.Counts[n-1].Severity == f.Severity {
+			report.Counts[n-1].Count++
+			continue
+		}
+		report.Counts = append(report.Counts, SeverityCount{Severity: f.Severity, Count: 1})
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("findings template execution error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,173 @@
+// Package latex renders LaTeX math formulas to PNG images with latex and dvipng,
+// or sets simple ones as Unicode text
+package latex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"report/internal/parallel"
+)
+
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultConcurrency = 4
+	// DPI is the resolution formulas are rendered at
+	DPI = 600
+	// FontSize is the LaTeX font size in points formulas are set in
+	FontSize = 10
+)
+
+// ErrNotFound is returned if latex or dvipng isn't installed
+var ErrNotFound = errors.New("latex or dvipng not found, install a TeX distribution with dvipng")
+
+// Options configures formula rendering
+type Options struct {
+	// Timeout bounds the rendering of a single formula (default 30s)
+	Timeout time.Duration
+	// Concurrency is the maximum number of formulas rendered in parallel (default 4)
+	Concurrency int
+}
+
+// Formula is the LaTeX source of an inline or display formula, without its delimiters
+type Formula struct {
+	Source  string
+	Display bool
+}
+
+// Image is a rendered formula
+type Image struct {
+	PNG []byte
+	// Width, Height and Depth are in pixels at DPI; Depth is the part of the
+	// height below the baseline
+	Width, Height, Depth int
+}
+
+// Result is the outcome of rendering one formula
+type Result struct {
+	Image Image
+	Err   error
+}
+
+// RenderAll renders formulas with bounded parallelism and returns the results by formula.
+// Duplicate formulas are rendered once.
+func RenderAll(ctx context.Context, formulas []Formula, opts Options) map[Formula]Result {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+
+	return parallel.Map(formulas, opts.Concurrency, func(f Formula) Result {
+		img, err := Render(ctx, f, opts)
+		return Result{Image: img, Err: err}
+	})
+}
+
+// document is the LaTeX document a formula is set in
+const document = `\documentclass[%dpt]{article}
+\usepackage{amsmath,amssymb}
+\pagestyle{empty}
+\begin{document}
+%s
+\end{document}
+`
+
+// texEnv is the environment latex and dvipng run in. Kpathsea's paranoid mode keeps
+// formulas like `\input{/etc/passwd}` from reading or writing files outside the working
+// directory: absolute paths, `..` and dot files are refused, and shell escapes are off
+// regardless of texmf.cnf.
+var texEnv = []string{"openin_any=p", "openout_any=p", "shell_escape=f"}
+
+// depthRegex matches the depth dvipng reports with --depth
+var depthRegex = regexp.MustCompile(`depth=(-?\d+)`)
+
+// Render renders a single formula with latex and dvipng
+func Render(ctx context.Context, f Formula, opts Options) (Image, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "report-latex-*")
+	if err != nil {
+		return Image{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	body := "$" + f.Source + "$"
+	if f.Display {
+		body = `\[` + f.Source + `\]`
+	}
+	tex := filepath.Join(dir, "formula.tex")
+	if err := os.WriteFile(tex, fmt.Appendf(nil, document, FontSize, body), 0o600); err != nil {
+		return Image{}, err
+	}
+
+	latex := exec.CommandContext(ctx, "latex", "-interaction=nonstopmode", "-halt-on-error", "-no-shell-escape", "formula.tex")
+	latex.Dir = dir
+	latex.Env = append(os.Environ(), texEnv...)
+	var log bytes.Buffer
+	latex.Stdout = &log
+	if err := latex.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return Image{}, ErrNotFound
+		}
+		return Image{}, fmt.Errorf("latex: %s", texError(log.String(), err))
+	}
+
+	// Crop to the formula and report its depth below the baseline
+	dvipng := exec.CommandContext(ctx, "dvipng", "-T", "tight", "-D", strconv.Itoa(DPI), "-bg", "Transparent", "--depth", "-o", "formula.png", "formula.dvi")
+	dvipng.Dir = dir
+	dvipng.Env = append(os.Environ(), texEnv...)
+	out, err := dvipng.CombinedOutput()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return Image{}, ErrNotFound
+		}
+		return Image{}, fmt.Errorf("dvipng: %w", err)
+	}
+
+	img := Image{}
+	if m := depthRegex.FindSubmatch(out); m != nil {
+		img.Depth, _ = strconv.Atoi(string(m[1]))
+	}
+	img.PNG, err = os.ReadFile(filepath.Join(dir, "formula.png"))
+	if err != nil {
+		return Image{}, err
+	}
+	img.Width, img.Height, err = pngSize(img.PNG)
+	if err != nil {
+		return Image{}, err
+	}
+	return img, nil
+}
+
+// texError returns the first error message of a latex log
+func texError(log string, err error) string {
+	for _, line := range strings.Split(log, "\n") {
+		if strings.HasPrefix(line, "! ") {
+			return strings.TrimPrefix(line, "! ")
+		}
+	}
+	return err.Error()
+}
+
+// pngSize returns the size of a PNG image
+func pngSize(data []byte) (int, int, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("dvipng: %w", err)
+	}
+	return config.Width, config.Height, nil
+}
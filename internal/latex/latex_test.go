@@ -0,0 +1,54 @@
+package latex
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestRenderAll(t *testing.T) {
+	formulas := []Formula{
+		{Source: `x^2`},
+		{Source: `x^2`},
+		{Source: `\frac{a}{b}`, Display: true},
+		{Source: `\frac{a}{b}`, Display: true},
+		{Source: `x^2`, Display: true},
+	}
+	results := RenderAll(context.Background(), formulas, Options{Concurrency: 2})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	_, err := exec.LookPath("latex")
+	for f, result := range results {
+		switch {
+		case err != nil && !errors.Is(result.Err, ErrNotFound):
+			t.Errorf("%q without latex: error %v, want ErrNotFound", f.Source, result.Err)
+		case err == nil && result.Err != nil:
+			t.Errorf("%q: %v", f.Source, result.Err)
+		case err == nil && (result.Image.Width == 0 || result.Image.Height == 0):
+			t.Errorf("%q: empty image", f.Source)
+		}
+	}
+}
+
+func TestRenderConfined(t *testing.T) {
+	if _, err := exec.LookPath("latex"); err != nil {
+		t.Skip("latex not installed")
+	}
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"absolute path", `\input{/etc/passwd}`},
+		{"parent directory", `\input{../../../../../../etc/passwd}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Render(context.Background(), Formula{Source: tt.source}, Options{})
+			if err == nil && img.Width > 1 {
+				t.Errorf("Render(%q) succeeded, want the file access refused", tt.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package latex
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Run is a piece of a formula set as text by Unicode
+type Run struct {
+	Text string
+	// Italic marks variables
+	Italic bool
+	// Sub and Sup mark scripts without Unicode subscript or superscript characters,
+	// which are set smaller and lowered or raised instead
+	Sub, Sup bool
+}
+
+// superscripts and subscripts are the Unicode script forms of characters, limited to
+// those the embedded font has glyphs for
+var (
+	superscripts = map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+		'+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ',
+	}
+	subscripts = map[rune]rune{
+		'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+		'+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎',
+	}
+)
+
+// symbols are the commands set as a single character
+var symbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ",
+	"nu": "ν", "xi": "ξ", "pi": "π", "rho": "ρ", "sigma": "σ", "tau": "τ", "upsilon": "υ",
+	"phi": "φ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "forall": "∀", "exists": "∃", "ldots": "…", "dots": "…",
+	"sum": "∑", "prod": "∏", "int": "∫", "prime": "′",
+	"{": "{", "}": "}", "%": "%", "$": "$", "&": "&", "_": "_", "#": "#",
+}
+
+// operators are the commands and characters set as binary operators and relations,
+// with space around them
+var operators = map[string]string{
+	"+": "+", "-": "−", "=": "=", "<": "<", ">": ">",
+	"times": "×", "cdot": "·", "pm": "±", "le": "≤", "leq": "≤", "ge": "≥", "geq": "≥",
+	"ne": "≠", "neq": "≠", "approx": "≈", "to": "→", "rightarrow": "→", "in": "∈",
+	"notin": "∉", "subset": "⊂", "cup": "∪", "cap": "∩",
+}
+
+// functions are the commands set as upright function names
+var functions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "log": true, "ln": true, "exp": true,
+	"min": true, "max": true, "lim": true, "det": true, "gcd": true,
+}
+
+// spaces are the spacing commands
+var spaces = map[string]string{",": " ", ";": " ", ":": " ", " ": " ", "quad": "  ", "qquad": "    ", "!": ""}
+
+// ToUnicode sets a simple formula as text: variables in italics, Greek letters and
+// operators as Unicode characters and scripts as Unicode subscripts and superscripts
+// where possible. It fails for constructs that need real typesetting, such as
+// environments, matrices or scripts of scripts.
+func ToUnicode(source string) ([]Run, error) {
+	c := &converter{src: []rune(source)}
+	if err := c.sequence(false); err != nil {
+		return nil, err
+	}
+	return c.runs, nil
+}
+
+// converter converts a formula from left to right
+type converter struct {
+	src  []rune
+	pos  int
+	runs []Run
+	// operand is set once a value was written, so a following - is binary
+	operand bool
+}
+
+// sequence converts tokens up to the end of the source or, in a group, its closing brace
+func (c *converter) sequence(group bool) error {
+	for c.pos < len(c.src) {
+		r := c.src[c.pos]
+		switch {
+		case r == '}':
+			if !group {
+				return fmt.Errorf("unbalanced }")
+			}
+			c.pos++
+			return nil
+		case r == '{':
+			c.pos++
+			if err := c.sequence(true); err != nil {
+				return err
+			}
+		case r == '^' || r == '_':
+			c.pos++
+			if err := c.script(r == '^'); err != nil {
+				return err
+			}
+		case unicode.IsSpace(r):
+			c.pos++
+		case r == '\\':
+			if err := c.command(); err != nil {
+				return err
+			}
+		default:
+			c.pos++
+			c.char(r)
+		}
+	}
+	if group {
+		return fmt.Errorf("unbalanced {")
+	}
+	return nil
+}
+
+// char writes a character of the source
+func (c *converter) char(r rune) {
+	if op, ok := operators[string(r)]; ok {
+		c.operator(op)
+		return
+	}
+	c.write(string(r), unicode.IsLetter(r))
+	c.operand = r != '(' && r != '['
+}
+
+// operator writes a binary operator or relation, or a sign at the start of an operand
+func (c *converter) operator(op string) {
+	if !c.operand && (op == "−" || op == "+" || op == "±") {
+		c.write(op, false)
+		return
+	}
+	c.write(" "+op+" ", false)
+	c.operand = false
+}
+
+// write appends text to the runs, merging it into the last run of the same style
+func (c *converter) write(text string, italic bool) {
+	if last := len(c.runs) - 1; last >= 0 && c.runs[last] == (Run{Text: c.runs[last].Text, Italic: italic}) {
+		c.runs[last].Text += text
+		return
+	}
+	c.runs = append(c.runs, Run{Text: text, Italic: italic})
+}
+
+// command converts the command at the current position
+func (c *converter) command() error {
+	c.pos++
+	if c.pos >= len(c.src) {
+		return fmt.Errorf("trailing \\")
+	}
+	name := string(c.src[c.pos])
+	if unicode.IsLetter(c.src[c.pos]) {
+		start := c.pos
+		for c.pos < len(c.src) && unicode.IsLetter(c.src[c.pos]) {
+			c.pos++
+		}
+		name = string(c.src[start:c.pos])
+	} else {
+		c.pos++
+	}
+
+	if s, ok := symbols[name]; ok {
+		c.write(s, false)
+		c.operand = true
+		return nil
+	}
+	if op, ok := operators[name]; ok {
+		c.operator(op)
+		return nil
+	}
+	if s, ok := spaces[name]; ok {
+		c.write(s, false)
+		return nil
+	}
+	if functions[name] {
+		c.write(name+" ", false)
+		c.operand = false
+		return nil
+	}
+
+	switch name {
+	case "left", "right":
+		// Delimiters keep their size
+		return nil
+	case "text", "mathrm", "operatorname":
+		text, err := c.argument()
+		if err != nil {
+			return err
+		}
+		c.write(text, false)
+		c.operand = true
+		return nil
+	case "frac":
+		num, err := c.argumentRuns()
+		if err != nil {
+			return err
+		}
+		den, err := c.argumentRuns()
+		if err != nil {
+			return err
+		}
+		c.append(parenthesize(num))
+		c.write("/", false)
+		c.append(parenthesize(den))
+		c.operand = true
+		return nil
+	case "sqrt":
+		arg, err := c.argumentRuns()
+		if err != nil {
+			return err
+		}
+		c.write("√", false)
+		c.append(parenthesize(arg))
+		c.operand = true
+		return nil
+	}
+	return fmt.Errorf("\\%s has no Unicode form", name)
+}
+
+// argument returns the raw text of the next group or character
+func (c *converter) argument() (string, error) {
+	for c.pos < len(c.src) && unicode.IsSpace(c.src[c.pos]) {
+		c.pos++
+	}
+	if c.pos >= len(c.src) {
+		return "", fmt.Errorf("missing argument")
+	}
+	switch start := c.pos; c.src[c.pos] {
+	case '{':
+	case '\\':
+		// A command, like the \pi of e^\pi
+		c.pos++
+		for c.pos < len(c.src) && unicode.IsLetter(c.src[c.pos]) {
+			c.pos++
+		}
+		if c.pos == start+1 && c.pos < len(c.src) {
+			c.pos++
+		}
+		return string(c.src[start:c.pos]), nil
+	default:
+		c.pos++
+		return string(c.src[start]), nil
+	}
+	depth := 0
+	for i := c.pos; i < len(c.src); i++ {
+		switch c.src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := string(c.src[c.pos+1 : i])
+				c.pos = i + 1
+				return text, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unbalanced {")
+}
+
+// argumentRuns converts the next argument
+func (c *converter) argumentRuns() ([]Run, error) {
+	arg, err := c.argument()
+	if err != nil {
+		return nil, err
+	}
+	return ToUnicode(arg)
+}
+
+// append adds converted runs
+func (c *converter) append(runs []Run) {
+	for _, r := range runs {
+		if r.Sub || r.Sup {
+			c.runs = append(c.runs, r)
+		} else {
+			c.write(r.Text, r.Italic)
+		}
+	}
+}
+
+// simpleArgument converts the next argument to plain text, failing for one that
+// needs styling such as scripts
+func (c *converter) simpleArgument() (string, error) {
+	runs, err := c.argumentRuns()
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, r := range runs {
+		if r.Sub || r.Sup {
+			return "", fmt.Errorf("nested scripts have no Unicode form")
+		}
+		text.WriteString(r.Text)
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// script writes the argument of ^ or _ as Unicode script characters, or else as a
+// raised or lowered run
+func (c *converter) script(sup bool) error {
+	text, err := c.simpleArgument()
+	if err != nil {
+		return err
+	}
+	text = strings.ReplaceAll(text, " ", "")
+
+	forms := subscripts
+	if sup {
+		forms = superscripts
+	}
+	var converted strings.Builder
+	for _, r := range strings.ReplaceAll(text, "−", "-") {
+		form, ok := forms[r]
+		if !ok {
+			c.runs = append(c.runs, Run{Text: text, Italic: isVariable(text), Sub: !sup, Sup: sup})
+			c.operand = true
+			return nil
+		}
+		converted.WriteRune(form)
+	}
+	c.write(converted.String(), false)
+	c.operand = true
+	return nil
+}
+
+// isVariable reports whether text consists of letters only
+func isVariable(text string) bool {
+	for _, r := range text {
+		if !unicode.IsLetter(r) || r > unicode.MaxLatin1 {
+			return false
+		}
+	}
+	return true
+}
+
+// parenthesize wraps runs of more than one character in parentheses
+func parenthesize(runs []Run) []Run {
+	length := 0
+	for _, r := range runs {
+		length += len([]rune(r.Text))
+	}
+	if length <= 1 {
+		return runs
+	}
+	return slices.Concat([]Run{{Text: "("}}, runs, []Run{{Text: ")"}})
+}
@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+)
+
+// gfmIDs generates heading IDs the way GitHub does, so links like `[see Architecture](#architecture)`
+// written against the rendered Markdown on GitHub also work in the PDF: the text is lowercased,
+// spaces become hyphens and punctuation other than hyphens and underscores is dropped.
+// Repeated IDs get a -1, -2, ... suffix.
+type gfmIDs struct {
+	values map[string]bool
+}
+
+func newGFMIDs() parser.IDs {
+	return &gfmIDs{values: map[string]bool{}}
+}
+
+// slug returns the GitHub heading ID of a heading text, without the suffix of repeated IDs
+func slug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (s *gfmIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	id := slug(string(value))
+	if id == "" {
+		id = "heading"
+		if kind != ast.KindHeading {
+			id = "id"
+		}
+	}
+	if s.values[id] {
+		for i := 1; ; i++ {
+			if next := fmt.Sprintf("%s-%d", id, i); !s.values[next] {
+				id = next
+				break
+			}
+		}
+	}
+	s.values[id] = true
+	return []byte(id)
+}
+
+func (s *gfmIDs) Put(value []byte) {
+	s.values[string(value)] = true
+}
+
+// HasInternalLinks reports whether the document links to anchors within it, like `[text](#id)`
+func HasInternalLinks(root ast.Node) bool {
+	found := false
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering && strings.HasPrefix(string(link.Destination), "#") {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
@@ -0,0 +1,127 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Attributes are Pandoc-style attributes, `{#id .class key=val}`, on headings,
+// images and fenced code blocks
+type Attributes struct {
+	ID      string
+	Classes []string
+	Values  map[string]string
+}
+
+// trailingAttrRegex matches an attribute block at the end of a string
+var trailingAttrRegex = regexp.MustCompile(`\{([^{}]*)\}\s*$`)
+
+// parseAttributes parses the content of an attribute block without its braces.
+// Values may be quoted; a bare key means "true", and `-` is Pandoc's
+// shorthand for the class "unnumbered".
+func parseAttributes(s string) Attributes {
+	attrs := Attributes{Values: map[string]string{}}
+	for _, field := range splitAttributeFields(s) {
+		switch {
+		case field == "-":
+			attrs.Classes = append(attrs.Classes, "unnumbered")
+		case strings.HasPrefix(field, "#"):
+			attrs.ID = field[1:]
+		case strings.HasPrefix(field, "."):
+			attrs.Classes = append(attrs.Classes, field[1:])
+		default:
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				value = "true"
+			}
+			attrs.Values[key] = strings.Trim(value, `"'`)
+		}
+	}
+	return attrs
+}
+
+// splitAttributeFields splits an attribute block at spaces outside of quotes
+func splitAttributeFields(s string) []string {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			field.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			field.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// nodeAttributes returns the attributes goldmark parsed for a node, e.g. on headings
+func nodeAttributes(n ast.Node) Attributes {
+	attrs := Attributes{Values: map[string]string{}}
+	for _, attr := range n.Attributes() {
+		name := string(attr.Name)
+		value := attributeString(n, name)
+		switch name {
+		case "id":
+			attrs.ID = value
+		case "class":
+			attrs.Classes = strings.Fields(value)
+		default:
+			attrs.Values[name] = value
+		}
+	}
+	return attrs
+}
+
+// HasClass reports whether the attributes include the class
+func (a Attributes) HasClass(class string) bool {
+	for _, c := range a.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// unlisted reports whether a heading is left out of the table of contents,
+// with Pandoc's `.unlisted` class or `toc=false`
+func (a Attributes) unlisted() bool {
+	return a.HasClass("unlisted") || a.Values["toc"] == "false"
+}
+
+// imageOptions maps the attributes of a block image to its placement and caption.
+// Alignment comes from align=center or the classes .center and .right.
+func imageOptions(attrs Attributes) pdf.ImageOptions {
+	opts := pdf.ImageOptions{
+		Width:   attrs.Values["width"],
+		Height:  attrs.Values["height"],
+		Align:   attrs.Values["align"],
+		ID:      attrs.ID,
+		Caption: attrs.Values["caption"],
+	}
+	for _, align := range []string{"center", "right"} {
+		if attrs.HasClass(align) {
+			opts.Align = align
+		}
+	}
+	return opts
+}
@@ -0,0 +1,199 @@
+package markdown
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindCriticMarkup is the node kind of CriticMarkup annotations
+var KindCriticMarkup = ast.NewNodeKind("CriticMarkup")
+
+// CriticOp is the kind of a CriticMarkup annotation
+type CriticOp int
+
+const (
+	// CriticComment is a reviewer comment, `{>> comment <<}`
+	CriticComment CriticOp = iota
+	// CriticInsertion is inserted text, `{++ text ++}`
+	CriticInsertion
+	// CriticDeletion is deleted text, `{-- text --}`
+	CriticDeletion
+	// CriticSubstitution replaces text, `{~~ old ~> new ~~}`
+	CriticSubstitution
+)
+
+// criticDelimiters are the opening and closing delimiters of each annotation
+var criticDelimiters = []struct {
+	op          CriticOp
+	open, close string
+}{
+	{CriticComment, "{>>", "<<}"},
+	{CriticInsertion, "{++", "++}"},
+	{CriticDeletion, "{--", "--}"},
+	{CriticSubstitution, "{~~", "~~}"},
+}
+
+// String returns the name of the operation, as shown in AST dumps
+func (op CriticOp) String() string {
+	return [...]string{"comment", "insertion", "deletion", "substitution"}[op]
+}
+
+// CriticMarkup is a CriticMarkup annotation
+type CriticMarkup struct {
+	ast.BaseInline
+	Op CriticOp
+	// Value is the comment, or the inserted, deleted or replaced text
+	Value string
+	// New is the replacement of a substitution
+	New string
+	// Segment spans the annotation in the source, including its delimiters
+	Segment text.Segment
+}
+
+// Kind implements ast.Node
+func (n *CriticMarkup) Kind() ast.NodeKind {
+	return KindCriticMarkup
+}
+
+// Dump implements ast.Node
+func (n *CriticMarkup) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{"Op": n.Op.String(), "Value": n.Value, "New": n.New}, nil)
+}
+
+// accepted returns the text of the annotation with the change accepted
+func (n *CriticMarkup) accepted() string {
+	switch n.Op {
+	case CriticInsertion:
+		return n.Value
+	case CriticSubstitution:
+		return n.New
+	default:
+		return ""
+	}
+}
+
+// criticParser parses CriticMarkup annotations, which may span the lines of a paragraph
+type criticParser struct{}
+
+func (criticParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (criticParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	i := slices.IndexFunc(criticDelimiters, func(d struct {
+		op          CriticOp
+		open, close string
+	}) bool {
+		return bytes.HasPrefix(line, []byte(d.open))
+	})
+	if i < 0 {
+		return nil
+	}
+	delim := criticDelimiters[i]
+
+	start := segment.Start
+	lineNo, position := block.Position()
+	block.Advance(len(delim.open))
+	for {
+		rest, segment := block.PeekLine()
+		if rest == nil {
+			break
+		}
+		if i := bytes.Index(rest, []byte(delim.close)); i >= 0 {
+			stop := segment.Start + i + len(delim.close)
+			content := string(block.Source()[start+len(delim.open) : stop-len(delim.close)])
+			n := &CriticMarkup{Op: delim.op, Segment: text.NewSegment(start, stop)}
+			if delim.op == CriticSubstitution {
+				old, replacement, ok := strings.Cut(content, "~>")
+				if !ok {
+					break
+				}
+				n.Value, n.New = criticText(old), criticText(replacement)
+			} else {
+				n.Value = criticText(content)
+			}
+			block.Advance(i + len(delim.close))
+			return n
+		}
+		block.AdvanceLine()
+	}
+	block.SetPosition(lineNo, position)
+	return nil
+}
+
+// criticText collapses the line breaks and surrounding spaces of annotated text
+func criticText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// criticExtension adds CriticMarkup to the parser
+type criticExtension struct{}
+
+func (criticExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(criticParser{}, 500)))
+}
+
+// criticSpans adds an annotation to the spans. Drafts show comments in color,
+// insertions underlined and deletions struck through; otherwise comments are left
+// out and changes accepted.
+func (r *renderer) criticSpans(n *CriticMarkup, style pdf.Span, spans *[]pdf.Span) {
+	if !r.opts.Draft {
+		text := n.accepted()
+		if text == "" {
+			// Drop the space before removed text, the one after it separates the words
+			if last := len(*spans) - 1; last >= 0 {
+				(*spans)[last].Text = strings.TrimRight((*spans)[last].Text, " ")
+			}
+			return
+		}
+		appendSpan(spans, style, text)
+		return
+	}
+
+	inserted, deleted := style, style
+	inserted.Underline, inserted.Color = true, "insertion"
+	deleted.Strike, deleted.Color = true, "deletion"
+	switch n.Op {
+	case CriticComment:
+		style.Italic, style.Color = true, "comment"
+		appendSpan(spans, style, "["+n.Value+"]")
+	case CriticInsertion:
+		appendSpan(spans, inserted, n.Value)
+	case CriticDeletion:
+		appendSpan(spans, deleted, n.Value)
+	case CriticSubstitution:
+		appendSpan(spans, deleted, n.Value)
+		appendSpan(spans, inserted, n.New)
+	}
+}
+
+// paragraphComments returns the comments of a paragraph consisting only of comments
+func paragraphComments(n *ast.Paragraph, src []byte) []string {
+	var comments []string
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch node := child.(type) {
+		case *CriticMarkup:
+			if node.Op != CriticComment {
+				return nil
+			}
+			comments = append(comments, node.Value)
+		case *ast.Text:
+			if strings.TrimSpace(string(node.Segment.Value(src))) != "" {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return comments
+}
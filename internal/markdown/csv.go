@@ -0,0 +1,98 @@
+package markdown
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// tableProgressRows is how often a data table reports the rows written so far
+const tableProgressRows = 10000
+
+// dataTableDelimiters are the field separators of the fence languages of data tables
+var dataTableDelimiters = map[string]rune{"csv": ',', "tsv": '\t'}
+
+// dataTable reports whether a fence is a data table: a `csv` or `tsv` fence with the class
+// `table` or a `src` file, like ```` ```csv {.table} ```` or ```` ```tsv {src="hosts.tsv"} ````.
+// Other csv fences stay code blocks.
+func dataTable(n *ast.FencedCodeBlock, src []byte) (rune, Attributes, bool) {
+	language, attrs := fenceInfo(n, src)
+	delimiter, ok := dataTableDelimiters[language]
+	if !ok || (!slices.Contains(attrs.Classes, "table") && attrs.Values["src"] == "") {
+		return 0, attrs, false
+	}
+	return delimiter, attrs, true
+}
+
+// writeDataTable streams the rows of a data table from its `src` file or the fence
+// content to the PDF, so tables of tens of thousands of rows don't have to be held in
+// memory. The first row is the header unless the fence sets header=false.
+func (r *renderer) writeDataTable(n *ast.FencedCodeBlock, delimiter rune, attrs Attributes) {
+	table := pdf.Table{Caption: r.figures.caption(n, attrs.Values["caption"]), ID: attrs.ID}
+	if table.ID == "" {
+		table.ID = r.figures.anchor(n)
+	}
+
+	source := attrs.Values["src"]
+	var in io.Reader
+	if source != "" {
+		f, err := r.p.OpenFile(source)
+		if err != nil {
+			// The caption is still written, so the table keeps its number and anchor
+			r.p.Warnf("data table %s: %v", source, err)
+			r.p.BeginTable(table).Close()
+			return
+		}
+		defer f.Close()
+		in = f
+	} else {
+		source = fmt.Sprintf("line %d", position(r.src, n.Info.Segment.Start).Line)
+		in = strings.NewReader(blockText(n, r.src))
+	}
+
+	reader := csv.NewReader(in)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header := attrs.Values["header"] != "false"
+	var stream *pdf.TableStream
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			r.p.Warnf("data table %s: %v", source, err)
+			break
+		}
+		if stream == nil {
+			if header {
+				table.Header = record
+			}
+			table.Truncate = r.truncateColumns(attrs.Values["truncate"], table.Header)
+			stream = r.p.BeginTable(table)
+			if header {
+				continue
+			}
+		}
+		stream.WriteRow(record)
+		if r.opts.TableProgress != nil && stream.Rows()%tableProgressRows == 0 {
+			r.opts.TableProgress(source, stream.Rows())
+		}
+	}
+	if stream == nil {
+		stream = r.p.BeginTable(table)
+	}
+	stream.Close()
+	if r.opts.TableProgress != nil && stream.Rows()%tableProgressRows != 0 {
+		r.opts.TableProgress(source, stream.Rows())
+	}
+}
@@ -0,0 +1,135 @@
+package markdown
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// Capabilities is the feature matrix of an output format: the effect on each feature
+// it can't fully reproduce. Features not listed are supported.
+type Capabilities map[string]string
+
+// Features of documents whose support differs between output formats. The HTML tag
+// feature stands for tags outside the subset the renderer understands.
+const (
+	featureHTMLTag       = "HTML tag"
+	featureStrikethrough = "strikethrough"
+	featureTaskCheckBox  = "task list checkbox"
+	featureInlineImage   = "inline image"
+	featureNestedList    = "nested list"
+	featureBlockquote    = "blockquote"
+	featureTable         = "table"
+	featureCallout       = "callout"
+	featureDiagram       = "diagram"
+	featureMath          = "math"
+	featureCriticMarkup  = "CriticMarkup"
+)
+
+// formats holds the feature matrix of each output format. A new backend adds its
+// matrix here, so its degradations are reported by check before anything is rendered.
+var formats = map[string]Capabilities{
+	"pdf": {
+		featureHTMLTag:       "ignored, content kept",
+		featureStrikethrough: "rendered as plain text",
+		featureTaskCheckBox:  "dropped",
+		featureInlineImage:   "rendered as its alt text",
+		featureNestedList:    "flattened into the parent item",
+		featureBlockquote:    "rendered as plain paragraphs",
+	},
+}
+
+// FormatCapabilities returns the feature matrix of an output format
+func FormatCapabilities(format string) (Capabilities, bool) {
+	caps, ok := formats[format]
+	return caps, ok
+}
+
+// Formats lists the output formats with a feature matrix
+func Formats() []string {
+	return slices.Sorted(maps.Keys(formats))
+}
+
+// Degradation is a construct an output format renders in a reduced form or drops
+type Degradation struct {
+	// Construct names the Markdown construct, e.g. "inline HTML"
+	Construct string
+	// Effect describes what happens to it, e.g. "dropped"
+	Effect string
+	// File is the input file of multi-file documents, empty otherwise
+	File string
+	// Line is the 1-based source line, 0 if unknown
+	Line int
+}
+
+// FindDegradations lists the constructs of a document an output format with the given
+// capabilities can't reproduce
+func FindDegradations(doc ast.Node, src []byte, caps Capabilities) []Degradation {
+	var found []Degradation
+	add := func(n ast.Node, feature, construct string) {
+		effect, ok := caps[feature]
+		if !ok {
+			return
+		}
+		d := Degradation{Construct: construct, Effect: effect}
+		// Nodes without source segments, like task checkboxes, take the line of their parent
+		for p := n; p != nil; p = p.Parent() {
+			if start, _, ok := nodeRange(p); ok {
+				d.Line = position(src, start).Line
+				break
+			}
+		}
+		found = append(found, d)
+	}
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.HTMLBlock:
+			for _, name := range unsupportedHTMLTags(htmlBlockSource(node, src)) {
+				add(node, featureHTMLTag, "HTML tag <"+name+">")
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.RawHTML:
+			for _, name := range unsupportedHTMLTags(rawHTMLSource(node, src)) {
+				add(node, featureHTMLTag, "HTML tag <"+name+">")
+			}
+		case *east.Strikethrough:
+			add(node, featureStrikethrough, featureStrikethrough)
+		case *east.TaskCheckBox:
+			add(node, featureTaskCheckBox, featureTaskCheckBox)
+		case *ast.Image:
+			if p, ok := node.Parent().(*ast.Paragraph); !ok || paragraphImages(p, src) == nil {
+				add(node, featureInlineImage, featureInlineImage)
+			}
+		case *ast.List:
+			// Nested lists are reported once, with the list they are flattened into
+			if _, ok := node.Parent().(*ast.ListItem); ok && caps[featureNestedList] != "" {
+				add(node, featureNestedList, featureNestedList)
+				return ast.WalkSkipChildren, nil
+			}
+		case *ast.Blockquote:
+			if _, _, ok := parseCallout(node, src); ok {
+				add(node, featureCallout, featureCallout)
+			} else {
+				add(node, featureBlockquote, featureBlockquote)
+			}
+		case *east.Table:
+			add(node, featureTable, featureTable)
+		case *ast.FencedCodeBlock:
+			if language, _ := fenceInfo(node, src); diagramKinds[language] {
+				add(node, featureDiagram, language+" diagram")
+			}
+		case *Math:
+			add(node, featureMath, featureMath)
+		case *CriticMarkup:
+			add(node, featureCriticMarkup, featureCriticMarkup)
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
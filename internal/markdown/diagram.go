@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"report/internal/diagram"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// diagramKinds are the fence languages rendered as diagrams
+var diagramKinds = map[string]bool{"mermaid": true, "plantuml": true}
+
+// Diagrams returns the diagrams of a document's diagram fences, ```mermaid and ```plantuml
+func Diagrams(root ast.Node, src []byte) []diagram.Diagram {
+	var diagrams []diagram.Diagram
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fence, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if language, _ := fenceInfo(fence, src); diagramKinds[language] {
+				diagrams = append(diagrams, diagram.Diagram{Kind: language, Source: blockText(fence, src)})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return diagrams
+}
+
+// diagram renders a diagram fence as its image, scaled to the content width unless
+// the fence sets a width or height, with the caption below it. It reports false if
+// the diagram wasn't rendered, so the source is shown as code instead.
+func (r *renderer) diagram(d diagram.Diagram, attrs Attributes, caption string) bool {
+	data, ok := r.opts.Diagrams[d]
+	if !ok {
+		return false
+	}
+
+	opts := imageOptions(attrs)
+	opts.Caption = caption
+	opts.Alt = cmp.Or(attrs.Values["alt"], caption, d.Kind+" diagram")
+	if opts.Width == "" && opts.Height == "" {
+		opts.Width = "100%"
+	}
+	sum := sha256.Sum256([]byte(d.Kind + "\x00" + d.Source))
+	if err := r.p.WriteImageData("diagram-"+hex.EncodeToString(sum[:8]), data, opts); err != nil {
+		r.p.Warnf("%s diagram: %v", d.Kind, err)
+		return false
+	}
+	return true
+}
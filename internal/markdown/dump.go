@@ -0,0 +1,229 @@
+package markdown
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// Position is a location in the Markdown source
+type Position struct {
+	// Offset is the 0-based byte offset
+	Offset int `json:"offset"`
+	// Line and Column are 1-based; the column counts bytes
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// NodeDump is the serializable form of an AST node, for debugging
+type NodeDump struct {
+	Kind     string            `json:"kind"`
+	Start    *Position         `json:"start,omitempty"`
+	End      *Position         `json:"end,omitempty"`
+	Props    map[string]string `json:"props,omitempty"`
+	Children []*NodeDump       `json:"children,omitempty"`
+}
+
+// DumpAST converts the tree below n to its serializable form, with positions in src
+func DumpAST(n ast.Node, src []byte) *NodeDump {
+	d := &NodeDump{Kind: n.Kind().String(), Props: nodeProps(n, src)}
+	if start, end, ok := nodeRange(n); ok {
+		d.Start = position(src, start)
+		d.End = position(src, end)
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		d.Children = append(d.Children, DumpAST(child, src))
+	}
+	return d
+}
+
+// nodeRange returns the byte range of a node in the source. Container blocks
+// without lines of their own span their children.
+func nodeRange(n ast.Node) (start, stop int, ok bool) {
+	switch node := n.(type) {
+	case *ast.Text:
+		return node.Segment.Start, node.Segment.Stop, true
+	case *ast.RawHTML:
+		if node.Segments.Len() > 0 {
+			return node.Segments.At(0).Start, node.Segments.At(node.Segments.Len() - 1).Stop, true
+		}
+		return 0, 0, false
+	case *Math:
+		return node.Segment.Start, node.Segment.Stop, true
+	case *CriticMarkup:
+		return node.Segment.Start, node.Segment.Stop, true
+	case *ast.String, *ast.Document:
+		return 0, 0, false
+	}
+	if n.Type() == ast.TypeBlock {
+		if lines := n.Lines(); lines != nil && lines.Len() > 0 {
+			return lines.At(0).Start, lines.At(lines.Len() - 1).Stop, true
+		}
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if s, e, found := nodeRange(child); found {
+			if !ok {
+				start, stop, ok = s, e, true
+			}
+			start, stop = min(start, s), max(stop, e)
+		}
+	}
+	return start, stop, ok
+}
+
+// position converts a byte offset to a line and column
+func position(src []byte, offset int) *Position {
+	offset = min(offset, len(src))
+	line := bytes.Count(src[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(src[:offset], '\n')
+	return &Position{Offset: offset, Line: line, Column: column}
+}
+
+// nodeProps returns the kind-specific properties of a node
+func nodeProps(n ast.Node, src []byte) map[string]string {
+	props := map[string]string{}
+	switch node := n.(type) {
+	case *ast.Heading:
+		props["level"] = strconv.Itoa(node.Level)
+	case *ast.Text:
+		props["text"] = string(node.Segment.Value(src))
+		if node.SoftLineBreak() {
+			props["softLineBreak"] = "true"
+		}
+		if node.HardLineBreak() {
+			props["hardLineBreak"] = "true"
+		}
+	case *ast.String:
+		props["text"] = string(node.Value)
+	case *ast.Emphasis:
+		props["level"] = strconv.Itoa(node.Level)
+	case *ast.Link:
+		props["destination"] = string(node.Destination)
+		if len(node.Title) > 0 {
+			props["title"] = string(node.Title)
+		}
+	case *ast.Image:
+		props["destination"] = string(node.Destination)
+		if len(node.Title) > 0 {
+			props["title"] = string(node.Title)
+		}
+	case *ast.AutoLink:
+		props["url"] = string(node.URL(src))
+	case *ast.FencedCodeBlock:
+		if lang := node.Language(src); lang != nil {
+			props["language"] = string(lang)
+		}
+	case *ast.List:
+		props["ordered"] = strconv.FormatBool(node.IsOrdered())
+		props["marker"] = string(node.Marker)
+		props["tight"] = strconv.FormatBool(node.IsTight)
+		if node.IsOrdered() {
+			props["start"] = strconv.Itoa(node.Start)
+		}
+	case *east.TableCell:
+		props["align"] = node.Alignment.String()
+	case *east.TaskCheckBox:
+		props["checked"] = strconv.FormatBool(node.IsChecked)
+	case *Math:
+		props["formula"] = node.Formula.Source
+		props["display"] = strconv.FormatBool(node.Formula.Display)
+	case *CriticMarkup:
+		props["op"] = node.Op.String()
+		props["value"] = node.Value
+		if node.Op == CriticSubstitution {
+			props["new"] = node.New
+		}
+	}
+
+	if n.Type() == ast.TypeBlock && n.Kind() != ast.KindDocument {
+		if lines := n.Lines(); lines != nil && lines.Len() > 0 {
+			var text strings.Builder
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				text.Write(segment.Value(src))
+			}
+			props["lines"] = text.String()
+		}
+	}
+	if raw, ok := n.(*ast.RawHTML); ok {
+		var text strings.Builder
+		for i := 0; i < raw.Segments.Len(); i++ {
+			segment := raw.Segments.At(i)
+			text.Write(segment.Value(src))
+		}
+		props["html"] = text.String()
+	}
+
+	for _, attr := range n.Attributes() {
+		props["attr."+string(attr.Name)] = fmt.Sprint(attributeValue(attr.Value))
+	}
+
+	if len(props) == 0 {
+		return nil
+	}
+	return props
+}
+
+// attributeValue converts a parsed attribute value to a printable value
+func attributeValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// WriteJSON writes the dump as indented JSON
+func (d *NodeDump) WriteJSON(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteYAML writes the dump as YAML. Strings are double-quoted, so they are
+// safe whatever they contain.
+func (d *NodeDump) WriteYAML(out io.Writer) error {
+	var b strings.Builder
+	d.writeYAML(&b, "")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// writeYAML writes the fields of the node as a mapping, the first line prefixed
+// by the caller (e.g. with a list dash) and the following ones indented
+func (d *NodeDump) writeYAML(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "kind: %s\n", d.Kind)
+	writePos := func(name string, p *Position) {
+		if p != nil {
+			fmt.Fprintf(b, "%s%s: {offset: %d, line: %d, column: %d}\n", indent, name, p.Offset, p.Line, p.Column)
+		}
+	}
+	writePos("start", d.Start)
+	writePos("end", d.End)
+
+	if len(d.Props) > 0 {
+		keys := make([]string, 0, len(d.Props))
+		for key := range d.Props {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(b, "%sprops:\n", indent)
+		for _, key := range keys {
+			fmt.Fprintf(b, "%s  %s: %s\n", indent, key, strconv.Quote(d.Props[key]))
+		}
+	}
+
+	if len(d.Children) > 0 {
+		fmt.Fprintf(b, "%schildren:\n", indent)
+		for _, child := range d.Children {
+			fmt.Fprintf(b, "%s  - ", indent)
+			child.writeYAML(b, indent+"    ")
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package markdown
+
+import (
+	"bytes"
+	"cmp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// emojiShortcodes are the GitHub emoji shortcodes most used in reports and release
+// notes, with their emoji. Only emoji of the Basic Multilingual Plane replace their
+// shortcode; gofpdf can't set the others, which are written as their shortcode instead.
+var emojiShortcodes = map[string]string{
+	"white_check_mark":            "✅",
+	"heavy_check_mark":            "✔",
+	"ballot_box_with_check":       "☑",
+	"x":                           "❌",
+	"negative_squared_cross_mark": "❎",
+	"heavy_multiplication_x":      "✖",
+	"warning":                     "⚠",
+	"no_entry":                    "⛔",
+	"no_entry_sign":               "🚫",
+	"construction":                "🚧",
+	"question":                    "❓",
+	"grey_question":               "❔",
+	"exclamation":                 "❗",
+	"heavy_exclamation_mark":      "❗",
+	"bangbang":                    "‼",
+	"information_source":          "ℹ",
+	"red_circle":                  "🔴",
+	"orange_circle":               "🟠",
+	"yellow_circle":               "🟡",
+	"green_circle":                "🟢",
+	"large_blue_circle":           "🔵",
+	"blue_circle":                 "🔵",
+	"white_circle":                "⚪",
+	"black_circle":                "⚫",
+	"red_square":                  "🟥",
+	"green_square":                "🟩",
+	"yellow_square":               "🟨",
+	"star":                        "⭐",
+	"star2":                       "🌟",
+	"sparkles":                    "✨",
+	"zap":                         "⚡",
+	"fire":                        "🔥",
+	"boom":                        "💥",
+	"100":                         "💯",
+	"tada":                        "🎉",
+	"trophy":                      "🏆",
+	"medal_sports":                "🏅",
+	"dart":                        "🎯",
+	"rocket":                      "🚀",
+	"checkered_flag":              "🏁",
+	"triangular_flag_on_post":     "🚩",
+	"bell":                        "🔔",
+	"no_bell":                     "🔕",
+	"pushpin":                     "📌",
+	"round_pushpin":               "📍",
+	"bookmark":                    "🔖",
+	"label":                       "🏷",
+	"link":                        "🔗",
+	"lock":                        "🔒",
+	"unlock":                      "🔓",
+	"key":                         "🔑",
+	"shield":                      "🛡",
+	"lady_beetle":                 "🐞",
+	"bug":                         "🐛",
+	"wrench":                      "🔧",
+	"hammer":                      "🔨",
+	"hammer_and_wrench":           "🛠",
+	"gear":                        "⚙",
+	"mag":                         "🔍",
+	"mag_right":                   "🔎",
+	"bulb":                        "💡",
+	"memo":                        "📝",
+	"pencil":                      "📝",
+	"pencil2":                     "✏",
+	"page_facing_up":              "📄",
+	"clipboard":                   "📋",
+	"file_folder":                 "📁",
+	"open_file_folder":            "📂",
+	"books":                       "📚",
+	"book":                        "📖",
+	"package":                     "📦",
+	"inbox_tray":                  "📥",
+	"outbox_tray":                 "📤",
+	"email":                       "📧",
+	"envelope":                    "✉",
+	"calendar":                    "📆",
+	"date":                        "📅",
+	"hourglass":                   "⌛",
+	"hourglass_flowing_sand":      "⏳",
+	"alarm_clock":                 "⏰",
+	"stopwatch":                   "⏱",
+	"chart_with_upwards_trend":    "📈",
+	"chart_with_downwards_trend":  "📉",
+	"bar_chart":                   "📊",
+	"moneybag":                    "💰",
+	"dollar":                      "💵",
+	"euro":                        "💶",
+	"computer":                    "💻",
+	"desktop_computer":            "🖥",
+	"iphone":                      "📱",
+	"globe_with_meridians":        "🌐",
+	"earth_africa":                "🌍",
+	"earth_americas":              "🌎",
+	"earth_asia":                  "🌏",
+	"cloud":                       "☁",
+	"sunny":                       "☀",
+	"umbrella":                    "☔",
+	"snowflake":                   "❄",
+	"recycle":                     "♻",
+	"arrows_counterclockwise":     "🔄",
+	"repeat":                      "🔁",
+	"arrow_right":                 "➡",
+	"arrow_left":                  "⬅",
+	"arrow_up":                    "⬆",
+	"arrow_down":                  "⬇",
+	"arrow_upper_right":           "↗",
+	"arrow_lower_right":           "↘",
+	"heavy_plus_sign":             "➕",
+	"heavy_minus_sign":            "➖",
+	"new":                         "🆕",
+	"up":                          "🆙",
+	"ok":                          "🆗",
+	"free":                        "🆓",
+	"sos":                         "🆘",
+	"speech_balloon":              "💬",
+	"thought_balloon":             "💭",
+	"eyes":                        "👀",
+	"brain":                       "🧠",
+	"handshake":                   "🤝",
+	"wave":                        "👋",
+	"clap":                        "👏",
+	"raised_hands":                "🙌",
+	"pray":                        "🙏",
+	"muscle":                      "💪",
+	"point_right":                 "👉",
+	"point_left":                  "👈",
+	"point_up":                    "☝",
+	"point_down":                  "👇",
+	"+1":                          "👍",
+	"thumbsup":                    "👍",
+	"-1":                          "👎",
+	"thumbsdown":                  "👎",
+	"ok_hand":                     "👌",
+	"v":                           "✌",
+	"heart":                       "❤",
+	"broken_heart":                "💔",
+	"green_heart":                 "💚",
+	"blue_heart":                  "💙",
+	"smile":                       "😄",
+	"smiley":                      "😃",
+	"grinning":                    "😀",
+	"laughing":                    "😆",
+	"joy":                         "😂",
+	"wink":                        "😉",
+	"blush":                       "😊",
+	"slightly_smiling_face":       "🙂",
+	"neutral_face":                "😐",
+	"thinking":                    "🤔",
+	"confused":                    "😕",
+	"worried":                     "😟",
+	"cry":                         "😢",
+	"sob":                         "😭",
+	"scream":                      "😱",
+	"sweat_smile":                 "😅",
+	"sunglasses":                  "😎",
+	"nerd_face":                   "🤓",
+	"rage":                        "😡",
+	"skull":                       "💀",
+	"robot":                       "🤖",
+	"ghost":                       "👻",
+	"see_no_evil":                 "🙈",
+	"coffee":                      "☕",
+	"beer":                        "🍺",
+	"cake":                        "🍰",
+	"gift":                        "🎁",
+	"balloon":                     "🎈",
+	"art":                         "🎨",
+	"lipstick":                    "💄",
+	"truck":                       "🚚",
+	"ambulance":                   "🚑",
+	"rotating_light":              "🚨",
+	"vertical_traffic_light":      "🚦",
+	"triangular_ruler":            "📐",
+	"straight_ruler":              "📏",
+	"microscope":                  "🔬",
+	"test_tube":                   "🧪",
+	"alembic":                     "⚗",
+	"heavy_dollar_sign":           "💲",
+	"copyright":                   "©",
+	"registered":                  "®",
+	"tm":                          "™",
+}
+
+// maxShortcode is the length of the longest shortcode, limiting the search for the
+// closing colon
+var maxShortcode = func() int {
+	longest := 0
+	for name := range emojiShortcodes {
+		longest = max(longest, len(name))
+	}
+	return longest
+}()
+
+// emojiNames are the shortcodes of the emoji in emojiShortcodes, the longest of
+// several for one emoji
+var emojiNames = func() map[rune]string {
+	names := map[rune]string{}
+	for name, emoji := range emojiShortcodes {
+		r := []rune(emoji)[0]
+		if old, ok := names[r]; !ok || cmp.Or(cmp.Compare(len(name), len(old)), strings.Compare(old, name)) > 0 {
+			names[r] = name
+		}
+	}
+	return names
+}()
+
+// ReplaceAstral replaces the characters beyond the Basic Multilingual Plane, which
+// gofpdf can't set: emoji with their shortcode, flags with their country code and
+// other characters with U+FFFD. Skin tone modifiers are left out.
+func ReplaceAstral(s string) string {
+	if !strings.ContainsFunc(s, func(r rune) bool { return r > 0xFFFF }) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r <= 0xFFFF:
+			b.WriteRune(r)
+		case r >= 0x1F3FB && r <= 0x1F3FF:
+		case r >= 0x1F1E6 && r <= 0x1F1FF:
+			// Regional indicators pair up as a country code
+			b.WriteRune('A' + r - 0x1F1E6)
+		case emojiNames[r] != "":
+			b.WriteString(":" + emojiNames[r] + ":")
+		default:
+			b.WriteRune('\uFFFD')
+		}
+	}
+	return b.String()
+}
+
+// emojiParser replaces emoji shortcodes like `:warning:` with the emoji. Unknown names
+// and emoji beyond the Basic Multilingual Plane are left as they are, so times like
+// 10:30:00 are kept.
+type emojiParser struct{}
+
+func (emojiParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (emojiParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	end := bytes.IndexByte(line[1:min(len(line), maxShortcode+2)], ':')
+	if end < 1 {
+		return nil
+	}
+	emoji, ok := emojiShortcodes[string(line[1:end+1])]
+	if !ok || []rune(emoji)[0] > 0xFFFF {
+		return nil
+	}
+	block.Advance(end + 2)
+	return ast.NewString([]byte(emoji))
+}
+
+// emojiExtension adds the emoji shortcodes to the parser
+type emojiExtension struct{}
+
+func (emojiExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(emojiParser{}, 999)))
+}
@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// fenceInfo returns the language and the attributes of a fenced code block,
+// e.g. ```go {linenos=true} or Pandoc's ```{.python .numberLines startFrom=10}
+// where the first class is the language.
+func fenceInfo(n *ast.FencedCodeBlock, src []byte) (string, Attributes) {
+	if n.Info == nil {
+		return "", Attributes{}
+	}
+	info := strings.TrimSpace(string(n.Info.Segment.Value(src)))
+
+	var attrs Attributes
+	if m := trailingAttrRegex.FindStringSubmatchIndex(info); m != nil {
+		attrs = parseAttributes(info[m[2]:m[3]])
+		info = strings.TrimSpace(info[:m[0]])
+	}
+
+	language, _, _ := strings.Cut(info, " ")
+	if language == "" && len(attrs.Classes) > 0 {
+		language = attrs.Classes[0]
+	}
+	return language, attrs
+}
+
+// blockText returns the content of a code block
+func blockText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		b.Write(segment.Value(src))
+	}
+	return b.String()
+}
+
+// codeText returns the content of a code block with its HTML entities decoded if the
+// options or the block's decode-entities attribute ask for it, warning when that
+// changes the code
+func (r *renderer) codeText(n ast.Node, code string, attrs Attributes) string {
+	decode := r.opts.DecodeCodeEntities
+	if v, ok := attrs.Values["decode-entities"]; ok {
+		decode = v != "false"
+	}
+	if !decode || !strings.Contains(code, "&") {
+		return code
+	}
+	decoded := html.UnescapeString(code)
+	if decoded == code {
+		return code
+	}
+	if start, _, ok := nodeRange(n); ok {
+		r.p.Warnf("line %d: decoded HTML entities in code block", position(r.src, start).Line)
+	} else {
+		r.p.Warnf("decoded HTML entities in code block")
+	}
+	return decoded
+}
+
+// codeOptions combines the global code options with the attributes of a code block.
+// linenos accepts true/false as well as Hugo's table and inline; Pandoc's
+// .numberLines and startFrom work as well.
+func (r *renderer) codeOptions(attrs Attributes) pdf.CodeOptions {
+	opts := pdf.CodeOptions{LineNumbers: r.opts.LineNumbers, ID: attrs.ID}
+	if attrs.HasClass("numberLines") || attrs.HasClass("number-lines") {
+		opts.LineNumbers = true
+	}
+	if v, ok := attrs.Values["linenos"]; ok {
+		opts.LineNumbers = v != "false"
+	}
+	for _, key := range []string{"linenostart", "startFrom"} {
+		if v, err := strconv.Atoi(attrs.Values[key]); err == nil {
+			opts.FirstLine = v
+		}
+	}
+	return opts
+}
@@ -0,0 +1,160 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"report/internal/diagram"
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// tableCaptionRegex matches Pandoc-style table captions like `Table: Open ports {#ports}`
+var tableCaptionRegex = regexp.MustCompile(`^(?:Table)?:\s+`)
+
+// figureNumbers holds the labels of numbered figures and tables, like "Figure 2"
+type figureNumbers struct {
+	// labels are keyed by image, diagram fence or table
+	labels map[ast.Node]string
+	// anchors are the IDs of figures and tables, "fig:2" or "tbl:2" unless set in the document
+	anchors map[ast.Node]string
+	// ids maps the anchors of figures and tables to their labels, for references
+	ids map[string]string
+	// figures and tables are the entries of the lists of figures and tables
+	figures, tables []pdf.TOCEntry
+}
+
+// numberFigures numbers the block images, rendered diagrams and tables of the parts in
+// document order. It visits blocks the way the renderer does, so images in list items,
+// which are rendered inline, don't count. Labels format their number with format.
+func numberFigures(parts []Part, diagrams map[diagram.Diagram][]byte, format func(int) string) *figureNumbers {
+	numbers := &figureNumbers{labels: map[ast.Node]string{}, anchors: map[ast.Node]string{}, ids: map[string]string{}}
+	add := func(n ast.Node, id, kind, caption string) {
+		list := &numbers.figures
+		prefix := "fig"
+		if kind == "Table" {
+			list, prefix = &numbers.tables, "tbl"
+		}
+		number := len(*list) + 1
+		label := kind + " " + format(number)
+		if id == "" {
+			id = fmt.Sprintf("%s:%d", prefix, number)
+		}
+		numbers.labels[n] = label
+		numbers.anchors[n] = id
+		numbers.ids[id] = label
+		*list = append(*list, pdf.TOCEntry{Level: 1, Text: numbers.caption(n, caption), ID: id})
+	}
+
+	var visit func(n ast.Node, src []byte)
+	visit = func(n ast.Node, src []byte) {
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			switch node := child.(type) {
+			case *ast.Paragraph:
+				for _, block := range paragraphImages(node, src) {
+					caption := block.attrs.Values["caption"]
+					if caption == "" {
+						caption = extractText(block.image, src)
+					}
+					add(block.image, block.attrs.ID, "Figure", caption)
+				}
+			case *ast.FencedCodeBlock:
+				if _, attrs, ok := dataTable(node, src); ok {
+					add(node, attrs.ID, "Table", attrs.Values["caption"])
+					continue
+				}
+				language, attrs := fenceInfo(node, src)
+				if _, ok := diagrams[diagram.Diagram{Kind: language, Source: blockText(node, src)}]; ok && diagramKinds[language] {
+					add(node, attrs.ID, "Figure", attrs.Values["caption"])
+				}
+			case *east.Table:
+				caption, attrs, _ := tableCaption(node, src)
+				add(node, attrs.ID, "Table", caption)
+			case *ast.Blockquote:
+				if _, _, ok := parseCallout(node, src); !ok {
+					visit(node, src)
+				}
+			case *ast.Heading, *ast.List, *ast.ListItem, *ast.CodeBlock, *ast.HTMLBlock:
+			default:
+				visit(child, src)
+			}
+		}
+	}
+	for _, part := range parts {
+		visit(part.Root, part.Src)
+	}
+	return numbers
+}
+
+// caption prefixes a caption with the label of a numbered figure or table: "Figure 2: Login form"
+func (f *figureNumbers) caption(n ast.Node, caption string) string {
+	if f == nil || f.labels[n] == "" {
+		return caption
+	}
+	if caption == "" {
+		return f.labels[n]
+	}
+	return f.labels[n] + ": " + caption
+}
+
+// anchor returns the ID of a numbered figure or table, "" if it isn't numbered
+func (f *figureNumbers) anchor(n ast.Node) string {
+	if f == nil {
+		return ""
+	}
+	return f.anchors[n]
+}
+
+// reference returns the text of a link to a numbered figure or table: links without
+// text or with just "Figure" or "Table", like `[Figure](#login)`, show its label
+func (f *figureNumbers) reference(link *ast.Link, src []byte) (string, bool) {
+	id, ok := strings.CutPrefix(string(link.Destination), "#")
+	if !ok || f == nil || f.ids[id] == "" {
+		return "", false
+	}
+	label := f.ids[id]
+	kind, _, _ := strings.Cut(label, " ")
+	if text := strings.TrimSpace(extractText(link, src)); text != "" && !strings.EqualFold(text, kind) {
+		return "", false
+	}
+	return label, true
+}
+
+// tableCaption returns the caption of a table from a Pandoc-style `Table: ...` paragraph
+// directly after or before it, with the attributes at its end, e.g. `{#ports}`
+func tableCaption(table *east.Table, src []byte) (string, Attributes, *ast.Paragraph) {
+	for _, sibling := range []ast.Node{table.NextSibling(), table.PreviousSibling()} {
+		para, ok := sibling.(*ast.Paragraph)
+		if !ok {
+			continue
+		}
+		text := strings.TrimSpace(extractText(para, src))
+		m := tableCaptionRegex.FindStringIndex(text)
+		if m == nil {
+			continue
+		}
+		text = text[m[1]:]
+		attrs := Attributes{Values: map[string]string{}}
+		if m := trailingAttrRegex.FindStringSubmatchIndex(text); m != nil {
+			attrs = parseAttributes(text[m[2]:m[3]])
+			text = strings.TrimSpace(text[:m[0]])
+		}
+		return text, attrs, para
+	}
+	return "", Attributes{}, nil
+}
+
+// isTableCaption reports whether a paragraph is the caption of an adjacent table
+func isTableCaption(para *ast.Paragraph, src []byte) bool {
+	for _, sibling := range []ast.Node{para.NextSibling(), para.PreviousSibling()} {
+		if table, ok := sibling.(*east.Table); ok {
+			if _, _, caption := tableCaption(table, src); caption == para {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Finding is a section of a document under a heading with a severity, like `### [High] SQL injection`
+type Finding struct {
+	// ID is the heading ID, the anchor of the section
+	ID       string
+	Severity string
+	Title    string
+	// Markdown is the source of the section below its heading
+	Markdown string
+	// EndID is the ID of the heading following the section, "" if the section ends the document
+	EndID string
+}
+
+// Findings returns the finding sections of the parts in document order. A section
+// runs to the next heading of the same or a higher level, or to the end of its part.
+func Findings(parts []Part) []Finding {
+	headings := make([][]*ast.Heading, len(parts))
+	for i, part := range parts {
+		for n := part.Root.FirstChild(); n != nil; n = n.NextSibling() {
+			if h, ok := n.(*ast.Heading); ok && h.Lines().Len() > 0 {
+				headings[i] = append(headings[i], h)
+			}
+		}
+	}
+
+	var findings []Finding
+	for i, part := range parts {
+		for j, h := range headings[i] {
+			text := extractText(h, part.Src)
+			matches := severityRegex.FindStringSubmatch(text)
+			if matches == nil {
+				continue
+			}
+			f := Finding{
+				ID:       attributeString(h, "id"),
+				Severity: strings.ToLower(matches[1]),
+				Title:    text[len(matches[0]):],
+			}
+
+			end := len(part.Src)
+			var next *ast.Heading
+			for _, h2 := range headings[i][j+1:] {
+				if h2.Level <= h.Level {
+					next = h2
+					end = lineStart(part.Src, h2.Lines().At(0).Start)
+					break
+				}
+			}
+			// The first heading of a later part follows a section ending its part
+			for k := i + 1; next == nil && k < len(parts); k++ {
+				if len(headings[k]) > 0 {
+					next = headings[k][0]
+				}
+			}
+			if next != nil {
+				f.EndID = attributeString(next, "id")
+			}
+
+			f.Markdown = strings.TrimSpace(string(part.Src[min(headingEnd(part.Src, h), end):end]))
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// headingEnd returns the offset after the line of a heading, and after the underline of a setext heading
+func headingEnd(src []byte, h *ast.Heading) int {
+	line := h.Lines().At(0)
+	end := lineEnd(src, line.Stop)
+	if !bytes.HasPrefix(bytes.TrimLeft(src[lineStart(src, line.Start):], " "), []byte("#")) {
+		end = lineEnd(src, end)
+	}
+	return end
+}
+
+// lineStart returns the offset of the start of the line containing pos
+func lineStart(src []byte, pos int) int {
+	return bytes.LastIndexByte(src[:pos], '\n') + 1
+}
+
+// lineEnd returns the offset after the line break ending the line containing pos
+func lineEnd(src []byte, pos int) int {
+	if i := bytes.IndexByte(src[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(src)
+}
@@ -0,0 +1,401 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// htmlTokenRegex matches HTML comments and start or end tags
+var htmlTokenRegex = regexp.MustCompile(`<!--[\s\S]*?-->|</?[A-Za-z][A-Za-z0-9]*(?:\s+[^<>]*?)?\s*/?>`)
+
+// htmlTagRegex splits a tag into its end marker, name and attributes
+var htmlTagRegex = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9]*)([^<>]*?)/?>$`)
+
+// htmlAttrRegex matches one attribute with an optional quoted or unquoted value
+var htmlAttrRegex = regexp.MustCompile(`([A-Za-z_:][-A-Za-z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+
+// pageBreakRegex matches a page break directive, optionally turning the following
+// pages sideways or back upright
+var pageBreakRegex = regexp.MustCompile(`(?i)^<!--\s*pagebreak(?:\s+(landscape|portrait))?\s*-->\s*$`)
+
+// columnsRegex matches a directive setting the body text in 1 to 3 columns
+var columnsRegex = regexp.MustCompile(`(?i)^<!--\s*columns\s+([1-3])\s*-->\s*$`)
+
+// multiColumnRegex matches a directive setting the body text in more than one column
+// anywhere in a source
+var multiColumnRegex = regexp.MustCompile(`(?im)^ {0,3}<!--\s*columns\s+[23]\s*-->\s*$`)
+
+// columnBreakRegex matches a column break directive
+var columnBreakRegex = regexp.MustCompile(`(?i)^<!--\s*columnbreak\s*-->\s*$`)
+
+// appendixRegex matches the directive starting the appendices, see headingNumbers
+var appendixRegex = regexp.MustCompile(`(?i)^<!--\s*appendix\s*-->\s*$`)
+
+// htmlWhitespaceRegex matches runs of whitespace, which HTML collapses to a single space
+var htmlWhitespaceRegex = regexp.MustCompile(`\s+`)
+
+// htmlTag is a parsed HTML start or end tag
+type htmlTag struct {
+	// Name is the lower-case tag name
+	Name  string
+	End   bool
+	Attrs map[string]string
+}
+
+// htmlToken is a run of text or a tag of an HTML fragment; comments are dropped
+type htmlToken struct {
+	Text string
+	Tag  *htmlTag
+}
+
+// parseHTMLTag parses a single start or end tag
+func parseHTMLTag(s string) (htmlTag, bool) {
+	m := htmlTagRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return htmlTag{}, false
+	}
+	tag := htmlTag{Name: strings.ToLower(m[2]), End: m[1] == "/", Attrs: map[string]string{}}
+	for _, a := range htmlAttrRegex.FindAllStringSubmatch(m[3], -1) {
+		tag.Attrs[strings.ToLower(a[1])] = html.UnescapeString(a[2] + a[3] + a[4])
+	}
+	return tag, true
+}
+
+// htmlTokens splits an HTML fragment into text and tags
+func htmlTokens(s string) []htmlToken {
+	var tokens []htmlToken
+	text := func(t string) {
+		if t != "" {
+			tokens = append(tokens, htmlToken{Text: html.UnescapeString(t)})
+		}
+	}
+
+	start := 0
+	for _, m := range htmlTokenRegex.FindAllStringIndex(s, -1) {
+		text(s[start:m[0]])
+		start = m[1]
+		if tag, ok := parseHTMLTag(s[m[0]:m[1]]); ok {
+			tokens = append(tokens, htmlToken{Tag: &tag})
+		}
+	}
+	text(s[start:])
+	return tokens
+}
+
+// htmlContainers are block tags rendered as paragraph breaks around their content
+var htmlContainers = map[string]bool{"p": true, "div": true, "center": true}
+
+// htmlStyle tracks the inline formatting of the open HTML tags
+type htmlStyle struct {
+	span pdf.Span
+	open []openHTMLTag
+}
+
+// openHTMLTag is an open formatting tag with the style in effect before it
+type openHTMLTag struct {
+	name string
+	prev pdf.Span
+}
+
+// apply updates the style for a tag and returns the text the tag stands for.
+// It reports false for start tags outside the supported subset, whose content
+// is kept as plain text.
+func (s *htmlStyle) apply(tag htmlTag) (string, bool) {
+	if tag.End {
+		for i := len(s.open) - 1; i >= 0; i-- {
+			if s.open[i].name == tag.Name {
+				s.span = s.open[i].prev
+				s.open = s.open[:i]
+				break
+			}
+		}
+		return "", true
+	}
+
+	next := s.span
+	switch tag.Name {
+	case "br":
+		return "\n", true
+	case "img":
+		// Images within text are rendered as their alt text, like Markdown inline images
+		return tag.Attrs["alt"], true
+	case "b", "strong":
+		next.Bold = true
+	case "i", "em":
+		next.Italic = true
+	case "u", "ins":
+		next.Underline = true
+	case "sub":
+		next.Sub, next.Sup = true, false
+	case "sup":
+		next.Sup, next.Sub = true, false
+	case "span":
+		// Transparent; tracked so its end tag doesn't close an enclosing tag
+	default:
+		return "", htmlContainers[tag.Name]
+	}
+	s.open = append(s.open, openHTMLTag{name: tag.Name, prev: s.span})
+	s.span = next
+	return "", true
+}
+
+// htmlBlockSource returns the source of an HTML block, including its closing line
+func htmlBlockSource(n *ast.HTMLBlock, src []byte) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		b.Write(segment.Value(src))
+	}
+	if n.HasClosure() {
+		b.Write(n.ClosureLine.Value(src))
+	}
+	return b.String()
+}
+
+// rawHTMLSource returns the source of an inline HTML node
+func rawHTMLSource(n *ast.RawHTML, src []byte) string {
+	var b strings.Builder
+	for i := 0; i < n.Segments.Len(); i++ {
+		segment := n.Segments.At(i)
+		b.Write(segment.Value(src))
+	}
+	return b.String()
+}
+
+// unsupportedHTMLTags returns the names of the start tags in an HTML fragment
+// that are outside the supported subset, in order of appearance
+func unsupportedHTMLTags(s string) []string {
+	var names []string
+	var style htmlStyle
+	inTable := false
+	for _, t := range htmlTokens(s) {
+		if t.Tag == nil {
+			continue
+		}
+		if t.Tag.Name == "table" {
+			inTable = !t.Tag.End
+			continue
+		}
+		if inTable && htmlTableTags[t.Tag.Name] {
+			continue
+		}
+		if _, ok := style.apply(*t.Tag); !ok {
+			names = append(names, t.Tag.Name)
+		}
+	}
+	return names
+}
+
+// htmlTableTags are the structural tags of an HTML table
+var htmlTableTags = map[string]bool{"thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true}
+
+// htmlTable converts the tokens of an HTML table to a table. The first row is
+// the header if it consists of <th> cells or is inside <thead>. Column alignment
+// comes from the align attribute or a text-align style of the first row's cells.
+func htmlTable(tokens []htmlToken) pdf.Table {
+	type cell struct {
+		text   strings.Builder
+		header bool
+	}
+	var rows [][]*cell
+	var align []string
+	inHead, headRow := false, -1
+
+	current := func() *cell {
+		if len(rows) == 0 || len(rows[len(rows)-1]) == 0 {
+			return nil
+		}
+		row := rows[len(rows)-1]
+		return row[len(row)-1]
+	}
+
+	for _, t := range tokens {
+		if t.Tag == nil {
+			if c := current(); c != nil {
+				c.text.WriteString(t.Text)
+			}
+			continue
+		}
+		tag := *t.Tag
+		switch {
+		case tag.Name == "thead":
+			inHead = !tag.End
+		case tag.Name == "tr" && !tag.End:
+			rows = append(rows, nil)
+			if inHead && headRow < 0 {
+				headRow = len(rows) - 1
+			}
+		case (tag.Name == "th" || tag.Name == "td") && !tag.End:
+			if len(rows) == 0 {
+				rows = append(rows, nil)
+			}
+			rows[len(rows)-1] = append(rows[len(rows)-1], &cell{header: tag.Name == "th"})
+			if len(rows) == 1 {
+				align = append(align, htmlCellAlign(tag))
+			}
+		case tag.Name == "br":
+			if c := current(); c != nil {
+				c.text.WriteString(" ")
+			}
+		}
+	}
+
+	var table pdf.Table
+	table.Align = align
+	for i, row := range rows {
+		cells := make([]string, len(row))
+		allHeader := len(row) > 0
+		for j, c := range row {
+			cells[j] = strings.TrimSpace(htmlWhitespaceRegex.ReplaceAllString(c.text.String(), " "))
+			allHeader = allHeader && c.header
+		}
+		if i == 0 && (headRow == 0 || allHeader) {
+			table.Header = cells
+		} else {
+			table.Rows = append(table.Rows, cells)
+		}
+	}
+	return table
+}
+
+// htmlCellAlign returns the table alignment ("L", "C" or "R") of a cell tag
+func htmlCellAlign(tag htmlTag) string {
+	value := strings.ToLower(tag.Attrs["align"])
+	if style := strings.ToLower(tag.Attrs["style"]); strings.Contains(style, "text-align") {
+		for _, decl := range strings.Split(style, ";") {
+			if key, val, ok := strings.Cut(decl, ":"); ok && strings.TrimSpace(key) == "text-align" {
+				value = strings.TrimSpace(val)
+			}
+		}
+	}
+	switch value {
+	case "center":
+		return "C"
+	case "right":
+		return "R"
+	default:
+		return "L"
+	}
+}
+
+// htmlImageOptions maps the attributes of an <img> tag to image options
+func htmlImageOptions(tag htmlTag) pdf.ImageOptions {
+	opts := pdf.ImageOptions{Width: tag.Attrs["width"], Height: tag.Attrs["height"], ID: tag.Attrs["id"]}
+	switch strings.ToLower(tag.Attrs["align"]) {
+	case "center", "middle":
+		opts.Align = "center"
+	case "right":
+		opts.Align = "right"
+	}
+	return opts
+}
+
+// HasColumns reports whether the source sets the body text in columns with a
+// `<!-- columns 2 -->` or `<!-- columns 3 -->` directive
+func HasColumns(src []byte) bool {
+	return multiColumnRegex.Match(src)
+}
+
+// htmlBlock renders an HTML block: `<!-- pagebreak -->` starts a new page,
+// `<!-- columns 2 -->` and `<!-- columnbreak -->` set the body text in columns, tables and
+// images become their PDF counterparts, text with inline formatting tags becomes paragraphs. Other tags are ignored with
+// their content kept, and reported if the options ask for it.
+func (r *renderer) htmlBlock(n *ast.HTMLBlock) {
+	source := htmlBlockSource(n, r.src)
+	if m := pageBreakRegex.FindStringSubmatch(strings.TrimSpace(source)); m != nil {
+		if m[1] != "" {
+			r.p.SetLandscape(strings.EqualFold(m[1], "landscape"))
+		}
+		r.p.WritePageBreak(false)
+		return
+	}
+	if m := columnsRegex.FindStringSubmatch(strings.TrimSpace(source)); m != nil {
+		r.p.SetColumns(int(m[1][0] - '0'))
+		return
+	}
+	if columnBreakRegex.MatchString(strings.TrimSpace(source)) {
+		r.p.WriteColumnBreak()
+		return
+	}
+	if appendixRegex.MatchString(strings.TrimSpace(source)) {
+		// Only numbering changes, see headingNumbers
+		return
+	}
+	tokens := htmlTokens(source)
+
+	var style htmlStyle
+	var spans []pdf.Span
+	align := ""
+	flush := func() {
+		if len(spans) > 0 {
+			spans[0].Text = strings.TrimLeft(spans[0].Text, " ")
+			spans[len(spans)-1].Text = strings.TrimRight(spans[len(spans)-1].Text, " ")
+		}
+		for i := range spans {
+			spans[i].Text = strings.ReplaceAll(strings.ReplaceAll(spans[i].Text, " \n", "\n"), "\n ", "\n")
+		}
+		r.p.WriteParagraph(spans)
+		spans = nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Tag == nil {
+			// Whitespace in HTML only separates words
+			appendSpan(&spans, style.span, htmlWhitespaceRegex.ReplaceAllString(t.Text, " "))
+			continue
+		}
+
+		tag := *t.Tag
+		switch {
+		case tag.Name == "table" && !tag.End:
+			flush()
+			end := i + 1
+			for end < len(tokens) && (tokens[end].Tag == nil || tokens[end].Tag.Name != "table" || !tokens[end].Tag.End) {
+				end++
+			}
+			r.p.WriteTable(htmlTable(tokens[i+1 : end]))
+			i = end
+		case tag.Name == "img":
+			flush()
+			if src := tag.Attrs["src"]; src != "" {
+				// Images without an alignment of their own follow their container, as in <p align="center">
+				if _, ok := tag.Attrs["align"]; !ok {
+					tag.Attrs["align"] = align
+				}
+				r.p.WriteImage(src, tag.Attrs["alt"], htmlImageOptions(tag))
+			}
+		case htmlContainers[tag.Name]:
+			flush()
+			align = ""
+			if !tag.End {
+				align = tag.Attrs["align"]
+			}
+		default:
+			text, ok := style.apply(tag)
+			if !ok {
+				r.unsupportedHTML(n, tag.Name)
+			}
+			appendSpan(&spans, style.span, text)
+		}
+	}
+	flush()
+}
+
+// unsupportedHTML reports a tag outside the supported subset if the options ask for it
+func (r *renderer) unsupportedHTML(n ast.Node, name string) {
+	if r.opts.UnsupportedHTML != "warn" {
+		return
+	}
+	if start, _, ok := nodeRange(n); ok {
+		r.p.Warnf("line %d: unsupported HTML tag <%s> ignored", position(r.src, start).Line, name)
+	} else {
+		r.p.Warnf("unsupported HTML tag <%s> ignored", name)
+	}
+}
@@ -0,0 +1,123 @@
+package markdown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// includeRegex matches an include directive on a line of its own:
+// `<!-- include: path -->` or `!include(path)`
+var includeRegex = regexp.MustCompile(`^ {0,3}(?:<!--\s*include:\s*(.+?)\s*-->|!include\(\s*(.+?)\s*\))\s*$`)
+
+// includeShiftRegex matches the heading shift following the path of an include
+// directive, like `!include(sections/intro.md shift=1)`
+var includeShiftRegex = regexp.MustCompile(`^(.+?)\s+shift=([+-]?\d)$`)
+
+// fenceRegex matches the opening or closing line of a fenced code block
+var fenceRegex = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// ExpandIncludes replaces include directives with the content of the referenced files,
+// recursively, with their headings moved by the levels of a `shift=N` after the path.
+// Paths are relative to the including file; those of the top-level content
+// are relative to dir. Name is the path of the top-level file, if any, for cycle detection
+// and error messages. Directives inside fenced code blocks are left alone.
+// Resolve maps each path to the file to read, and may reject it; nil resolves
+// paths without restrictions.
+func ExpandIncludes(content, name, dir string, resolve func(path, dir string) (string, error)) (string, error) {
+	if resolve == nil {
+		resolve = joinPath
+	}
+	var stack []string
+	if name != "" {
+		if abs, err := filepath.Abs(name); err == nil {
+			stack = append(stack, abs)
+		}
+	}
+	return expandIncludes(content, "", dir, stack, resolve)
+}
+
+// joinPath resolves a path relative to dir without restrictions
+func joinPath(path, dir string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// expandIncludes expands the includes of content. Errors are prefixed with
+// the name of the file, except for the top-level content named "".
+func expandIncludes(content, name, dir string, stack []string, resolve func(path, dir string) (string, error)) (string, error) {
+	var out strings.Builder
+	fence := ""
+
+	for i, line := range strings.SplitAfter(content, "\n") {
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1][0] == fence[0] && len(m[1]) >= len(fence) {
+				fence = ""
+			}
+		}
+
+		m := includeRegex.FindStringSubmatch(strings.TrimRight(line, "\n"))
+		if fence != "" || m == nil {
+			out.WriteString(line)
+			continue
+		}
+
+		target, shift := m[1]+m[2], 0
+		if s := includeShiftRegex.FindStringSubmatch(target); s != nil {
+			target = s[1]
+			shift, _ = strconv.Atoi(s[2])
+		}
+
+		var included string
+		path, err := resolve(target, dir)
+		if err != nil {
+			err = fmt.Errorf("include %s: %w", target, err)
+		} else {
+			included, err = includeFile(path, stack, resolve)
+		}
+		if err != nil {
+			if name != "" {
+				return "", fmt.Errorf("%s:%d: %w", name, i+1, err)
+			}
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		out.WriteString(ShiftHeadings(included, shift))
+	}
+
+	return out.String(), nil
+}
+
+// includeFile reads and expands an included file, refusing files already being included
+func includeFile(path string, stack []string, resolve func(path, dir string) (string, error)) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("include %s: %w", path, err)
+	}
+	for i, p := range stack {
+		if p == abs {
+			chain := append(append([]string{}, stack[i:]...), abs)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			return "", fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("include %s: %w", path, err)
+	}
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return expandIncludes(content, path, filepath.Dir(path), append(stack[:len(stack):len(stack)], abs), resolve)
+}
@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// Literals returns the byte ranges of src that template expansion must leave alone,
+// as their braces aren't template actions: fenced code blocks, code spans and
+// page references like `{{page-of: #id}}`, in order
+func Literals(src string) [][2]int {
+	var ranges [][2]int
+	fence, fenceStart := "", 0
+	text := 0 // start of the text outside fenced code blocks
+	pos := 0
+	for _, line := range strings.SplitAfter(src, "\n") {
+		start := pos
+		pos += len(line)
+		m := fenceRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if fence == "" {
+			ranges = append(ranges, inlineLiterals(src, text, start)...)
+			fence, fenceStart = m[1], start
+		} else if m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(line[len(m[0]):]) == "" {
+			ranges = append(ranges, [2]int{fenceStart, pos})
+			fence, text = "", pos
+		}
+	}
+	if fence != "" {
+		// An unclosed fence runs to the end of the document
+		return append(ranges, [2]int{fenceStart, len(src)})
+	}
+	return append(ranges, inlineLiterals(src, text, len(src))...)
+}
+
+// inlineLiterals returns the ranges of the code spans and page references in
+// src[start:end], which holds no fenced code blocks
+func inlineLiterals(src string, start, end int) [][2]int {
+	var ranges [][2]int
+	text := src[start:end]
+	spanStart := 0 // where the text after the last code span starts
+	addPageRefs := func(to int) {
+		for _, m := range pageRefRegex.FindAllStringIndex(text[spanStart:to], -1) {
+			ranges = append(ranges, [2]int{start + spanStart + m[0], start + spanStart + m[1]})
+		}
+	}
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		n := backticks(text[i:])
+		// The span ends at the next run of as many backticks, within the paragraph
+		paragraph := len(text)
+		if p := strings.Index(text[i:], "\n\n"); p >= 0 {
+			paragraph = i + p
+		}
+		closing := -1
+		for j := i + n; j < paragraph; {
+			if text[j] != '`' {
+				j++
+				continue
+			}
+			run := backticks(text[j:])
+			if run == n && j+run <= paragraph {
+				closing = j
+				break
+			}
+			j += run
+		}
+		if closing < 0 {
+			// Unmatched backticks are literal text
+			i += n
+			continue
+		}
+		addPageRefs(i)
+		ranges = append(ranges, [2]int{start + i, start + closing + n})
+		i = closing + n
+		spanStart = i
+	}
+	addPageRefs(len(text))
+	return ranges
+}
+
+// backticks returns the length of the run of backticks s starts with
+func backticks(s string) int {
+	n := 0
+	for n < len(s) && s[n] == '`' {
+		n++
+	}
+	return n
+}
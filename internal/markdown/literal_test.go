@@ -0,0 +1,38 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLiterals(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"plain text", "Hello {{client}}\n", nil},
+		{"code span", "Use `{{name}}` for {{client}}\n", []string{"`{{name}}`"}},
+		{"double backticks", "Run ``a ` {{b}}`` now\n", []string{"``a ` {{b}}``"}},
+		{"unmatched backtick", "It`s {{client}}\n", nil},
+		{"span ends at paragraph", "`open {{x}}\n\nclosed`\n", nil},
+		{"page reference", "See page {{page-of: #intro}}.\n", []string{"{{page-of: #intro}}"}},
+		{"page reference after span", "`x` {{ page-of: #a }}\n", []string{"`x`", "{{ page-of: #a }}"}},
+		{"fence", "Text\n```go\nfmt.Println(\"{{x}}\")\n```\nMore\n", []string{"```go\nfmt.Println(\"{{x}}\")\n```\n"}},
+		{"tilde fence with backticks", "~~~\n```\n{{x}}\n~~~\n", []string{"~~~\n```\n{{x}}\n~~~\n"}},
+		{"longer closing fence", "```\n{{x}}\n`````\n", []string{"```\n{{x}}\n`````\n"}},
+		{"unclosed fence", "```\n{{x}}\n", []string{"```\n{{x}}\n"}},
+		{"span before fence", "`a`\n```\nb\n```\n`c`", []string{"`a`", "```\nb\n```\n", "`c`"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range Literals(tt.src) {
+				got = append(got, tt.src[r[0]:r[1]])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"report/internal/latex"
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMath is the node kind of math formulas
+var KindMath = ast.NewNodeKind("Math")
+
+// Math is an inline `$...$` or display `$$...$$` formula
+type Math struct {
+	ast.BaseInline
+	Formula latex.Formula
+	// Segment spans the formula in the source, including its delimiters
+	Segment text.Segment
+}
+
+// Kind implements ast.Node
+func (n *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+// Dump implements ast.Node
+func (n *Math) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{"Formula": n.Formula.Source}, nil)
+}
+
+// delimiter returns the delimiter of the formula in the source
+func (n *Math) delimiter() string {
+	if n.Formula.Display {
+		return "$$"
+	}
+	return "$"
+}
+
+// mathParser parses formulas with Pandoc's rules: the content of an inline
+// formula may not start or end with a space, and its closing $ may not be
+// followed by a digit
+type mathParser struct{}
+
+func (mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	delim := "$"
+	if bytes.HasPrefix(line, []byte("$$")) {
+		delim = "$$"
+	} else if len(line) < 2 || util.IsSpace(line[1]) {
+		return nil
+	}
+
+	// Formulas may continue on the following lines of the paragraph
+	start := segment.Start
+	lineNo, position := block.Position()
+	block.Advance(len(delim))
+	for {
+		rest, segment := block.PeekLine()
+		if rest == nil {
+			break
+		}
+		i := closingMath(rest, delim)
+		if i == -2 {
+			break
+		}
+		if i >= 0 {
+			stop := segment.Start + i + len(delim)
+			source := strings.TrimSpace(string(block.Source()[start+len(delim) : stop-len(delim)]))
+			if source == "" {
+				break
+			}
+			block.Advance(i + len(delim))
+			return &Math{
+				Formula: latex.Formula{Source: source, Display: delim == "$$"},
+				Segment: text.NewSegment(start, stop),
+			}
+		}
+		block.AdvanceLine()
+	}
+	block.SetPosition(lineNo, position)
+	return nil
+}
+
+// closingMath returns the position of the closing delimiter in a line, -1 if the
+// line doesn't contain it and -2 if the formula can't be closed. An inline formula
+// ends at its next unescaped $, so "$5 to $10" isn't taken for one.
+func closingMath(line []byte, delim string) int {
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			// Escaped characters such as \$ belong to the formula
+			i++
+		case bytes.HasPrefix(line[i:], []byte(delim)):
+			if delim == "$" && (i == 0 || util.IsSpace(line[i-1]) || (i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9')) {
+				return -2
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// mathExtension adds `$...$` and `$$...$$` formulas to the parser
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(mathParser{}, 500)))
+}
+
+// Formulas returns the formulas of a document
+func Formulas(root ast.Node) []latex.Formula {
+	var formulas []latex.Formula
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if m, ok := n.(*Math); ok && entering {
+			formulas = append(formulas, m.Formula)
+		}
+		return ast.WalkContinue, nil
+	})
+	return formulas
+}
+
+// paragraphMath returns the formula of a paragraph consisting only of a display formula
+func paragraphMath(n *ast.Paragraph, src []byte) *Math {
+	var formula *Math
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch node := child.(type) {
+		case *Math:
+			if formula != nil || !node.Formula.Display {
+				return nil
+			}
+			formula = node
+		case *ast.Text:
+			if strings.TrimSpace(string(node.Segment.Value(src))) != "" {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return formula
+}
+
+// formulaImage returns the rendered image of a formula, if there is one
+func (r *renderer) formulaImage(f latex.Formula) (pdf.InlineImage, bool) {
+	img, ok := r.opts.Formulas[f]
+	if !ok || img.Height == 0 {
+		return pdf.InlineImage{}, false
+	}
+	// Formulas are set in LaTeX's font size, so their size in ems follows from the resolution
+	em := float64(latex.DPI) * latex.FontSize / 72
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%t\x00%s", f.Display, f.Source)))
+	return pdf.InlineImage{
+		Name:   "math-" + hex.EncodeToString(sum[:8]),
+		Data:   img.PNG,
+		Width:  float64(img.Width) / em,
+		Height: float64(img.Height) / em,
+		Depth:  float64(img.Depth) / em,
+	}, true
+}
+
+// unicodeSpans sets a formula as text with RenderOptions.UnicodeMath, returning false
+// if it isn't enabled or the formula is too complex
+func (r *renderer) unicodeSpans(f latex.Formula, style pdf.Span) ([]pdf.Span, bool) {
+	if !r.opts.UnicodeMath {
+		return nil, false
+	}
+	runs, err := latex.ToUnicode(f.Source)
+	if err != nil {
+		r.p.Warnf("formula %s shown as source: %v", f.Source, err)
+		return nil, false
+	}
+	var spans []pdf.Span
+	for _, run := range runs {
+		s := style
+		s.Italic = style.Italic || run.Italic
+		s.Sub, s.Sup = run.Sub, run.Sup
+		appendSpan(&spans, s, run.Text)
+	}
+	return spans, true
+}
+
+// mathSpan adds a formula to the spans as its image or Unicode text, or as its source
+// if it wasn't rendered
+func (r *renderer) mathSpan(n *Math, style pdf.Span, spans *[]pdf.Span) {
+	source := n.delimiter() + n.Formula.Source + n.delimiter()
+	img, ok := r.formulaImage(n.Formula)
+	if !ok {
+		if text, ok := r.unicodeSpans(n.Formula, style); ok {
+			*spans = append(*spans, text...)
+			return
+		}
+		style.Code = true
+		appendSpan(spans, style, source)
+		return
+	}
+	style.Text = source
+	style.Image = &img
+	*spans = append(*spans, style)
+}
+
+// displayMath renders a display formula centered as a block, as its image or Unicode
+// text, or its source as code
+func (r *renderer) displayMath(n *Math) {
+	img, ok := r.formulaImage(n.Formula)
+	if ok {
+		err := r.p.WriteImageData(img.Name, img.Data, pdf.ImageOptions{
+			Width: fmt.Sprintf("%.3fem", img.Width),
+			Align: "center",
+			Alt:   n.Formula.Source,
+		})
+		if err == nil {
+			return
+		}
+		r.p.Warnf("formula %s: %v", n.Formula.Source, err)
+	}
+	if text, ok := r.unicodeSpans(n.Formula, pdf.Span{}); ok {
+		r.p.WriteCenteredParagraph(text)
+		return
+	}
+	r.p.WriteHighlightedCode(n.Formula.Source+"\n", "latex", pdf.CodeOptions{})
+}
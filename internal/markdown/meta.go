@@ -0,0 +1,26 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// metadataRegex matches metadata variables like `__author__: Jane Doe`
+var metadataRegex = regexp.MustCompile(`__([A-Za-z0-9]+(?:_[A-Za-z0-9]+)*)__\s*:\s*(.+)`)
+
+// Metadata holds the `__name__: value` variables found in a document,
+// keyed by lower-case variable name
+type Metadata map[string]string
+
+// ExtractMetadata collects all metadata variables from the markdown content.
+// If a variable appears more than once, the first occurrence wins.
+func ExtractMetadata(content string) Metadata {
+	meta := Metadata{}
+	for _, matches := range metadataRegex.FindAllStringSubmatch(content, -1) {
+		key := strings.ToLower(matches[1])
+		if _, exists := meta[key]; !exists {
+			meta[key] = strings.TrimSpace(matches[2])
+		}
+	}
+	return meta
+}
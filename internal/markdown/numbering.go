@@ -0,0 +1,192 @@
+package markdown
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// headingShorthand handles Pandoc's `{-}` shorthand for unnumbered headings,
+// e.g. `## Preface {-}` or `## Preface {- #preface}`, which goldmark's
+// attribute syntax doesn't accept and leaves in the heading text
+type headingShorthand struct{}
+
+func (headingShorthand) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	src := reader.Source()
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		lines := heading.Lines()
+		if lines.Len() == 0 {
+			return ast.WalkSkipChildren, nil
+		}
+		line := lines.At(lines.Len() - 1)
+		m := trailingAttrRegex.FindSubmatchIndex(line.Value(src))
+		if m == nil {
+			return ast.WalkSkipChildren, nil
+		}
+		attrs := parseAttributes(string(line.Value(src)[m[2]:m[3]]))
+		if !attrs.HasClass("unnumbered") {
+			return ast.WalkSkipChildren, nil
+		}
+
+		// Cut the attribute block off the text
+		cut := line.Start + m[0]
+		for child := heading.LastChild(); child != nil; {
+			prev := child.PreviousSibling()
+			if t, ok := child.(*ast.Text); ok {
+				if t.Segment.Stop > cut {
+					segment := t.Segment.WithStop(max(cut, t.Segment.Start))
+					t.Segment = segment.TrimRightSpace(src)
+				}
+				if t.Segment.IsEmpty() {
+					heading.RemoveChild(heading, t)
+				}
+			}
+			child = prev
+		}
+
+		classes := attrs.Classes
+		if class := attributeString(heading, "class"); class != "" {
+			classes = append(strings.Fields(class), classes...)
+		}
+		heading.SetAttributeString("class", []byte(strings.Join(classes, " ")))
+		for key, value := range attrs.Values {
+			heading.SetAttributeString(key, []byte(value))
+		}
+		if attrs.ID != "" {
+			heading.SetAttributeString("id", []byte(attrs.ID))
+		} else {
+			// The automatic ID was made from the text including the attribute block
+			heading.SetAttributeString("id", pc.IDs().Generate([]byte(extractText(heading, src)), ast.KindHeading))
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// headingNumbers returns the section numbers of the headings of the parts, such as
+// "2.1", or "Appendix B" and "B.1" from the first top-level heading with the class
+// "appendix", or following an `<!-- appendix -->` directive, on. Headings marked unnumbered, with `{-}` or `{.unnumbered}`, get no number and
+// don't count. Numbering starts at level from, headings above it stay unnumbered; with
+// from 0, a level-1 heading that is the first and only one is the document title and
+// numbering starts at level 2, else at level 1. The levels of a number are joined with
+// separator, "." if empty.
+func headingNumbers(parts []Part, from int, separator string) map[*ast.Heading]string {
+	var headings []*ast.Heading
+	// appendices are the headings following an appendix directive
+	appendices := map[*ast.Heading]bool{}
+	directive := false
+	for _, part := range parts {
+		ast.Walk(part.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if !entering {
+				return ast.WalkContinue, nil
+			}
+			switch node := n.(type) {
+			case *ast.Heading:
+				headings = append(headings, node)
+				appendices[node] = directive
+				return ast.WalkSkipChildren, nil
+			case *ast.HTMLBlock:
+				if appendixRegex.MatchString(strings.TrimSpace(htmlBlockSource(node, part.Src))) {
+					directive = true
+				}
+				return ast.WalkSkipChildren, nil
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+
+	top, titles := max(from, 1), 0
+	for _, h := range headings {
+		if h.Level == 1 {
+			titles++
+		}
+	}
+	if from == 0 && titles == 1 && len(headings) > 0 && headings[0].Level == 1 {
+		top = 2
+		headings = headings[1:]
+	}
+	separator = cmp.Or(separator, ".")
+
+	numbers := map[*ast.Heading]string{}
+	var counters [7]int
+	appendix := false
+	for _, h := range headings {
+		attrs := nodeAttributes(h)
+		if h.Level < top || attrs.HasClass("unnumbered") {
+			continue
+		}
+		depth := h.Level - top
+		if depth == 0 && (attrs.HasClass("appendix") || appendices[h]) && !appendix {
+			appendix = true
+			counters[0] = 0
+		}
+		counters[depth]++
+		for i := depth + 1; i < len(counters); i++ {
+			counters[i] = 0
+		}
+
+		parts := make([]string, depth+1)
+		for i := range parts {
+			parts[i] = strconv.Itoa(counters[i])
+		}
+		if appendix {
+			parts[0] = appendixLetter(counters[0])
+			if depth == 0 {
+				numbers[h] = "Appendix " + parts[0]
+				continue
+			}
+		}
+		numbers[h] = strings.Join(parts, separator)
+	}
+	return numbers
+}
+
+// sectionLabels returns the labels of the numbered headings by their IDs for references,
+// like "Section 2.1" or "Appendix A"
+func sectionLabels(numbers map[*ast.Heading]string) map[string]string {
+	labels := map[string]string{}
+	for h, number := range numbers {
+		id := attributeString(h, "id")
+		if id == "" {
+			continue
+		}
+		if strings.HasPrefix(number, "Appendix ") {
+			labels[id] = number
+		} else {
+			labels[id] = "Section " + number
+		}
+	}
+	return labels
+}
+
+// sectionReference returns the text of a link to a numbered heading: links without
+// text or with just "Section" (or "Appendix" for an appendix), like `[Section](#scope)`,
+// show its number
+func (r *renderer) sectionReference(link *ast.Link, src []byte) (string, bool) {
+	id, ok := strings.CutPrefix(string(link.Destination), "#")
+	if !ok || r.sections[id] == "" {
+		return "", false
+	}
+	label := r.sections[id]
+	kind, _, _ := strings.Cut(label, " ")
+	if text := strings.TrimSpace(extractText(link, src)); text != "" && !strings.EqualFold(text, kind) {
+		return "", false
+	}
+	return label, true
+}
+
+// appendixLetter returns the letter of the nth appendix: A to Z, then AA, AB and so on
+func appendixLetter(n int) string {
+	letter := ""
+	for ; n > 0; n = (n - 1) / 26 {
+		letter = string(rune('A'+(n-1)%26)) + letter
+	}
+	return letter
+}
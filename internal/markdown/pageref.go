@@ -0,0 +1,45 @@
+package markdown
+
+import (
+	"regexp"
+
+	"report/internal/pdf"
+)
+
+// pageRefRegex matches inline page references like `{{page-of: #finding-f-03}}`
+var pageRefRegex = regexp.MustCompile(`\{\{\s*page-of:\s*#([^\s}]+)\s*\}\}`)
+
+// HasPageRefs reports whether the source contains inline page references
+func HasPageRefs(src []byte) bool {
+	return pageRefRegex.Match(src)
+}
+
+// splitPageRefs splits inline page references out of the text spans.
+// It runs on merged spans, as goldmark may split the text of a reference across nodes.
+func splitPageRefs(spans []pdf.Span) []pdf.Span {
+	var out []pdf.Span
+	for _, span := range spans {
+		if span.Code {
+			out = append(out, span)
+			continue
+		}
+		text, start := span.Text, 0
+		for _, m := range pageRefRegex.FindAllStringSubmatchIndex(text, -1) {
+			if m[0] > start {
+				before := span
+				before.Text = text[start:m[0]]
+				out = append(out, before)
+			}
+			ref := span
+			ref.Text = text[m[0]:m[1]]
+			ref.PageRef = text[m[2]:m[3]]
+			out = append(out, ref)
+			start = m[1]
+		}
+		if start < len(text) {
+			span.Text = text[start:]
+			out = append(out, span)
+		}
+	}
+	return out
+}
@@ -4,17 +4,36 @@ import (
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
-var md = goldmark.New(
-	goldmark.WithExtensions(
-		extension.GFM,
-	),
-)
+// Options controls optional parser extensions
+type Options struct {
+	// Typographer replaces straight quotes, dashes and ellipses with their typographic forms
+	Typographer bool
+	// Lang is the document language (e.g. "de", "fr-CH"), used for locale-specific quotation marks
+	Lang string
+}
+
+func ParseMarkdown(src []byte, opts Options) (ast.Node, error) {
+	extensions := []goldmark.Extender{extension.GFM, mathExtension{}, criticExtension{}, emojiExtension{}}
+	if opts.Typographer {
+		extensions = append(extensions, newTypographer(opts.Lang), unitSpaceExtension{})
+	}
+	md := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		// Heading attributes like `{#id toc=false}` and heading IDs as GitHub generates them
+		goldmark.WithParserOptions(
+			parser.WithAttribute(),
+			parser.WithAutoHeadingID(),
+			// Pandoc's `{-}` for unnumbered headings
+			parser.WithASTTransformers(util.Prioritized(headingShorthand{}, 100)),
+		),
+	)
 
-func ParseMarkdown(src []byte) (ast.Node, error) {
 	reader := text.NewReader(src)
-	doc := md.Parser().Parse(reader)
+	doc := md.Parser().Parse(reader, parser.WithContext(parser.NewContext(parser.WithIDs(newGFMIDs()))))
 	return doc, nil
 }
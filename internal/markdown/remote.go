@@ -0,0 +1,21 @@
+package markdown
+
+import (
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// RemoteImages returns the URLs of the images a document loads over the network
+func RemoteImages(root ast.Node) []string {
+	var urls []string
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering {
+			if dest := string(img.Destination); pdf.IsRemote(dest) {
+				urls = append(urls, dest)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return urls
+}
@@ -3,14 +3,173 @@ package markdown
 import (
 	"bytes"
 	"html"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
+	"report/internal/diagram"
+	"report/internal/latex"
 	"report/internal/pdf"
 
 	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
 )
 
-func RenderToPDF(n ast.Node, p *pdf.Writer, src []byte) error {
-	return walk(n, p, src)
+// RenderOptions controls generated content such as the table of contents
+type RenderOptions struct {
+	// TOC inserts a table of contents at the start of the document,
+	// unless a `[TOC]` paragraph places it elsewhere
+	TOC bool
+	// TOCDepth is the deepest heading level listed in the table of contents (default 3)
+	TOCDepth int
+	// TOCTitle is the title of the table of contents (default "Contents")
+	TOCTitle string
+	// ListOfFigures and ListOfTables insert a list of figures and a list of tables after
+	// the table of contents, unless `[LOF]` and `[LOT]` paragraphs place them elsewhere.
+	// They number figures and tables like NumberFigures.
+	ListOfFigures bool
+	ListOfTables  bool
+	// LineNumbers prints line numbers next to all code blocks,
+	// unless a block sets {linenos=false}
+	LineNumbers bool
+	// DecodeCodeEntities decodes HTML entities in code blocks, unless a block sets
+	// {decode-entities=false}; see codeText
+	DecodeCodeEntities bool
+	// PartBreak separates the parts of a multi-file document:
+	// "page" (default) starts each part on a new page, "odd" on a right-hand page
+	// and "none" continues on the same page
+	PartBreak string
+	// UnsupportedHTML is "warn" to report HTML tags outside the supported subset,
+	// which are otherwise ignored with their content kept
+	UnsupportedHTML string
+	// Draft shows CriticMarkup comments and changes; otherwise comments are left
+	// out and changes accepted
+	Draft bool
+	// NumberHeadings prefixes headings with section numbers, also in the table of
+	// contents, bookmarks and links referring to them; see headingNumbers
+	NumberHeadings bool
+	// NumberFrom is the heading level numbering starts at, 0 to start below a
+	// document title; NumberSeparator joins the levels, "." if empty
+	NumberFrom      int
+	NumberSeparator string
+	// NumberFigures labels block images and rendered diagrams "Figure 1", "Figure 2", ...
+	// and tables "Table 1", ... in their captions, and lets links refer to them by number;
+	// see numberFigures
+	NumberFigures bool
+	// TableProgress, if set, is called with the source and the number of rows written
+	// while streaming large data tables from `csv` and `tsv` fences
+	TableProgress func(source string, rows int)
+	// Diagrams holds the rendered PNG images of diagram fences;
+	// fences without an image are rendered as code
+	Diagrams map[diagram.Diagram][]byte
+	// Formulas holds the rendered images of `$...$` and `$$...$$` formulas;
+	// formulas without an image are shown as their source
+	Formulas map[latex.Formula]latex.Image
+	// UnicodeMath sets formulas without an image as text with Unicode characters,
+	// see latex.ToUnicode; those too complex for it are shown as their source
+	UnicodeMath bool
+}
+
+// Part is one parsed input file of a document
+type Part struct {
+	Root ast.Node
+	Src  []byte
+	// BaseDir is the directory relative image paths of the part are resolved against
+	BaseDir string
+}
+
+// renderer holds the state of one rendering pass
+type renderer struct {
+	p    *pdf.Writer
+	src  []byte
+	opts RenderOptions
+	toc  []pdf.TOCEntry
+	// numbers are the section numbers of the headings if they are numbered
+	numbers map[*ast.Heading]string
+	// sections are the labels of the numbered headings by ID, for references
+	sections map[string]string
+	// figures are the labels of figures and tables if they are numbered
+	figures *figureNumbers
+}
+
+func RenderToPDF(n ast.Node, p *pdf.Writer, src []byte, opts RenderOptions) error {
+	return RenderParts([]Part{{Root: n, Src: src}}, p, opts)
+}
+
+// RenderParts renders the parts in order as one document with a shared table of contents
+func RenderParts(parts []Part, p *pdf.Writer, opts RenderOptions) error {
+	r := &renderer{p: p, opts: opts}
+	if opts.NumberHeadings {
+		r.numbers = headingNumbers(parts, opts.NumberFrom, opts.NumberSeparator)
+		r.sections = sectionLabels(r.numbers)
+	}
+	marked := map[string]bool{}
+	for _, part := range parts {
+		maps.Copy(marked, markedLists(part.Root, part.Src))
+	}
+	if opts.NumberFigures || opts.ListOfFigures || opts.ListOfTables || marked["lof"] || marked["lot"] {
+		r.figures = numberFigures(parts, opts.Diagrams, p.FormatNumber)
+	}
+	if opts.TOC || marked["toc"] {
+		for _, part := range parts {
+			r.toc = append(r.toc, collectTOC(part.Root, part.Src, opts.TOCDepth, r.numbers)...)
+		}
+	}
+
+	// Lists not placed by a marker open the document, the table of contents first
+	for _, list := range []string{"toc", "lof", "lot"} {
+		requested := map[string]bool{"toc": opts.TOC, "lof": opts.ListOfFigures, "lot": opts.ListOfTables}[list]
+		if requested && !marked[list] {
+			p.WriteTOC(r.listTitle(list), r.listEntries(list))
+		}
+	}
+
+	for i, part := range parts {
+		if i > 0 {
+			switch opts.PartBreak {
+			case "none":
+			case "odd":
+				p.WriteChapterBreak(true)
+			default:
+				p.WriteChapterBreak(false)
+			}
+		}
+		if part.BaseDir != "" {
+			p.SetBaseDir(part.BaseDir)
+		}
+		r.src = part.Src
+		if err := r.walk(part.Root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listTitle returns the title of the table of contents or a list of figures or tables
+func (r *renderer) listTitle(list string) string {
+	switch list {
+	case "lof":
+		return "List of Figures"
+	case "lot":
+		return "List of Tables"
+	}
+	if r.opts.TOCTitle != "" {
+		return r.opts.TOCTitle
+	}
+	return "Contents"
+}
+
+// listEntries returns the entries of the table of contents or a list of figures or tables
+func (r *renderer) listEntries(list string) []pdf.TOCEntry {
+	switch list {
+	case "lof":
+		return r.figures.figures
+	case "lot":
+		return r.figures.tables
+	}
+	return r.toc
 }
 
 // extractText recursively extracts all text from a node and its children
@@ -26,8 +185,18 @@ func extractTextRecursive(n ast.Node, buf *bytes.Buffer, src []byte) {
 	switch node := n.(type) {
 	case *ast.Text:
 		buf.Write(node.Segment.Value(src))
+		if node.HardLineBreak() {
+			buf.WriteByte('\n')
+		} else if node.SoftLineBreak() {
+			buf.WriteByte(' ')
+		}
 	case *ast.String:
 		buf.Write(node.Value)
+	case *Math:
+		buf.WriteString(node.Formula.Source)
+	case *CriticMarkup:
+		// Headings and cells show changes accepted, without comments
+		buf.WriteString(node.accepted())
 	case *ast.CodeSpan:
 		// Extract text from code span children
 		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
@@ -52,6 +221,225 @@ func extractTextRecursive(n ast.Node, buf *bytes.Buffer, src []byte) {
 	}
 }
 
+// extractSpans collects the inline content of a node as styled text runs,
+// so emphasis, strong text, code spans, links and inline HTML tags keep their formatting
+func (r *renderer) extractSpans(n ast.Node) []pdf.Span {
+	var spans []pdf.Span
+	r.collectSpans(n, pdf.Span{}, &spans)
+	return splitPageRefs(spans)
+}
+
+func (r *renderer) collectSpans(n ast.Node, base pdf.Span, spans *[]pdf.Span) {
+	src := r.src
+	// Inline HTML tags are separate nodes, so their formatting applies to the following siblings
+	tags := htmlStyle{span: base}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		style := tags.span
+		switch node := child.(type) {
+		case *ast.Text, *ast.String:
+			appendSpan(spans, style, extractText(node, src))
+		case *ast.CodeSpan:
+			code := style
+			code.Code = true
+			appendSpan(spans, code, extractText(node, src))
+		case *ast.Emphasis:
+			// Level 1 = emphasis (italic), Level 2 = strong (bold)
+			emphasis := style
+			if node.Level >= 2 {
+				emphasis.Bold = true
+			} else {
+				emphasis.Italic = true
+			}
+			r.collectSpans(node, emphasis, spans)
+		case *ast.Link:
+			link := style
+			link.Link = string(node.Destination)
+			if label, ok := r.figures.reference(node, src); ok {
+				appendSpan(spans, link, label)
+				continue
+			}
+			if label, ok := r.sectionReference(node, src); ok {
+				appendSpan(spans, link, label)
+				continue
+			}
+			r.collectSpans(node, link, spans)
+		case *ast.AutoLink:
+			link := style
+			link.Link = string(node.URL(src))
+			appendSpan(spans, link, string(node.Label(src)))
+		case *Math:
+			r.mathSpan(node, style, spans)
+		case *CriticMarkup:
+			r.criticSpans(node, style, spans)
+		case *ast.RawHTML:
+			for _, t := range htmlTokens(rawHTMLSource(node, src)) {
+				if t.Tag == nil {
+					continue
+				}
+				text, ok := tags.apply(*t.Tag)
+				if !ok {
+					r.unsupportedHTML(node, t.Tag.Name)
+				}
+				appendSpan(spans, tags.span, text)
+			}
+		default:
+			r.collectSpans(child, style, spans)
+		}
+	}
+}
+
+// appendSpan adds text to the spans, merging it into the last span if the style matches
+func appendSpan(spans *[]pdf.Span, style pdf.Span, text string) {
+	if text == "" {
+		return
+	}
+	style.Text = ""
+	if last := len(*spans) - 1; last >= 0 {
+		prev := (*spans)[last]
+		prev.Text = ""
+		if prev == style {
+			(*spans)[last].Text += text
+			return
+		}
+	}
+	style.Text = text
+	*spans = append(*spans, style)
+}
+
+// imageBlock is an image of an image-only paragraph with its Pandoc-style attributes
+type imageBlock struct {
+	image *ast.Image
+	attrs Attributes
+}
+
+// paragraphImages returns the images of a paragraph consisting only of images,
+// each optionally followed by an attribute block: ![Chart](chart.png){width=50%}
+func paragraphImages(n *ast.Paragraph, src []byte) []imageBlock {
+	var blocks []imageBlock
+	var text bytes.Buffer
+
+	// attach parses the text after the last image, which may only be attributes
+	attach := func() bool {
+		trimmed := strings.TrimSpace(text.String())
+		text.Reset()
+		if trimmed == "" {
+			return true
+		}
+		if len(blocks) == 0 || !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+			return false
+		}
+		blocks[len(blocks)-1].attrs = parseAttributes(trimmed[1 : len(trimmed)-1])
+		return true
+	}
+
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch node := child.(type) {
+		case *ast.Image:
+			if !attach() {
+				return nil
+			}
+			blocks = append(blocks, imageBlock{image: node})
+		case *ast.Text:
+			text.Write(node.Segment.Value(src))
+		case *ast.String:
+			text.Write(node.Value)
+		default:
+			return nil
+		}
+	}
+	if !attach() {
+		return nil
+	}
+	return blocks
+}
+
+// calloutRegex matches GitHub-style alert markers like `[!WARNING]` at the start of a blockquote
+var calloutRegex = regexp.MustCompile(`^\[!([A-Za-z]+)\]\s*`)
+
+// severityRegex matches severity prefixes in headings like `[High] SQL injection`
+var severityRegex = regexp.MustCompile(`(?i)^\[(critical|high|medium|low|info)\]\s*`)
+
+// tableFromNode converts a GFM table to its cell texts and column alignments
+func tableFromNode(n *east.Table, src []byte) pdf.Table {
+	var table pdf.Table
+	for _, a := range n.Alignments {
+		switch a {
+		case east.AlignCenter:
+			table.Align = append(table.Align, "C")
+		case east.AlignRight:
+			table.Align = append(table.Align, "R")
+		default:
+			table.Align = append(table.Align, "L")
+		}
+	}
+
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, strings.TrimSpace(extractText(cell, src)))
+		}
+		if _, ok := row.(*east.TableHeader); ok {
+			table.Header = cells
+		} else {
+			table.Rows = append(table.Rows, cells)
+		}
+	}
+	return table
+}
+
+// truncateColumns returns the cell policies of a table's columns from its truncate
+// attribute: a comma-separated list of columns by header or 1-based number, each
+// optionally followed by :footnote (default) or :attachment, e.g. "Path,SHA-256:attachment"
+func (r *renderer) truncateColumns(spec string, header []string) []string {
+	if spec == "" {
+		return nil
+	}
+	var policies []string
+	for entry := range strings.SplitSeq(spec, ",") {
+		column, policy, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		column = strings.TrimSpace(column)
+		if policy = strings.TrimSpace(policy); policy == "" {
+			policy = "footnote"
+		}
+		if policy != "footnote" && policy != "attachment" {
+			r.p.Warnf("table column %s: unknown truncate policy %q (want footnote or attachment)", column, policy)
+			continue
+		}
+		i := slices.IndexFunc(header, func(h string) bool { return strings.EqualFold(h, column) })
+		if i < 0 {
+			if n, err := strconv.Atoi(column); err == nil && n > 0 {
+				i = n - 1
+			}
+		}
+		if i < 0 {
+			r.p.Warnf("table column %s to truncate not found", column)
+			continue
+		}
+		for len(policies) <= i {
+			policies = append(policies, "")
+		}
+		policies[i] = policy
+	}
+	return policies
+}
+
+// parseCallout returns the kind and body text of a blockquote that starts with an alert marker
+func parseCallout(n *ast.Blockquote, src []byte) (kind, body string, ok bool) {
+	var parts []string
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if text := extractText(child, src); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	text := strings.Join(parts, "\n")
+
+	matches := calloutRegex.FindStringSubmatch(text)
+	if matches == nil {
+		return "", "", false
+	}
+	return strings.ToLower(matches[1]), strings.TrimSpace(text[len(matches[0]):]), true
+}
+
 // getSyntaxHighlightColor returns color for a CSS class
 func getSyntaxHighlightColor(class string) (r, g, b int) {
 	switch class {
@@ -74,33 +462,84 @@ func getSyntaxHighlightColor(class string) (r, g, b int) {
 	}
 }
 
-func walk(n ast.Node, p *pdf.Writer, src []byte) error {
+func (r *renderer) walk(n ast.Node) error {
+	p, src := r.p, r.src
 	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
 		switch node := child.(type) {
 		case *ast.Heading:
 			// Extract all text including nested structures
-			text := extractText(node, src)
-			if text != "" {
-				p.WriteHeading(node.Level, text)
+			heading := pdf.Heading{
+				Level:  node.Level,
+				Text:   extractText(node, src),
+				ID:     attributeString(node, "id"),
+				Number: r.numbers[node],
 			}
+			if matches := severityRegex.FindStringSubmatch(heading.Text); matches != nil {
+				heading.Severity = strings.ToLower(matches[1])
+				heading.Text = heading.Text[len(matches[0]):]
+			}
+			p.WriteHeading(heading)
 			// Don't recurse into heading children - we've already extracted all text
 			continue
 
 		case *ast.Paragraph:
-			// Extract all text including nested structures
-			text := extractText(node, src)
-			if text != "" {
-				p.WriteParagraph(text)
+			// Paragraphs consisting only of images are rendered as image blocks
+			if images := paragraphImages(node, src); images != nil {
+				for _, block := range images {
+					alt := extractText(block.image, src)
+					opts := imageOptions(block.attrs)
+					if r.figures != nil && opts.Caption == "" {
+						// Numbered figures are captioned with their alt text by default
+						opts.Caption = alt
+					}
+					opts.Caption = r.figures.caption(block.image, opts.Caption)
+					if opts.ID == "" {
+						opts.ID = r.figures.anchor(block.image)
+					}
+					p.WriteImage(string(block.image.Destination), alt, opts)
+				}
+				continue
+			}
+
+			// Table captions are rendered with their table
+			if isTableCaption(node, src) {
+				continue
+			}
+
+			// Paragraphs consisting only of a display formula are rendered as formula blocks
+			if formula := paragraphMath(node, src); formula != nil {
+				r.displayMath(formula)
+				continue
+			}
+
+			// Paragraphs consisting only of comments are notes for reviewers, shown in drafts
+			if comments := paragraphComments(node, src); comments != nil {
+				if r.opts.Draft {
+					p.WriteCallout("comment", strings.Join(comments, "\n"))
+				}
+				continue
+			}
+
+			// A `[TOC]`, `[LOF]` or `[LOT]` paragraph places the table of contents or the list of
+			// figures or tables. Directly below a heading, that heading serves as its title and
+			// is left out of the table of contents.
+			if list := listMarker(node, src); list != "" {
+				if _, ok := node.PreviousSibling().(*ast.Heading); ok {
+					p.WriteTOC("", r.listEntries(list))
+				} else {
+					p.WriteTOC(r.listTitle(list), r.listEntries(list))
+				}
+				continue
 			}
+
+			// Extract all text including nested structures, keeping inline styles
+			p.WriteParagraph(r.extractSpans(node))
 			// Don't recurse into paragraph children - we've already extracted all text
 			continue
 
 		case *ast.Image:
-			// Images are handled but not implemented yet
-			p.WriteImageBytes(
-				string(node.Title),
-				node.Destination,
-			)
+			p.WriteImage(string(node.Destination), extractText(node, src), pdf.ImageOptions{})
+			continue
 
 		case *ast.CodeBlock:
 			// Extract code block content using Lines() method
@@ -112,7 +551,7 @@ func walk(n ast.Node, p *pdf.Writer, src []byte) error {
 					codeBuf.Write(segment.Value(src))
 				}
 			}
-			code := codeBuf.String()
+			code := r.codeText(node, codeBuf.String(), Attributes{})
 			if code != "" {
 				// Get language from code block attributes if available
 				language := ""
@@ -121,26 +560,28 @@ func walk(n ast.Node, p *pdf.Writer, src []byte) error {
 						language = langStr
 					}
 				}
-				p.WriteHighlightedCode(code, language)
+				p.WriteHighlightedCode(code, language, r.codeOptions(Attributes{}))
 			}
 			// Don't recurse into code block - we've already extracted all content
 			continue
 
 		case *ast.FencedCodeBlock:
-			// Extract fenced code block content using Lines() method
-			var codeBuf bytes.Buffer
-			lines := node.Lines()
-			if lines != nil {
-				for i := 0; i < lines.Len(); i++ {
-					segment := lines.At(i)
-					codeBuf.Write(segment.Value(src))
-				}
+			if delimiter, attrs, ok := dataTable(node, src); ok {
+				r.writeDataTable(node, delimiter, attrs)
+				continue
 			}
-			code := codeBuf.String()
+			code := blockText(node, src)
 			if code != "" {
-				// Get language from fenced code block (e.g., ```javascript)
-				language := string(node.Language(src))
-				p.WriteHighlightedCode(code, language)
+				// Get language and attributes from the info string (e.g., ```go {linenos=true})
+				language, attrs := fenceInfo(node, src)
+				// Diagrams that couldn't be rendered are shown as their source
+				if attrs.ID == "" {
+					attrs.ID = r.figures.anchor(node)
+				}
+				if diagramKinds[language] && r.diagram(diagram.Diagram{Kind: language, Source: code}, attrs, r.figures.caption(node, attrs.Values["caption"])) {
+					continue
+				}
+				p.WriteHighlightedCode(r.codeText(node, code, attrs), language, r.codeOptions(attrs))
 			}
 			// Don't recurse into fenced code block - we've already extracted all content
 			continue
@@ -154,6 +595,30 @@ func walk(n ast.Node, p *pdf.Writer, src []byte) error {
 			// Don't recurse - we've extracted the code content
 			continue
 
+		case *ast.Blockquote:
+			// GitHub-style alerts (`> [!NOTE]`, `> [!WARNING]`, ...) are rendered as callouts,
+			// plain blockquotes fall through to their paragraphs
+			if kind, body, ok := parseCallout(node, src); ok {
+				p.WriteCallout(kind, body)
+				continue
+			}
+
+		case *east.Table:
+			table := tableFromNode(node, src)
+			caption, attrs, _ := tableCaption(node, src)
+			table.Caption = r.figures.caption(node, caption)
+			table.ID = attrs.ID
+			if table.ID == "" {
+				table.ID = r.figures.anchor(node)
+			}
+			table.Truncate = r.truncateColumns(attrs.Values["truncate"], table.Header)
+			p.WriteTable(table)
+			continue
+
+		case *ast.HTMLBlock:
+			r.htmlBlock(node)
+			continue
+
 		case *ast.ThematicBreak:
 			// Horizontal rule - render with subtle styling (like Microsoft Word)
 			p.WriteThematicBreak()
@@ -169,9 +634,9 @@ func walk(n ast.Node, p *pdf.Writer, src []byte) error {
 			for item := node.FirstChild(); item != nil; item = item.NextSibling() {
 				if listItem, ok := item.(*ast.ListItem); ok {
 					// Extract all text from list item (including nested paragraphs, etc.)
-					itemText := extractText(listItem, src)
-					if itemText != "" {
-						p.WriteListItem(itemText, node.Marker, itemIndex)
+					itemSpans := r.extractSpans(listItem)
+					if len(itemSpans) > 0 {
+						p.WriteListItem(itemSpans, node.Marker, itemIndex)
 						if node.IsOrdered() {
 							itemIndex++
 						}
@@ -184,10 +649,7 @@ func walk(n ast.Node, p *pdf.Writer, src []byte) error {
 		case *ast.ListItem:
 			// List items are handled within List nodes, but if we encounter one standalone,
 			// extract and render it
-			itemText := extractText(node, src)
-			if itemText != "" {
-				p.WriteListItem(itemText, '-', 0) // Default to bullet
-			}
+			p.WriteListItem(r.extractSpans(node), '-', 0) // Default to bullet
 			// Don't recurse - we've extracted all text
 			continue
 
@@ -195,7 +657,7 @@ func walk(n ast.Node, p *pdf.Writer, src []byte) error {
 
 		// Recursively process children for nested structures
 		// This ensures we don't miss any content in complex nodes
-		if err := walk(child, p, src); err != nil {
+		if err := r.walk(child); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,185 @@
+package markdown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes the metadata variables a document must provide.
+// It is a subset of JSON Schema:
+//
+//	{
+//	  "required": ["author", "client"],
+//	  "properties": {
+//	    "date":    {"type": "string", "format": "date"},
+//	    "version": {"type": "number"},
+//	    "classification": {"enum": ["public", "internal", "confidential"]}
+//	  }
+//	}
+type Schema struct {
+	Required   []string                  `json:"required"`
+	Properties map[string]PropertySchema `json:"properties"`
+}
+
+// PropertySchema constrains the value of one metadata variable
+type PropertySchema struct {
+	// Type is one of "string", "number", "integer" or "boolean"
+	Type string `json:"type"`
+	// Format is "date" (YYYY-MM-DD), "date-time" (RFC 3339), "email" or "uri"
+	Format    string   `json:"format"`
+	Enum      []string `json:"enum"`
+	Pattern   string   `json:"pattern"`
+	MinLength int      `json:"minLength"`
+	MaxLength int      `json:"maxLength"`
+}
+
+// FieldError describes a metadata variable that doesn't match the schema
+type FieldError struct {
+	Field string
+	// Line is the 1-based line of the variable, or 0 if it is missing
+	Line    int
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: __%s__: %s", e.Line, e.Field, e.Message)
+	}
+	return fmt.Sprintf("__%s__: %s", e.Field, e.Message)
+}
+
+// LoadSchema reads a JSON schema file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	for name, prop := range schema.Properties {
+		if prop.Pattern != "" {
+			if _, err := regexp.Compile(prop.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid schema %s: pattern of %q: %w", path, name, err)
+			}
+		}
+	}
+	return &schema, nil
+}
+
+// Validate checks the metadata of a document against the schema. Content is the
+// markdown source, used to report the line of each offending variable.
+// All problems are returned together, joined with errors.Join.
+func (s *Schema) Validate(meta Metadata, content string) error {
+	var errs []error
+
+	for _, field := range s.Required {
+		field = strings.ToLower(field)
+		if meta[field] == "" {
+			errs = append(errs, &FieldError{Field: field, Message: "required field is missing"})
+		}
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := strings.ToLower(name)
+		value, ok := meta[key]
+		if !ok {
+			continue
+		}
+		if msg := s.Properties[name].check(value); msg != "" {
+			errs = append(errs, &FieldError{Field: key, Line: metadataLine(content, key), Message: msg})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// check returns a description of why value violates the property, or "" if it is valid
+func (p PropertySchema) check(value string) string {
+	switch p.Type {
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+	case "integer":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Sprintf("%q is not an integer", value)
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Sprintf("%q is not a boolean (expected true or false)", value)
+		}
+	}
+
+	switch p.Format {
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Sprintf("%q is not a valid date (expected YYYY-MM-DD)", value)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Sprintf("%q is not a valid date-time (expected RFC 3339)", value)
+		}
+	case "email":
+		if local, domain, ok := strings.Cut(value, "@"); !ok || local == "" || !strings.Contains(domain, ".") {
+			return fmt.Sprintf("%q is not a valid email address", value)
+		}
+	case "uri":
+		if !strings.Contains(value, "://") {
+			return fmt.Sprintf("%q is not a valid URI", value)
+		}
+	}
+
+	if len(p.Enum) > 0 {
+		found := false
+		for _, allowed := range p.Enum {
+			if value == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("%q is not one of %s", value, strings.Join(p.Enum, ", "))
+		}
+	}
+
+	if p.Pattern != "" {
+		if re, err := regexp.Compile(p.Pattern); err == nil && !re.MatchString(value) {
+			return fmt.Sprintf("%q does not match pattern %s", value, p.Pattern)
+		}
+	}
+
+	length := len([]rune(value))
+	if p.MinLength > 0 && length < p.MinLength {
+		return fmt.Sprintf("must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Sprintf("must be at most %d characters long", p.MaxLength)
+	}
+	return ""
+}
+
+// metadataLine returns the 1-based line of the first occurrence of a metadata variable, or 0
+func metadataLine(content, key string) int {
+	for _, loc := range metadataRegex.FindAllStringSubmatchIndex(content, -1) {
+		if strings.ToLower(content[loc[2]:loc[3]]) == key {
+			return strings.Count(content[:loc[0]], "\n") + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// atxHeadingRegex matches an ATX heading line: its indentation, its marks and the rest
+var atxHeadingRegex = regexp.MustCompile(`^( {0,3})(#{1,6})([ \t].*|)$`)
+
+// setextUnderlineRegex matches the underline of a setext heading, `===` for level 1
+// and `---` for level 2
+var setextUnderlineRegex = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+
+// blockStartRegex matches lines starting a block other than a paragraph, which a
+// `---` below makes a thematic break rather than a heading
+var blockStartRegex = regexp.MustCompile(`^ {0,3}(?:[-*+>|#<]|\d+[.)]|` + "```|~~~)")
+
+// ShiftHeadings moves all headings of content by levels, e.g. 1 to make an H1 an H2,
+// keeping them between H1 and H6. Setext headings become ATX headings; lines inside
+// fenced code blocks are left alone.
+func ShiftHeadings(content string, levels int) string {
+	if levels == 0 {
+		return content
+	}
+	lines := strings.SplitAfter(content, "\n")
+	fence := ""
+	paragraph := false
+	for i, line := range lines {
+		text := strings.TrimRight(line, "\n")
+		if m := fenceRegex.FindStringSubmatch(text); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1][0] == fence[0] && len(m[1]) >= len(fence) {
+				fence = ""
+			}
+			paragraph = false
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if m := atxHeadingRegex.FindStringSubmatch(text); m != nil {
+			lines[i] = m[1] + strings.Repeat("#", shiftLevel(len(m[2]), levels)) + m[3] + line[len(text):]
+			paragraph = false
+			continue
+		}
+		if m := setextUnderlineRegex.FindStringSubmatch(text); m != nil && paragraph {
+			level := 1
+			if m[1][0] == '-' {
+				level = 2
+			}
+			lines[i-1] = strings.Repeat("#", shiftLevel(level, levels)) + " " + strings.TrimSpace(lines[i-1]) + "\n"
+			lines[i] = ""
+			paragraph = false
+			continue
+		}
+		// Only a single line of paragraph text is taken as a setext heading's title
+		paragraph = strings.TrimSpace(text) != "" && !strings.HasPrefix(text, "    ") &&
+			!blockStartRegex.MatchString(text) && (i == 0 || strings.TrimSpace(lines[i-1]) == "")
+	}
+	return strings.Join(lines, "")
+}
+
+// shiftLevel moves a heading level, keeping it between 1 and 6
+func shiftLevel(level, levels int) int {
+	return min(max(level+levels, 1), 6)
+}
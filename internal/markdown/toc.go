@@ -0,0 +1,96 @@
+package markdown
+
+import (
+	"strings"
+
+	"report/internal/pdf"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// listMarkers are the paragraph texts placing the table of contents and the lists of
+// figures and tables, by the list they place
+var listMarkers = map[string]string{"[TOC]": "toc", "[LOF]": "lof", "[LOT]": "lot"}
+
+// listMarker returns the list a marker paragraph like `[TOC]` places: "toc", "lof"
+// or "lot", or "" for other paragraphs
+func listMarker(n *ast.Paragraph, src []byte) string {
+	return listMarkers[strings.TrimSpace(extractText(n, src))]
+}
+
+// markedLists returns the lists the document places with markers
+func markedLists(doc ast.Node, src []byte) map[string]bool {
+	lists := map[string]bool{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if p, ok := n.(*ast.Paragraph); ok && entering {
+			if list := listMarker(p, src); list != "" {
+				lists[list] = true
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return lists
+}
+
+// HasListMarker reports whether the document places a table of contents or a list
+// of figures or tables with `[TOC]`, `[LOF]` or `[LOT]`
+func HasListMarker(doc ast.Node, src []byte) bool {
+	return len(markedLists(doc, src)) > 0
+}
+
+// collectTOC returns the table of contents entries for all headings up to depth,
+// with their section numbers if given. Headings marked with `{toc=false}` and
+// the headings directly above a `[TOC]`, `[LOF]` or `[LOT]` marker are left out.
+func collectTOC(doc ast.Node, src []byte, depth int, numbers map[*ast.Heading]string) []pdf.TOCEntry {
+	if depth <= 0 {
+		depth = 3
+	}
+
+	var entries []pdf.TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if heading.Level > depth || nodeAttributes(heading).unlisted() {
+			return ast.WalkSkipChildren, nil
+		}
+		if next, ok := heading.NextSibling().(*ast.Paragraph); ok && listMarker(next, src) != "" {
+			return ast.WalkSkipChildren, nil
+		}
+
+		text := extractText(heading, src)
+		if matches := severityRegex.FindStringSubmatch(text); matches != nil {
+			text = text[len(matches[0]):]
+		}
+		if number := numbers[heading]; number != "" && text != "" {
+			text = number + " " + text
+		}
+		if text != "" {
+			entries = append(entries, pdf.TOCEntry{
+				Level: heading.Level,
+				Text:  text,
+				ID:    attributeString(heading, "id"),
+			})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return entries
+}
+
+// attributeString returns the value of a node attribute such as `{toc=false}` as a string
+func attributeString(n ast.Node, name string) string {
+	value, ok := n.AttributeString(name)
+	if !ok {
+		return ""
+	}
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
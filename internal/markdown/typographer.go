@@ -0,0 +1,139 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// quoteStyle holds the quotation marks used by a language
+type quoteStyle struct {
+	leftDouble, rightDouble string
+	leftSingle, rightSingle string
+}
+
+// quoteStyles maps language tags to their quotation marks.
+// Full tags (e.g. "de-ch") take precedence over the primary language.
+var quoteStyles = map[string]quoteStyle{
+	"en":    {"“", "”", "‘", "’"},
+	"nl":    {"“", "”", "‘", "’"},
+	"de":    {"„", "“", "‚", "‘"},
+	"de-ch": {"«", "»", "‹", "›"},
+	"cs":    {"„", "“", "‚", "‘"},
+	"sk":    {"„", "“", "‚", "‘"},
+	"pl":    {"„", "”", "‚", "’"},
+	"hu":    {"„", "”", "‚", "’"},
+	"ro":    {"„", "”", "«", "»"},
+	"fr":    {"« ", " »", "‹ ", " ›"},
+	"fr-ch": {"«", "»", "‹", "›"},
+	"es":    {"«", "»", "“", "”"},
+	"it":    {"«", "»", "“", "”"},
+	"pt":    {"«", "»", "“", "”"},
+	"ru":    {"«", "»", "„", "“"},
+	"uk":    {"«", "»", "„", "“"},
+	"da":    {"»", "«", "›", "‹"},
+	"sv":    {"”", "”", "’", "’"},
+	"fi":    {"”", "”", "’", "’"},
+	"no":    {"«", "»", "‘", "’"},
+	"nb":    {"«", "»", "‘", "’"},
+	"ja":    {"「", "」", "『", "』"},
+	"zh":    {"“", "”", "‘", "’"},
+}
+
+// quoteStyleFor returns the quotation marks for a language tag such as "de" or "fr-CH",
+// falling back to English quotes for unknown languages
+func quoteStyleFor(lang string) quoteStyle {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if style, ok := quoteStyles[tag]; ok {
+		return style
+	}
+	if primary, _, found := strings.Cut(tag, "-"); found {
+		if style, ok := quoteStyles[primary]; ok {
+			return style
+		}
+	}
+	return quoteStyles["en"]
+}
+
+// newTypographer builds the goldmark Typographer extension using the quotation marks of the given language
+func newTypographer(lang string) goldmark.Extender {
+	style := quoteStyleFor(lang)
+	return extension.NewTypographer(
+		extension.WithTypographicSubstitutions(extension.TypographicSubstitutions{
+			extension.LeftDoubleQuote:  []byte(style.leftDouble),
+			extension.RightDoubleQuote: []byte(style.rightDouble),
+			extension.LeftSingleQuote:  []byte(style.leftSingle),
+			extension.RightSingleQuote: []byte(style.rightSingle),
+			extension.Apostrophe:       []byte("’"),
+		}),
+	)
+}
+
+// nbsp keeps a number on the line of its unit
+const nbsp = "\u00a0"
+
+// unitSpace matches the space between a number and a unit of measure or currency
+// that follows it, like "10 km", "5 %" or "20 €". Longer units go first, as the first
+// alternative that matches is taken.
+var unitSpace = regexp.MustCompile(`\d( )(%|‰|°C|°F|°|min|mA|CHF|EUR|USD|dpi|rpm|px|pt|[kMGT]?Hz|[kMGTP]i?B|[kMG]?bit|[kMG]?bps|[kMG]?W|[mk]?V|Ω|[km]?g|m?l|[mµn]?s|[kcmµn]?m|h|€|£|¥)`)
+
+// unitSpaceExtension replaces the spaces between numbers and their units with
+// non-breaking spaces
+type unitSpaceExtension struct{}
+
+func (unitSpaceExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(unitSpaceExtension{}, 200)))
+}
+
+// Transform splits text nodes at the spaces before units, putting a non-breaking
+// space between the parts. Code is left alone.
+func (unitSpaceExtension) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	src := reader.Source()
+	var texts []*ast.Text
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := n.(type) {
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering {
+				texts = append(texts, node)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, node := range texts {
+		for {
+			value := node.Segment.Value(src)
+			space := unitSpaceIn(value)
+			if space < 0 {
+				break
+			}
+			start := node.Segment.Start
+			parent := node.Parent()
+			parent.InsertBefore(parent, node, ast.NewTextSegment(text.NewSegment(start, start+space)))
+			parent.InsertBefore(parent, node, ast.NewString([]byte(nbsp)))
+			node.Segment = text.NewSegment(start+space+1, node.Segment.Stop)
+		}
+	}
+}
+
+// unitSpaceIn returns the offset of the first space before a unit in value, or -1.
+// The unit must end the word, so "5 min" matches but "5 mice" doesn't.
+func unitSpaceIn(value []byte) int {
+	for _, match := range unitSpace.FindAllSubmatchIndex(value, -1) {
+		next, _ := utf8.DecodeRune(value[match[5]:])
+		if match[5] == len(value) || !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+			return match[2]
+		}
+	}
+	return -1
+}
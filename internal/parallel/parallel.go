@@ -0,0 +1,36 @@
+// Package parallel runs independent jobs, such as downloads and renders, with bounded parallelism
+package parallel
+
+import "sync"
+
+// Map calls do once for each distinct key, at most concurrency calls at a time, and
+// returns the results by key
+func Map[K comparable, V any](keys []K, concurrency int, do func(K) V) map[K]V {
+	// Keys are deduplicated up front, so the goroutines only share the results
+	seen := make(map[K]bool, len(keys))
+	var unique []K
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	results := make(map[K]V, len(unique))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(concurrency, 1))
+	for _, key := range unique {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			value := do(key)
+			mu.Lock()
+			results[key] = value
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,65 @@
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        []int
+		concurrency int
+		want        map[int]int
+	}{
+		{"empty", nil, 4, map[int]int{}},
+		{"distinct", []int{1, 2, 3}, 2, map[int]int{1: 2, 2: 4, 3: 6}},
+		{"duplicates", []int{1, 1, 2, 1, 2}, 4, map[int]int{1: 2, 2: 4}},
+		{"no concurrency", []int{5, 6}, 0, map[int]int{5: 10, 6: 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			got := Map(tt.keys, tt.concurrency, func(key int) int {
+				calls.Add(1)
+				return key * 2
+			})
+			if len(got) != len(tt.want) {
+				t.Fatalf("Map() = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("Map()[%d] = %d, want %d", key, got[key], value)
+				}
+			}
+			if int(calls.Load()) != len(tt.want) {
+				t.Errorf("do called %d times, want %d", calls.Load(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMapConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	keys := make([]int, 20)
+	for i := range keys {
+		// Keys repeat, so duplicates are skipped while other calls run
+		keys[i] = i % 10
+	}
+	Map(keys, 3, func(int) struct{} {
+		n := running.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return struct{}{}
+	})
+	if peak.Load() > 3 {
+		t.Errorf("%d calls ran at once, want at most 3", peak.Load())
+	}
+}
@@ -0,0 +1,156 @@
+package pdf
+
+import "unicode"
+
+// arabicForms are the presentation forms of Arabic letters: isolated, final, initial
+// and medial. Letters joining only to the preceding one have no initial and medial form.
+var arabicForms = map[rune][4]rune{
+	0x0621: {0xFE80},
+	0x0622: {0xFE81, 0xFE82},
+	0x0623: {0xFE83, 0xFE84},
+	0x0624: {0xFE85, 0xFE86},
+	0x0625: {0xFE87, 0xFE88},
+	0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	0x0627: {0xFE8D, 0xFE8E},
+	0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	0x0629: {0xFE93, 0xFE94},
+	0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	0x062F: {0xFEA9, 0xFEAA},
+	0x0630: {0xFEAB, 0xFEAC},
+	0x0631: {0xFEAD, 0xFEAE},
+	0x0632: {0xFEAF, 0xFEB0},
+	0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	0x0648: {0xFEED, 0xFEEE},
+	0x0649: {0xFEEF, 0xFEF0},
+	0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+	// Persian and Urdu letters
+	0x067E: {0xFB56, 0xFB57, 0xFB58, 0xFB59},
+	0x0686: {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D},
+	0x0698: {0xFB8A, 0xFB8B},
+	0x06A9: {0xFB8E, 0xFB8F, 0xFB90, 0xFB91},
+	0x06AF: {0xFB92, 0xFB93, 0xFB94, 0xFB95},
+	0x06CC: {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF},
+}
+
+// lamAlef are the isolated and final ligatures of lam followed by a form of alef
+var lamAlef = map[rune][2]rune{
+	0x0622: {0xFEF5, 0xFEF6},
+	0x0623: {0xFEF7, 0xFEF8},
+	0x0625: {0xFEF9, 0xFEFA},
+	0x0627: {0xFEFB, 0xFEFC},
+}
+
+// tatweel stretches the joint between letters; it joins on both sides
+const tatweel = 0x0640
+
+// joinsBoth reports whether an Arabic character connects to the following letter
+func joinsBoth(r rune) bool {
+	if r == tatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[2] != 0
+}
+
+// joins reports whether an Arabic character connects to the preceding letter
+func joins(r rune) bool {
+	if r == tatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[1] != 0
+}
+
+// transparent reports whether a character, such as a vowel mark, is skipped when
+// letters join
+func transparent(r rune) bool {
+	return unicode.Is(unicode.Mn, r)
+}
+
+// shapeArabic replaces the Arabic letters of s, in logical order, with the
+// presentation forms for their position in the word, so fonts without shaping tables
+// in PDF viewers show them joined. Text without Arabic letters is returned unchanged.
+func shapeArabic(s string) string {
+	text := []rune(s)
+	if !containsArabic(text) {
+		return s
+	}
+	// neighbor returns the letter next to i in direction step, skipping vowel marks
+	neighbor := func(i, step int) rune {
+		for j := i + step; j >= 0 && j < len(text); j += step {
+			if !transparent(text[j]) {
+				return text[j]
+			}
+		}
+		return 0
+	}
+
+	shaped := make([]rune, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		r := text[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			shaped = append(shaped, r)
+			continue
+		}
+		before := joinsBoth(neighbor(i, -1))
+		if r == 0x0644 {
+			// Lam and a following alef form a ligature
+			if next := i + 1; next < len(text) {
+				if ligature, ok := lamAlef[text[next]]; ok {
+					shaped = append(shaped, ligature[boolIndex(before)])
+					i++
+					continue
+				}
+			}
+		}
+		after := joinsBoth(r) && joins(neighbor(i, 1))
+		switch {
+		case before && after:
+			shaped = append(shaped, forms[3])
+		case after:
+			shaped = append(shaped, forms[2])
+		case before && forms[1] != 0:
+			shaped = append(shaped, forms[1])
+		default:
+			shaped = append(shaped, forms[0])
+		}
+	}
+	return string(shaped)
+}
+
+// containsArabic reports whether text has an Arabic letter with presentation forms
+func containsArabic(text []rune) bool {
+	for _, r := range text {
+		if _, ok := arabicForms[r]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// boolIndex returns 1 for true and 0 for false
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,320 @@
+package pdf
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// bidiClass is the bidirectional type of a character, simplified from the Unicode
+// bidirectional algorithm: explicit embeddings and isolates aren't supported
+type bidiClass int
+
+const (
+	bidiNeutral bidiClass = iota
+	bidiL
+	bidiR
+	// bidiEN are European digits, bidiAN Arabic-Indic ones
+	bidiEN
+	bidiAN
+)
+
+// bidiMirrors are the characters shown mirrored in right-to-left text
+var bidiMirrors = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+	'‹': '›', '›': '‹',
+}
+
+// bidiClassOf returns the bidirectional type of a character
+func bidiClassOf(r rune) bidiClass {
+	switch {
+	case r >= '0' && r <= '9':
+		return bidiEN
+	case r >= 0x0660 && r <= 0x0669, r >= 0x06F0 && r <= 0x06F9:
+		return bidiAN
+	case isRTL(r):
+		return bidiR
+	case unicode.IsLetter(r) || unicode.IsMark(r) && r < 0x0590:
+		return bidiL
+	}
+	return bidiNeutral
+}
+
+// isRTL reports whether a character is written right to left: Hebrew, Arabic and
+// their presentation forms
+func isRTL(r rune) bool {
+	return r >= 0x0590 && r <= 0x08FF || r >= 0xFB1D && r <= 0xFDFF || r >= 0xFE70 && r <= 0xFEFF
+}
+
+// hasRTL reports whether spans contain right-to-left text
+func hasRTL(spans []Span) bool {
+	for _, span := range spans {
+		for _, r := range span.Text {
+			if isRTL(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bidiLevels returns the embedding level of each character of a line, in logical
+// order, in a paragraph of the base level: 0 for left to right, 1 for right to left
+func bidiLevels(text []rune, base int) []int {
+	classes := make([]bidiClass, len(text))
+	for i, r := range text {
+		classes[i] = bidiClassOf(r)
+	}
+
+	// Separators between digits belong to the number, like 1,000 or 3.14
+	for i := 1; i < len(text)-1; i++ {
+		if classes[i] == bidiNeutral && (text[i] == '.' || text[i] == ',' || text[i] == ':' || text[i] == '/') &&
+			classes[i-1] == classes[i+1] && (classes[i-1] == bidiEN || classes[i-1] == bidiAN) {
+			classes[i] = classes[i-1]
+		}
+	}
+
+	// European digits take the direction of the preceding strong text
+	strong := bidiL
+	if base == 1 {
+		strong = bidiR
+	}
+	for i, class := range classes {
+		switch class {
+		case bidiL, bidiR:
+			strong = class
+		case bidiEN:
+			if strong == bidiL {
+				classes[i] = bidiL
+			}
+		}
+	}
+
+	// Neutrals between text of one direction take it, others the base direction;
+	// numbers count as right to left
+	direction := func(class bidiClass) bidiClass {
+		if class == bidiEN || class == bidiAN {
+			return bidiR
+		}
+		return class
+	}
+	baseClass := bidiL
+	if base == 1 {
+		baseClass = bidiR
+	}
+	for i := 0; i < len(classes); {
+		if classes[i] != bidiNeutral {
+			i++
+			continue
+		}
+		end := i
+		for end < len(classes) && classes[end] == bidiNeutral {
+			end++
+		}
+		before, after := baseClass, baseClass
+		if i > 0 {
+			before = direction(classes[i-1])
+		}
+		if end < len(classes) {
+			after = direction(classes[end])
+		}
+		resolved := baseClass
+		if before == after {
+			resolved = before
+		}
+		for j := i; j < end; j++ {
+			classes[j] = resolved
+		}
+		i = end
+	}
+
+	levels := make([]int, len(text))
+	for i, class := range classes {
+		switch {
+		case base == 0 && class == bidiR:
+			levels[i] = 1
+		case base == 0 && (class == bidiEN || class == bidiAN):
+			levels[i] = 2
+		case base == 1 && class != bidiR:
+			levels[i] = 2
+		default:
+			levels[i] = base
+		}
+	}
+	// Trailing spaces stay at the base level
+	for i := len(text) - 1; i >= 0 && unicode.IsSpace(text[i]); i-- {
+		levels[i] = base
+	}
+	return levels
+}
+
+// visualOrder returns the indexes of the characters of a line in display order from
+// left to right, reversing the runs of each level from the highest down to the lowest
+// odd one
+func visualOrder(levels []int) []int {
+	order := make([]int, len(levels))
+	for i := range order {
+		order[i] = i
+	}
+	if len(levels) == 0 {
+		return order
+	}
+	highest, lowestOdd := slices.Max(levels), len(levels)+1
+	for _, level := range levels {
+		if level%2 == 1 {
+			lowestOdd = min(lowestOdd, level)
+		}
+	}
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			end := i
+			for end < len(order) && levels[order[end]] >= level {
+				end++
+			}
+			slices.Reverse(order[i:end])
+			i = end
+		}
+	}
+	return order
+}
+
+// visualText returns a line of text in display order, with Arabic letters shaped and
+// brackets mirrored in right-to-left runs
+func visualText(s string, base int) string {
+	text := []rune(shapeArabic(s))
+	levels := bidiLevels(text, base)
+	visual := make([]rune, 0, len(text))
+	for _, i := range visualOrder(levels) {
+		visual = append(visual, mirror(text[i], levels[i]))
+	}
+	return string(visual)
+}
+
+// mirror returns the mirrored form of a bracket in right-to-left text
+func mirror(r rune, level int) rune {
+	if m, ok := bidiMirrors[r]; ok && level%2 == 1 {
+		return m
+	}
+	return r
+}
+
+// baseLevel returns the bidirectional base level of the document's paragraphs
+func (w *Writer) baseLevel() int {
+	if w.theme.RTL {
+		return 1
+	}
+	return 0
+}
+
+// laidOutFirst reports whether spans are broken into lines before they are written,
+// rather than by gofpdf: right-to-left text, put in display order line by line, and
+// CJK text, which breaks between characters
+func (w *Writer) laidOutFirst(spans []Span) bool {
+	return (w.theme.RTL || hasRTL(spans) || hasCJK(spans)) && justifiable(spans)
+}
+
+// writeBidi writes the lines of a paragraph laid out first, such as one with
+// right-to-left text, in display order between left and left+width: aligned to the right in a right-to-left document and
+// to the left otherwise, or stretched to both edges with justify except for the last
+// line. It leaves the position at the start of the last line.
+func (w *Writer) writeBidi(spans []Span, lines []justifiedLine, lineHeight, size, left, width float64, justify bool) {
+	margin := w.pdf.GetCellMargin()
+	w.pdf.SetCellMargin(0)
+	defer w.pdf.SetCellMargin(margin)
+	links := w.spanLinks(spans)
+	base := w.baseLevel()
+
+	for i, line := range lines {
+		if i > 0 {
+			w.pdf.Ln(lineHeight)
+		}
+		if column, _, _, _ := w.pdf.GetMargins(); w.breakLine(lineHeight) {
+			next, _, _, _ := w.pdf.GetMargins()
+			left += next - column
+		}
+		stretch := 0.0
+		if justify && !line.last && len(line.words) > 1 {
+			stretch = (width - line.width) / float64(len(line.words)-1)
+		}
+
+		// The characters of the line in logical order, with the span of each and the
+		// width of the spaces between words
+		var text []rune
+		var owners []int
+		var spaces []float64
+		for j, word := range line.words {
+			if j > 0 {
+				text = append(text, ' ')
+				owners = append(owners, -1)
+				spaces = append(spaces, line.words[j-1].space+stretch)
+			}
+			for _, piece := range word.pieces {
+				for _, r := range piece.text {
+					text = append(text, r)
+					owners = append(owners, piece.span)
+					spaces = append(spaces, 0)
+				}
+			}
+		}
+
+		x := left
+		if base == 1 && stretch == 0 {
+			x = left + width - line.width
+		}
+		levels := bidiLevels(text, base)
+		order := visualOrder(levels)
+		for k := 0; k < len(order); {
+			c := order[k]
+			if owners[c] < 0 {
+				x += spaces[c]
+				k++
+				continue
+			}
+			// A run of characters of one span in display order
+			var run []rune
+			end := k
+			for end < len(order) && owners[order[end]] == owners[c] {
+				run = append(run, mirror(text[order[end]], levels[order[end]]))
+				end++
+			}
+			span := spans[owners[c]]
+			w.setSpanFont(span, size)
+			runWidth := w.pdf.GetStringWidth(string(run))
+			w.pdf.SetX(x)
+			w.drawSpanText(span, string(run), runWidth, lineHeight, links[owners[c]])
+			x += runWidth
+			k = end
+		}
+	}
+	w.pdf.SetX(left)
+	w.setFont(fontBody, "", size)
+}
+
+// writeBidiListItem writes a list item with right-to-left text: the marker at the
+// start of the first line, on the right in a right-to-left document, and the text
+// wrapped beside it
+func (w *Writer) writeBidiListItem(spans []Span, prefix string, lineHeight float64) {
+	left, _, _, _ := w.pdf.GetMargins()
+	width := w.contentWidth()
+	indent := w.pdf.GetStringWidth(prefix)
+	markerX, textLeft, align := left, left+indent, "L"
+	if w.baseLevel() == 1 {
+		markerX, textLeft, align = left+width-indent, left, "R"
+	}
+
+	margin := w.pdf.GetCellMargin()
+	w.pdf.SetCellMargin(0)
+	w.pdf.SetX(markerX)
+	w.pdf.CellFormat(indent, lineHeight, visualText(strings.TrimSpace(prefix)+" ", w.baseLevel()), "", 0, align, false, 0, "")
+	w.pdf.SetCellMargin(margin)
+
+	w.writeBidi(spans, w.justifyLines(spans, 12, width-indent), lineHeight, 12, textLeft, width-indent, false)
+}
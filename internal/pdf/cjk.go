@@ -0,0 +1,40 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kinsoku rules of Japanese and Chinese typesetting: closing punctuation, small kana
+// and iteration marks may not start a line, opening brackets may not end one
+const (
+	cjkNoLineStart = "、。，．・：；？！ー…‥）」』】〕〉》〙〗｝］｠ゝゞヽヾ々〻ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶㇰㇱㇲㇳㇴㇵㇶㇷㇸㇹㇺㇻㇼㇽㇾㇿ゛゜" +
+		",.;:!?)]}%"
+	cjkNoLineEnd = "（「『【〔〈《〘〖｛［｟" + "([{"
+)
+
+// isCJK reports whether a character is a Chinese, Japanese or Korean character or
+// punctuation, between which lines may break
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303F || r >= 0xFF00 && r <= 0xFFEF
+}
+
+// hasCJK reports whether spans contain CJK text
+func hasCJK(spans []Span) bool {
+	for _, span := range spans {
+		if strings.ContainsFunc(span.Text, isCJK) {
+			return true
+		}
+	}
+	return false
+}
+
+// cjkBreak reports whether a line may break between two characters without a space:
+// next to CJK characters, unless the kinsoku rules forbid it
+func cjkBreak(before, after rune) bool {
+	if !isCJK(before) && !isCJK(after) || unicode.IsSpace(before) || unicode.IsSpace(after) {
+		return false
+	}
+	return !strings.ContainsRune(cjkNoLineStart, after) && !strings.ContainsRune(cjkNoLineEnd, before)
+}
@@ -0,0 +1,162 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// runeRange is a range of characters from lo to hi inclusive
+type runeRange struct {
+	lo, hi rune
+}
+
+// glyphCoverage are the characters a font has glyphs for, in sorted ranges
+type glyphCoverage []runeRange
+
+// has reports whether the font has a glyph for r
+func (c glyphCoverage) has(r rune) bool {
+	_, found := slices.BinarySearchFunc(c, r, func(rr runeRange, r rune) int {
+		switch {
+		case rr.hi < r:
+			return -1
+		case rr.lo > r:
+			return 1
+		}
+		return 0
+	})
+	return found
+}
+
+// fontCoverage reads the characters mapped to glyphs by a TrueType font's Unicode
+// cmap subtable, format 12 for fonts beyond the Basic Multilingual Plane or else
+// format 4. Broken tables give no characters.
+func fontCoverage(data []byte) glyphCoverage {
+	if len(data) < 12 {
+		return nil
+	}
+	cmap := -1
+	tables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := range tables {
+		entry := 12 + 16*i
+		if entry+16 <= len(data) && string(data[entry:entry+4]) == "cmap" {
+			cmap = int(binary.BigEndian.Uint32(data[entry+8:]))
+		}
+	}
+	if cmap < 0 || cmap+4 > len(data) {
+		return nil
+	}
+
+	// The subtables for Unicode: Windows full repertoire, Windows BMP, Unicode platform
+	var full, bmp int
+	count := int(binary.BigEndian.Uint16(data[cmap+2:]))
+	for i := range count {
+		record := cmap + 4 + 8*i
+		if record+8 > len(data) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(data[record:])
+		encoding := binary.BigEndian.Uint16(data[record+2:])
+		offset := cmap + int(binary.BigEndian.Uint32(data[record+4:]))
+		if offset+2 > len(data) {
+			continue
+		}
+		switch format := binary.BigEndian.Uint16(data[offset:]); {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			full = offset
+		case format == 4 && (platform == 3 && encoding == 1 || platform == 0) && bmp == 0:
+			bmp = offset
+		}
+	}
+	switch {
+	case full > 0:
+		return cmapFormat12(data, full)
+	case bmp > 0:
+		return cmapFormat4(data, bmp)
+	}
+	return nil
+}
+
+// cmapFormat12 reads the groups of characters mapped to consecutive glyphs
+func cmapFormat12(data []byte, offset int) glyphCoverage {
+	if offset+16 > len(data) {
+		return nil
+	}
+	var coverage glyphCoverage
+	groups := int(binary.BigEndian.Uint32(data[offset+12:]))
+	for i := range groups {
+		group := offset + 16 + 12*i
+		if group+12 > len(data) {
+			break
+		}
+		lo := rune(binary.BigEndian.Uint32(data[group:]))
+		hi := rune(binary.BigEndian.Uint32(data[group+4:]))
+		if binary.BigEndian.Uint32(data[group+8:]) == 0 {
+			// Glyph 0 is the missing glyph
+			lo++
+		}
+		if lo <= hi {
+			coverage = append(coverage, runeRange{lo, hi})
+		}
+	}
+	return normalizeCoverage(coverage)
+}
+
+// cmapFormat4 reads the segments of characters of the Basic Multilingual Plane,
+// leaving out those mapped to the missing glyph
+func cmapFormat4(data []byte, offset int) glyphCoverage {
+	if offset+14 > len(data) {
+		return nil
+	}
+	segments := int(binary.BigEndian.Uint16(data[offset+6:])) / 2
+	ends := offset + 14
+	starts := ends + 2*segments + 2
+	deltas := starts + 2*segments
+	rangeOffsets := deltas + 2*segments
+	if rangeOffsets+2*segments > len(data) {
+		return nil
+	}
+
+	var coverage glyphCoverage
+	for i := range segments {
+		end := rune(binary.BigEndian.Uint16(data[ends+2*i:]))
+		start := rune(binary.BigEndian.Uint16(data[starts+2*i:]))
+		delta := binary.BigEndian.Uint16(data[deltas+2*i:])
+		rangeOffset := int(binary.BigEndian.Uint16(data[rangeOffsets+2*i:]))
+		for r := start; r <= end && r != 0xFFFF; r++ {
+			glyph := uint16(r) + delta
+			if rangeOffset != 0 {
+				at := rangeOffsets + 2*i + rangeOffset + 2*int(r-start)
+				if at+2 > len(data) {
+					break
+				}
+				glyph = binary.BigEndian.Uint16(data[at:])
+				if glyph != 0 {
+					glyph += delta
+				}
+			}
+			if glyph == 0 {
+				continue
+			}
+			if n := len(coverage); n > 0 && coverage[n-1].hi == r-1 {
+				coverage[n-1].hi = r
+			} else {
+				coverage = append(coverage, runeRange{r, r})
+			}
+		}
+	}
+	return normalizeCoverage(coverage)
+}
+
+// normalizeCoverage sorts ranges and merges those that touch
+func normalizeCoverage(coverage glyphCoverage) glyphCoverage {
+	slices.SortFunc(coverage, func(a, b runeRange) int { return int(a.lo - b.lo) })
+	merged := coverage[:0]
+	for _, rr := range coverage {
+		if n := len(merged); n > 0 && rr.lo <= merged[n-1].hi+1 {
+			merged[n-1].hi = max(merged[n-1].hi, rr.hi)
+			continue
+		}
+		merged = append(merged, rr)
+	}
+	return merged
+}
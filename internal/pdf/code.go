@@ -0,0 +1,314 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	codeLineHeight = 6.0
+	codeTabWidth   = 4
+	// codeWrapIndent indents continuation rows of wrapped lines, leaving room for the marker
+	codeWrapIndent = 5.0
+	// codeContinuedHeight is the height of the notes where a code block breaks across pages
+	codeContinuedHeight = 4.0
+)
+
+// CodeOptions controls the rendering of a code block
+type CodeOptions struct {
+	// LineNumbers prints line numbers in a gutter left of the code
+	LineNumbers bool
+	// FirstLine is the number of the first line (default 1)
+	FirstLine int
+	// ID is the anchor of the block for links and page references
+	ID string
+}
+
+// codeSegment is a run of code in one color
+type codeSegment struct {
+	text  string
+	color Color
+}
+
+// codeLine is one source line of code as colored segments
+type codeLine []codeSegment
+
+// plainCodeLines splits unhighlighted code into lines
+func plainCodeLines(code string) []codeLine {
+	var lines []codeLine
+	for _, text := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+		lines = append(lines, expandLineTabs(codeLine{{text: text}}))
+	}
+	return lines
+}
+
+// highlightedCodeLines tokenizes code with chroma and splits it into colored lines
+func (w *Writer) highlightedCodeLines(code, language string) []codeLine {
+	// Get lexer for the language
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		// Fallback to auto-detection
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		// Ultimate fallback - treat as plain text
+		lexer = lexers.Fallback
+	}
+
+	// Use github-style theme
+	style := styles.Get("github")
+	if style == nil {
+		style = styles.Fallback
+	}
+
+	iterator, err := lexer.Tokenise(nil, strings.TrimSuffix(code, "\n"))
+	if err != nil {
+		return plainCodeLines(code)
+	}
+
+	lines := []codeLine{nil}
+	for token := iterator(); token != chroma.EOF; token = iterator() {
+		r, g, b := w.getChromaColor(style, token.Type)
+		color := Color{r, g, b}
+
+		// Tokens such as comments or whitespace may span several lines
+		for i, text := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if text != "" {
+				last := len(lines) - 1
+				lines[last] = append(lines[last], codeSegment{text: text, color: color})
+			}
+		}
+	}
+
+	// Expand tabs per line, as tab stops depend on the preceding segments
+	for i, line := range lines {
+		lines[i] = expandLineTabs(line)
+	}
+	return lines
+}
+
+// expandLineTabs replaces the tabs of a line with spaces up to the next tab stop
+func expandLineTabs(line codeLine) codeLine {
+	var text strings.Builder
+	for _, seg := range line {
+		text.WriteString(seg.text)
+	}
+	if !strings.Contains(text.String(), "\t") {
+		return line
+	}
+
+	col := 0
+	expanded := make(codeLine, 0, len(line))
+	for _, seg := range line {
+		var b strings.Builder
+		for _, r := range seg.text {
+			if r == '\t' {
+				n := codeTabWidth - col%codeTabWidth
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			b.WriteRune(r)
+			col++
+		}
+		expanded = append(expanded, codeSegment{text: b.String(), color: seg.color})
+	}
+	return expanded
+}
+
+// wrapCodeLine breaks a line into rows no wider than width, measured in the current font.
+// Continuation rows are limited to contWidth. Colors are kept across breaks.
+func (w *Writer) wrapCodeLine(line codeLine, width, contWidth float64) []codeLine {
+	rows := []codeLine{nil}
+	used, limit := 0.0, width
+
+	for _, seg := range line {
+		var run strings.Builder
+		for _, r := range seg.text {
+			rw := w.pdf.GetStringWidth(string(r))
+			if used+rw > limit && used > 0 {
+				if run.Len() > 0 {
+					rows[len(rows)-1] = append(rows[len(rows)-1], codeSegment{text: run.String(), color: seg.color})
+					run.Reset()
+				}
+				rows = append(rows, nil)
+				used, limit = 0, contWidth
+			}
+			run.WriteRune(r)
+			used += rw
+		}
+		if run.Len() > 0 {
+			rows[len(rows)-1] = append(rows[len(rows)-1], codeSegment{text: run.String(), color: seg.color})
+		}
+	}
+	return rows
+}
+
+// writeCodeLines renders code lines row by row, wrapping long lines at the right margin
+// and breaking pages between rows. Blocks slightly too wide are scaled down within
+// the shrink limit instead. The rows of a line are kept on one page, and breaks
+// within the block are marked as continued.
+func (w *Writer) writeCodeLines(lines []codeLine, background Color, opts CodeOptions) {
+	defer w.beginBlock("code", "", "", false)()
+	left, _, _, _ := w.pdf.GetMargins()
+	margin := w.pdf.GetCellMargin()
+	contentWidth := w.contentWidth()
+	width := contentWidth - 2*margin
+	lineHeight := codeLineHeight
+
+	// Fixed-width gutter for the widest line number
+	first := opts.FirstLine
+	if first == 0 {
+		first = 1
+	}
+	gutter := 0.0
+	if opts.LineNumbers {
+		digits := len(strconv.Itoa(first + len(lines) - 1))
+		gutter = w.pdf.GetStringWidth(strings.Repeat("0", digits)) + 2*margin
+		width -= gutter
+	}
+
+	// Shrink the font if that avoids wrapping
+	widest := 0.0
+	for _, line := range lines {
+		widest = max(widest, w.codeLineWidth(line))
+	}
+	if widest > width {
+		if scale, ok := w.shrinkScale(width / widest); ok {
+			w.warnf("code block scaled to %d%% to fit the page width", int(scale*100))
+			fontSize, _ := w.pdf.GetFontSize()
+			w.pdf.SetFontSize(fontSize * scale)
+			lineHeight *= scale
+		}
+	}
+	fontPt, fontSize := w.pdf.GetFontSize()
+	_, top, _, _ := w.pdf.GetMargins()
+	pageHeight := w.contentBottom() - top
+
+	wrapped := make([][]codeLine, len(lines))
+	for n, line := range lines {
+		wrapped[n] = w.wrapCodeLine(line, width, width-codeWrapIndent)
+	}
+
+	// Keep preceding headings with the first line
+	if len(wrapped) > 0 {
+		w.placeBlock(min(float64(len(wrapped[0]))*lineHeight, pageHeight))
+		w.setFont(fontCode, "", fontPt)
+	}
+	w.setAnchor(opts.ID)
+
+	for n, rows := range wrapped {
+
+		// Keep the rows of a line together, leaving room for the continuation marker
+		height := float64(len(rows)) * lineHeight
+		if n < len(lines)-1 {
+			height += codeContinuedHeight
+		}
+		if w.remainingSpace() < height && height <= pageHeight {
+			if n == 0 {
+				w.breakColumn()
+			} else {
+				w.writeCodeContinued("continued…", background)
+				w.breakColumn()
+				w.writeCodeContinued("…continued", background)
+				w.setFont(fontCode, "", fontPt)
+			}
+		}
+
+		for i, row := range rows {
+			// Lines taller than a page can only be split between rows
+			if w.remainingSpace() < lineHeight {
+				w.breakColumn()
+			}
+			// The block may have moved on to another column
+			left, _, _, _ = w.pdf.GetMargins()
+			y := w.pdf.GetY()
+
+			w.setFillColor(background.R, background.G, background.B)
+			w.pdf.Rect(left, y, contentWidth, lineHeight, "F")
+
+			x := left + margin
+			if opts.LineNumbers {
+				w.drawLineNumber(n, i == 0, first, x, y, gutter-2*margin, lineHeight, fontSize)
+				x += gutter
+			}
+			if i > 0 {
+				if w.theme.CodeWrapMarker {
+					w.drawWrapMarker(x, y, lineHeight)
+				}
+				x += codeWrapIndent
+			}
+
+			baseline := y + 0.5*lineHeight + 0.3*fontSize
+			for _, seg := range row {
+				w.setTextColor(seg.color.R, seg.color.G, seg.color.B)
+				w.pdf.Text(x, baseline, seg.text)
+				x += w.pdf.GetStringWidth(seg.text)
+			}
+
+			w.pdf.SetY(y + lineHeight)
+		}
+	}
+
+	w.setTextColor(0, 0, 0)
+}
+
+// writeCodeContinued draws a small right-aligned note on the code background
+// where a code block breaks across pages. It leaves the body font selected.
+func (w *Writer) writeCodeContinued(text string, background Color) {
+	left, _, _, _ := w.pdf.GetMargins()
+	y := w.pdf.GetY()
+
+	w.setFillColor(background.R, background.G, background.B)
+	w.pdf.Rect(left, y, w.contentWidth(), codeContinuedHeight, "F")
+	w.setFont(fontBody, "I", 8)
+	w.setTextColor(120, 120, 120)
+	w.pdf.SetXY(left, y)
+	w.pdf.CellFormat(w.contentWidth(), codeContinuedHeight, text, "", 0, "R", false, 0, "")
+	w.pdf.SetY(y + codeContinuedHeight)
+}
+
+// drawLineNumber draws the gutter of a code row: the right-aligned number of line n
+// on its first row, and the separator line on every row
+func (w *Writer) drawLineNumber(n int, firstRow bool, first int, x, y, width, lineHeight, fontSize float64) {
+	if firstRow {
+		number := strconv.Itoa(first + n)
+		w.setTextColor(150, 150, 150)
+		w.pdf.Text(x+width-w.pdf.GetStringWidth(number), y+0.5*lineHeight+0.3*fontSize, number)
+	}
+
+	sepX := x + width + w.pdf.GetCellMargin()
+	w.setDrawColor(200, 200, 200)
+	w.pdf.SetLineWidth(0.2)
+	w.pdf.Line(sepX, y, sepX, y+lineHeight)
+}
+
+// codeLineWidth returns the width of a line in the current font
+func (w *Writer) codeLineWidth(line codeLine) float64 {
+	width := 0.0
+	for _, seg := range line {
+		width += w.pdf.GetStringWidth(seg.text)
+	}
+	return width
+}
+
+// drawWrapMarker draws a small hook arrow marking the continuation of a wrapped line.
+// It is drawn as vector shapes so it doesn't depend on the glyph coverage of the code font.
+func (w *Writer) drawWrapMarker(x, y, lineHeight float64) {
+	mid := y + lineHeight/2
+	w.setDrawColor(150, 150, 150)
+	w.setFillColor(150, 150, 150)
+	w.pdf.SetLineWidth(0.3)
+	w.pdf.Line(x+0.5, mid-1.8, x+0.5, mid)
+	w.pdf.Line(x+0.5, mid, x+2.6, mid)
+	w.pdf.Polygon([]gofpdf.PointType{{X: x + 2.4, Y: mid - 0.9}, {X: x + 3.6, Y: mid}, {X: x + 2.4, Y: mid + 0.9}}, "F")
+	w.pdf.SetLineWidth(0.2)
+}
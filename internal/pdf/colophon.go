@@ -0,0 +1,145 @@
+package pdf
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+	"unicode/utf16"
+)
+
+// Credit is a font, asset or software component named in the colophon of a document
+type Credit struct {
+	Name string
+	// Detail is the copyright of a font, the use of an asset or the version of software
+	Detail string
+	// License is the license or attribution, empty if unknown
+	License string
+}
+
+// Colophon lists what a document is made of, for deliverables that must attribute
+// the fonts, images and software they contain
+type Colophon struct {
+	Fonts    []Credit
+	Assets   []Credit
+	Software []Credit
+}
+
+// FontCredits returns the fonts embedded in the document, with the copyright and
+// license stated in their name tables
+func (w *Writer) FontCredits() []Credit {
+	return w.fontCredits
+}
+
+// Images returns the paths and URLs of the images placed in the document, each once
+func (w *Writer) Images() []string {
+	return w.images
+}
+
+// creditFont records the credit of a registered font, once per font
+func (w *Writer) creditFont(family, style string, data []byte) {
+	names := fontNames(data)
+	// The license URL is preferred to the description, which is often a paragraph
+	credit := Credit{Name: names[4], Detail: names[0], License: cmp.Or(names[14], names[13])}
+	if credit.Name == "" {
+		credit.Name = family + " " + map[string]string{"": "Regular", "B": "Bold", "I": "Italic", "BI": "Bold Italic"}[style]
+	}
+	if !slices.ContainsFunc(w.fontCredits, func(c Credit) bool { return c.Name == credit.Name }) {
+		w.fontCredits = append(w.fontCredits, credit)
+	}
+}
+
+// fontNames reads the English Windows and Mac entries of a TrueType font's name table by
+// name ID: 0 copyright, 4 full name, 13 license, 14 license URL. Broken tables give
+// no names.
+func fontNames(data []byte) map[uint16]string {
+	names := map[uint16]string{}
+	if len(data) < 12 {
+		return names
+	}
+	tables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := range tables {
+		entry := 12 + 16*i
+		if entry+16 > len(data) || string(data[entry:entry+4]) != "name" {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset+6 > len(data) {
+			return names
+		}
+		count := int(binary.BigEndian.Uint16(data[offset+2:]))
+		strings := offset + int(binary.BigEndian.Uint16(data[offset+4:]))
+		for j := range count {
+			record := offset + 6 + 12*j
+			if record+12 > len(data) {
+				break
+			}
+			platform := binary.BigEndian.Uint16(data[record:])
+			language := binary.BigEndian.Uint16(data[record+4:])
+			id := binary.BigEndian.Uint16(data[record+6:])
+			length := int(binary.BigEndian.Uint16(data[record+8:]))
+			start := strings + int(binary.BigEndian.Uint16(data[record+10:]))
+			if start+length > len(data) {
+				continue
+			}
+			value := data[start : start+length]
+			switch {
+			case platform == 3 && language == 0x409:
+				// Windows names are UTF-16 and take precedence
+				units := make([]uint16, len(value)/2)
+				for k := range units {
+					units[k] = binary.BigEndian.Uint16(value[2*k:])
+				}
+				names[id] = string(utf16.Decode(units))
+			case platform == 1 && language == 0 && names[id] == "":
+				names[id] = string(value)
+			}
+		}
+	}
+	return names
+}
+
+// WriteColophon writes the colophon on a new page: lists of the fonts, assets and
+// software of the document. Empty lists are left out.
+func (w *Writer) WriteColophon(c Colophon) {
+	w.WritePageBreak(false)
+	w.WriteHeading(Heading{Level: 1, Text: "Colophon", ID: "colophon"})
+	w.WriteParagraph([]Span{{Text: "This document was produced with the fonts, assets and software listed below."}})
+
+	// Fonts and assets without a license or attribution are pointed out
+	sections := []struct {
+		title   string
+		credits []Credit
+		line    func(c Credit) []Span
+	}{
+		{"Fonts", c.Fonts, func(c Credit) []Span {
+			return []Span{{Text: c.Name, Bold: true}, {Text: ": " + cmp.Or(c.Detail, "copyright not stated") + ". License: "}, creditLink(cmp.Or(c.License, "not stated"))}
+		}},
+		{"Assets", c.Assets, func(c Credit) []Span {
+			return []Span{{Text: c.Name, Bold: true}, {Text: " (" + c.Detail + "): "}, creditLink(cmp.Or(c.License, "attribution not stated"))}
+		}},
+		{"Software", c.Software, func(c Credit) []Span {
+			spans := []Span{{Text: c.Name, Bold: true}, {Text: " " + c.Detail}}
+			if c.License != "" {
+				spans = append(spans, Span{Text: ", " + c.License + " license"})
+			}
+			return spans
+		}},
+	}
+	for _, section := range sections {
+		if len(section.credits) == 0 {
+			continue
+		}
+		w.WriteHeading(Heading{Level: 2, Text: section.title})
+		for _, credit := range section.credits {
+			w.WriteListItem(section.line(credit), '-', 0)
+		}
+	}
+}
+
+// creditLink returns a license or attribution as a span, linked if it is a URL
+func creditLink(text string) Span {
+	if IsRemote(text) {
+		return Span{Text: text, Link: text}
+	}
+	return Span{Text: text}
+}
@@ -0,0 +1,64 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+)
+
+// setTextColor, setFillColor and setDrawColor set the colors of text and shapes,
+// converted to gray for grayscale output
+func (w *Writer) setTextColor(r, g, b int) {
+	c := w.outputColor(Color{r, g, b})
+	w.pdf.SetTextColor(c.R, c.G, c.B)
+}
+
+func (w *Writer) setFillColor(r, g, b int) {
+	c := w.outputColor(Color{r, g, b})
+	w.pdf.SetFillColor(c.R, c.G, c.B)
+}
+
+func (w *Writer) setDrawColor(r, g, b int) {
+	c := w.outputColor(Color{r, g, b})
+	w.pdf.SetDrawColor(c.R, c.G, c.B)
+}
+
+// outputColor returns a color as it is written to the PDF
+func (w *Writer) outputColor(c Color) Color {
+	if w.theme.Grayscale {
+		return c.gray()
+	}
+	return c
+}
+
+// outputImage converts an image to 8-bit NRGBA as it is embedded, in gray for grayscale output
+func (w *Writer) outputImage(img image.Image) *image.NRGBA {
+	nrgba := toNRGBA(img)
+	if w.theme.Grayscale {
+		nrgba = grayNRGBA(nrgba)
+	}
+	return nrgba
+}
+
+// grayNRGBA returns a copy of an image with each pixel replaced by its luminance, keeping transparency
+func grayNRGBA(img *image.NRGBA) *image.NRGBA {
+	gray := image.NewNRGBA(img.Rect)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		l := Color{int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])}.gray().R
+		gray.Pix[i], gray.Pix[i+1], gray.Pix[i+2], gray.Pix[i+3] = uint8(l), uint8(l), uint8(l), img.Pix[i+3]
+	}
+	return gray
+}
+
+// grayPNG converts PNG data to gray, returning it unchanged if it can't be converted
+func grayPNG(data []byte) []byte {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, grayNRGBA(toNRGBA(img))); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,195 @@
+package pdf
+
+import "slices"
+
+// columnGap is the space between the columns of the body in millimeters
+const columnGap = 8.0
+
+// ColumnLayout records the length of the runs of columns of a layout pass, the body
+// text set in columns between blocks spanning them, so the next pass can balance
+// their columns
+type ColumnLayout struct {
+	// runs holds the height of the content of all columns of each run, 0 for runs with
+	// a forced column break, which aren't balanced
+	runs []float64
+}
+
+// SetColumns sets the body text in n columns from the current position on, e.g. 2 for
+// a two-column layout, or across the full width again with 1. Headings of levels 1 and
+// 2, tables and images too wide for a column and the table of contents span the
+// columns, which start again below them. Columns are balanced on the page where they
+// end given the ColumnLayout of a previous pass.
+func (w *Writer) SetColumns(n int) {
+	w.flushHeadings()
+	w.spanColumns()
+	w.columns = max(n, 1)
+	w.resumeColumns()
+}
+
+// WriteColumnBreak continues the body text at the top of the next column, or of the
+// next page from the last one. Outside columns it does nothing.
+func (w *Writer) WriteColumnBreak() {
+	w.flushHeadings()
+	if w.inColumns {
+		w.forcedColumn = true
+		w.breakColumn()
+	}
+}
+
+// ColumnLayout returns the length of the runs of columns written so far, for SetColumnLayout
+func (w *Writer) ColumnLayout() ColumnLayout {
+	w.flushHeadings()
+	runs := slices.Clone(w.columnRuns)
+	if w.inColumns {
+		runs = append(runs, w.currentRun())
+	}
+	return ColumnLayout{runs: runs}
+}
+
+// SetColumnLayout provides the runs of columns of a previous layout pass, so the
+// columns are balanced on the page where each run ends. It is called before writing.
+func (w *Writer) SetColumnLayout(layout ColumnLayout) {
+	w.balance = layout.runs
+	// The columns of Theme.Columns already started without it
+	if w.inColumns && len(w.columnRuns) == 0 && w.runHeight == 0 {
+		w.startColumns(w.columnTop)
+	}
+}
+
+// spanColumns ends the run of columns for a block spanning them, moving below the
+// longest column. It reports whether there was a run to end, to start the next one
+// with resumeColumns.
+func (w *Writer) spanColumns() bool {
+	if !w.inColumns {
+		return false
+	}
+	w.columnRuns = append(w.columnRuns, w.currentRun())
+	y := slices.Max(append(w.columnEnds, w.pdf.GetY()))
+	w.inColumns = false
+	w.columnBreak = 0
+	w.pdf.SetLeftMargin(w.theme.Page.MarginLeft)
+	w.pdf.SetRightMargin(w.theme.Page.MarginRight)
+	w.pdf.SetY(y)
+	w.resetPageBreak()
+	return true
+}
+
+// resumeColumns starts a run of columns at the current position, below a block
+// spanning them
+func (w *Writer) resumeColumns() {
+	if w.columns > 1 && !w.inColumns {
+		w.inColumns = true
+		w.runHeight, w.forcedColumn = 0, false
+		w.startColumns(w.pdf.GetY())
+	}
+}
+
+// currentRun returns the height of the content of the current run of columns so far,
+// or 0 once a column break was forced
+func (w *Writer) currentRun() float64 {
+	if w.forcedColumn {
+		return 0
+	}
+	return w.runHeight + w.pageRunHeight()
+}
+
+// pageRunHeight returns the height of the content of the columns on the current page
+func (w *Writer) pageRunHeight() float64 {
+	height := w.pdf.GetY() - w.columnTop
+	for _, end := range w.columnEnds {
+		height += end - w.columnTop
+	}
+	return height
+}
+
+// startColumns starts the columns of the current run on a page at top, in the first
+// column. When the rest of the run as long as in the layout pass fits on the page, the
+// columns but the last break early to share it evenly, with half a line to spare for
+// the first.
+func (w *Writer) startColumns(top float64) {
+	w.column, w.columnTop, w.columnEnds = 0, top, nil
+	w.columnBreak = 0
+	if r := len(w.columnRuns); r < len(w.balance) && w.balance[r] > 0 {
+		lineHeight := w.theme.Text.LineHeight
+		share := (w.balance[r] - w.runHeight) / float64(w.columns)
+		if share > 0 && top+share+lineHeight < w.pageBottom() {
+			w.columnBreak = top + share + lineHeight/2
+		}
+	}
+	w.setColumnMargins()
+	left, _, _, _ := w.pdf.GetMargins()
+	w.pdf.SetX(left)
+	w.resetPageBreak()
+}
+
+// nextColumn moves on to the top of the next column of the page. It reports false in the
+// last column or outside columns, where the body continues on the next page.
+func (w *Writer) nextColumn() bool {
+	if !w.inColumns {
+		return false
+	}
+	if w.column == w.columns-1 {
+		w.runHeight += w.pageRunHeight()
+		return false
+	}
+	w.breakBlock()
+	w.columnEnds = append(w.columnEnds, w.pdf.GetY())
+	w.column++
+	w.setColumnMargins()
+	left, _, _, _ := w.pdf.GetMargins()
+	w.pdf.SetXY(left, w.columnTop)
+	w.resetPageBreak()
+	w.continueBlock(w.columnTop)
+	return true
+}
+
+// breakColumn moves on to the next column, or to a new page from the last column and
+// outside columns
+func (w *Writer) breakColumn() {
+	if !w.nextColumn() {
+		w.addPage()
+	}
+}
+
+// breakLine breaks the column ahead of a line of the given height where gofpdf's
+// automatic page break would, reporting whether it did. Writers placing the pieces of
+// a line themselves call it first to place them in the new column.
+func (w *Writer) breakLine(lineHeight float64) bool {
+	_, pageHeight := w.pdf.GetPageSize()
+	auto, margin := w.pdf.GetAutoPageBreak()
+	if !w.inColumns || !auto || w.pdf.GetY()+lineHeight <= pageHeight-margin {
+		return false
+	}
+	w.breakColumn()
+	return true
+}
+
+// columnBounds returns the left edge and width of a column on the current page.
+// Columns run from the right in a right-to-left document.
+func (w *Writer) columnBounds(column int) (left, width float64) {
+	pageWidth, _ := w.pdf.GetPageSize()
+	page := w.theme.Page
+	width = (pageWidth - page.MarginLeft - page.MarginRight - columnGap*float64(w.columns-1)) / float64(w.columns)
+	if w.theme.RTL {
+		column = w.columns - 1 - column
+	}
+	return page.MarginLeft + float64(column)*(width+columnGap), width
+}
+
+// setColumnMargins sets the left and right margins to the edges of the current column
+func (w *Writer) setColumnMargins() {
+	pageWidth, _ := w.pdf.GetPageSize()
+	left, width := w.columnBounds(w.column)
+	w.pdf.SetLeftMargin(left)
+	w.pdf.SetRightMargin(pageWidth - left - width)
+}
+
+// blockTop returns the Y position where blocks start at the top of the page, or of the
+// columns in a run of columns
+func (w *Writer) blockTop() float64 {
+	if w.inColumns {
+		return w.columnTop
+	}
+	_, top, _, _ := w.pdf.GetMargins()
+	return top
+}
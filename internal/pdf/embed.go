@@ -1,12 +1,13 @@
 package pdf
 
-import _ "embed"
+import "embed"
 
 //go:embed embed/logo.png
 var Logo []byte
 
-//go:embed embed/MapleMono-BoldItalic.ttf
-var FontBoldItalic []byte
-
-//go:embed embed/MapleMono-Italic.ttf
-var FontItalic []byte
+// Fonts holds the embedded Maple Mono cuts, named MapleMono-<Style>.ttf. Only the
+// Italic and BoldItalic cuts are checked in so far; Regular and Bold stand in for
+// themselves as soon as MapleMono-Regular.ttf and MapleMono-Bold.ttf are added.
+//
+//go:embed embed/MapleMono-*.ttf
+var Fonts embed.FS
@@ -0,0 +1,14 @@
+package pdf
+
+// isEmoji reports whether a character is an emoji or pictograph of the Basic
+// Multilingual Plane, the only ones gofpdf can set
+func isEmoji(r rune) bool {
+	return r >= 0x2300 && r <= 0x23FF || r >= 0x2600 && r <= 0x27BF || r >= 0x2B00 && r <= 0x2BFF
+}
+
+// emojiControl reports whether a character only selects how the emoji around it are
+// shown: the emoji and text variation selectors and the joiner of emoji sequences.
+// Fonts without color glyphs show them as boxes, so they're left out.
+func emojiControl(r rune) bool {
+	return r == 0xFE0E || r == 0xFE0F || r == 0x200D
+}
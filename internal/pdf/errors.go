@@ -0,0 +1,10 @@
+package pdf
+
+import "errors"
+
+var (
+	// ErrFontLoad is wrapped by errors of fonts that can't be loaded
+	ErrFontLoad = errors.New("font could not be loaded")
+	// ErrImageDecode is wrapped by errors of image data that can't be decoded or embedded
+	ErrImageDecode = errors.New("image could not be decoded")
+)
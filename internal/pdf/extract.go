@@ -0,0 +1,206 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+var (
+	startXrefRegex = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	objectRegex    = regexp.MustCompile(`^(\d+) 0 obj\s`)
+	referenceRegex = regexp.MustCompile(`(\d+) 0 R\b`)
+	streamRegex    = regexp.MustCompile(`>>\s*stream\r?\n`)
+	rootRegex      = regexp.MustCompile(`/Root (\d+) 0 R`)
+	infoRegex      = regexp.MustCompile(`/Info (\d+) 0 R`)
+	kidsRegex      = regexp.MustCompile(`/Kids \[([^\]]*)\]`)
+	countRegex     = regexp.MustCompile(`/Count \d+`)
+	pagesRegex     = regexp.MustCompile(`/Pages (\d+) 0 R`)
+	outlinesRegex  = regexp.MustCompile(`/Outlines \d+ 0 R\s*|/PageMode /UseOutlines\s*`)
+)
+
+// pdfObject is an indirect object of a PDF file
+type pdfObject struct {
+	// dict is the object up to its stream data, where references are found
+	dict []byte
+	// stream is the rest of the object, from its stream data to endobj
+	stream []byte
+}
+
+// ExtractPages returns a PDF with the pages first to last (1-based) of a PDF written by
+// Writer. It relies on the structure of gofpdf's output: a single cross-reference table
+// and plain object dictionaries. Bookmarks are dropped, since they point into the whole
+// document.
+func ExtractPages(data []byte, first, last int) ([]byte, error) {
+	objects, root, info, err := parseObjects(data)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, ok := objects[root]
+	if !ok {
+		return nil, errors.New("invalid PDF: no catalog")
+	}
+	m := pagesRegex.FindSubmatch(catalog.dict)
+	if m == nil {
+		return nil, errors.New("invalid PDF: no page tree")
+	}
+	treeNum, _ := strconv.Atoi(string(m[1]))
+	tree, ok := objects[treeNum]
+	if !ok {
+		return nil, errors.New("invalid PDF: no page tree")
+	}
+	kids := kidsRegex.FindSubmatch(tree.dict)
+	if kids == nil {
+		return nil, errors.New("invalid PDF: page tree without pages")
+	}
+	pages := referenceRegex.FindAll(kids[1], -1)
+	if first < 1 || last < first || last > len(pages) {
+		return nil, fmt.Errorf("pages %d-%d out of range 1-%d", first, last, len(pages))
+	}
+
+	selected := bytes.Join(pages[first-1:last], []byte(" "))
+	tree.dict = kidsRegex.ReplaceAllLiteral(tree.dict, append(append([]byte("/Kids ["), selected...), ']'))
+	tree.dict = countRegex.ReplaceAllLiteral(tree.dict, fmt.Appendf(nil, "/Count %d", last-first+1))
+	objects[treeNum] = tree
+	catalog.dict = outlinesRegex.ReplaceAllLiteral(catalog.dict, nil)
+	objects[root] = catalog
+
+	// Leave out the other pages, which links on the selected ones may point to
+	dropped := map[int]bool{}
+	for i, ref := range pages {
+		if i < first-1 || i >= last {
+			num, _ := strconv.Atoi(string(referenceRegex.FindSubmatch(ref)[1]))
+			dropped[num] = true
+		}
+	}
+	return writeObjects(objects, root, info, dropped), nil
+}
+
+// parseObjects reads the objects of a PDF through its cross-reference table
+func parseObjects(data []byte) (objects map[int]pdfObject, root, info int, err error) {
+	m := startXrefRegex.FindSubmatch(data)
+	if m == nil {
+		return nil, 0, 0, errors.New("invalid PDF: no cross-reference table")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if xref >= len(data) || !bytes.HasPrefix(data[xref:], []byte("xref")) {
+		return nil, 0, 0, errors.New("unsupported PDF: no classic cross-reference table")
+	}
+
+	// Objects end where the next one starts, or at the table
+	fields := bytes.Fields(data[xref:])
+	if len(fields) < 3 {
+		return nil, 0, 0, errors.New("invalid PDF: truncated cross-reference table")
+	}
+	count, _ := strconv.Atoi(string(fields[2]))
+	var offsets []int
+	for i := range count {
+		at := 3 + 3*i
+		if at+2 >= len(fields) {
+			return nil, 0, 0, errors.New("invalid PDF: truncated cross-reference table")
+		}
+		if string(fields[at+2]) == "n" {
+			offset, _ := strconv.Atoi(string(fields[at]))
+			offsets = append(offsets, offset)
+		}
+	}
+	slices.Sort(offsets)
+
+	objects = map[int]pdfObject{}
+	for i, offset := range offsets {
+		end := xref
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		if offset >= end {
+			return nil, 0, 0, errors.New("invalid PDF: bad object offset")
+		}
+		body := data[offset:end]
+		m := objectRegex.FindSubmatch(body)
+		if m == nil {
+			return nil, 0, 0, fmt.Errorf("invalid PDF: no object at offset %d", offset)
+		}
+		num, _ := strconv.Atoi(string(m[1]))
+		obj := pdfObject{dict: body}
+		if loc := streamRegex.FindIndex(body); loc != nil {
+			obj = pdfObject{dict: body[:loc[1]], stream: body[loc[1]:]}
+		}
+		objects[num] = obj
+	}
+
+	trailer := data[xref:]
+	if m := rootRegex.FindSubmatch(trailer); m != nil {
+		root, _ = strconv.Atoi(string(m[1]))
+	}
+	if m := infoRegex.FindSubmatch(trailer); m != nil {
+		info, _ = strconv.Atoi(string(m[1]))
+	}
+	return objects, root, info, nil
+}
+
+// writeObjects writes the objects reachable from the catalog and info dictionary,
+// except dropped ones, as a PDF, numbered anew
+func writeObjects(objects map[int]pdfObject, root, info int, dropped map[int]bool) []byte {
+	// Number the reachable objects in the order they are found
+	numbers := map[int]int{}
+	var order []int
+	queue := []int{root, info}
+	for len(queue) > 0 {
+		num := queue[0]
+		queue = queue[1:]
+		obj, ok := objects[num]
+		if _, seen := numbers[num]; seen || !ok || dropped[num] {
+			continue
+		}
+		numbers[num] = len(order) + 1
+		order = append(order, num)
+		for _, ref := range referenceRegex.FindAllSubmatch(obj.dict, -1) {
+			n, _ := strconv.Atoi(string(ref[1]))
+			queue = append(queue, n)
+		}
+	}
+
+	renumber := func(b []byte) []byte {
+		return referenceRegex.ReplaceAllFunc(b, func(ref []byte) []byte {
+			n, _ := strconv.Atoi(string(referenceRegex.FindSubmatch(ref)[1]))
+			if renumbered, ok := numbers[n]; ok {
+				return fmt.Appendf(nil, "%d 0 R", renumbered)
+			}
+			// References to dropped objects, such as link targets on other pages, become null
+			return []byte("null")
+		})
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(order))
+	for i, num := range order {
+		obj := objects[num]
+		offsets[i] = out.Len()
+		dict := objectRegex.ReplaceAllLiteral(obj.dict, fmt.Appendf(nil, "%d 0 obj\n", i+1))
+		out.Write(renumber(dict))
+		out.Write(obj.stream)
+		if obj.stream == nil && !bytes.HasSuffix(bytes.TrimRight(dict, " \r\n"), []byte("endobj")) {
+			out.WriteString("\nendobj")
+		}
+		if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteByte('\n')
+		}
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(order)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<<\n/Size %d\n/Root %d 0 R\n", len(order)+1, numbers[root])
+	if n, ok := numbers[info]; ok {
+		fmt.Fprintf(&out, "/Info %d 0 R\n", n)
+	}
+	fmt.Fprintf(&out, ">>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return out.Bytes()
+}
@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fontRun is a run of text set in the font of one role
+type fontRun struct {
+	text string
+	role string
+}
+
+// fontRuns splits text in the font of a role into runs of characters that font has
+// glyphs for and runs set in the first of the emoji and fallback families with
+// glyphs for the others. Emoji variation selectors and joiners are left out.
+func (w *Writer) fontRuns(text, role string) []fontRun {
+	family := w.fontFamily(role)
+	if w.fontStyles[family] == nil {
+		family = embeddedFamily
+	}
+	covered := w.coverage[family]
+
+	var runs []fontRun
+	var run strings.Builder
+	current := role
+	for _, r := range text {
+		if emojiControl(r) {
+			continue
+		}
+		if next := w.glyphRole(covered, role, r); next != current && !unicode.IsSpace(r) {
+			if run.Len() > 0 {
+				runs = append(runs, fontRun{run.String(), current})
+				run.Reset()
+			}
+			current = next
+		}
+		run.WriteRune(r)
+	}
+	return append(runs, fontRun{run.String(), current})
+}
+
+// glyphRole returns the role of the font a character of text in a role is set in:
+// the role itself if its font has a glyph for it, else the fallback role of the emoji
+// family for emoji or of the first fallback family with a glyph. Characters no font
+// has a glyph for are warned about, once each, if there are fallback families to add
+// to, and emoji always.
+func (w *Writer) glyphRole(covered glyphCoverage, role string, r rune) string {
+	if unicode.IsSpace(r) || covered.has(r) {
+		return role
+	}
+	families := w.theme.Fonts.Fallback
+	if isEmoji(r) {
+		families = append([]string{w.theme.Fonts.Emoji}, families...)
+	}
+	for _, family := range families {
+		if w.fontStyles[family] != nil && w.coverage[family].has(r) {
+			return fontFallback + family
+		}
+	}
+	if (isEmoji(r) || len(w.theme.Fonts.Fallback) > 0) && unicode.IsGraphic(r) && !w.missingGlyphs[r] {
+		w.missingGlyphs[r] = true
+		w.warnf("no font has a glyph for %c (U+%04X)", r, r)
+	}
+	return role
+}
+
+// fallbackSpans splits spans into the font runs of their text, see fontRuns
+func (w *Writer) fallbackSpans(spans []Span) []Span {
+	var split []Span
+	for _, span := range spans {
+		if span.Image != nil || span.PageRef != "" {
+			split = append(split, span)
+			continue
+		}
+		role := span.role()
+		for _, run := range w.fontRuns(span.Text, role) {
+			part := span
+			part.Text = run.text
+			if run.role != role {
+				part.font = run.role
+			}
+			split = append(split, part)
+		}
+	}
+	return split
+}
+
+// drawRuns writes a line of text in the font of a role, with its characters that font
+// has no glyphs for in the fallback families, and moves to the next line
+func (w *Writer) drawRuns(text, role, style string, size, lineHeight float64, align string) {
+	runs := w.fontRuns(text, role)
+	if len(runs) == 1 {
+		w.pdf.CellFormat(0, lineHeight, runs[0].text, "", 1, align, false, 0, "")
+		return
+	}
+	width := 0.0
+	for _, run := range runs {
+		w.setFont(run.role, style, size)
+		width += w.pdf.GetStringWidth(run.text)
+	}
+	// The runs are set edge to edge, inside the cell margins of the line
+	margin := w.pdf.GetCellMargin()
+	left, _, _, _ := w.pdf.GetMargins()
+	switch align {
+	case "C":
+		w.pdf.SetX(left + (w.contentWidth()-width)/2)
+	case "R":
+		w.pdf.SetX(left + w.contentWidth() - width - margin)
+	default:
+		w.pdf.SetX(w.pdf.GetX() + margin)
+	}
+	w.pdf.SetCellMargin(0)
+	for _, run := range runs {
+		w.setFont(run.role, style, size)
+		w.pdf.CellFormat(w.pdf.GetStringWidth(run.text), lineHeight, run.text, "", 0, "L", false, 0, "")
+	}
+	w.pdf.SetCellMargin(margin)
+	w.setFont(role, style, size)
+	w.pdf.Ln(lineHeight)
+}
@@ -0,0 +1,275 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Font roles used for per-element font mapping
+const (
+	fontBody    = "body"
+	fontHeading = "heading"
+	fontCode    = "code"
+	// fontFallback prefixes the roles of the families characters are set in when the
+	// font of their text has no glyphs for them, e.g. "fallback:Noto Sans JP"
+	fontFallback = "fallback:"
+)
+
+// embeddedFamily is the font family name of the embedded Maple Mono fonts
+const embeddedFamily = "Mono"
+
+// FontFamily lists the font files of one family. Variants left empty
+// fall back to the corresponding variant of the embedded font.
+type FontFamily struct {
+	Regular    string
+	Bold       string
+	Italic     string
+	BoldItalic string
+}
+
+// FontMapping assigns font families to document elements.
+// Empty or unknown family names use the embedded font.
+type FontMapping struct {
+	Body    string
+	Heading string
+	Code    string
+	// Fallback are the families tried in order for the characters of body text,
+	// code and headings that their font has no glyphs for, e.g. Chinese, Japanese or
+	// Korean in a Latin font
+	Fallback []string
+	// Emoji sets the emoji of body text, code and headings, before Fallback. Fonts
+	// with color glyphs only aren't supported; monochrome ones like Noto Emoji are.
+	Emoji string
+}
+
+// fontStyleSuffixes maps file name suffixes to gofpdf style strings
+var fontStyleSuffixes = map[string]string{
+	"regular":     "",
+	"bold":        "B",
+	"italic":      "I",
+	"oblique":     "I",
+	"bolditalic":  "BI",
+	"boldoblique": "BI",
+}
+
+// LoadFontDir scans a directory for TTF/OTF files named `<Family>-<Style>.ttf`
+// (e.g. `Inter-Regular.ttf`, `Inter-BoldItalic.ttf`) and groups them into families.
+// Files without a recognized style suffix are treated as the regular variant.
+func LoadFontDir(dir string) (map[string]FontFamily, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font directory: %w", err)
+	}
+
+	families := map[string]FontFamily{}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".ttf" && ext != ".otf") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		family, style := name, ""
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if s, ok := fontStyleSuffixes[strings.ToLower(name[i+1:])]; ok {
+				family, style = name[:i], s
+			}
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		f := families[family]
+		switch style {
+		case "B":
+			f.Bold = path
+		case "I":
+			f.Italic = path
+		case "BI":
+			f.BoldItalic = path
+		default:
+			f.Regular = path
+		}
+		families[family] = f
+	}
+	return families, nil
+}
+
+// embeddedFallbacks lists, per style, which embedded cuts may stand in for it
+// when the style's own MapleMono-<Style>.ttf isn't embedded
+var embeddedFallbacks = map[string][]string{
+	"":   {"Regular", "Italic"},
+	"B":  {"Bold", "BoldItalic"},
+	"I":  {"Italic", "Regular"},
+	"BI": {"BoldItalic", "Bold"},
+}
+
+// registerEmbeddedFonts registers the embedded Maple Mono cuts under the embedded family.
+// Styles without their own cut use the closest embedded one (e.g. Italic for Regular).
+func (w *Writer) registerEmbeddedFonts() error {
+	for _, style := range []string{"", "B", "I", "BI"} {
+		cut, data, err := embeddedCut(style)
+		if err != nil {
+			return err
+		}
+		if err := w.addFont(embeddedFamily, style, data); err != nil {
+			return fmt.Errorf("%w: embedded MapleMono-%s: %v", ErrFontLoad, cut, err)
+		}
+	}
+	return nil
+}
+
+// embeddedCut returns the embedded cut used for a style and its font data
+func embeddedCut(style string) (string, []byte, error) {
+	for _, cut := range embeddedFallbacks[style] {
+		if data, err := Fonts.ReadFile("embed/MapleMono-" + cut + ".ttf"); err == nil {
+			return cut, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: no embedded font for style %q", ErrFontLoad, style)
+}
+
+// checkTrueType checks that data is a TrueType font before gofpdf, which only
+// understands TrueType outlines and panics later on other data, gets to see it
+func checkTrueType(data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("OTTO")):
+		return errors.New("CFF-based OpenType fonts are not supported")
+	case bytes.HasPrefix(data, []byte("ttcf")):
+		return errors.New("font collections are not supported")
+	case len(data) < 12 || !(bytes.HasPrefix(data, []byte{0, 1, 0, 0}) || bytes.HasPrefix(data, []byte("true"))):
+		return errors.New("not a TrueType font")
+	}
+	return nil
+}
+
+// cachedFont is the content of a font file as of its modification time and size
+type cachedFont struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// fontCache holds the font files read so far by path, so documents rendered one
+// after another, like by the server for its tenants, share them
+var fontCache sync.Map
+
+// readFontFile reads a font file, from the cache unless it changed since
+func readFontFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := fontCache.Load(path); ok {
+		if c := v.(cachedFont); c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.data, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fontCache.Store(path, cachedFont{info.ModTime(), info.Size(), data})
+	return data, nil
+}
+
+// addFont registers TrueType font data, returning the error gofpdf ran into
+func (w *Writer) addFont(family, style string, data []byte) error {
+	w.pdf.AddUTF8FontFromBytes(family, style, data)
+	if w.pdf.Err() {
+		err := w.pdf.Error()
+		w.pdf.ClearError()
+		return err
+	}
+	w.creditFont(family, style, data)
+	if style == "" {
+		w.coverage[family] = fontCoverage(data)
+	}
+	return nil
+}
+
+// registerFontFamilies loads the theme's font families into the PDF. Fonts that
+// can't be read or aren't TrueType-flavoured fail with ErrFontLoad if the font
+// mapping uses their family, and are skipped with a warning otherwise.
+func (w *Writer) registerFontFamilies() error {
+	mapped := append([]string{w.theme.Fonts.Body, w.theme.Fonts.Heading, w.theme.Fonts.Code, w.theme.Fonts.Emoji}, w.theme.Fonts.Fallback...)
+	used := map[string]bool{}
+	for _, family := range mapped {
+		used[family] = true
+	}
+	w.fontStyles = map[string]map[string]bool{}
+	for name, family := range w.theme.FontFamilies {
+		variants := map[string]string{
+			"":   family.Regular,
+			"B":  family.Bold,
+			"I":  family.Italic,
+			"BI": family.BoldItalic,
+		}
+		for style, path := range variants {
+			if path == "" {
+				continue
+			}
+			data, err := readFontFile(path)
+			if err == nil {
+				err = checkTrueType(data)
+			}
+			if err == nil {
+				err = w.addFont(name, style, data)
+			}
+			if err != nil {
+				if used[name] {
+					return fmt.Errorf("%w: %s: %v", ErrFontLoad, path, err)
+				}
+				w.warnf("font %s: %v", path, err)
+				continue
+			}
+			if w.fontStyles[name] == nil {
+				w.fontStyles[name] = map[string]bool{}
+			}
+			w.fontStyles[name][style] = true
+		}
+	}
+
+	for _, family := range mapped {
+		if family != "" && w.fontStyles[family] == nil {
+			w.warnf("font family %q not found, using embedded font", family)
+		}
+	}
+	return nil
+}
+
+// setFont selects the font mapped to a document element ("body", "heading", "code")
+// in the given style ("", "B", "I", "BI", each optionally with "U" for underlining and "S" for strikeout),
+// falling back to the embedded font
+func (w *Writer) setFont(role, style string, size float64) {
+	family := w.fontFamily(role)
+	if strings.HasPrefix(role, fontFallback) && !w.fontStyles[family][strings.Trim(style, "US")] {
+		// Fallback families are set upright and regular rather than in the embedded font
+		style = strings.Trim(style, "BI")
+	}
+
+	// Underlining and strikeout are drawn by gofpdf and need no font variant
+	if w.fontStyles[family][strings.Trim(style, "US")] {
+		w.pdf.SetFont(family, style, size)
+		return
+	}
+	w.pdf.SetFont(embeddedFamily, style, size)
+}
+
+// fontFamily returns the family mapped to a font role
+func (w *Writer) fontFamily(role string) string {
+	switch role {
+	case fontHeading:
+		return w.theme.Fonts.Heading
+	case fontCode:
+		return w.theme.Fonts.Code
+	}
+	if family, ok := strings.CutPrefix(role, fontFallback); ok {
+		return family
+	}
+	return w.theme.Fonts.Body
+}
@@ -0,0 +1,36 @@
+package pdf
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEmbeddedCut(t *testing.T) {
+	tests := []struct {
+		style string
+		own   string
+	}{
+		{"", "Regular"},
+		{"B", "Bold"},
+		{"I", "Italic"},
+		{"BI", "BoldItalic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.own, func(t *testing.T) {
+			cut, data, err := embeddedCut(tt.style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkTrueType(data); err != nil {
+				t.Fatalf("MapleMono-%s: %v", cut, err)
+			}
+			// A style uses its own cut whenever it is embedded
+			if _, err := Fonts.ReadFile("embed/MapleMono-" + tt.own + ".ttf"); err == nil && cut != tt.own {
+				t.Errorf("style %q uses MapleMono-%s, want its own MapleMono-%s", tt.style, cut, tt.own)
+			}
+			if !slices.Contains(embeddedFallbacks[tt.style], cut) {
+				t.Errorf("style %q uses MapleMono-%s, not one of its fallbacks %v", tt.style, cut, embeddedFallbacks[tt.style])
+			}
+		})
+	}
+}
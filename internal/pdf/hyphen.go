@@ -0,0 +1,189 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// softHyphen marks where a word may be broken; it is only shown at the end of a line
+const softHyphen = '\u00ad'
+
+// Hyphenator finds the points where words may be broken, using Liang's hyphenation
+// patterns as TeX does
+type Hyphenator struct {
+	// patterns maps the letters of a pattern to its values between them
+	patterns   map[string][]int
+	exceptions map[string][]int
+	longest    int
+}
+
+// Fewest letters kept before and after a hyphen, as in TeX's English settings
+const (
+	hyphenLeftMin  = 2
+	hyphenRightMin = 3
+)
+
+// LoadHyphenation reads the hyphenation patterns for a language tag such as "de" or
+// "en-GB". path is a pattern file, or a directory of files named like those of
+// hyph-utf8, hyph-<lang>.pat.txt, where the file for the full tag is preferred over
+// one for the primary language (e.g. hyph-de-1996.pat.txt for "de"). Exceptions are
+// read from the matching .hyp.txt file if there is one.
+func LoadHyphenation(path, lang string) (*Hyphenator, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hyphenation patterns: %w", err)
+	}
+	if info.IsDir() {
+		if path, err = hyphenationFile(path, lang); err != nil {
+			return nil, err
+		}
+	}
+
+	patterns, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hyphenation patterns: %w", err)
+	}
+	var exceptions []byte
+	if base, ok := strings.CutSuffix(path, ".pat.txt"); ok {
+		exceptions, err = os.ReadFile(base + ".hyp.txt")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read hyphenation exceptions: %w", err)
+		}
+	}
+	h, err := ParseHyphenation(string(patterns), string(exceptions))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return h, nil
+}
+
+// hyphenationFile returns the pattern file in dir for a language tag
+func hyphenationFile(dir, lang string) (string, error) {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if tag == "" {
+		tag = "en-us"
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	candidates := []string{"hyph-" + tag + ".pat.txt", "hyph-" + primary + ".pat.txt"}
+	for _, name := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name), nil
+		}
+	}
+	// Variants such as hyph-de-1996 or hyph-en-us, the first in name order
+	matches, _ := filepath.Glob(filepath.Join(dir, "hyph-"+primary+"-*.pat.txt"))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no hyphenation patterns for language %q in %s", tag, dir)
+	}
+	slices.Sort(matches)
+	return matches[0], nil
+}
+
+// ParseHyphenation builds a hyphenator from patterns like "a1b" or ".ach4" and
+// exceptions like "ta-ble", separated by white space. The TeX syntax of the
+// \patterns{...} and \hyphenation{...} commands with % comments is accepted too.
+func ParseHyphenation(patterns, exceptions string) (*Hyphenator, error) {
+	if body, ok := texCommand(patterns, `\hyphenation`); ok {
+		exceptions += "\n" + body
+	}
+	if body, ok := texCommand(patterns, `\patterns`); ok {
+		patterns = body
+	}
+
+	h := &Hyphenator{patterns: map[string][]int{}, exceptions: map[string][]int{}}
+	for pattern := range strings.FieldsSeq(stripTeXComments(patterns)) {
+		var letters []rune
+		values := []int{0}
+		for _, r := range pattern {
+			if r >= '0' && r <= '9' {
+				values[len(values)-1] = int(r - '0')
+				continue
+			}
+			letters = append(letters, unicode.ToLower(r))
+			values = append(values, 0)
+		}
+		if len(letters) == 0 {
+			return nil, fmt.Errorf("invalid hyphenation pattern %q", pattern)
+		}
+		h.patterns[string(letters)] = values
+		h.longest = max(h.longest, len(letters))
+	}
+	if len(h.patterns) == 0 {
+		return nil, errors.New("no hyphenation patterns")
+	}
+
+	for exception := range strings.FieldsSeq(stripTeXComments(exceptions)) {
+		var letters []rune
+		var points []int
+		for _, r := range exception {
+			if r == '-' {
+				points = append(points, len(letters))
+				continue
+			}
+			letters = append(letters, unicode.ToLower(r))
+		}
+		h.exceptions[string(letters)] = points
+	}
+	return h, nil
+}
+
+// texCommand returns the argument of a TeX command like \patterns{...} in s
+func texCommand(s, command string) (string, bool) {
+	_, rest, ok := strings.Cut(s, command+"{")
+	if !ok {
+		return "", false
+	}
+	body, _, _ := strings.Cut(rest, "}")
+	return body, true
+}
+
+// stripTeXComments removes the % comments of TeX source from s
+func stripTeXComments(s string) string {
+	var b strings.Builder
+	for line := range strings.Lines(s) {
+		line, _, _ = strings.Cut(line, "%")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Points returns the rune offsets in word where a hyphen may go, in increasing order
+func (h *Hyphenator) Points(word string) []int {
+	letters := []rune(strings.ToLower(word))
+	if len(letters) < hyphenLeftMin+hyphenRightMin {
+		return nil
+	}
+	if points, ok := h.exceptions[string(letters)]; ok {
+		return points
+	}
+
+	// Patterns match the word between dots marking its edges
+	text := append(append([]rune{'.'}, letters...), '.')
+	values := make([]int, len(text)+1)
+	for start := range text {
+		for end := start + 1; end <= min(len(text), start+h.longest); end++ {
+			pattern, ok := h.patterns[string(text[start:end])]
+			if !ok {
+				continue
+			}
+			for i, v := range pattern {
+				values[start+i] = max(values[start+i], v)
+			}
+		}
+	}
+
+	var points []int
+	for i := hyphenLeftMin; i <= len(letters)-hyphenRightMin; i++ {
+		// The value before letter i of the word follows the leading dot
+		if values[i+1]%2 == 1 {
+			points = append(points, i)
+		}
+	}
+	return points
+}
@@ -0,0 +1,110 @@
+package pdf
+
+import (
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Icon is one of the embedded vector icons
+type Icon int
+
+const (
+	IconInfo Icon = iota
+	IconWarning
+	IconCritical
+	IconCheck
+)
+
+// iconForKind maps callout and severity kinds to icons
+func iconForKind(kind string) Icon {
+	switch strings.ToLower(kind) {
+	case "warning", "medium", "low":
+		return IconWarning
+	case "critical", "caution", "danger", "error", "high":
+		return IconCritical
+	case "check", "success", "tip", "pass", "fixed", "resolved":
+		return IconCheck
+	default:
+		return IconInfo
+	}
+}
+
+// calloutColorKind maps GitHub-style alert kinds to the theme color of their icon
+func calloutColorKind(kind string) string {
+	// Reviewer comments have a color of their own
+	if strings.EqualFold(kind, "comment") {
+		return "comment"
+	}
+	switch iconForKind(kind) {
+	case IconWarning:
+		return "warning"
+	case IconCritical:
+		return "critical"
+	case IconCheck:
+		return "check"
+	default:
+		return "info"
+	}
+}
+
+// drawIcon draws an icon as vector shapes in a size×size box at (x, y).
+// The shape is filled with the fill color and the mark inside it uses the mark color,
+// so icons don't depend on the glyph coverage of the embedded fonts.
+func (w *Writer) drawIcon(icon Icon, x, y, size float64, fill, mark Color) {
+	cx, cy := x+size/2, y+size/2
+	r := size / 2
+
+	w.setFillColor(fill.R, fill.G, fill.B)
+	w.setDrawColor(mark.R, mark.G, mark.B)
+	w.pdf.SetLineCapStyle("round")
+	w.pdf.SetLineJoinStyle("round")
+
+	switch icon {
+	case IconInfo:
+		// Circle with an "i"
+		w.pdf.Circle(cx, cy, r, "F")
+		w.setFillColor(mark.R, mark.G, mark.B)
+		w.pdf.Circle(cx, y+size*0.28, size*0.07, "F")
+		w.pdf.SetLineWidth(size * 0.13)
+		w.pdf.Line(cx, y+size*0.45, cx, y+size*0.75)
+
+	case IconWarning:
+		// Triangle with an "!"
+		w.pdf.Polygon([]gofpdf.PointType{
+			{X: cx, Y: y},
+			{X: x + size, Y: y + size*0.9},
+			{X: x, Y: y + size*0.9},
+		}, "F")
+		w.pdf.SetLineWidth(size * 0.12)
+		w.pdf.Line(cx, y+size*0.35, cx, y+size*0.6)
+		w.setFillColor(mark.R, mark.G, mark.B)
+		w.pdf.Circle(cx, y+size*0.75, size*0.065, "F")
+
+	case IconCritical:
+		// Octagon with an "×"
+		points := make([]gofpdf.PointType, 8)
+		for i := range points {
+			angle := math.Pi/8 + float64(i)*math.Pi/4
+			points[i] = gofpdf.PointType{X: cx + r*math.Cos(angle), Y: cy + r*math.Sin(angle)}
+		}
+		w.pdf.Polygon(points, "F")
+		w.pdf.SetLineWidth(size * 0.12)
+		d := size * 0.2
+		w.pdf.Line(cx-d, cy-d, cx+d, cy+d)
+		w.pdf.Line(cx-d, cy+d, cx+d, cy-d)
+
+	case IconCheck:
+		// Circle with a check mark
+		w.pdf.Circle(cx, cy, r, "F")
+		w.pdf.SetLineWidth(size * 0.12)
+		w.pdf.Line(x+size*0.28, y+size*0.52, x+size*0.44, y+size*0.68)
+		w.pdf.Line(x+size*0.44, y+size*0.68, x+size*0.73, y+size*0.35)
+	}
+
+	// Restore defaults used by the rest of the writer
+	w.pdf.SetLineCapStyle("butt")
+	w.pdf.SetLineJoinStyle("miter")
+	w.pdf.SetLineWidth(0.2)
+}
@@ -0,0 +1,483 @@
+package pdf
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// SetBaseDir sets the directory relative image paths are resolved against
+func (w *Writer) SetBaseDir(dir string) {
+	w.baseDir = dir
+}
+
+// SetPathResolver sets the function mapping image paths, relative to the base directory,
+// to the files to read. It may reject paths, which skips the image with a warning.
+func (w *Writer) SetPathResolver(resolve func(path, dir string) (string, error)) {
+	w.resolve = resolve
+}
+
+// resolvePath returns the file an image path refers to
+func (w *Writer) resolvePath(path string) (string, error) {
+	if w.resolve != nil {
+		return w.resolve(path, w.baseDir)
+	}
+	if !filepath.IsAbs(path) && w.baseDir != "" {
+		path = filepath.Join(w.baseDir, path)
+	}
+	return path, nil
+}
+
+// OpenFile opens a file the document refers to, like the source of a data table,
+// resolving its path like image paths
+func (w *Writer) OpenFile(path string) (*os.File, error) {
+	path, err := w.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// SetRemoteImages provides the downloaded content of remote images by URL.
+// Remote images without content are drawn as a placeholder box showing the URL.
+func (w *Writer) SetRemoteImages(images map[string][]byte) {
+	w.remoteImages = images
+}
+
+// IsRemote reports whether an image path is a URL to download rather than a file
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// IsDataURI reports whether an image path is a data URI holding the image itself,
+// like `data:image/png;base64,iVBORw0...`
+func IsDataURI(path string) bool {
+	return strings.HasPrefix(path, "data:")
+}
+
+// decodeDataURI returns the content of a base64 data URI with an image media type
+func decodeDataURI(uri string) ([]byte, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, errors.New("data URI without content")
+	}
+	mediaType, params, _ := strings.Cut(header, ";")
+	if !strings.HasPrefix(mediaType, "image/") {
+		return nil, fmt.Errorf("unsupported media type %q", mediaType)
+	}
+	if !slices.Contains(strings.Split(params, ";"), "base64") {
+		return nil, errors.New("data URI is not base64-encoded")
+	}
+	// Line breaks and spaces are allowed within the payload
+	payload = strings.Join(strings.Fields(payload), "")
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		// Some encoders leave out the padding
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 content: %w", err)
+	}
+	return data, nil
+}
+
+// dataURIName returns the name a data URI image is registered under, so repeated
+// images are embedded once
+func dataURIName(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return "data-" + hex.EncodeToString(sum[:8])
+}
+
+// ImageOptions controls the placement of a block image
+type ImageOptions struct {
+	// Width and Height are lengths such as "50%" (of the content width), "60mm",
+	// "3cm", "2in", "72pt", "200px" or "2em" (of the body text size); plain numbers
+	// are pixels. If only one is given, the other follows the aspect ratio.
+	Width  string
+	Height string
+	// Align is "left" (default), "center" or "right"
+	Align string
+	// ID is the anchor of the image for links and page references
+	ID string
+	// Caption is shown centered below the image
+	Caption string
+	// Alt is the alternative text read by screen readers in a tagged PDF, the
+	// caption if empty
+	Alt string
+}
+
+// captionFontSize and captionLineHeight set the text of image captions
+const (
+	captionFontSize   = 10.0
+	captionLineHeight = 5.0
+)
+
+// WriteImageData renders image data (PNG, JPEG or GIF) as a block like WriteImage.
+// Name identifies the image within the document; data registered under the same
+// name is embedded once.
+func (w *Writer) WriteImageData(name string, data []byte, opts ImageOptions) error {
+	name, info, err := w.registerImage(name, data)
+	if err != nil {
+		return err
+	}
+	w.placeImage(name, info, opts)
+	return nil
+}
+
+// WriteImage renders an image file or remote image as a block, scaled down to the content
+// width and the page height if necessary. Images that can't be loaded are drawn as a
+// placeholder box showing the path and alt text, with a warning.
+func (w *Writer) WriteImage(path, alt string, opts ImageOptions) {
+	if path == "" {
+		return
+	}
+	opts.Alt = cmp.Or(opts.Alt, alt)
+
+	if IsDataURI(path) {
+		w.WriteImageBytes(path, opts)
+		return
+	}
+
+	var data []byte
+	if IsRemote(path) {
+		data = w.remoteImages[path]
+		if data == nil {
+			w.writeImagePlaceholder(path, alt, errors.New("not downloaded"), opts)
+			return
+		}
+	} else {
+		resolved, err := w.resolvePath(path)
+		if err == nil {
+			data, err = os.ReadFile(resolved)
+		}
+		if err != nil {
+			w.warnf("image %s: %v", path, err)
+			w.writeImagePlaceholder(path, alt, err, opts)
+			return
+		}
+	}
+
+	name, info, err := w.registerImage(path, data)
+	if err != nil {
+		w.warnf("image %s: %v", path, err)
+		w.writeImagePlaceholder(path, alt, err, opts)
+		return
+	}
+
+	if !slices.Contains(w.images, path) {
+		w.images = append(w.images, path)
+	}
+	w.placeImage(name, info, opts)
+}
+
+// WriteImageBytes renders an image inlined in the document as a base64 data URI, like
+// `data:image/png;base64,...`, as a block like WriteImage. Invalid data URIs are
+// drawn as a placeholder box, with a warning.
+func (w *Writer) WriteImageBytes(uri string, opts ImageOptions) {
+	data, err := decodeDataURI(uri)
+	if err == nil {
+		err = w.WriteImageData(dataURIName(uri), data, opts)
+	}
+	if err != nil {
+		// The content would flood the warning
+		if len(uri) > 40 {
+			uri = uri[:40] + "..."
+		}
+		w.warnf("image %s: %v", uri, err)
+		w.writeImagePlaceholder(uri, "", err, opts)
+	}
+}
+
+// MissingImage is an image drawn as a placeholder because it couldn't be loaded
+type MissingImage struct {
+	Path string
+	Err  error
+}
+
+// MissingImages returns the images drawn as a placeholder, in document order
+func (w *Writer) MissingImages() []MissingImage {
+	return w.missingImages
+}
+
+// registerImage decodes image data and registers it with the PDF under name.
+// JPEGs are embedded as-is; other formats are re-encoded as 8-bit PNGs, since
+// gofpdf can't read interlaced or 16-bit PNGs and GIFs with transparency.
+func (w *Writer) registerImage(name string, data []byte) (string, *gofpdf.ImageInfoType, error) {
+	if info := w.pdf.GetImageInfo(name); info != nil {
+		return name, info, nil
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrImageDecode, err)
+	}
+
+	opt := gofpdf.ImageOptions{ImageType: "PNG"}
+	if format == "jpeg" {
+		opt.ImageType = "JPG"
+		// Re-encode JPEGs gofpdf can't handle (e.g. CMYK) so they don't fail the whole
+		// document, and JPEGs converted to gray
+		if _, ok := img.(*image.CMYK); ok || w.theme.Grayscale {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, w.outputImage(img), &jpeg.Options{Quality: 90}); err != nil {
+				return "", nil, fmt.Errorf("failed to convert image: %w", err)
+			}
+			data = buf.Bytes()
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, w.outputImage(img)); err != nil {
+			return "", nil, fmt.Errorf("failed to convert image: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	info := w.pdf.RegisterImageOptionsReader(name, opt, bytes.NewReader(data))
+	if w.pdf.Err() {
+		// Don't let one broken image fail the whole document
+		err := w.pdf.Error()
+		w.pdf.ClearError()
+		return "", nil, fmt.Errorf("%w: embedding failed: %v", ErrImageDecode, err)
+	}
+	return name, info, nil
+}
+
+// toNRGBA converts an image to 8-bit non-premultiplied RGBA
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	return nrgba
+}
+
+// placeImage draws a registered image at the current position as a block
+func (w *Writer) placeImage(name string, info *gofpdf.ImageInfoType, opts ImageOptions) {
+	defer w.beginBlock("image", opts.ID, "", false)()
+	defer w.beginTag("Figure", cmp.Or(opts.Alt, opts.Caption))()
+	_, top, _, _ := w.pdf.GetMargins()
+	contentWidth := w.contentWidth()
+
+	// The caption stays on the page of the image
+	caption, captionHeight := w.splitCaption(opts.Caption)
+	contentHeight := w.contentBottom() - top - captionHeight
+
+	// Natural size at 96 DPI, or the requested size, scaled down to fit the content area
+	width := info.Width() * 25.4 / 96
+	height := info.Height() * 25.4 / 96
+	w.applyImageSize(name, &width, &height, opts, contentWidth, contentHeight)
+	if width > contentWidth && w.spanColumns() {
+		// Images too wide for a column span the columns
+		defer w.resumeColumns()
+		contentWidth = w.contentWidth()
+		caption, captionHeight = w.splitCaption(opts.Caption)
+		contentHeight = w.contentBottom() - top - captionHeight
+		width, height = info.Width()*25.4/96, info.Height()*25.4/96
+		w.applyImageSize(name, &width, &height, opts, contentWidth, contentHeight)
+	}
+	if width > contentWidth {
+		height *= contentWidth / width
+		width = contentWidth
+	}
+	if height > contentHeight {
+		width *= contentHeight / height
+		height = contentHeight
+	}
+
+	// Keep preceding headings with the image, shrunk as far as allowed
+	w.placeBlock(height*w.shrinkLimit() + captionHeight)
+
+	// Shrink images slightly too tall for the rest of the page instead of moving them
+	if remaining := w.remainingSpace() - captionHeight; remaining < height {
+		if scale, ok := w.shrinkScale(remaining / height); ok {
+			w.warnf("image %s scaled to %d%% to fit the page", name, int(scale*100))
+			width *= scale
+			height *= scale
+		} else {
+			w.breakColumn()
+		}
+	}
+	y := w.pdf.GetY()
+	w.setAnchor(opts.ID)
+
+	left, _, _, _ := w.pdf.GetMargins()
+	x := left
+	switch opts.Align {
+	case "center":
+		x += (contentWidth - width) / 2
+	case "right":
+		x += contentWidth - width
+	}
+
+	w.pdf.ImageOptions(name, x, y, width, height, false, gofpdf.ImageOptions{}, 0, "")
+	w.figures++
+	w.pdf.SetY(y + height)
+
+	w.writeCaption(caption)
+	w.pdf.Ln(4)
+}
+
+// splitCaption wraps a caption to the content width and returns its lines and height
+func (w *Writer) splitCaption(caption string) ([]string, float64) {
+	if caption == "" {
+		return nil, 0
+	}
+	w.setFont(fontBody, "I", captionFontSize)
+	lines := w.pdf.SplitText(caption, w.contentWidth())
+	return lines, 2 + float64(len(lines))*captionLineHeight
+}
+
+// writeCaption writes the lines of a caption centered below an image or table
+func (w *Writer) writeCaption(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	left, _, _, _ := w.pdf.GetMargins()
+	w.pdf.Ln(2)
+	w.setFont(fontBody, "I", captionFontSize)
+	for _, line := range lines {
+		w.pdf.SetX(left)
+		w.pdf.CellFormat(w.contentWidth(), captionLineHeight, line, "", 1, "C", false, 0, "")
+	}
+	w.setFont(fontBody, "", 12)
+}
+
+// writeImagePlaceholder draws a box showing the path and alt text of an image that
+// couldn't be loaded, like a remote image that wasn't downloaded; remote paths are linked
+func (w *Writer) writeImagePlaceholder(path, alt string, err error, opts ImageOptions) {
+	w.missingImages = append(w.missingImages, MissingImage{path, err})
+	defer w.beginBlock("image_placeholder", opts.ID, "", false)()
+	defer w.beginTag("Figure", cmp.Or(alt, opts.Alt, opts.Caption))()
+	width := w.contentWidth()
+	if requested, ok := w.imageLength(path, opts.Width, width); ok {
+		width = min(requested, width)
+	}
+	height := 25.0
+
+	w.placeBlock(height)
+	y := w.pdf.GetY()
+	w.setAnchor(opts.ID)
+
+	left, _, _, _ := w.pdf.GetMargins()
+	x := left
+	switch opts.Align {
+	case "center":
+		x += (w.contentWidth() - width) / 2
+	case "right":
+		x += w.contentWidth() - width
+	}
+
+	w.setDrawColor(180, 180, 180)
+	w.setFillColor(245, 245, 245)
+	w.pdf.SetLineWidth(0.3)
+	w.pdf.SetDashPattern([]float64{1.5, 1}, 0)
+	w.pdf.Rect(x, y, width, height, "FD")
+	w.pdf.SetDashPattern(nil, 0)
+	w.figures++
+	w.pdf.SetLineWidth(0.2)
+
+	lines := 2.0
+	if alt != "" {
+		lines++
+	}
+	w.setFont(fontBody, "B", 10)
+	w.setTextColor(120, 120, 120)
+	w.pdf.SetXY(x, y+(height-lines*5)/2)
+	w.keepTogether(func() {
+		w.pdf.CellFormat(width, 5, "Image not available", "", 2, "C", false, 0, "")
+		w.setFont(fontBody, "", 9)
+		if alt != "" {
+			w.pdf.CellFormat(width, 5, w.fitText(alt, width-4), "", 2, "C", false, 0, "")
+		}
+		link := ""
+		if IsRemote(path) {
+			link = path
+		}
+		w.pdf.CellFormat(width, 5, w.fitText(path, width-4), "", 0, "C", false, 0, link)
+	})
+	w.setTextColor(0, 0, 0)
+	w.setFont(fontBody, "", 12)
+
+	w.pdf.SetXY(left, y+height)
+	w.pdf.Ln(4)
+}
+
+// applyImageSize replaces the natural image size with the requested one, keeping
+// the aspect ratio when only one dimension is given
+func (w *Writer) applyImageSize(name string, width, height *float64, opts ImageOptions, contentWidth, contentHeight float64) {
+	ratio := *height / *width
+	reqWidth, okWidth := w.imageLength(name, opts.Width, contentWidth)
+	reqHeight, okHeight := w.imageLength(name, opts.Height, contentHeight)
+	switch {
+	case okWidth && okHeight:
+		*width, *height = reqWidth, reqHeight
+	case okWidth:
+		*width, *height = reqWidth, reqWidth*ratio
+	case okHeight:
+		*width, *height = reqHeight/ratio, reqHeight
+	}
+}
+
+// imageLength parses an image dimension in millimeters; percentages are relative to full.
+// Invalid lengths are ignored with a warning.
+func (w *Writer) imageLength(name, s string, full float64) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	length, err := parseLength(s, full)
+	if err != nil || length <= 0 {
+		w.warnf("image %s: invalid size %q", name, s)
+		return 0, false
+	}
+	return length, true
+}
+
+// lengthUnits converts CSS-style length units to millimeters
+var lengthUnits = map[string]float64{
+	"mm": 1,
+	"cm": 10,
+	"in": 25.4,
+	"pt": 25.4 / 72,
+	"px": 25.4 / 96,
+	"":   25.4 / 96,
+	// em is the size of the body text
+	"em": 12 * 25.4 / 72,
+}
+
+// parseLength parses a length such as "50%", "60mm", "200px" or "2em" to millimeters
+func parseLength(s string, full float64) (float64, error) {
+	s = strings.TrimSpace(s)
+	if number, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(number, 64)
+		return v / 100 * full, err
+	}
+
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := lengthUnits[strings.ToLower(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", s[i:])
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	return v * unit, err
+}
@@ -0,0 +1,361 @@
+package pdf
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// justifiedPiece is the part of a word in one span, with its width
+type justifiedPiece struct {
+	span  int
+	text  string
+	width float64
+}
+
+// justifiedWord is a word of a justified paragraph, which may run across spans
+type justifiedWord struct {
+	pieces []justifiedPiece
+	width  float64
+	// space is the width of the space after the word, newline whether a line break
+	// follows instead
+	space   float64
+	newline bool
+	// soft are the rune offsets of the soft hyphens taken out of the word
+	soft []int
+}
+
+// justifiedLine is a line of a justified paragraph. Its width includes the spaces
+// between the words; the last line and lines before a break are not stretched.
+type justifiedLine struct {
+	words []justifiedWord
+	width float64
+	last  bool
+}
+
+// justifiable reports whether spans can be set justified: text without inline images,
+// subscripts and superscripts, which keep the ragged layout of writeSpans
+func justifiable(spans []Span) bool {
+	for _, span := range spans {
+		if span.Image != nil || span.Sub || span.Sup {
+			return false
+		}
+	}
+	return true
+}
+
+// setSpanFont sets the font of a span in text of the given size
+func (w *Writer) setSpanFont(span Span, size float64) {
+	w.setFont(span.role(), span.style(), span.fontSize(size))
+}
+
+// spanText returns the text a span shows: the page number of a page reference
+func (w *Writer) spanText(span Span) string {
+	if span.PageRef == "" {
+		return span.Text
+	}
+	anchor, ok := w.anchor(span.PageRef)
+	if !ok {
+		return "?"
+	}
+	return w.numbers.Int(anchor.Page)
+}
+
+// justifiedWords splits spans into words at spaces and line breaks, and between CJK
+// characters where the kinsoku rules allow, with no space between such words
+func (w *Writer) justifiedWords(spans []Span, size float64) []justifiedWord {
+	var words []justifiedWord
+	var word justifiedWord
+	var piece strings.Builder
+	runes := 0
+	var last rune
+	flushPiece := func(span int) {
+		if piece.Len() == 0 {
+			return
+		}
+		text := piece.String()
+		word.pieces = append(word.pieces, justifiedPiece{span: span, text: text, width: w.pdf.GetStringWidth(text)})
+		word.width += word.pieces[len(word.pieces)-1].width
+		piece.Reset()
+	}
+	flushWord := func() {
+		if len(word.pieces) > 0 {
+			words = append(words, word)
+		} else if len(words) > 0 {
+			// Consecutive spaces widen the one before
+			words[len(words)-1].space += word.space
+			words[len(words)-1].newline = words[len(words)-1].newline || word.newline
+		}
+		word, runes = justifiedWord{}, 0
+	}
+
+	for i, span := range spans {
+		w.setSpanFont(span, size)
+		text := shapeArabic(w.spanText(span))
+		if span.PageRef != "" {
+			// Page numbers aren't broken
+			piece.WriteString(text)
+			runes += len([]rune(text))
+			flushPiece(i)
+			last = 0
+			continue
+		}
+		for _, r := range text {
+			if last != 0 && cjkBreak(last, r) {
+				flushPiece(i)
+				flushWord()
+			}
+			last = r
+			switch r {
+			case ' ', '\n':
+				flushPiece(i)
+				if r == '\n' {
+					word.newline = true
+				} else {
+					word.space = w.pdf.GetStringWidth(" ")
+				}
+				flushWord()
+			case softHyphen:
+				word.soft = append(word.soft, runes)
+			default:
+				piece.WriteRune(r)
+				runes++
+			}
+		}
+		flushPiece(i)
+	}
+	flushWord()
+	return words
+}
+
+// justifyLines breaks spans into lines of the given width, hyphenating words that
+// don't fit at their soft hyphens or else where the theme's hyphenation allows
+func (w *Writer) justifyLines(spans []Span, size, width float64) []justifiedLine {
+	words := w.justifiedWords(spans, size)
+	var lines []justifiedLine
+	var line justifiedLine
+	endLine := func(last bool) {
+		line.last = last
+		lines = append(lines, line)
+		line = justifiedLine{}
+	}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		used := line.width
+		if len(line.words) > 0 {
+			used += line.words[len(line.words)-1].space
+		}
+		if used+word.width > width {
+			head, tail, ok := w.hyphenateWord(spans, word, width-used, size, len(line.words) == 0)
+			if ok {
+				line.words = append(line.words, head)
+				line.width = used + head.width
+				endLine(false)
+				words[i] = tail
+				i--
+				continue
+			}
+			if len(line.words) > 0 {
+				endLine(false)
+				i--
+				continue
+			}
+		}
+		line.words = append(line.words, word)
+		line.width = used + word.width
+		if word.newline {
+			endLine(true)
+		}
+	}
+	if len(line.words) > 0 || len(lines) == 0 {
+		endLine(true)
+	}
+	return lines
+}
+
+// hyphenateWord splits a word so its head with a hyphen fits in width. With force,
+// a word that can't be hyphenated is cut where it overflows the line.
+func (w *Writer) hyphenateWord(spans []Span, word justifiedWord, width, size float64, force bool) (head, tail justifiedWord, ok bool) {
+	for _, point := range slices.Backward(w.hyphenationPoints(word)) {
+		head, tail = w.cutWord(spans, word, point, "-", size)
+		if head.width <= width {
+			return head, tail, true
+		}
+	}
+	if !force {
+		return head, tail, false
+	}
+	total := 0
+	for _, piece := range word.pieces {
+		total += len([]rune(piece.text))
+	}
+	for point := total - 1; point > 0; point-- {
+		head, tail = w.cutWord(spans, word, point, "", size)
+		if head.width <= width || point == 1 {
+			return head, tail, true
+		}
+	}
+	return head, tail, false
+}
+
+// hyphenationPoints returns the rune offsets where a word may be hyphenated: its soft
+// hyphens, or else the points of the theme's hyphenation in the letters of the word
+// between any leading and trailing punctuation
+func (w *Writer) hyphenationPoints(word justifiedWord) []int {
+	if len(word.soft) > 0 {
+		return word.soft
+	}
+	if w.theme.Hyphenation == nil {
+		return nil
+	}
+	var text []rune
+	for _, piece := range word.pieces {
+		text = append(text, []rune(piece.text)...)
+	}
+	start := slices.IndexFunc(text, unicode.IsLetter)
+	if start < 0 {
+		return nil
+	}
+	end := len(text)
+	for !unicode.IsLetter(text[end-1]) {
+		end--
+	}
+	if slices.ContainsFunc(text[start:end], func(r rune) bool { return !unicode.IsLetter(r) }) {
+		return nil
+	}
+	points := w.theme.Hyphenation.Points(string(text[start:end]))
+	for i := range points {
+		points[i] += start
+	}
+	return points
+}
+
+// cutWord splits a word at a rune offset, ending the head with mark
+func (w *Writer) cutWord(spans []Span, word justifiedWord, at int, mark string, size float64) (head, tail justifiedWord) {
+	measure := func(span int, text string) justifiedPiece {
+		w.setSpanFont(spans[span], size)
+		return justifiedPiece{span: span, text: text, width: w.pdf.GetStringWidth(text)}
+	}
+	offset := 0
+	for _, piece := range word.pieces {
+		runes := []rune(piece.text)
+		switch {
+		case offset+len(runes) <= at:
+			head.pieces = append(head.pieces, piece)
+		case offset >= at:
+			tail.pieces = append(tail.pieces, piece)
+		default:
+			cut := at - offset
+			head.pieces = append(head.pieces, measure(piece.span, string(runes[:cut])))
+			tail.pieces = append(tail.pieces, measure(piece.span, string(runes[cut:])))
+		}
+		offset += len(runes)
+	}
+	if mark != "" {
+		last := &head.pieces[len(head.pieces)-1]
+		*last = measure(last.span, last.text+mark)
+	}
+	for _, piece := range head.pieces {
+		head.width += piece.width
+	}
+	for _, piece := range tail.pieces {
+		tail.width += piece.width
+	}
+	tail.space, tail.newline = word.space, word.newline
+	for _, point := range word.soft {
+		if point > at {
+			tail.soft = append(tail.soft, point-at)
+		}
+	}
+	w.setFont(fontBody, "", size)
+	return head, tail
+}
+
+// writeJustified writes the lines of a justified paragraph from the left margin,
+// stretching the spaces of each line but the last to the content width. Like
+// writeSpans, it leaves the position at the end of the last line.
+func (w *Writer) writeJustified(spans []Span, lines []justifiedLine, lineHeight, size float64) {
+	margin := w.pdf.GetCellMargin()
+	w.pdf.SetCellMargin(0)
+	defer w.pdf.SetCellMargin(margin)
+
+	links := w.spanLinks(spans)
+
+	left, _, _, _ := w.pdf.GetMargins()
+	x := left
+	for i, line := range lines {
+		if i > 0 {
+			w.pdf.Ln(lineHeight)
+		}
+		if w.breakLine(lineHeight) {
+			left, _, _, _ = w.pdf.GetMargins()
+		}
+		stretch := 0.0
+		if !line.last && len(line.words) > 1 {
+			stretch = (w.contentWidth() - line.width) / float64(len(line.words)-1)
+		}
+		x = left
+		for j, word := range line.words {
+			if j > 0 {
+				x += line.words[j-1].space + stretch
+			}
+			for _, piece := range word.pieces {
+				w.setSpanFont(spans[piece.span], size)
+				w.pdf.SetX(x)
+				w.drawSpanText(spans[piece.span], piece.text, piece.width, lineHeight, links[piece.span])
+				x += piece.width
+			}
+		}
+	}
+	w.pdf.SetX(x)
+	w.setFont(fontBody, "", size)
+}
+
+// spanLinks returns the links to the anchors of the internal links and page
+// references among spans, by span index, warning about unknown anchors
+func (w *Writer) spanLinks(spans []Span) map[int]int {
+	links := map[int]int{}
+	for i, span := range spans {
+		id, internal := strings.CutPrefix(span.Link, "#")
+		if span.PageRef != "" {
+			id, internal = span.PageRef, true
+		}
+		if !internal {
+			continue
+		}
+		anchor, ok := w.anchor(id)
+		if !ok {
+			if w.layout != nil && span.PageRef != "" {
+				w.warnf("page reference to unknown anchor #%s", id)
+			} else if w.layout != nil {
+				w.warnf("link to unknown anchor #%s", id)
+			}
+			continue
+		}
+		links[i] = w.pdf.AddLink()
+		w.pdf.SetLink(links[i], anchor.Y, anchor.Page)
+	}
+	return links
+}
+
+// drawSpanText writes text of a span, in its font already set, in a cell of the given
+// width at the current position, in the link color if it links to link or a URL
+func (w *Writer) drawSpanText(span Span, text string, width, lineHeight float64, link int) {
+	color := span.Color
+	external := ""
+	if span.Link != "" && !strings.HasPrefix(span.Link, "#") {
+		external = span.Link
+	}
+	if link != 0 || external != "" {
+		color = "link"
+	}
+	if color != "" {
+		c := w.theme.color(color)
+		w.setTextColor(c.R, c.G, c.B)
+	}
+	w.pdf.CellFormat(width, lineHeight, text, "", 0, "L", false, link, external)
+	if color != "" {
+		w.setTextColor(0, 0, 0)
+	}
+}
@@ -0,0 +1,170 @@
+package pdf
+
+import "slices"
+
+const (
+	headingSpaceBefore = 4.0
+	headingLineHeight  = 12.0
+	headingSpaceAfter  = 3.0
+)
+
+// headingSize returns the font size of a heading level
+func headingSize(level int) float64 {
+	switch level {
+	case 1:
+		return 20
+	case 2:
+		return 16
+	case 3:
+		return 14
+	case 4:
+		return 13
+	case 5:
+		return 12.5
+	default:
+		return 12
+	}
+}
+
+// placeBlock draws the queued headings in front of the next block, keeping them on
+// one page with its first keep millimeters: if they don't fit together, both move to
+// a new page. Blocks that can't break across pages pass their full height; others
+// pass the height of their first line or row.
+func (w *Writer) placeBlock(keep float64) {
+	headings := w.pendingHeadings
+	w.pendingHeadings = nil
+
+	// Headings of the top levels span the columns, which start again below them
+	spanned := slices.ContainsFunc(headings, func(h Heading) bool { return h.Level <= 2 }) && w.spanColumns()
+
+	needed := float64(len(headings))*(headingSpaceBefore+headingLineHeight+headingSpaceAfter) + keep
+	if w.pdf.GetY() > w.blockTop() && w.remainingSpace() < needed {
+		w.breakColumn()
+	}
+
+	for _, h := range headings {
+		w.drawHeading(h)
+	}
+	if spanned {
+		w.resumeColumns()
+	}
+	w.placeCurrentBlock()
+}
+
+// keepTogether draws a block whose page placeBlock or the caller has already chosen
+// with gofpdf's automatic page break turned off, so a line reaching a little past the
+// bottom margin can't split the block across pages
+func (w *Writer) keepTogether(draw func()) {
+	auto, margin := w.pdf.GetAutoPageBreak()
+	w.pdf.SetAutoPageBreak(false, margin)
+	defer w.pdf.SetAutoPageBreak(auto, margin)
+	draw()
+}
+
+// flushHeadings draws the queued headings without a block to keep them with
+func (w *Writer) flushHeadings() {
+	if len(w.pendingHeadings) > 0 {
+		w.placeBlock(0)
+	}
+}
+
+// breakParagraph chooses where a paragraph of the given number of lines breaks across
+// pages, leaving at least orphans lines at the bottom of the page and at least widows
+// lines at the top of the next. It moves the whole paragraph to a new page or brings the
+// automatic page break forward as needed. Only the first break of a paragraph is adjusted.
+func (w *Writer) breakParagraph(lines int, lineHeight float64, orphans, widows int) {
+	fit := int(w.remainingSpace()/lineHeight + 1e-6)
+	if lines <= fit {
+		return
+	}
+
+	k := fit
+	if lines-k < widows {
+		k = lines - widows
+	}
+	if k < orphans {
+		if w.pdf.GetY() > w.blockTop() {
+			w.breakColumn()
+		}
+		return
+	}
+	if k < fit {
+		// Break after k lines; the accept page break function restores the margin
+		_, pageHeight := w.pdf.GetPageSize()
+		w.pdf.SetAutoPageBreak(true, pageHeight-(w.pdf.GetY()+float64(k)*lineHeight+0.01))
+	}
+}
+
+// spanLines returns the number of lines writeSpans needs for spans starting at the left
+// margin. It follows the line breaking of gofpdf's Write, which writeSpans calls per span.
+func (w *Writer) spanLines(spans []Span, size float64) int {
+	margin := w.pdf.GetCellMargin()
+	full := w.contentWidth()
+	lines, x := 1, 0.0
+
+	for _, span := range spans {
+		w.setFont(span.role(), span.style(), span.fontSize(size))
+		if span.Image != nil {
+			// Images move to the next line whole, like writeInlineImage does
+			width := span.Image.Width * size * ptToMM
+			if x > 0 && x+width > full-2*margin {
+				lines++
+				x = 0
+			}
+			x += width
+			continue
+		}
+		text := []rune(span.Text)
+		if span.PageRef != "" {
+			text = []rune("00")
+		}
+		if len(text) == 1 && text[0] == ' ' {
+			x += w.pdf.GetStringWidth(" ")
+			continue
+		}
+
+		wmax := full - x - 2*margin
+		sep, l := -1, 0.0
+		for i := 0; i < len(text); {
+			c := text[i]
+			if c == '\n' {
+				i++
+				sep, l, x = -1, 0, 0
+				wmax = full - 2*margin
+				lines++
+				continue
+			}
+			if c == ' ' {
+				sep = i
+			}
+			l += w.pdf.GetStringWidth(string(c))
+			if l <= wmax {
+				i++
+				continue
+			}
+
+			if sep == -1 && x > 0 {
+				// The word continues a line started by a previous span and moves down whole
+				i++
+				x = 0
+				wmax = full - 2*margin
+				lines++
+				continue
+			}
+			if sep == -1 {
+				if l == w.pdf.GetStringWidth(string(c)) {
+					i++
+				}
+			} else {
+				i = sep + 1
+			}
+			sep, l, x = -1, 0, 0
+			wmax = full - 2*margin
+			lines++
+		}
+		x += l
+	}
+
+	w.setFont(fontBody, "", size)
+	return lines
+}
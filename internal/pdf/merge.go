@@ -0,0 +1,476 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	objectHeaderRegex = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	anyReferenceRegex = regexp.MustCompile(`(\d+)\s+(\d+)\s+R\b`)
+	importRootRegex   = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R\b`)
+	encryptRegex      = regexp.MustCompile(`/Encrypt\s+(\d+\s+\d+\s+R|<<)`)
+)
+
+// inheritedKeys are the page attributes a page inherits from its page tree
+var inheritedKeys = []string{"/Resources", "/MediaBox", "/CropBox", "/Rotate"}
+
+// importedObject is an object of an existing PDF: its value and, for a stream, its data
+type importedObject struct {
+	value  []byte
+	stream []byte
+}
+
+// importedPDF is an existing PDF whose pages are merged into the output
+type importedPDF struct {
+	objects map[int]importedObject
+	// pages are the page objects in order, with the attributes each inherits
+	pages     []int
+	inherited map[int]map[string][]byte
+}
+
+// CheckImport reports why the pages of an existing PDF can't be merged into the
+// output, nil if they can
+func CheckImport(data []byte) error {
+	_, err := parseImport(data)
+	return err
+}
+
+// SetMerged merges the pages of existing PDFs into the output: those of before ahead
+// of the document's pages, those of after behind them. The generated page numbers, such
+// as those of the footer and the table of contents, don't count the merged pages.
+func (w *Writer) SetMerged(before, after [][]byte) {
+	w.mergeBefore, w.mergeAfter = before, after
+}
+
+// parseImport reads the objects of an existing PDF by scanning the file for them
+// rather than through its cross-reference sections, which makes it tolerant of
+// incremental updates, cross-reference streams and damaged tables alike. Objects in
+// object streams are read as well. Encrypted PDFs aren't supported.
+func parseImport(data []byte) (*importedPDF, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if encryptRegex.Match(data) {
+		return nil, errors.New("encrypted PDFs can't be merged")
+	}
+	p := &importedPDF{objects: map[int]importedObject{}, inherited: map[int]map[string][]byte{}}
+
+	// Objects defined later, by incremental updates, replace earlier ones
+	for pos := 0; ; {
+		loc := objectHeaderRegex.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		start := skipSpace(data, pos+loc[1])
+		end := valueEnd(data, start)
+		obj := importedObject{value: data[start:end]}
+		pos = end
+		if after := skipSpace(data, end); bytes.HasPrefix(data[after:], []byte("stream")) {
+			streamStart := after + len("stream")
+			if bytes.HasPrefix(data[streamStart:], []byte("\r\n")) {
+				streamStart += 2
+			} else if streamStart < len(data) && (data[streamStart] == '\n' || data[streamStart] == '\r') {
+				streamStart++
+			}
+			streamEnd := -1
+			if length, ok := directInt(dictValue(obj.value, "/Length")); ok && streamStart+length <= len(data) &&
+				bytes.HasPrefix(bytes.TrimLeft(data[streamStart+length:], "\r\n "), []byte("endstream")) {
+				streamEnd = streamStart + length
+			} else if i := bytes.Index(data[streamStart:], []byte("endstream")); i >= 0 {
+				streamEnd = streamStart + i
+				for streamEnd > streamStart && (data[streamEnd-1] == '\n' || data[streamEnd-1] == '\r') {
+					streamEnd--
+				}
+			}
+			if streamEnd < 0 {
+				return nil, fmt.Errorf("damaged PDF: stream of object %d has no end", num)
+			}
+			obj.stream = data[streamStart:streamEnd]
+			pos = streamEnd
+		}
+		p.objects[num] = obj
+	}
+	for num, obj := range maps.Clone(p.objects) {
+		if string(dictValue(obj.value, "/Type")) == "/ObjStm" {
+			if err := p.readObjectStream(obj); err != nil {
+				return nil, fmt.Errorf("damaged PDF: object stream %d: %w", num, err)
+			}
+		}
+	}
+
+	m := importRootRegex.FindAllSubmatch(data, -1)
+	if m == nil {
+		return nil, errors.New("damaged PDF: no catalog")
+	}
+	root, _ := strconv.Atoi(string(m[len(m)-1][1]))
+	tree, ok := refNumber(dictValue(p.objects[root].value, "/Pages"))
+	if !ok {
+		return nil, errors.New("damaged PDF: no page tree")
+	}
+	if err := p.collectPages(tree, map[string][]byte{}, map[int]bool{}); err != nil {
+		return nil, err
+	}
+	if len(p.pages) == 0 {
+		return nil, errors.New("the PDF has no pages")
+	}
+	return p, nil
+}
+
+// readObjectStream adds the objects compressed in an object stream, unless they are
+// also defined directly
+func (p *importedPDF) readObjectStream(obj importedObject) error {
+	if filter := string(dictValue(obj.value, "/Filter")); filter != "/FlateDecode" && filter != "[/FlateDecode]" {
+		return fmt.Errorf("unsupported filter %s", filter)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(obj.stream))
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(r)
+	if err != nil && len(content) == 0 {
+		return err
+	}
+	count, _ := directInt(dictValue(obj.value, "/N"))
+	first, _ := directInt(dictValue(obj.value, "/First"))
+	if first > len(content) {
+		return errors.New("invalid /First")
+	}
+	header := strings.Fields(string(content[:first]))
+	for i := 0; i < count && 2*i+1 < len(header); i++ {
+		num, err1 := strconv.Atoi(header[2*i])
+		offset, err2 := strconv.Atoi(header[2*i+1])
+		if err1 != nil || err2 != nil || first+offset > len(content) {
+			return errors.New("invalid object offsets")
+		}
+		if _, ok := p.objects[num]; ok {
+			continue
+		}
+		start := skipSpace(content, first+offset)
+		p.objects[num] = importedObject{value: content[start:valueEnd(content, start)]}
+	}
+	return nil
+}
+
+// collectPages lists the pages of a page tree node in order, with the attributes they
+// inherit from it
+func (p *importedPDF) collectPages(num int, inherited map[string][]byte, seen map[int]bool) error {
+	if seen[num] {
+		return errors.New("damaged PDF: cycle in the page tree")
+	}
+	seen[num] = true
+	node, ok := p.objects[num]
+	if !ok {
+		return fmt.Errorf("damaged PDF: missing page tree node %d", num)
+	}
+	kids := dictValue(node.value, "/Kids")
+	if kids == nil {
+		p.pages = append(p.pages, num)
+		p.inherited[num] = inherited
+		return nil
+	}
+	inherited = maps.Clone(inherited)
+	for _, key := range inheritedKeys {
+		if value := dictValue(node.value, key); value != nil {
+			inherited[key] = value
+		}
+	}
+	if ref, ok := refNumber(kids); ok {
+		kids = p.objects[ref].value
+	}
+	for _, ref := range anyReferenceRegex.FindAllSubmatch(kids, -1) {
+		kid, _ := strconv.Atoi(string(ref[1]))
+		if err := p.collectPages(kid, inherited, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePDFs adds the pages of existing PDFs before and after the pages of a PDF written
+// by Writer, returning the number of pages added before
+func mergePDFs(data []byte, before, after [][]byte) ([]byte, int, error) {
+	objects, root, info, err := parseObjects(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	m := pagesRegex.FindSubmatch(objects[root].dict)
+	if m == nil {
+		return nil, 0, errors.New("invalid PDF: no page tree")
+	}
+	tree, _ := strconv.Atoi(string(m[1]))
+	kids := kidsRegex.FindSubmatch(objects[tree].dict)
+	if kids == nil {
+		return nil, 0, errors.New("invalid PDF: page tree without pages")
+	}
+
+	next := slices.Max(slices.Collect(maps.Keys(objects))) + 1
+	importAll := func(files [][]byte) ([]byte, error) {
+		var refs []byte
+		for i, file := range files {
+			imported, err := parseImport(file)
+			if err != nil {
+				return nil, fmt.Errorf("PDF %d: %w", i+1, err)
+			}
+			for _, page := range imported.importPages(objects, tree, &next) {
+				refs = fmt.Appendf(refs, "%d 0 R ", page)
+			}
+		}
+		return refs, nil
+	}
+	first, err := importAll(before)
+	if err != nil {
+		return nil, 0, err
+	}
+	last, err := importAll(after)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pageRefs := bytes.Join(bytes.Fields(slices.Concat(first, kids[1], []byte(" "), last)), []byte(" "))
+	count := len(referenceRegex.FindAll(pageRefs, -1))
+	dict := kidsRegex.ReplaceAllLiteral(objects[tree].dict, fmt.Appendf(nil, "/Kids [%s]", pageRefs))
+	dict = countRegex.ReplaceAllLiteral(dict, fmt.Appendf(nil, "/Count %d", count))
+	objects[tree] = pdfObject{dict: dict, stream: objects[tree].stream}
+	return writeObjects(objects, root, info, nil), len(referenceRegex.FindAll(first, -1)), nil
+}
+
+// importPages adds the pages of an imported PDF and the objects they use to objects,
+// numbered from next on, as kids of the page tree node tree. Links to the imported
+// PDF's page tree and catalog are dropped.
+func (p *importedPDF) importPages(objects map[int]pdfObject, tree int, next *int) []int {
+	numbers := map[int]int{}
+	var queue []int
+	number := func(old int) (int, bool) {
+		if n, ok := numbers[old]; ok {
+			return n, true
+		}
+		obj, ok := p.objects[old]
+		if !ok {
+			return 0, false
+		}
+		switch string(dictValue(obj.value, "/Type")) {
+		case "/Catalog", "/Pages":
+			return 0, false
+		}
+		numbers[old] = *next
+		*next++
+		queue = append(queue, old)
+		return numbers[old], true
+	}
+	renumber := func(b []byte) []byte {
+		return anyReferenceRegex.ReplaceAllFunc(b, func(ref []byte) []byte {
+			old, _ := strconv.Atoi(string(anyReferenceRegex.FindSubmatch(ref)[1]))
+			if n, ok := number(old); ok {
+				return fmt.Appendf(nil, "%d 0 R", n)
+			}
+			return []byte("null")
+		})
+	}
+
+	var pages []int
+	for _, page := range p.pages {
+		n, _ := number(page)
+		pages = append(pages, n)
+	}
+	for len(queue) > 0 {
+		old := queue[0]
+		queue = queue[1:]
+		obj := p.objects[old]
+		value := obj.value
+		if _, isPage := p.inherited[old]; isPage {
+			value = p.pageDict(old)
+		}
+		if obj.stream != nil {
+			// The length may be an object of its own, which page info checksums can't read
+			value = setDictValue(value, "/Length", strconv.AppendInt(nil, int64(len(obj.stream)), 10))
+		}
+		value = renumber(value)
+		if _, isPage := p.inherited[old]; isPage {
+			value = setDictValue(value, "/Parent", fmt.Appendf(nil, "%d 0 R", tree))
+		}
+		dict := fmt.Appendf(nil, "%d 0 obj\n%s", numbers[old], value)
+		if obj.stream == nil {
+			objects[numbers[old]] = pdfObject{dict: append(dict, "\nendobj\n"...)}
+			continue
+		}
+		objects[numbers[old]] = pdfObject{
+			dict:   append(dict, "\nstream\n"...),
+			stream: slices.Concat(obj.stream, []byte("\nendstream\nendobj\n")),
+		}
+	}
+	return pages
+}
+
+// pageDict returns the dictionary of an imported page with its inherited attributes
+// and without its parent. Its place in the structure tree is dropped.
+func (p *importedPDF) pageDict(page int) []byte {
+	keys, values := dictEntries(p.objects[page].value)
+	var b bytes.Buffer
+	b.WriteString("<<")
+	for i, key := range keys {
+		switch key {
+		case "/Parent", "/StructParents":
+			continue
+		case "/Contents":
+			// Content streams are listed on the page, like page info checksums expect
+			if ref, ok := refNumber(values[i]); ok && p.objects[ref].stream == nil {
+				values[i] = p.objects[ref].value
+			}
+		}
+		fmt.Fprintf(&b, "%s %s\n", key, values[i])
+	}
+	for _, key := range inheritedKeys {
+		if value, ok := p.inherited[page][key]; ok && !slices.Contains(keys, key) {
+			fmt.Fprintf(&b, "%s %s\n", key, value)
+		}
+	}
+	b.WriteString(">>")
+	return b.Bytes()
+}
+
+// setDictValue returns a dictionary with the value of a key replaced, or added
+func setDictValue(dict []byte, key string, value []byte) []byte {
+	keys, values := dictEntries(dict)
+	var b bytes.Buffer
+	b.WriteString("<<")
+	for i := range keys {
+		if keys[i] != key {
+			fmt.Fprintf(&b, "%s %s\n", keys[i], values[i])
+		}
+	}
+	fmt.Fprintf(&b, "%s %s>>", key, value)
+	return b.Bytes()
+}
+
+// isDelimiter reports whether c ends a PDF name, number or keyword
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f\x00()<>[]{}/%", c) >= 0
+}
+
+// skipSpace returns the position of the first token at or after i, past whitespace
+// and comments
+func skipSpace(b []byte, i int) int {
+	for i < len(b) {
+		switch b[i] {
+		case ' ', '\t', '\r', '\n', '\f', 0:
+			i++
+		case '%':
+			for i < len(b) && b[i] != '\n' && b[i] != '\r' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// valueEnd returns the end of the PDF value starting at i: a dictionary, array,
+// string, name, number, keyword or indirect reference
+func valueEnd(b []byte, i int) int {
+	if i >= len(b) {
+		return len(b)
+	}
+	switch {
+	case bytes.HasPrefix(b[i:], []byte("<<")):
+		for j := skipSpace(b, i+2); j < len(b); j = skipSpace(b, j) {
+			if bytes.HasPrefix(b[j:], []byte(">>")) {
+				return j + 2
+			}
+			j = max(valueEnd(b, j), j+1)
+		}
+		return len(b)
+	case b[i] == '<':
+		if end := bytes.IndexByte(b[i:], '>'); end >= 0 {
+			return i + end + 1
+		}
+		return len(b)
+	case b[i] == '(':
+		depth := 0
+		for j := i; j < len(b); j++ {
+			switch b[j] {
+			case '\\':
+				j++
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(b)
+	case b[i] == '[':
+		for j := skipSpace(b, i+1); j < len(b); j = skipSpace(b, j) {
+			if b[j] == ']' {
+				return j + 1
+			}
+			j = max(valueEnd(b, j), j+1)
+		}
+		return len(b)
+	}
+
+	end := i + 1
+	for end < len(b) && !isDelimiter(b[end]) {
+		end++
+	}
+	// A number may start an indirect reference like 12 0 R
+	if _, err := strconv.Atoi(string(b[i:end])); err == nil {
+		if m := anyReferenceRegex.FindIndex(b[i:min(len(b), i+32)]); m != nil && m[0] == 0 {
+			return i + m[1]
+		}
+	}
+	return end
+}
+
+// dictEntries returns the keys of a dictionary and their values
+func dictEntries(dict []byte) (keys []string, values [][]byte) {
+	i := skipSpace(dict, 0)
+	if !bytes.HasPrefix(dict[i:], []byte("<<")) {
+		return nil, nil
+	}
+	for i = skipSpace(dict, i+2); i < len(dict) && dict[i] == '/'; {
+		keyEnd := valueEnd(dict, i)
+		valueStart := skipSpace(dict, keyEnd)
+		end := valueEnd(dict, valueStart)
+		keys = append(keys, string(dict[i:keyEnd]))
+		values = append(values, dict[valueStart:end])
+		i = skipSpace(dict, end)
+	}
+	return keys, values
+}
+
+// dictValue returns the value of a key of a dictionary, nil if it has none
+func dictValue(dict []byte, key string) []byte {
+	keys, values := dictEntries(dict)
+	if i := slices.Index(keys, key); i >= 0 {
+		return values[i]
+	}
+	return nil
+}
+
+// directInt parses an integer value
+func directInt(value []byte) (int, bool) {
+	n, err := strconv.Atoi(string(value))
+	return n, err == nil
+}
+
+// refNumber returns the object number of an indirect reference
+func refNumber(value []byte) (int, bool) {
+	m := anyReferenceRegex.FindSubmatch(value)
+	if m == nil || !bytes.Equal(bytes.TrimSpace(value), m[0]) {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(string(m[1]))
+	return n, true
+}
@@ -0,0 +1,178 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a PDF from numbered object bodies, the catalog being object 1, and a
+// trailer. Like parseImport, it does without a cross-reference table.
+func testPDF(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	fmt.Fprintf(&b, "trailer\n%s\n%%%%EOF\n", trailer)
+	return b.Bytes()
+}
+
+// contentStream is a page content stream showing text
+func contentStream(text string) string {
+	content := fmt.Sprintf("BT /F1 12 Tf 10 50 Td (%s) Tj ET", text)
+	return fmt.Sprintf("<</Length %d>>\nstream\n%s\nendstream", len(content), content)
+}
+
+// objectStream is a compressed object stream holding object num
+func objectStream(num int, value string) string {
+	header := fmt.Sprintf("%d 0 ", num)
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write([]byte(header + value))
+	w.Close()
+	return fmt.Sprintf("<</Type /ObjStm /N 1 /First %d /Filter /FlateDecode /Length %d>>\nstream\n%s\nendstream", len(header), z.Len(), z.Bytes())
+}
+
+func TestParseImport(t *testing.T) {
+	const (
+		catalog = "<</Type /Catalog /Pages 2 0 R>>"
+		trailer = "<</Root 1 0 R>>"
+	)
+	tests := []struct {
+		name  string
+		data  []byte
+		pages []string // the text of each page in order; nil if the PDF is rejected
+		want  string   // part of the first page's dictionary
+	}{
+		{"not a PDF", []byte("hello"), nil, ""},
+		{"encrypted", testPDF("<</Root 1 0 R /Encrypt 5 0 R>>", catalog,
+			"<</Type /Pages /Kids [3 0 R] /Count 1>>", "<</Type /Page /Parent 2 0 R /Contents 4 0 R>>", contentStream("One")), nil, ""},
+		{"no catalog", testPDF("<<>>", catalog,
+			"<</Type /Pages /Kids [3 0 R] /Count 1>>", "<</Type /Page /Parent 2 0 R /Contents 4 0 R>>", contentStream("One")), nil, ""},
+		{"no pages", testPDF(trailer, catalog, "<</Type /Pages /Kids [] /Count 0>>"), nil, ""},
+		{"cycle in the page tree", testPDF(trailer, catalog, "<</Type /Pages /Kids [2 0 R] /Count 1>>"), nil, ""},
+		{"one page", testPDF(trailer, catalog,
+			"<</Type /Pages /Kids [3 0 R] /Count 1>>", "<</Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Contents 4 0 R>>", contentStream("One")),
+			[]string{"One"}, "/MediaBox [0 0 100 100]"},
+		{"inherited attributes", testPDF(trailer, catalog,
+			"<</Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 100] /Rotate 90>>", "<</Type /Page /Parent 2 0 R /Contents 4 0 R>>", contentStream("One")),
+			[]string{"One"}, "/Rotate 90"},
+		{"own attributes win", testPDF(trailer, catalog,
+			"<</Type /Pages /Kids [3 0 R] /Count 1 /Rotate 90>>", "<</Type /Page /Parent 2 0 R /Rotate 180 /Contents 4 0 R>>", contentStream("One")),
+			[]string{"One"}, "/Rotate 180"},
+		{"nested page tree", testPDF(trailer, catalog,
+			"<</Type /Pages /Kids [3 0 R 5 0 R] /Count 2 /MediaBox [0 0 200 100]>>",
+			"<</Type /Pages /Parent 2 0 R /Kids [4 0 R] /Count 1 /Rotate 90>>",
+			"<</Type /Page /Parent 3 0 R /Contents 6 0 R>>",
+			"<</Type /Page /Parent 2 0 R /Contents 7 0 R>>",
+			contentStream("One"), contentStream("Two")),
+			[]string{"One", "Two"}, "/MediaBox [0 0 200 100]"},
+		{"incremental update", append(
+			testPDF(trailer, catalog, "<</Type /Pages /Kids [3 0 R] /Count 1>>", "<</Type /Page /Parent 2 0 R /Contents 4 0 R>>", contentStream("Old")),
+			fmt.Sprintf("4 0 obj\n%s\nendobj\ntrailer\n%s\n%%%%EOF\n", contentStream("New"), trailer)...),
+			[]string{"New"}, "/Contents 4 0 R"},
+		{"object stream", testPDF(trailer, catalog,
+			"<</Type /Pages /Kids [5 0 R] /Count 1>>", contentStream("One"), objectStream(5, "<</Type /Page /Parent 2 0 R /Contents 3 0 R>>")),
+			[]string{"One"}, "/Contents 3 0 R"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseImport(tt.data)
+			if tt.pages == nil {
+				if err == nil {
+					t.Fatal("import succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.pages) != len(tt.pages) {
+				t.Fatalf("got %d pages, want %d", len(p.pages), len(tt.pages))
+			}
+			for i, page := range p.pages {
+				contents, _ := refNumber(dictValue(p.objects[page].value, "/Contents"))
+				if got := string(p.objects[contents].stream); !strings.Contains(got, "("+tt.pages[i]+")") {
+					t.Errorf("page %d shows %q, want %q", i+1, got, tt.pages[i])
+				}
+			}
+			dict := string(p.pageDict(p.pages[0]))
+			if !strings.Contains(dict, tt.want) {
+				t.Errorf("first page %s lacks %s", dict, tt.want)
+			}
+			if strings.Contains(dict, "/Parent") {
+				t.Errorf("first page %s keeps its parent", dict)
+			}
+		})
+	}
+}
+
+func TestMergePDFs(t *testing.T) {
+	cover := testPDF("<</Root 1 0 R>>", "<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 100]>>",
+		"<</Type /Page /Parent 2 0 R /Contents 4 0 R>>", contentStream("Cover"))
+	appendix := testPDF("<</Root 1 0 R>>", "<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 200 100]>>",
+		"<</Type /Page /Parent 2 0 R /Contents 5 0 R>>", "<</Type /Page /Parent 2 0 R /Contents 6 0 R>>",
+		contentStream("Appendix 1"), contentStream("Appendix 2"))
+
+	tests := []struct {
+		name       string
+		before     [][]byte
+		after      [][]byte
+		wantBefore int
+		wantPages  int
+		wantErr    bool
+	}{
+		{"nothing", nil, nil, 0, 1, false},
+		{"before", [][]byte{cover}, nil, 1, 2, false},
+		{"after", nil, [][]byte{appendix}, 0, 3, false},
+		{"both", [][]byte{cover, cover}, [][]byte{appendix}, 2, 5, false},
+		{"not a PDF", nil, [][]byte{[]byte("hello")}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewWriter(DefaultTheme())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.WriteParagraph([]Span{{Text: "Document"}})
+			var buf bytes.Buffer
+			if err := w.Output(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			merged, before, err := mergePDFs(buf.Bytes(), tt.before, tt.after)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("merge succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before != tt.wantBefore {
+				t.Errorf("%d pages added before, want %d", before, tt.wantBefore)
+			}
+			objects, root, _, err := parseObjects(merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages, err := pageNumbers(objects, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != tt.wantPages {
+				t.Errorf("got %d pages, want %d", len(pages), tt.wantPages)
+			}
+			// The merged PDF can be merged again
+			if err := CheckImport(merged); err != nil {
+				t.Errorf("merged PDF can't be imported: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NumberFormat holds the digit grouping of a language
+type NumberFormat struct {
+	// Group separates groups of three digits
+	Group string
+	// MinDigits is the fewest digits that are grouped, e.g. 5 where 1234 stays ungrouped
+	MinDigits int
+}
+
+// numberFormats maps language tags to their digit grouping. Full tags (e.g. "de-ch")
+// take precedence over the primary language. Space-separated groups use a no-break
+// space, since the embedded font lacks the narrow one.
+var numberFormats = map[string]NumberFormat{
+	"en":    {",", 4},
+	"zh":    {",", 4},
+	"ja":    {",", 4},
+	"de":    {".", 4},
+	"de-ch": {"’", 4},
+	"fr-ch": {"’", 4},
+	"it-ch": {"’", 4},
+	"nl":    {".", 4},
+	"da":    {".", 4},
+	"it":    {".", 5},
+	"es":    {".", 5},
+	"pt":    {" ", 5},
+	"pt-br": {".", 4},
+	"ro":    {".", 4},
+	"fr":    {" ", 4},
+	"pl":    {" ", 5},
+	"cs":    {" ", 4},
+	"sk":    {" ", 4},
+	"hu":    {" ", 4},
+	"ru":    {" ", 4},
+	"uk":    {" ", 4},
+	"sv":    {" ", 4},
+	"fi":    {" ", 4},
+	"no":    {" ", 4},
+	"nb":    {" ", 4},
+}
+
+// NumberFormatFor returns the digit grouping for a language tag such as "de" or
+// "fr-CH", falling back to English for unknown languages
+func NumberFormatFor(lang string) NumberFormat {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if format, ok := numberFormats[tag]; ok {
+		return format
+	}
+	if primary, _, found := strings.Cut(tag, "-"); found {
+		if format, ok := numberFormats[primary]; ok {
+			return format
+		}
+	}
+	return numberFormats["en"]
+}
+
+// Int formats an integer with grouped digits, e.g. 12345 as "12.345" in German
+func (f NumberFormat) Int(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if f.Group == "" || len(digits) < max(f.MinDigits, 4) {
+		return sign + digits
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(f.Group)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PageSize is a paper size in millimeters, in portrait orientation
+type PageSize struct {
+	Width, Height float64
+}
+
+// pageSizes are the named paper sizes
+var pageSizes = map[string]PageSize{
+	"a3":     {297, 420},
+	"a4":     {210, 297},
+	"a5":     {148, 210},
+	"letter": {215.9, 279.4},
+	"legal":  {215.9, 355.6},
+}
+
+// ParsePageSize parses a paper size: A3, A4, A5, Letter, Legal, or the width and
+// height in millimeters like 170x240
+func ParsePageSize(s string) (PageSize, error) {
+	if size, ok := pageSizes[strings.ToLower(s)]; ok {
+		return size, nil
+	}
+	width, height, ok := strings.Cut(strings.TrimSuffix(strings.ToLower(s), "mm"), "x")
+	if ok {
+		w, errW := strconv.ParseFloat(strings.TrimSpace(width), 64)
+		h, errH := strconv.ParseFloat(strings.TrimSpace(height), 64)
+		if errW == nil && errH == nil && w >= 50 && h >= 50 {
+			return PageSize{min(w, h), max(w, h)}, nil
+		}
+	}
+	return PageSize{}, fmt.Errorf("unknown page size %q (want A3, A4, A5, Letter, Legal or <width>x<height> in mm, at least 50x50)", s)
+}
+
+// orientation returns the gofpdf orientation of landscape or portrait pages
+func orientation(landscape bool) string {
+	if landscape {
+		return "L"
+	}
+	return "P"
+}
+
+// addPage starts a new page in the current orientation
+func (w *Writer) addPage() {
+	w.pdf.AddPageFormat(orientation(w.landscape), gofpdf.SizeType{Wd: w.pageSize.Width, Ht: w.pageSize.Height})
+}
+
+// SetLandscape turns the pages added from now on sideways, or back upright, e.g. for
+// wide tables; WritePageBreak starts one
+func (w *Writer) SetLandscape(landscape bool) {
+	w.landscape = landscape
+}
+
+// contentBottom returns the Y position where content ends on the page, or in the
+// column when columns are balanced
+func (w *Writer) contentBottom() float64 {
+	if w.inColumns && w.columnBreak > 0 && w.column < w.columns-1 {
+		return w.columnBreak
+	}
+	return w.pageBottom()
+}
+
+// pageBottom returns the Y position of the theme's bottom margin on the page
+func (w *Writer) pageBottom() float64 {
+	_, pageHeight := w.pdf.GetPageSize()
+	return pageHeight - w.theme.Page.bottom()
+}
+
+// resetPageBreak makes gofpdf break pages at the content bottom, the limit the
+// writer's own breaks use, so text flowing past it breaks where blocks are moved
+func (w *Writer) resetPageBreak() {
+	_, pageHeight := w.pdf.GetPageSize()
+	w.pdf.SetAutoPageBreak(true, pageHeight-w.contentBottom())
+}
+
+// remainingSpace returns the vertical space left for content below the current position
+func (w *Writer) remainingSpace() float64 {
+	return w.contentBottom() - w.pdf.GetY()
+}
+
+// contentWidth returns the width between the left and right margins
+func (w *Writer) contentWidth() float64 {
+	pageWidth, _ := w.pdf.GetPageSize()
+	left, _, right, _ := w.pdf.GetMargins()
+	return pageWidth - left - right
+}
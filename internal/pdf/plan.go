@@ -0,0 +1,144 @@
+package pdf
+
+import "math"
+
+// Plan is the layout of a rendered document: the blocks of content on each page
+type Plan struct {
+	Pages []PlanPage `json:"pages"`
+}
+
+// PlanPage is a page of a Plan, with its size in millimeters
+type PlanPage struct {
+	Number int         `json:"number"`
+	Width  float64     `json:"width"`
+	Height float64     `json:"height"`
+	Blocks []PlanBlock `json:"blocks"`
+}
+
+// PlanBlock is a block of content on a page, in millimeters from the top-left corner
+// of the page. Blocks span the content width; their height includes the space below
+// them. A block continuing on the next page has a piece there with the same index.
+type PlanBlock struct {
+	// Index numbers the blocks in document order
+	Index int `json:"index"`
+	// Type is heading, paragraph, list_item, code, table, image, image_placeholder,
+	// callout, toc or rule
+	Type string `json:"type"`
+	// ID is the anchor of headings, tables and images that have one
+	ID string `json:"id,omitempty"`
+	// Text is the title of headings
+	Text      string  `json:"text,omitempty"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Width     float64 `json:"width"`
+	Height    float64 `json:"height"`
+	Continued bool    `json:"continued,omitempty"`
+}
+
+// planBlock is the block being written and where it starts
+type planBlock struct {
+	PlanBlock
+	page int
+	// placed is set once the block's page is chosen; until then a new page moves the
+	// block rather than breaking it
+	placed bool
+	// left and width are the margins of the piece being written
+	left, width float64
+}
+
+// beginBlock starts recording a block for the plan, returning the function that ends
+// it. Blocks that don't call placeBlock are placed where they begin. Headings drawn
+// by placeBlock while another block is recorded are recorded on their own.
+func (w *Writer) beginBlock(kind, id, text string, placed bool) (end func()) {
+	outer := w.block
+	w.block = &planBlock{PlanBlock: PlanBlock{Type: kind, ID: id, Text: text}, placed: placed}
+	w.moveBlock(w.pdf.GetY())
+	endTag := w.beginTag(blockRoles[kind], "")
+	return func() {
+		endTag()
+		w.endBlock()
+		w.block = outer
+	}
+}
+
+// placeCurrentBlock marks the block being recorded as starting at the current position
+func (w *Writer) placeCurrentBlock() {
+	if w.block != nil {
+		w.moveBlock(w.pdf.GetY())
+		w.block.placed = true
+	}
+}
+
+// moveBlock makes the block being recorded continue on the current page at y, between
+// the current margins
+func (w *Writer) moveBlock(y float64) {
+	left, _, _, _ := w.pdf.GetMargins()
+	w.block.page, w.block.Y = w.pdf.PageNo(), y
+	w.block.left, w.block.width = left, w.contentWidth()
+}
+
+// breakBlock records the piece of the block being written on the page or in the column
+// just finished, called as a new one starts
+func (w *Writer) breakBlock() {
+	if w.block == nil || !w.block.placed {
+		return
+	}
+	w.addPlanPiece(w.block.page, w.block.Y, w.contentBottom())
+	w.block.Continued = true
+}
+
+// continueBlock continues the block broken by breakBlock at the top of the new page
+// or column
+func (w *Writer) continueBlock(top float64) {
+	if w.block != nil && w.block.placed {
+		w.moveBlock(top)
+	}
+}
+
+// endBlock records the last piece of the block being written, unless it is an empty
+// continuation left by a page break at its very end
+func (w *Writer) endBlock() {
+	if w.block.Continued && w.pdf.GetY() <= w.block.Y {
+		return
+	}
+	if w.block.page == w.pdf.PageNo() {
+		w.addPlanPiece(w.block.page, w.block.Y, w.pdf.GetY())
+	}
+}
+
+// addPlanPiece adds a piece of the block being written from top to bottom on a page
+func (w *Writer) addPlanPiece(page int, top, bottom float64) {
+	// Blocks are numbered as they appear, after the headings drawn in front of them
+	if w.block.Index == 0 {
+		w.planned++
+		w.block.Index = w.planned
+	}
+	piece := w.block.PlanBlock
+	piece.X = round2(w.block.left)
+	piece.Y = round2(top)
+	piece.Width = round2(w.block.width)
+	piece.Height = round2(max(bottom-top, 0))
+	for len(w.plan) < page {
+		w.plan = append(w.plan, nil)
+	}
+	w.plan[page-1] = append(w.plan[page-1], piece)
+}
+
+// Plan returns the layout of the document written so far
+func (w *Writer) Plan() Plan {
+	plan := Plan{Pages: []PlanPage{}}
+	for number := 1; number <= w.pdf.PageCount(); number++ {
+		width, height, _ := w.pdf.PageSize(number)
+		page := PlanPage{Number: number, Width: round2(width), Height: round2(height), Blocks: []PlanBlock{}}
+		if number <= len(w.plan) && w.plan[number-1] != nil {
+			page.Blocks = w.plan[number-1]
+		}
+		plan.Pages = append(plan.Pages, page)
+	}
+	return plan
+}
+
+// round2 rounds to hundredths of a millimeter
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
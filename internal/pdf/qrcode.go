@@ -0,0 +1,51 @@
+package pdf
+
+import (
+	"fmt"
+
+	"report/internal/qr"
+)
+
+// qrCodeSize is the width and height of the QR code in the footer band
+const qrCodeSize = 12.0
+
+// SetQRCode stamps a QR code linking to url in the bottom-right corner of the first
+// page, or of every page if everyPage is set
+func (w *Writer) SetQRCode(url string, everyPage bool) error {
+	code, err := qr.Encode([]byte(url))
+	if err != nil {
+		return fmt.Errorf("QR code for %s: %w", url, err)
+	}
+	w.qrCode = code
+	w.qrURL = url
+	w.qrEveryPage = everyPage
+	return nil
+}
+
+// drawQRCode draws the QR code in the footer band of the current page, if it has one
+func (w *Writer) drawQRCode() {
+	if w.qrCode == nil || (!w.qrEveryPage && w.pdf.PageNo() != 1) {
+		return
+	}
+	pageWidth, pageHeight := w.pdf.GetPageSize()
+	x := pageWidth - w.theme.Page.MarginRight - qrCodeSize
+	y := pageHeight - qrCodeSize - (w.theme.Page.FooterHeight-qrCodeSize)/2
+	module := qrCodeSize / float64(w.qrCode.Size)
+
+	// Draw runs of dark modules as one rectangle; the page is the light background
+	w.setFillColor(0, 0, 0)
+	for row := range w.qrCode.Size {
+		for col := 0; col < w.qrCode.Size; {
+			if !w.qrCode.Dark(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < w.qrCode.Size && w.qrCode.Dark(col, row) {
+				col++
+			}
+			w.pdf.Rect(x+float64(start)*module, y+float64(row)*module, float64(col-start)*module, module, "F")
+		}
+	}
+	w.pdf.LinkString(x, y, qrCodeSize, qrCodeSize, w.qrURL)
+}
@@ -0,0 +1,99 @@
+package pdf
+
+import "strings"
+
+// runningHeadFontSize is the text size of the running head
+const runningHeadFontSize = 9.0
+
+// headerImage is the horizontal extent and height of an image in the page header
+type headerImage struct {
+	x, width, height float64
+}
+
+// runningHead is the section title shown in the header of the current page and the
+// space it goes in
+type runningHead struct {
+	// continued is the section the page starts in, section the first one starting on it
+	continued string
+	section   string
+	x, width  float64
+	height    float64
+	align     string
+}
+
+// headerImage returns the extent of a registered image drawn in the page header
+func (w *Writer) headerImage(name string, x, width float64) headerImage {
+	image := headerImage{x: x, width: width}
+	if info := w.pdf.GetImageInfo(name); info != nil && info.Width() > 0 {
+		image.height = width * info.Height() / info.Width()
+	}
+	return image
+}
+
+// startRunningHead starts the running head of a new page in the widest gap the logos
+// leave between the margins, aligned to the margin it touches
+func (w *Writer) startRunningHead(left, right float64, logos []headerImage) {
+	// Keep some distance from the logos
+	const gap = 5.0
+	w.head = runningHead{continued: w.section, height: 8}
+	start := left
+	for _, logo := range sortedHeaderImages(logos) {
+		w.head.height = max(w.head.height, logo.height)
+		w.headGap(start, logo.x-gap, left, right)
+		start = max(start, logo.x+logo.width+gap)
+	}
+	w.headGap(start, right, left, right)
+}
+
+// headGap makes the gap from start to end the space of the running head if it is the
+// widest so far
+func (w *Writer) headGap(start, end, left, right float64) {
+	if end-start <= w.head.width {
+		return
+	}
+	w.head.x, w.head.width = start, end-start
+	switch {
+	case start <= left:
+		w.head.align = "L"
+	case end >= right:
+		w.head.align = "R"
+	default:
+		w.head.align = "C"
+	}
+}
+
+// sortedHeaderImages returns the header images from left to right
+func sortedHeaderImages(images []headerImage) []headerImage {
+	if len(images) == 2 && images[1].x < images[0].x {
+		return []headerImage{images[1], images[0]}
+	}
+	return images
+}
+
+// drawRunningHead writes the running head of the finished page: the header text of a
+// first page, or else the first section starting on it or the one it continues,
+// shortened to its space
+func (w *Writer) drawRunningHead() {
+	text := w.head.section
+	if text == "" {
+		text = w.head.continued
+	}
+	if w.first.Header != "" {
+		text = strings.ReplaceAll(w.first.Header, "{date}", w.now().Format("02.01.2006"))
+	}
+	if text == "" || w.head.width <= 0 {
+		return
+	}
+	w.setFont(fontBody, "I", runningHeadFontSize)
+	w.setTextColor(100, 100, 100)
+	if w.pdf.GetStringWidth(text) > w.head.width {
+		runes := []rune(text)
+		for len(runes) > 0 && w.pdf.GetStringWidth(string(runes)+"…") > w.head.width {
+			runes = runes[:len(runes)-1]
+		}
+		text = strings.TrimRight(string(runes), " ") + "…"
+	}
+	w.pdf.SetXY(w.head.x, w.theme.Page.HeaderY)
+	w.pdf.CellFormat(w.head.width, w.head.height, text, "", 0, w.head.align+"M", false, 0, "")
+	w.setTextColor(0, 0, 0)
+}
@@ -0,0 +1,21 @@
+package pdf
+
+// defaultShrinkLimit is the smallest scale applied to oversized elements unless the theme sets one
+const defaultShrinkLimit = 0.8
+
+// shrinkScale reports whether an element needing the given scale to fit may be shrunk.
+// The scale is allowed down to the theme's shrink limit; a limit of 1 disables shrinking.
+func (w *Writer) shrinkScale(scale float64) (float64, bool) {
+	if scale >= 1 || scale < w.shrinkLimit() {
+		return 1, false
+	}
+	return scale, true
+}
+
+// shrinkLimit returns the smallest scale oversized elements may be shrunk to
+func (w *Writer) shrinkLimit() float64 {
+	if w.theme.ShrinkLimit <= 0 {
+		return defaultShrinkLimit
+	}
+	return w.theme.ShrinkLimit
+}
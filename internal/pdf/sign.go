@@ -0,0 +1,377 @@
+package pdf
+
+import (
+	"bytes"
+	"cmp"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size of the visible signature box in mm
+const (
+	signatureWidth  = 65.0
+	signatureHeight = 20.0
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidDataContent   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// Signature is a digital signature of the PDF, with a visible box naming the signer
+type Signature struct {
+	// Key signs the document; RSA and ECDSA keys are supported
+	Key crypto.Signer
+	// Chain is the certificate of Key followed by the certificates of its issuers
+	Chain []*x509.Certificate
+	// Page is the 1-based page of the signature box, which sits above the footer in
+	// the bottom right corner; 0 puts it after the content on the last page
+	Page int
+}
+
+// signatureBox is where the signature box is placed: the page and its rectangle in
+// points from the bottom left corner
+type signatureBox struct {
+	page                     int
+	left, bottom, right, top float64
+}
+
+// SetSignature signs the PDF when it is written. A signed PDF can't be changed
+// afterwards without breaking the signature.
+func (w *Writer) SetSignature(sig Signature) error {
+	switch sig.Key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return fmt.Errorf("unsupported signing key %T: use an RSA or ECDSA key", sig.Key)
+	}
+	if len(sig.Chain) == 0 {
+		return errors.New("no signing certificate")
+	}
+	if sig.Page < 0 {
+		return fmt.Errorf("invalid signature page %d", sig.Page)
+	}
+	w.signature = &sig
+	return nil
+}
+
+// placeSignature reserves the signature box, after the content on the last page unless
+// another page is chosen, which a new page is started for if it doesn't fit
+func (w *Writer) placeSignature() (signatureBox, error) {
+	pageWidth, pageHeight := w.pdf.GetPageSize()
+	_, _, right, _ := w.pdf.GetMargins()
+	k := w.pdf.GetConversionRatio()
+	box := signatureBox{page: w.signature.Page, right: (pageWidth - right) * k}
+	box.left = box.right - signatureWidth*k
+
+	if box.page == 0 {
+		gap := w.theme.Text.ParagraphSpacing
+		if w.pdf.GetY()+gap+signatureHeight > pageHeight-w.theme.Page.bottom() {
+			w.pdf.AddPage()
+			gap = 0
+		}
+		box.page = w.pdf.PageNo()
+		box.top = (pageHeight - w.pdf.GetY() - gap) * k
+	} else {
+		if box.page > w.pdf.PageNo() {
+			return signatureBox{}, fmt.Errorf("signature page %d is beyond the last page %d", box.page, w.pdf.PageNo())
+		}
+		box.top = (w.theme.Page.bottom() + signatureHeight) * k
+	}
+	box.bottom = box.top - signatureHeight*k
+	return box, nil
+}
+
+// signPDF signs a PDF written by Writer in an incremental update: a signature field
+// whose widget shows the box, with a detached CMS signature (adbe.pkcs7.detached) of
+// the whole file but the signature itself
+func signPDF(data []byte, sig Signature, box signatureBox, signed time.Time) ([]byte, error) {
+	objects, root, info, err := parseObjects(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := pageNumbers(objects, root)
+	if err != nil {
+		return nil, err
+	}
+	if box.page > len(pages) {
+		return nil, fmt.Errorf("signature page %d is beyond the last page %d", box.page, len(pages))
+	}
+	m := startXrefRegex.FindSubmatch(data)
+	if m == nil {
+		return nil, errors.New("invalid PDF: no cross-reference table")
+	}
+	prev, _ := strconv.Atoi(string(m[1]))
+
+	next := slices.Max(slices.Collect(maps.Keys(objects))) + 1
+	sigNum, fontNum, appearanceNum, widgetNum := next, next+1, next+2, next+3
+	page := pages[box.page-1]
+	signer := cmp.Or(sig.Chain[0].Subject.CommonName, sig.Chain[0].Subject.String())
+
+	// The signature's size doesn't depend on the digest, so one of an empty digest
+	// tells the room to leave for it
+	placeholder, err := cmsSignature(make([]byte, sha256.Size), sig, signed)
+	if err != nil {
+		return nil, err
+	}
+	contentsLength := 2 * (len(placeholder) + 64)
+
+	updated := map[int][]byte{}
+	updated[sigNum] = fmt.Appendf(nil, "%d 0 obj\n<</Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached\n/ByteRange [0 %s]\n/Contents <%s>\n/M %s\n/Name %s>>\nendobj\n",
+		sigNum, strings.Repeat(" ", 32), strings.Repeat("0", contentsLength), pdfText(pdfDate(signed)), pdfText(signer))
+	updated[fontNum] = fmt.Appendf(nil, "%d 0 obj\n<</Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding>>\nendobj\n", fontNum)
+	width, height := box.right-box.left, box.top-box.bottom
+	appearance := signatureAppearance(signer, signed, width, height)
+	updated[appearanceNum] = fmt.Appendf(nil, "%d 0 obj\n<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources <</Font <</Helv %d 0 R>>>> /Length %d>>\nstream\n%s\nendstream\nendobj\n",
+		appearanceNum, width, height, fontNum, len(appearance), appearance)
+	updated[widgetNum] = fmt.Appendf(nil, "%d 0 obj\n<</Type /Annot /Subtype /Widget /FT /Sig /F 132 /T (Signature1) /V %d 0 R /P %d 0 R /Rect [%.2f %.2f %.2f %.2f] /AP <</N %d 0 R>>>>\nendobj\n",
+		widgetNum, sigNum, page, box.left, box.bottom, box.right, box.top, appearanceNum)
+
+	// The page lists the widget among its annotations, the catalog the field in its form
+	pageDict := bytes.Clone(objects[page].dict)
+	if i := bytes.Index(pageDict, []byte("/Annots [")); i >= 0 {
+		i += len("/Annots [")
+		pageDict = slices.Concat(pageDict[:i], fmt.Appendf(nil, "%d 0 R ", widgetNum), pageDict[i:])
+		objects[page] = pdfObject{dict: pageDict}
+	} else if err := appendToDict(objects, page, fmt.Sprintf("\n/Annots [%d 0 R]\n", widgetNum)); err != nil {
+		return nil, fmt.Errorf("page %d: %w", box.page, err)
+	}
+	updated[page] = objects[page].dict
+	if bytes.Contains(objects[root].dict, []byte("/AcroForm")) {
+		return nil, errors.New("unsupported PDF: the document already has a form")
+	}
+	if err := appendToDict(objects, root, fmt.Sprintf("\n/AcroForm <</Fields [%d 0 R] /SigFlags 3>>\n", widgetNum)); err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+	updated[root] = objects[root].dict
+
+	// The incremental update: the new and changed objects, their cross-reference
+	// sections and a trailer pointing back to the original table
+	var out bytes.Buffer
+	out.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	offsets := map[int]int{}
+	nums := slices.Sorted(maps.Keys(updated))
+	for _, num := range nums {
+		offsets[num] = out.Len()
+		out.Write(updated[num])
+		if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteByte('\n')
+		}
+	}
+	xref := out.Len()
+	out.WriteString("xref\n")
+	for _, num := range nums {
+		fmt.Fprintf(&out, "%d 1\n%010d 00000 n \n", num, offsets[num])
+	}
+	fmt.Fprintf(&out, "trailer\n<<\n/Size %d\n/Root %d 0 R\n", widgetNum+1, root)
+	if info != 0 {
+		fmt.Fprintf(&out, "/Info %d 0 R\n", info)
+	}
+	fmt.Fprintf(&out, "/Prev %d\n>>\nstartxref\n%d\n%%%%EOF\n", prev, xref)
+
+	// The byte range covers the file but the signature's hex string
+	signedPDF := out.Bytes()
+	contents := offsets[sigNum] + bytes.Index(updated[sigNum], []byte("/Contents <")) + len("/Contents ")
+	contentsEnd := contents + contentsLength + 2
+	byteRange := fmt.Sprintf("%d %d %d", contents, contentsEnd, len(signedPDF)-contentsEnd)
+	rangeAt := offsets[sigNum] + bytes.Index(updated[sigNum], []byte("/ByteRange [0 ")) + len("/ByteRange [0 ")
+	copy(signedPDF[rangeAt:], byteRange+"]")
+	for i := rangeAt + len(byteRange) + 1; signedPDF[i] != '\n'; i++ {
+		signedPDF[i] = ' '
+	}
+
+	digest := sha256.New()
+	digest.Write(signedPDF[:contents])
+	digest.Write(signedPDF[contentsEnd:])
+	signature, err := cmsSignature(digest.Sum(nil), sig, signed)
+	if err != nil {
+		return nil, err
+	}
+	if 2*len(signature) > contentsLength {
+		return nil, errors.New("the signature is larger than the room left for it")
+	}
+	hex.Encode(signedPDF[contents+1:], signature)
+	return signedPDF, nil
+}
+
+// signatureAppearance returns the content stream of the visible signature: a framed
+// box naming the signer and the signing time
+func signatureAppearance(signer string, signed time.Time, width, height float64) string {
+	// Helvetica is about half as wide as high on average
+	fit := func(s string, size float64) string {
+		s = winAnsi(s)
+		if limit := int((width - 12) / (size * 0.55)); len(s) > limit {
+			s = s[:max(limit-3, 0)] + "..."
+		}
+		return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "q 0.95 0.97 1 rg 0 0 %.2f %.2f re f Q\n", width, height)
+	fmt.Fprintf(&b, "q 0.2 0.4 0.7 RG 0.8 w 0.4 0.4 %.2f %.2f re S Q\n", width-0.8, height-0.8)
+	fmt.Fprintf(&b, "BT 0.35 g /Helv 7 Tf 6 %.2f Td (%s) Tj ET\n", height-13, fit("Digitally signed by", 7))
+	fmt.Fprintf(&b, "BT 0 g /Helv 10 Tf 6 %.2f Td (%s) Tj ET\n", height-27, fit(signer, 10))
+	fmt.Fprintf(&b, "BT 0.35 g /Helv 7 Tf 6 %.2f Td (%s) Tj ET", height-40, fit("Date: "+signed.Format("2006-01-02 15:04:05 -07:00"), 7))
+	return b.String()
+}
+
+// winAnsi encodes text for the standard Helvetica font, replacing the characters it
+// lacks with '?'
+func winAnsi(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r >= 0x7F && r < 0xA0 || r > 0xFF {
+			r = '?'
+		}
+		b.WriteByte(byte(r))
+	}
+	return b.String()
+}
+
+// pdfDate formats a time as a PDF date string
+func pdfDate(t time.Time) string {
+	zone := strings.Replace(t.Format("-07'00'"), "+00'00'", "Z", 1)
+	return "D:" + t.Format("20060102150405") + zone
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type signerInfo struct {
+	Version            int
+	Signer             issuerAndSerial
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttributes   asn1.RawValue
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type encapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	Content          encapsulatedContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      asn1.RawValue
+}
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// cmsSignature returns the detached CMS signature (RFC 5652) of a SHA-256 digest of
+// the document, signed with the key and carrying the certificate chain
+func cmsSignature(digest []byte, sig Signature, signed time.Time) ([]byte, error) {
+	attribute := func(oid asn1.ObjectIdentifier, value any) ([]byte, error) {
+		v, err := asn1.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(struct {
+			Type   asn1.ObjectIdentifier
+			Values asn1.RawValue
+		}{oid, derSet(v)})
+	}
+	var attributes [][]byte
+	for _, attr := range []struct {
+		oid   asn1.ObjectIdentifier
+		value any
+	}{
+		{oidContentType, oidDataContent},
+		{oidSigningTime, signed.UTC()},
+		{oidMessageDigest, digest},
+	} {
+		der, err := attribute(attr.oid, attr.value)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, der)
+	}
+
+	// The signature covers the attributes encoded as a SET, stored implicitly tagged [0]
+	signedAttributes := derSet(attributes...)
+	attributesDigest := sha256.Sum256(signedAttributes.FullBytes)
+	signature, err := sig.Key.Sign(rand.Reader, attributesDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	signatureAlgorithm := algorithmIdentifier{Algorithm: oidECDSAWithSHA}
+	if _, ok := sig.Key.(*rsa.PrivateKey); ok {
+		signatureAlgorithm = algorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	}
+
+	cert := sig.Chain[0]
+	info, err := asn1.Marshal(signerInfo{
+		Version:            1,
+		Signer:             issuerAndSerial{asn1.RawValue{FullBytes: cert.RawIssuer}, cert.SerialNumber},
+		DigestAlgorithm:    algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		SignedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttributes.Bytes},
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	digestAlgorithm, err := asn1.Marshal(algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue})
+	if err != nil {
+		return nil, err
+	}
+	var certs []byte
+	for _, c := range sig.Chain {
+		certs = append(certs, c.Raw...)
+	}
+	data, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: derSet(digestAlgorithm),
+		Content:          encapsulatedContentInfo{oidDataContent},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos:      derSet(info),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: data},
+	})
+}
+
+// derSet encodes DER elements as a SET OF, sorted as DER requires
+func derSet(elements ...[]byte) asn1.RawValue {
+	slices.SortFunc(elements, bytes.Compare)
+	set := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: slices.Concat(elements...)}
+	set.FullBytes, _ = asn1.Marshal(set)
+	return set
+}
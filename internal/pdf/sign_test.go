@@ -0,0 +1,205 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// selfSigned returns a self-signed certificate for the key
+func selfSigned(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+var (
+	byteRangeRegex  = regexp.MustCompile(`/ByteRange \[0 (\d+) (\d+) (\d+)\s*\]`)
+	widgetPageRegex = regexp.MustCompile(`/FT /Sig /F 132 /T \(Signature1\) /V \d+ 0 R /P (\d+) 0 R`)
+)
+
+func TestSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		key      crypto.Signer
+		chain    bool
+		page     int
+		wantPage int // page of the signature box, 0 if signing fails
+	}{
+		{"rsa on last page", rsaKey, true, 0, 2},
+		{"ecdsa on last page", ecKey, true, 0, 2},
+		{"chosen page", ecKey, true, 1, 1},
+		{"page beyond the document", ecKey, true, 3, 0},
+		{"unsupported key", edKey, true, 0, 0},
+		{"no certificate", ecKey, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewWriter(DefaultTheme())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.WriteParagraph([]Span{{Text: "First page"}})
+			w.WritePageBreak(false)
+			w.WriteParagraph([]Span{{Text: "Second page"}})
+
+			sig := Signature{Key: tt.key, Page: tt.page}
+			if tt.chain {
+				sig.Chain = []*x509.Certificate{selfSigned(t, tt.key)}
+			}
+			var buf bytes.Buffer
+			err = w.SetSignature(sig)
+			if err == nil {
+				err = w.Output(&buf)
+			}
+			if tt.wantPage == 0 {
+				if err == nil {
+					t.Fatal("signing succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			verifySignature(t, data, sig.Chain[0])
+
+			// The pages of the document before the incremental update of the signature
+			original := data[:bytes.Index(data, []byte("%%EOF"))+len("%%EOF\n")]
+			objects, root, _, err := parseObjects(original)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages, err := pageNumbers(objects, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := widgetPageRegex.FindSubmatch(data)
+			if m == nil {
+				t.Fatal("no signature widget")
+			}
+			if page, _ := strconv.Atoi(string(m[1])); page != pages[tt.wantPage-1] {
+				t.Errorf("signature box on object %d, want page %d (object %d)", page, tt.wantPage, pages[tt.wantPage-1])
+			}
+		})
+	}
+}
+
+// verifySignature checks that the CMS signature of a signed PDF covers the whole file
+// but the signature, and is made by the key of cert
+func verifySignature(t *testing.T, data []byte, cert *x509.Certificate) {
+	t.Helper()
+	m := byteRangeRegex.FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no byte range")
+	}
+	contents, _ := strconv.Atoi(string(m[1]))
+	contentsEnd, _ := strconv.Atoi(string(m[2]))
+	rest, _ := strconv.Atoi(string(m[3]))
+	if contentsEnd+rest != len(data) {
+		t.Fatalf("byte range ends at %d, file at %d", contentsEnd+rest, len(data))
+	}
+	if data[contents] != '<' || data[contentsEnd-1] != '>' {
+		t.Fatalf("byte range gap %d-%d isn't the signature's hex string", contents, contentsEnd)
+	}
+	der, err := hex.DecodeString(string(data[contents+1 : contentsEnd-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info cmsContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		t.Fatal(err)
+	}
+	var signer signerInfo
+	if _, err := asn1.Unmarshal(sd.SignerInfos.Bytes, &signer); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message digest attribute holds the digest of the byte range
+	digest := sha256.New()
+	digest.Write(data[:contents])
+	digest.Write(data[contentsEnd:])
+	var messageDigest []byte
+	for attrs := signer.SignedAttributes.Bytes; len(attrs) > 0; {
+		var attr struct {
+			Type   asn1.ObjectIdentifier
+			Values asn1.RawValue
+		}
+		var err error
+		if attrs, err = asn1.Unmarshal(attrs, &attr); err != nil {
+			t.Fatal(err)
+		}
+		if attr.Type.Equal(oidMessageDigest) {
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &messageDigest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !bytes.Equal(messageDigest, digest.Sum(nil)) {
+		t.Error("message digest doesn't match the signed byte range")
+	}
+
+	// The signature covers the attributes encoded as a SET
+	attributes := bytes.Clone(signer.SignedAttributes.FullBytes)
+	attributes[0] = 0x31
+	hash := sha256.Sum256(attributes)
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signer.Signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, hash[:], signer.Signature) {
+			err = errInvalidSignature
+		}
+	}
+	if err != nil {
+		t.Errorf("signature doesn't verify: %v", err)
+	}
+}
+
+var errInvalidSignature = errors.New("invalid signature")
@@ -0,0 +1,225 @@
+package pdf
+
+import (
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Span is a run of inline text sharing one style
+type Span struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Code   bool
+	// Underline, Sub and Sup come from the HTML tags <u>, <sub> and <sup>
+	Underline bool
+	Sub       bool
+	Sup       bool
+	// Strike draws a line through the text, e.g. deleted text
+	Strike bool
+	// Link is the target URL if the span is a hyperlink
+	Link string
+	// Color is the theme color of the text, e.g. "comment"; black if empty
+	Color string
+	// PageRef is the anchor whose page number replaces the text
+	PageRef string
+	// Image, if set, is drawn in place of the text, e.g. a formula
+	Image *InlineImage
+	// font sets the text in the emoji or fallback family instead of the font of its role
+	font string
+}
+
+// InlineImage is an image set in a line of text, such as a rendered formula
+type InlineImage struct {
+	// Name identifies the image; images with the same name are embedded once
+	Name string
+	Data []byte
+	// Width, Height and Depth are in ems of the text size;
+	// Depth is the part of the height below the baseline
+	Width, Height, Depth float64
+}
+
+// ptToMM converts font sizes in points to millimeters
+const ptToMM = 25.4 / 72
+
+// style returns the gofpdf font style string of the span
+func (s Span) style() string {
+	style := ""
+	if s.Bold {
+		style += "B"
+	}
+	if s.Italic {
+		style += "I"
+	}
+	if s.Underline {
+		style += "U"
+	}
+	if s.Strike {
+		style += "S"
+	}
+	return style
+}
+
+// role returns the font role of the span
+func (s Span) role() string {
+	switch {
+	case s.font != "":
+		return s.font
+	case s.Code:
+		return fontCode
+	}
+	return fontBody
+}
+
+// scriptScale is the font size of subscripts and superscripts relative to the text
+const scriptScale = 0.65
+
+// fontSize returns the font size of the span in text of the given size
+func (s Span) fontSize(size float64) float64 {
+	if s.Code {
+		size--
+	}
+	if s.Sub || s.Sup {
+		size *= scriptScale
+	}
+	return size
+}
+
+// spansWidth returns the width of the spans set on one line
+func (w *Writer) spansWidth(spans []Span, size float64) float64 {
+	width := 0.0
+	for _, span := range spans {
+		if span.Image != nil {
+			width += span.Image.Width * size * ptToMM
+			continue
+		}
+		w.setFont(span.role(), span.style(), span.fontSize(size))
+		width += w.pdf.GetStringWidth(span.Text)
+	}
+	w.setFont(fontBody, "", size)
+	return width
+}
+
+// writeSpans writes styled text runs at the current position, wrapping at the right margin
+func (w *Writer) writeSpans(spans []Span, lineHeight, size float64) {
+	for _, span := range spans {
+		if span.Code {
+			w.setFont(span.role(), span.style(), size-1)
+		} else {
+			w.setFont(span.role(), span.style(), size)
+		}
+
+		if span.Image != nil {
+			w.writeInlineImage(*span.Image, span.Text, lineHeight, size)
+		} else if span.Sub || span.Sup {
+			// Raise superscripts by a third of the text size, lower subscripts by a sixth
+			offset := size / 3
+			if span.Sub {
+				offset = -size / 6
+			}
+			w.pdf.SubWrite(lineHeight, span.Text, span.fontSize(size), offset, 0, span.Link)
+		} else if span.PageRef != "" {
+			w.writePageRef(span.PageRef, lineHeight)
+		} else if id, ok := strings.CutPrefix(span.Link, "#"); ok {
+			w.writeInternalLink(span.Text, id, lineHeight)
+		} else if span.Link != "" {
+			c := w.theme.color("link")
+			w.setTextColor(c.R, c.G, c.B)
+			w.pdf.WriteLinkString(lineHeight, span.Text, span.Link)
+			w.setTextColor(0, 0, 0)
+		} else if span.Color != "" {
+			c := w.theme.color(span.Color)
+			w.setTextColor(c.R, c.G, c.B)
+			w.pdf.Write(lineHeight, span.Text)
+			w.setTextColor(0, 0, 0)
+		} else {
+			w.pdf.Write(lineHeight, span.Text)
+		}
+	}
+
+	// Restore the default body font
+	w.setFont(fontBody, "", size)
+}
+
+// writeInlineImage draws an image on the baseline of the text, moving it to the next
+// line if it doesn't fit. Images that can't be decoded are written as their text.
+func (w *Writer) writeInlineImage(img InlineImage, text string, lineHeight, size float64) {
+	name, _, err := w.registerImage(img.Name, img.Data)
+	if err != nil {
+		w.warnf("inline image %s: %v", text, err)
+		w.pdf.Write(lineHeight, text)
+		return
+	}
+
+	em := size * ptToMM
+	width, height := img.Width*em, img.Height*em
+	left, _, _, _ := w.pdf.GetMargins()
+	x := w.pdf.GetX()
+	if x > left && x-left+width > w.contentWidth()-2*w.pdf.GetCellMargin() {
+		w.pdf.Ln(lineHeight)
+		x = w.pdf.GetX()
+	}
+
+	// gofpdf sets text in a line at 0.3 of the font size below its middle
+	baseline := w.pdf.GetY() + lineHeight/2 + 0.3*em
+	w.pdf.ImageOptions(name, x, baseline-height+img.Depth*em, width, height, false, gofpdf.ImageOptions{}, 0, "")
+	w.pdf.SetX(x + width)
+}
+
+// writePageRef writes the page number of an anchor as an internal link
+func (w *Writer) writePageRef(id string, lineHeight float64) {
+	anchor, ok := w.anchor(id)
+	if !ok {
+		if w.layout != nil {
+			w.warnf("page reference to unknown anchor #%s", id)
+		}
+		w.pdf.Write(lineHeight, "?")
+		return
+	}
+	w.writeLinkID(w.numbers.Int(anchor.Page), anchor, lineHeight)
+}
+
+// writeInternalLink writes link text jumping to an anchor of the document, like
+// `[see Architecture](#architecture)`. Links to unknown anchors are written as plain text.
+func (w *Writer) writeInternalLink(text, id string, lineHeight float64) {
+	anchor, ok := w.anchor(id)
+	if !ok {
+		if w.layout != nil {
+			w.warnf("link to unknown anchor #%s", id)
+		}
+		w.pdf.Write(lineHeight, text)
+		return
+	}
+	w.writeLinkID(text, anchor, lineHeight)
+}
+
+// anchor looks up the position of a heading or other anchor by ID.
+// Anchors come from the layout pass, or from headings already rendered in this one.
+func (w *Writer) anchor(id string) (Anchor, bool) {
+	anchor, ok := w.layout[id]
+	if !ok {
+		anchor, ok = w.anchors[id]
+	}
+	return anchor, ok
+}
+
+// writeLinkID writes text in the link color, linking to a position in the document
+func (w *Writer) writeLinkID(text string, anchor Anchor, lineHeight float64) {
+	link := w.pdf.AddLink()
+	w.pdf.SetLink(link, anchor.Y, anchor.Page)
+	c := w.theme.color("link")
+	w.setTextColor(c.R, c.G, c.B)
+	w.pdf.WriteLinkID(lineHeight, text, link)
+	w.setTextColor(0, 0, 0)
+}
+
+// spansEmpty reports whether the spans contain no text
+func spansEmpty(spans []Span) bool {
+	for _, span := range spans {
+		if span.Text != "" {
+			return false
+		}
+	}
+	return true
+}
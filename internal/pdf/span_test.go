@@ -0,0 +1,50 @@
+package pdf
+
+import "testing"
+
+func TestPageRefText(t *testing.T) {
+	// write renders a reference to a later heading, then headings on pages 1 to 3
+	write := func(w *Writer) {
+		w.WriteParagraph([]Span{{Text: "See page "}, {Text: "{{page-of: #three}}", PageRef: "three"}})
+		w.WriteHeading(Heading{Level: 1, Text: "One", ID: "one"})
+		w.WritePageBreak(false)
+		w.WriteHeading(Heading{Level: 1, Text: "Two", ID: "two"})
+		w.WritePageBreak(false)
+		w.WriteHeading(Heading{Level: 1, Text: "Three", ID: "three"})
+	}
+	layoutPass, err := NewWriter(DefaultTheme())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(layoutPass)
+	layout := layoutPass.Anchors()
+
+	tests := []struct {
+		name   string
+		layout map[string]Anchor
+		ref    string
+		want   string
+	}{
+		{"earlier heading without layout", nil, "one", "1"},
+		{"later heading without layout", nil, "three", "?"},
+		{"later heading with layout", layout, "three", "3"},
+		{"middle heading with layout", layout, "two", "2"},
+		{"unknown heading", layout, "four", "?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewWriter(DefaultTheme())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.SetLayout(tt.layout)
+			// The reference is looked up after the first heading, before the others, like
+			// the reference at the start of write
+			w.WriteHeading(Heading{Level: 1, Text: "One", ID: "one"})
+			w.flushHeadings()
+			if got := w.spanText(Span{PageRef: tt.ref}); got != tt.want {
+				t.Errorf("page reference to %s shows %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SystemInfoProvider supplies the description of the system a report was generated
+// on, e.g. "Ubuntu 24.04 LTS", named in the footer
+type SystemInfoProvider interface {
+	SystemInfo() string
+}
+
+// HostInfo describes the operating system and machine the process runs on
+type HostInfo struct{}
+
+// hostInfo is the host description, collected on first use
+var hostInfo = sync.OnceValue(getSystemMetadata)
+
+// SystemInfo returns the operating system and machine, like "macOS 14.5 MacBook Pro"
+func (HostInfo) SystemInfo() string {
+	return hostInfo()
+}
+
+// StaticInfo is a fixed description, e.g. "Build server 3"
+type StaticInfo string
+
+// SystemInfo returns the description itself
+func (s StaticInfo) SystemInfo() string {
+	return string(s)
+}
+
+// CIInfo describes the pipeline and runner of the CI service whose environment the
+// process runs in: GitHub Actions, GitLab CI, Jenkins, Azure Pipelines or CircleCI.
+// Outside CI it falls back to Fallback, or to HostInfo if that is nil.
+type CIInfo struct {
+	Fallback SystemInfoProvider
+	// Getenv looks up environment variables; os.Getenv if nil
+	Getenv func(string) string
+}
+
+// ciServices describe CI services by the variable identifying them and the variables
+// naming their pipeline or build and their runner
+var ciServices = []struct {
+	name, detect, run, runner string
+}{
+	{"GitHub Actions run", "GITHUB_ACTIONS", "GITHUB_RUN_ID", "RUNNER_NAME"},
+	{"GitLab CI pipeline", "GITLAB_CI", "CI_PIPELINE_ID", "CI_RUNNER_DESCRIPTION"},
+	{"Jenkins build", "JENKINS_URL", "BUILD_NUMBER", "NODE_NAME"},
+	{"Azure Pipelines build", "TF_BUILD", "BUILD_BUILDID", "AGENT_NAME"},
+	{"CircleCI job", "CIRCLECI", "CIRCLE_BUILD_NUM", ""},
+}
+
+// SystemInfo returns the CI service with its pipeline ID and runner, like
+// "GitLab CI pipeline 4711 on docker-runner-2"
+func (c CIInfo) SystemInfo() string {
+	getenv := c.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	for _, service := range ciServices {
+		if getenv(service.detect) == "" {
+			continue
+		}
+		info := service.name
+		if run := getenv(service.run); run != "" {
+			info += " " + run
+		}
+		if runner := getenv(service.runner); service.runner != "" && runner != "" {
+			info += " on " + runner
+		}
+		return info
+	}
+	if c.Fallback != nil {
+		return c.Fallback.SystemInfo()
+	}
+	return HostInfo{}.SystemInfo()
+}
+
+// getSystemMetadata returns OS-specific system information for the footer
+func getSystemMetadata() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return getMacOSMetadata()
+	case "linux":
+		return getLinuxMetadata()
+	case "windows":
+		return "Microsoft Windows"
+	default:
+		return runtime.GOOS
+	}
+}
+
+// getMacOSMetadata returns macOS version and Mac model
+func getMacOSMetadata() string {
+	var version, model string
+
+	// Get macOS version using sw_vers
+	if cmd := exec.Command("sw_vers", "-productVersion"); cmd != nil {
+		if output, err := cmd.Output(); err == nil {
+			version = strings.TrimSpace(string(output))
+		}
+	}
+
+	// Get Mac model using system_profiler
+	if cmd := exec.Command("system_profiler", "SPHardwareDataType"); cmd != nil {
+		if output, err := cmd.Output(); err == nil {
+			lines := strings.Split(string(output), "\n")
+			for _, line := range lines {
+				if strings.Contains(line, "Model Name:") || strings.Contains(line, "Model Identifier:") {
+					parts := strings.Split(line, ":")
+					if len(parts) > 1 {
+						model = strings.TrimSpace(parts[1])
+						// Prefer Model Name over Model Identifier
+						if strings.Contains(line, "Model Name:") {
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Fallback if model not found
+	if model == "" {
+		if cmd := exec.Command("sysctl", "-n", "hw.model"); cmd != nil {
+			if output, err := cmd.Output(); err == nil {
+				model = strings.TrimSpace(string(output))
+			}
+		}
+	}
+
+	if version != "" && model != "" {
+		return fmt.Sprintf("macOS %s %s", version, model)
+	} else if version != "" {
+		return fmt.Sprintf("macOS %s", version)
+	} else if model != "" {
+		return fmt.Sprintf("macOS on %s", model)
+	}
+	return "macOS"
+}
+
+// getLinuxMetadata returns Linux distribution information
+func getLinuxMetadata() string {
+	// Try to read /etc/os-release first (most common)
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		lines := strings.Split(string(data), "\n")
+		var name, version string
+		for _, line := range lines {
+			if strings.HasPrefix(line, "PRETTY_NAME=") {
+				value := strings.TrimPrefix(line, "PRETTY_NAME=")
+				value = strings.Trim(value, "\"")
+				return value
+			}
+			if strings.HasPrefix(line, "NAME=") {
+				name = strings.TrimPrefix(line, "NAME=")
+				name = strings.Trim(name, "\"")
+			}
+			if strings.HasPrefix(line, "VERSION=") {
+				version = strings.TrimPrefix(line, "VERSION=")
+				version = strings.Trim(version, "\"")
+			}
+		}
+		if name != "" {
+			if version != "" {
+				return fmt.Sprintf("%s %s", name, version)
+			}
+			return name
+		}
+	}
+
+	// Fallback to /etc/issue
+	if data, err := os.ReadFile("/etc/issue"); err == nil {
+		line := strings.TrimSpace(string(data))
+		// Remove escape sequences and newlines
+		line = strings.ReplaceAll(line, "\\n", "")
+		line = strings.ReplaceAll(line, "\\l", "")
+		line = strings.TrimSpace(line)
+		if line != "" {
+			return line
+		}
+	}
+
+	// Last resort
+	return "Linux"
+}
@@ -0,0 +1,256 @@
+package pdf
+
+import "strings"
+
+const (
+	tableFontSize   = 10.0
+	tableLineHeight = 5.0
+	tablePadding    = 1.5
+)
+
+// Table is a table with an optional header row
+type Table struct {
+	Header []string
+	Rows   [][]string
+	// Align holds the alignment of each column: "L" (default), "C" or "R"
+	Align []string
+	// Caption is shown centered below the table, on the page of its last row
+	Caption string
+	// ID is the anchor of the table for links and page references
+	ID string
+	// Truncate holds the cell policy of each column for values too long for it, like
+	// paths and hashes: "" (default) wraps them, "footnote" and "attachment" shorten
+	// them to one line with an ellipsis in the middle, listing the full value in a
+	// note below the table or attaching it to the PDF as a file opened from the cell
+	Truncate []string
+}
+
+// WriteTable renders a table with borders, repeating the header row after page breaks.
+// Tables slightly wider than the page are scaled down within the theme's shrink limit;
+// wider ones get their columns narrowed and cell text wrapped.
+func (w *Writer) WriteTable(t Table) {
+	cols := len(t.Header)
+	for _, row := range t.Rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 0 {
+		return
+	}
+	defer w.beginBlock("table", t.ID, "", false)()
+	w.tables++
+
+	l := w.layoutTable(cols, t.Header, t.Rows, t.Truncate)
+	var first []string
+	if len(t.Rows) > 0 {
+		first = t.Rows[0]
+	}
+	w.beginTable(t, l, first)
+	caption, captionHeight := w.splitCaption(t.Caption)
+	for i, row := range t.Rows {
+		// The caption stays on the page of the last row
+		keep := 0.0
+		if i == len(t.Rows)-1 {
+			keep = captionHeight
+		}
+		w.writeTableBodyRow(t, l, row, keep)
+	}
+	w.endTable(l, caption)
+}
+
+// tableLayout holds the column widths and text size of a table
+type tableLayout struct {
+	widths     []float64
+	fontSize   float64
+	lineHeight float64
+	truncate   []string
+	// spanned is set for a table spanning the columns of the body
+	spanned bool
+}
+
+// layoutTable measures the header and rows and fits the columns to the content width.
+// Columns truncating long values need no more than their header words.
+func (w *Writer) layoutTable(cols int, header []string, rows [][]string, truncate []string) tableLayout {
+	// Natural column widths with unwrapped cells, and the widths of their longest words
+	natural := make([]float64, cols)
+	words := make([]float64, cols)
+	measure := func(row []string, style string, header bool) {
+		w.setFont(fontBody, style, tableFontSize)
+		for i, cell := range row[:min(len(row), cols)] {
+			natural[i] = max(natural[i], w.pdf.GetStringWidth(cell)+2*tablePadding)
+			if !header && truncatePolicy(truncate, i) != "" {
+				continue
+			}
+			for _, word := range strings.Fields(cell) {
+				words[i] = max(words[i], w.pdf.GetStringWidth(word)+2*tablePadding)
+			}
+		}
+	}
+	measure(header, "B", true)
+	for _, row := range rows {
+		measure(row, "", false)
+	}
+
+	total := 0.0
+	for _, width := range natural {
+		total += width
+	}
+
+	// Tables too wide for a column span the columns, until endTable
+	contentWidth := w.contentWidth()
+	spanned := total > contentWidth && w.spanColumns()
+	if spanned {
+		contentWidth = w.contentWidth()
+	}
+
+	// Shrink slightly oversized tables, narrow the columns of the rest
+	scale := 1.0
+	widths := natural
+	if total > contentWidth {
+		// Only the text shrinks, the cell padding stays
+		padding := 2 * tablePadding * float64(cols)
+		if s, ok := w.shrinkScale((contentWidth - padding) / (total - padding)); ok {
+			scale = s
+			w.warnf("table scaled to %d%% to fit the page width", int(scale*100))
+			widths = make([]float64, cols)
+			for i := range natural {
+				widths[i] = (natural[i]-2*tablePadding)*scale + 2*tablePadding
+			}
+		} else {
+			widths = fitColumns(natural, words, contentWidth)
+		}
+	}
+	return tableLayout{widths: widths, fontSize: tableFontSize * scale, lineHeight: tableLineHeight * scale, truncate: truncate, spanned: spanned}
+}
+
+// beginTable places a table, keeping preceding headings with its header and first row,
+// and writes the header
+func (w *Writer) beginTable(t Table, l tableLayout, first []string) {
+	keep := 2.0
+	if len(t.Header) > 0 {
+		keep += w.tableRowHeight(t.Header, nil, l.widths, l.fontSize, l.lineHeight, "B")
+	}
+	if first != nil {
+		keep += w.tableRowHeight(first, l.truncate, l.widths, l.fontSize, l.lineHeight, "")
+	}
+	w.placeBlock(keep)
+	w.cellNotes = cellNotes{}
+
+	w.pdf.Ln(2)
+	w.setAnchor(t.ID)
+	if len(t.Header) > 0 {
+		w.writeTableRow(t.Header, t.Align, nil, l.widths, l.fontSize, l.lineHeight, true)
+	}
+}
+
+// writeTableBodyRow writes a row, moving it to a new page with the header repeated if it
+// doesn't fit together with keep millimeters below it
+func (w *Writer) writeTableBodyRow(t Table, l tableLayout, row []string, keep float64) {
+	if w.remainingSpace() < w.tableRowHeight(row, l.truncate, l.widths, l.fontSize, l.lineHeight, "")+keep {
+		w.breakColumn()
+		if len(t.Header) > 0 {
+			w.writeTableRow(t.Header, t.Align, nil, l.widths, l.fontSize, l.lineHeight, true)
+		}
+	}
+	w.writeTableRow(row, t.Align, l.truncate, l.widths, l.fontSize, l.lineHeight, false)
+}
+
+// endTable writes the caption lines and the notes of truncated cells below a table,
+// and starts the columns again below a table spanning them
+func (w *Writer) endTable(l tableLayout, caption []string) {
+	w.writeCaption(caption)
+	w.writeCellNotes()
+	w.pdf.Ln(4)
+	w.setFont(fontBody, "", 12)
+	if l.spanned {
+		w.resumeColumns()
+	}
+}
+
+// fitColumns narrows columns to the available width. Each column keeps the width of
+// its longest word if possible; the remaining width is shared in proportion to how
+// much each column would need beyond that.
+func fitColumns(natural, words []float64, available float64) []float64 {
+	widths := make([]float64, len(natural))
+	minTotal, extraTotal := 0.0, 0.0
+	for i := range natural {
+		widths[i] = min(natural[i], words[i])
+		minTotal += widths[i]
+		extraTotal += natural[i] - widths[i]
+	}
+
+	if minTotal >= available {
+		for i := range widths {
+			widths[i] *= available / minTotal
+		}
+		return widths
+	}
+
+	if extraTotal > 0 {
+		for i := range widths {
+			widths[i] += (natural[i] - min(natural[i], words[i])) * (available - minTotal) / extraTotal
+		}
+	}
+	return widths
+}
+
+// tableRowHeight returns the height of a row with its cells wrapped to the column widths,
+// except those of columns truncating long values
+func (w *Writer) tableRowHeight(row, truncate []string, widths []float64, fontSize, lineHeight float64, style string) float64 {
+	w.setFont(fontBody, style, fontSize)
+	lines := 1
+	for i, cell := range row {
+		if cell != "" && truncatePolicy(truncate, i) == "" {
+			lines = max(lines, len(w.pdf.SplitText(cell, widths[i]-2*tablePadding)))
+		}
+	}
+	return float64(lines)*lineHeight + 2*tablePadding
+}
+
+// writeTableRow draws one table row at the current position
+func (w *Writer) writeTableRow(row, align, truncate []string, widths []float64, fontSize, lineHeight float64, header bool) {
+	style := ""
+	if header {
+		style = "B"
+	}
+	height := w.tableRowHeight(row, truncate, widths, fontSize, lineHeight, style)
+	if w.remainingSpace() < height {
+		w.breakColumn()
+	}
+
+	left, _, _, _ := w.pdf.GetMargins()
+	x, y := left, w.pdf.GetY()
+	w.setDrawColor(200, 200, 200)
+	w.pdf.SetLineWidth(0.2)
+	w.setFillColor(240, 240, 240)
+	margin := w.pdf.GetCellMargin()
+	w.pdf.SetCellMargin(0)
+
+	w.keepTogether(func() {
+		for i, width := range widths {
+			fill := "D"
+			if header {
+				fill = "FD"
+			}
+			w.pdf.Rect(x, y, width, height, fill)
+
+			cellAlign := "L"
+			if i < len(align) && align[i] != "" {
+				cellAlign = align[i]
+			}
+			if policy := truncatePolicy(truncate, i); policy != "" && i < len(row) && row[i] != "" {
+				text := w.truncateCell(row[i], width-2*tablePadding, policy, x, y, width, height)
+				w.pdf.SetXY(x+tablePadding, y+tablePadding)
+				w.pdf.CellFormat(width-2*tablePadding, lineHeight, text, "", 0, cellAlign, false, 0, "")
+			} else if i < len(row) && row[i] != "" {
+				for j, line := range w.pdf.SplitText(row[i], width-2*tablePadding) {
+					w.pdf.SetXY(x+tablePadding, y+tablePadding+float64(j)*lineHeight)
+					w.pdf.CellFormat(width-2*tablePadding, lineHeight, line, "", 0, cellAlign, false, 0, "")
+				}
+			}
+			x += width
+		}
+	})
+
+	w.pdf.SetCellMargin(margin)
+	w.pdf.SetXY(left, y+height)
+}
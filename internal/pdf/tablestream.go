@@ -0,0 +1,87 @@
+package pdf
+
+// tableSampleRows is the number of leading rows a table stream measures to fix
+// its column widths before writing anything
+const tableSampleRows = 200
+
+// TableStream writes the rows of a large table as they arrive, for data tables with
+// tens of thousands of rows. Only the first rows are kept in memory: the column widths
+// are fitted to them, and longer cells in later rows wrap.
+type TableStream struct {
+	w       *Writer
+	table   Table
+	layout  tableLayout
+	cols    int
+	pending [][]string // Rows waiting for the layout, and the row held back for the caption
+	started bool
+	rows    int
+	end     func() // Ends the table's block in the plan
+}
+
+// BeginTable starts a streamed table with the header, alignment, caption and ID of t.
+// Its rows are added with WriteRow, and the table is finished with Close.
+func (w *Writer) BeginTable(t Table) *TableStream {
+	t.Rows = nil
+	return &TableStream{w: w, table: t, cols: len(t.Header), end: w.beginBlock("table", t.ID, "", false)}
+}
+
+// WriteRow adds a row to the table. Rows of the sample may widen the table; later
+// rows are cut to its columns.
+func (s *TableStream) WriteRow(row []string) {
+	s.rows++
+	if !s.started {
+		s.cols = max(s.cols, len(row))
+		s.pending = append(s.pending, row)
+		if len(s.pending) > tableSampleRows {
+			s.start()
+		}
+		return
+	}
+	s.pending = append(s.pending, row[:min(len(row), s.cols)])
+	// The last row is held back so the caption can be kept on its page
+	for len(s.pending) > 1 {
+		s.w.writeTableBodyRow(s.table, s.layout, s.pending[0], 0)
+		s.pending = s.pending[1:]
+	}
+}
+
+// Rows returns the number of rows added so far
+func (s *TableStream) Rows() int {
+	return s.rows
+}
+
+// Close writes the remaining rows and the caption. A table without rows or header,
+// e.g. because its data couldn't be read, is just its caption.
+func (s *TableStream) Close() {
+	if !s.started {
+		s.start()
+	}
+	caption, captionHeight := s.w.splitCaption(s.table.Caption)
+	for i, row := range s.pending {
+		keep := 0.0
+		if i == len(s.pending)-1 {
+			keep = captionHeight
+		}
+		s.w.writeTableBodyRow(s.table, s.layout, row, keep)
+	}
+	s.pending = nil
+	s.w.endTable(s.layout, caption)
+	s.end()
+}
+
+// start fits the columns to the sampled rows and writes the header and all but the last
+// of them
+func (s *TableStream) start() {
+	s.started = true
+	s.w.tables++
+	s.layout = s.w.layoutTable(s.cols, s.table.Header, s.pending, s.table.Truncate)
+	var first []string
+	if len(s.pending) > 0 {
+		first = s.pending[0]
+	}
+	s.w.beginTable(s.table, s.layout, first)
+	for len(s.pending) > 1 {
+		s.w.writeTableBodyRow(s.table, s.layout, s.pending[0], 0)
+		s.pending = s.pending[1:]
+	}
+}
@@ -0,0 +1,221 @@
+package pdf
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// blockRoles are the structure types of the blocks tagged where they begin; headings
+// and images are tagged by their writers, which know the level and alt text
+var blockRoles = map[string]string{
+	"paragraph": "P",
+	"list_item": "LI",
+	"code":      "Code",
+	"table":     "Table",
+	"callout":   "Div",
+	"toc":       "TOC",
+}
+
+// structElem is an element of the structure tree of a tagged PDF
+type structElem struct {
+	role string
+	alt  string
+	// content are the marked-content sequences of the element, by page and MCID
+	content []markedContent
+	// kids are the list items of a list
+	kids []*structElem
+}
+
+// markedContent identifies a marked-content sequence on a page
+type markedContent struct {
+	page, mcid int
+}
+
+// SetTagged makes the PDF a tagged PDF for screen readers: its headings, paragraphs,
+// list items, code, tables and images are marked as structure elements in reading
+// order, images carry their alt text and the document its language, a tag such as
+// "en" or "de-CH". Headers, footers and watermarks are marked as artifacts.
+func (w *Writer) SetTagged(lang string) {
+	w.tagged = true
+	w.tagLang = cmp.Or(strings.TrimSpace(lang), "en")
+}
+
+// beginTag starts a structure element of a role with alt text, returning the
+// function that ends it. Content drawn in between is marked as the element's, except
+// that of elements begun inside, which come before it in reading order.
+func (w *Writer) beginTag(role, alt string) (end func()) {
+	if !w.tagged || role == "" {
+		return func() {}
+	}
+	elem := &structElem{role: role, alt: alt}
+	w.suspendTag()
+	w.tags = append(w.tags, elem)
+	w.resumeTag()
+	return func() {
+		w.suspendTag()
+		w.tags = w.tags[:len(w.tags)-1]
+		w.addStructElem(elem)
+		w.resumeTag()
+	}
+}
+
+// addStructElem adds an ended element to the structure tree, collecting consecutive
+// list items in a list
+func (w *Writer) addStructElem(elem *structElem) {
+	if elem.role != "LI" {
+		w.structure = append(w.structure, elem)
+		w.list = nil
+		return
+	}
+	if w.list == nil {
+		w.list = &structElem{role: "L"}
+		w.structure = append(w.structure, w.list)
+	}
+	w.list.kids = append(w.list.kids, elem)
+}
+
+// suspendTag ends the marked-content sequence of the innermost open element
+func (w *Writer) suspendTag() {
+	if w.marking {
+		w.pdf.RawWriteStr("EMC")
+		w.marking = false
+	}
+}
+
+// resumeTag starts a marked-content sequence of the innermost open element on the
+// current page
+func (w *Writer) resumeTag() {
+	if len(w.tags) == 0 || w.pdf.PageNo() == 0 {
+		return
+	}
+	elem := w.tags[len(w.tags)-1]
+	page := w.pdf.PageNo()
+	for len(w.mcids) < page {
+		w.mcids = append(w.mcids, 0)
+	}
+	mcid := w.mcids[page-1]
+	w.mcids[page-1]++
+	elem.content = append(elem.content, markedContent{page, mcid})
+	w.pdf.RawWriteStr(fmt.Sprintf("/%s <</MCID %d>> BDC", elem.role, mcid))
+	w.marking = true
+}
+
+// beginArtifact marks the content drawn until endArtifact as an artifact, such as a
+// page header, which screen readers skip
+func (w *Writer) beginArtifact() {
+	if w.tagged {
+		w.suspendTag()
+		w.pdf.RawWriteStr("/Artifact BMC")
+	}
+}
+
+// endArtifact ends an artifact, resuming the open element unless the page ends
+func (w *Writer) endArtifact(resume bool) {
+	if w.tagged {
+		w.pdf.RawWriteStr("EMC")
+		if resume {
+			w.resumeTag()
+		}
+	}
+}
+
+// addStructure adds the structure tree of elements to a PDF written by Writer, whose
+// pages mark their content with the MCIDs of the elements, making it a tagged PDF in
+// the language lang
+func addStructure(data []byte, elements []*structElem, lang string) ([]byte, error) {
+	objects, root, info, err := parseObjects(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := pageNumbers(objects, root)
+	if err != nil {
+		return nil, err
+	}
+
+	next := slices.Max(slices.Collect(maps.Keys(objects))) + 1
+	newObject := func() int {
+		next++
+		return next - 1
+	}
+	treeRoot, document, parentTree := newObject(), newObject(), newObject()
+
+	// The elements of each page's marked content by MCID, for the parent tree
+	parents := make([][]int, len(pages))
+	var writeElem func(elem *structElem, parent int) int
+	writeElem = func(elem *structElem, parent int) int {
+		num := newObject()
+		var kids bytes.Buffer
+		for _, mc := range elem.content {
+			if mc.page > len(pages) {
+				continue
+			}
+			fmt.Fprintf(&kids, "<</Type /MCR /Pg %d 0 R /MCID %d>> ", pages[mc.page-1], mc.mcid)
+			for len(parents[mc.page-1]) <= mc.mcid {
+				parents[mc.page-1] = append(parents[mc.page-1], 0)
+			}
+			parents[mc.page-1][mc.mcid] = num
+		}
+		for _, kid := range elem.kids {
+			fmt.Fprintf(&kids, "%d 0 R ", writeElem(kid, num))
+		}
+		var dict bytes.Buffer
+		fmt.Fprintf(&dict, "%d 0 obj\n<</Type /StructElem /S /%s /P %d 0 R /K [%s]", num, elem.role, parent, bytes.TrimSpace(kids.Bytes()))
+		if elem.alt != "" {
+			fmt.Fprintf(&dict, " /Alt %s", pdfText(elem.alt))
+		}
+		dict.WriteString(">>\nendobj\n")
+		objects[num] = pdfObject{dict: dict.Bytes()}
+		return num
+	}
+	var kids []string
+	for _, elem := range elements {
+		kids = append(kids, strconv.Itoa(writeElem(elem, document))+" 0 R")
+	}
+
+	objects[treeRoot] = pdfObject{dict: fmt.Appendf(nil, "%d 0 obj\n<</Type /StructTreeRoot /K [%d 0 R] /ParentTree %d 0 R /ParentTreeNextKey %d>>\nendobj\n",
+		treeRoot, document, parentTree, len(pages))}
+	objects[document] = pdfObject{dict: fmt.Appendf(nil, "%d 0 obj\n<</Type /StructElem /S /Document /P %d 0 R /K [%s]>>\nendobj\n",
+		document, treeRoot, strings.Join(kids, " "))}
+	var nums bytes.Buffer
+	for i, elems := range parents {
+		fmt.Fprintf(&nums, "%d [", i)
+		for _, num := range elems {
+			if num == 0 {
+				nums.WriteString("null ")
+			} else {
+				fmt.Fprintf(&nums, "%d 0 R ", num)
+			}
+		}
+		nums.WriteString("] ")
+	}
+	objects[parentTree] = pdfObject{dict: fmt.Appendf(nil, "%d 0 obj\n<</Nums [%s]>>\nendobj\n", parentTree, bytes.TrimSpace(nums.Bytes()))}
+
+	// Pages name their entry in the parent tree and follow the structure order for tabbing
+	for i, num := range pages {
+		if err := appendToDict(objects, num, fmt.Sprintf("\n/StructParents %d\n/Tabs /S\n", i)); err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+	entry := fmt.Sprintf("\n/MarkInfo <</Marked true>>\n/StructTreeRoot %d 0 R\n/Lang %s\n/ViewerPreferences <</DisplayDocTitle true>>\n", treeRoot, pdfText(lang))
+	if err := appendToDict(objects, root, entry); err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+	return writeObjects(objects, root, info, nil), nil
+}
+
+// appendToDict adds entries at the end of the dictionary of an object
+func appendToDict(objects map[int]pdfObject, num int, entries string) error {
+	obj := objects[num]
+	end := bytes.LastIndex(obj.dict, []byte(">>"))
+	if end < 0 {
+		return fmt.Errorf("invalid PDF: object %d is not a dictionary", num)
+	}
+	obj.dict = slices.Concat(obj.dict[:end], []byte(entries), obj.dict[end:])
+	objects[num] = obj
+	return nil
+}
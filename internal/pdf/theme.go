@@ -0,0 +1,234 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Color is an RGB color with components in the range 0-255
+type Color struct {
+	R, G, B int
+}
+
+// Theme holds the visual settings of the rendered document
+type Theme struct {
+	// Colors of callouts and severity badges, keyed by kind
+	// ("info", "warning", "critical", "check", "high", "medium", "low", ...)
+	Colors map[string]Color
+
+	// FontFamilies are additional font families available to the font mapping, keyed by family name
+	FontFamilies map[string]FontFamily
+	// Fonts maps document elements to font families
+	Fonts FontMapping
+
+	// Page is the page geometry
+	Page PageGeometry
+	// Text is the spacing of body text
+	Text TextSpacing
+	// Justify sets paragraphs flush with both margins, breaking words at their soft
+	// hyphens or else where Hyphenation allows; nil hyphenates at soft hyphens only
+	Justify     bool
+	Hyphenation *Hyphenator
+	// RTL sets paragraphs, list items and headings right to left, aligned to the right
+	// margin. Right-to-left text in other documents is reordered within its lines.
+	RTL bool
+
+	// ShrinkLimit is the smallest scale applied to tables, code blocks and images slightly
+	// too large for the page (default 0.8); 1 disables shrinking
+	ShrinkLimit float64
+
+	// Orphans is the minimum number of lines of a paragraph left at the bottom of a page,
+	// Widows the minimum carried over to the top of the next one (default 2; 1 disables)
+	Orphans int
+	Widows  int
+
+	// Footer replaces the footer text, with {date} standing for the generation date
+	Footer string
+	// SystemInfo describes the system named in the footer, e.g. HostInfo; nil names none
+	SystemInfo SystemInfoProvider
+
+	// Headings sets the tracking and small caps of headings
+	Headings HeadingTypography
+	// HeadingBreaks sets the page break before the headings of each level, indexed by
+	// level - 1: "page" starts them on a new page, "odd" on the next right-hand page
+	// with a blank page inserted if needed; "" or "auto" only keeps them together with
+	// the following block
+	HeadingBreaks [6]string
+
+	// CodeWrapMarker marks the continuation rows of wrapped code lines with an arrow
+	CodeWrapMarker bool
+
+	// Grayscale converts all colors and images to gray for cheap printing
+	Grayscale bool
+
+	// Logo replaces the built-in logo in the page header; without Data the built-in
+	// logo is kept, at Logo.Width if set
+	Logo HeaderLogo
+	// NoLogo leaves our logo out of the page header
+	NoLogo bool
+	// LogoPosition places our logo in the page header: "right" (default), "left" or "center"
+	LogoPosition string
+	// PageSize is the paper size; A4 if zero
+	PageSize PageSize
+	// Landscape turns the pages sideways
+	Landscape bool
+	// Columns sets the body text in columns, e.g. 2 for a two-column layout; see
+	// SetColumns. 0 or 1 for none.
+	Columns int
+
+	// Watermark is stamped across every page; empty for none
+	Watermark Watermark
+
+	// ClientLogo is shown in the page header opposite our logo: top-left, or top-right
+	// if our logo is on the left
+	ClientLogo HeaderLogo
+
+	// RunningHead shows the title of the current level 1 or 2 section in the page
+	// header, in the space the logos leave
+	RunningHead bool
+
+	// Cover and Chapter set the header and footer of the first page of the document
+	// and of each chapter, like the different first page of word processors
+	Cover   FirstPage
+	Chapter FirstPage
+}
+
+// FirstPage is the header and footer of a page opening the document or a chapter
+type FirstPage struct {
+	// NoHeader leaves the logos and the running head out of the page header
+	NoHeader bool
+	// NoFooter leaves out the footer text
+	NoFooter bool
+	// Header is text shown in the page header in place of the running head, with {date}
+	// standing for the generation date
+	Header string
+	// Footer replaces the footer text, with {date} standing for the generation date
+	Footer string
+}
+
+// HeaderLogo is an image shown in the page header
+type HeaderLogo struct {
+	// Data is the image file content (PNG, JPEG or GIF); empty for no logo
+	Data []byte
+	// Width in millimeters, 40 if zero; the height follows the aspect ratio
+	Width float64
+}
+
+// PageGeometry holds the page margins and the header and footer bands, in millimeters
+type PageGeometry struct {
+	MarginLeft  float64
+	MarginTop   float64
+	MarginRight float64
+	// MarginTopNoHeader is the top margin of pages without a header, MarginTop if zero
+	MarginTopNoHeader float64
+	// MarginBottom is the distance from the bottom edge where content breaks to a new page.
+	// It is never smaller than FooterHeight, so content can't overrun the footer.
+	MarginBottom float64
+	// FooterHeight is the band at the bottom of the page reserved for the footer
+	FooterHeight float64
+	// HeaderY is the distance of the logo from the top edge
+	HeaderY float64
+	// LogoWidth is the width of the header logo
+	LogoWidth float64
+}
+
+// DefaultPageGeometry returns the built-in page geometry
+func DefaultPageGeometry() PageGeometry {
+	return PageGeometry{
+		MarginLeft:   20,
+		MarginTop:    30,
+		MarginRight:  20,
+		MarginBottom: 20,
+		FooterHeight: 15,
+		HeaderY:      10,
+		LogoWidth:    40,
+	}
+}
+
+// TextSpacing holds the line height of body text and the space after paragraphs and
+// list items, in millimeters
+type TextSpacing struct {
+	LineHeight       float64
+	ParagraphSpacing float64
+	ListItemSpacing  float64
+}
+
+// DefaultTextSpacing returns the built-in text spacing
+func DefaultTextSpacing() TextSpacing {
+	return TextSpacing{
+		LineHeight:       6,
+		ParagraphSpacing: 4,
+		ListItemSpacing:  2,
+	}
+}
+
+// defaultOrphans and defaultWidows keep single lines of a paragraph off page edges
+const (
+	defaultOrphans = 2
+	defaultWidows  = 2
+)
+
+// bottom returns the effective bottom margin, kept clear of the footer band
+func (g PageGeometry) bottom() float64 {
+	return max(g.MarginBottom, g.FooterHeight)
+}
+
+// DefaultTheme returns the built-in theme
+func DefaultTheme() Theme {
+	return Theme{
+		Colors: map[string]Color{
+			"info":      {31, 111, 235},
+			"warning":   {191, 135, 0},
+			"critical":  {207, 34, 46},
+			"check":     {26, 127, 55},
+			"high":      {225, 98, 25},
+			"medium":    {191, 135, 0},
+			"low":       {31, 111, 235},
+			"link":      {9, 105, 218},
+			"comment":   {130, 80, 223},
+			"insertion": {26, 127, 55},
+			"deletion":  {207, 34, 46},
+		},
+		Page: DefaultPageGeometry(),
+		Text: DefaultTextSpacing(),
+	}
+}
+
+// color returns the theme color for a callout or severity kind, falling back to the "info" color
+func (t Theme) color(kind string) Color {
+	if c, ok := t.Colors[strings.ToLower(kind)]; ok {
+		return c
+	}
+	if c, ok := t.Colors["info"]; ok {
+		return c
+	}
+	return Color{0, 0, 0}
+}
+
+// tint mixes a color with white; amount 0 keeps the color, 1 gives white
+func (c Color) tint(amount float64) Color {
+	mix := func(v int) int { return v + int(float64(255-v)*amount) }
+	return Color{mix(c.R), mix(c.G), mix(c.B)}
+}
+
+// gray returns the luminance of a color as a gray of the same brightness
+func (c Color) gray() Color {
+	l := int(0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B) + 0.5)
+	return Color{l, l, l}
+}
+
+// ParseColor parses a hex color like #0055a4 or #05a
+func ParseColor(s string) (Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	var c Color
+	if len(hex) != 6 {
+		return c, fmt.Errorf("invalid color %q (want #rrggbb)", s)
+	}
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, fmt.Errorf("invalid color %q (want #rrggbb)", s)
+	}
+	return c, nil
+}
@@ -0,0 +1,128 @@
+package pdf
+
+import (
+	"strings"
+)
+
+// Anchor is the position of a heading in the rendered document
+type Anchor struct {
+	Page int
+	Y    float64
+}
+
+// TOCEntry is one line of the table of contents
+type TOCEntry struct {
+	Level int
+	Text  string
+	// ID is the anchor of the heading the entry points to
+	ID string
+}
+
+// Anchors returns the positions of all headings with an ID rendered so far,
+// placing queued headings first
+func (w *Writer) Anchors() map[string]Anchor {
+	w.flushHeadings()
+	return w.anchors
+}
+
+// PageCount returns the number of pages rendered so far
+func (w *Writer) PageCount() int {
+	return w.pdf.PageCount()
+}
+
+// setAnchor records the current position as the target of links to id
+func (w *Writer) setAnchor(id string) {
+	if id != "" {
+		w.anchors[id] = Anchor{Page: w.pdf.PageNo(), Y: w.pdf.GetY()}
+	}
+}
+
+// SetLayout provides the heading positions of a previous layout pass,
+// so the table of contents can show page numbers of headings that follow it
+func (w *Writer) SetLayout(anchors map[string]Anchor) {
+	w.layout = anchors
+}
+
+// WriteTOC renders a table of contents with dotted leaders, page numbers
+// and internal links, followed by a page break. The title is not listed;
+// an empty title renders the entries only.
+// Page numbers come from the layout set with SetLayout and are left blank without one.
+func (w *Writer) WriteTOC(title string, entries []TOCEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	// The table of contents spans the columns, which start again on the next page
+	w.flushHeadings()
+	if w.spanColumns() {
+		defer w.resumeColumns()
+	}
+	if title != "" {
+		w.WriteHeading(Heading{Level: 1, Text: title})
+	}
+	defer w.beginBlock("toc", "", "", false)()
+
+	minLevel := entries[0].Level
+	for _, e := range entries {
+		minLevel = min(minLevel, e.Level)
+	}
+
+	left, _, _, _ := w.pdf.GetMargins()
+	contentWidth := w.contentWidth()
+	lineHeight := 7.0
+	numberWidth := 12.0
+
+	// Keep the title with the first entry
+	w.placeBlock(lineHeight)
+
+	for _, e := range entries {
+		if w.remainingSpace() < lineHeight {
+			w.addPage()
+		}
+
+		if e.Level == minLevel {
+			w.setFont(fontBody, "B", 12)
+		} else {
+			w.setFont(fontBody, "", 11)
+		}
+
+		indent := float64(e.Level-minLevel) * 6
+		textWidth := contentWidth - indent - numberWidth
+		text := w.fitText(e.Text, textWidth-w.pdf.GetStringWidth(" ..."))
+
+		page := ""
+		link := 0
+		if anchor, ok := w.layout[e.ID]; ok {
+			page = w.numbers.Int(anchor.Page)
+			link = w.pdf.AddLink()
+			w.pdf.SetLink(link, anchor.Y, anchor.Page)
+		}
+
+		// Dotted leader between the text and the page number
+		dotWidth := w.pdf.GetStringWidth(".")
+		gap := textWidth - 2*w.pdf.GetCellMargin() - w.pdf.GetStringWidth(text+" ")
+		leader := ""
+		if dotWidth > 0 && gap > 0 {
+			leader = " " + strings.Repeat(".", int(gap/dotWidth))
+		}
+
+		w.pdf.SetX(left + indent)
+		w.pdf.CellFormat(textWidth, lineHeight, text+leader, "", 0, "L", false, link, "")
+		w.pdf.CellFormat(numberWidth, lineHeight, page, "", 1, "R", false, link, "")
+	}
+
+	w.setFont(fontBody, "", 12)
+	w.addPage()
+}
+
+// fitText shortens text with an ellipsis so it fits into width in the current font
+func (w *Writer) fitText(text string, width float64) string {
+	if w.pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && w.pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,73 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// smallCapsScale is the size of the capitals standing in for lowercase letters in
+// small caps, relative to the font size
+const smallCapsScale = 0.78
+
+// HeadingTypography adjusts the letterforms of headings, e.g. to follow brand guidelines
+type HeadingTypography struct {
+	// Tracking is the space added between characters in thousandths of an em, e.g. 50;
+	// negative values tighten the text
+	Tracking float64
+	// SmallCaps sets lowercase letters as smaller capitals
+	SmallCaps bool
+}
+
+// styled reports whether headings need per-character placement
+func (t HeadingTypography) styled() bool {
+	return t.Tracking != 0 || t.SmallCaps
+}
+
+// trackedPiece is a run of characters set at the same size
+type trackedPiece struct {
+	text  string
+	scale float64
+}
+
+// trackedPieces splits text into runs of the same size, turning lowercase letters
+// into smaller capitals for small caps
+func trackedPieces(text string, smallCaps bool) []trackedPiece {
+	if !smallCaps {
+		return []trackedPiece{{text, 1}}
+	}
+	var pieces []trackedPiece
+	for _, r := range text {
+		piece := trackedPiece{string(r), 1}
+		if unicode.IsLower(r) {
+			piece = trackedPiece{strings.ToUpper(string(r)), smallCapsScale}
+		}
+		if last := len(pieces) - 1; last >= 0 && pieces[last].scale == piece.scale {
+			pieces[last].text += piece.text
+			continue
+		}
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
+
+// drawTracked draws text at the current position in a line of height lineHeight,
+// placing each character to apply the tracking and small caps of the typography,
+// and moves to the next line
+func (w *Writer) drawTracked(text, role, style string, size, lineHeight float64, t HeadingTypography) {
+	x, y := w.pdf.GetXY()
+	// gofpdf sets text in a line at 0.3 of the font size below its middle
+	baseline := y + lineHeight/2 + 0.3*size*ptToMM
+	tracking := t.Tracking / 1000 * size * ptToMM
+	// Start where a cell would place the text
+	x += w.pdf.GetCellMargin()
+	for _, piece := range trackedPieces(text, t.SmallCaps) {
+		w.setFont(role, style, size*piece.scale)
+		for _, r := range piece.text {
+			w.pdf.Text(x, baseline, string(r))
+			x += w.pdf.GetStringWidth(string(r)) + tracking
+		}
+	}
+	w.setFont(role, style, size)
+	w.pdf.SetXY(x, y)
+	w.pdf.Ln(lineHeight)
+}
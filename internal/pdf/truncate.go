@@ -0,0 +1,101 @@
+package pdf
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// cellNotes holds the full values of the truncated cells of a table: those listed in
+// notes, numbered in the order they appear, and those attached. A value repeated in
+// several cells keeps its number or attachment.
+type cellNotes struct {
+	values      []string
+	numbers     map[string]int
+	attachments map[string]*gofpdf.Attachment
+}
+
+// truncatePolicy returns the policy of column i for values too long for it
+func truncatePolicy(truncate []string, i int) string {
+	if i < len(truncate) {
+		return truncate[i]
+	}
+	return ""
+}
+
+// truncateCell returns the text of a cell of a truncating column fitted to width on one
+// line. A shortened value is numbered and marked for its note below the table, or
+// attached to the PDF as a file opened from the cell at x, y.
+func (w *Writer) truncateCell(value string, width float64, policy string, x, y, cellWidth, cellHeight float64) string {
+	if w.pdf.GetStringWidth(value) <= width {
+		return value
+	}
+	if policy == "attachment" {
+		attachment, ok := w.cellNotes.attachments[value]
+		if !ok {
+			attachment = &gofpdf.Attachment{
+				Content:     []byte(value),
+				Filename:    fmt.Sprintf("table-%d-value-%d.txt", w.tables, len(w.cellNotes.attachments)+1),
+				Description: value,
+			}
+			if w.cellNotes.attachments == nil {
+				w.cellNotes.attachments = map[string]*gofpdf.Attachment{}
+			}
+			w.cellNotes.attachments[value] = attachment
+		}
+		w.pdf.AddAttachmentAnnotation(attachment, x, y, cellWidth, cellHeight)
+		return w.middleEllipsis(value, width)
+	}
+
+	number, ok := w.cellNotes.numbers[value]
+	if !ok {
+		w.cellNotes.values = append(w.cellNotes.values, value)
+		number = len(w.cellNotes.values)
+		if w.cellNotes.numbers == nil {
+			w.cellNotes.numbers = map[string]int{}
+		}
+		w.cellNotes.numbers[value] = number
+	}
+	marker := " [" + w.FormatNumber(number) + "]"
+	return w.middleEllipsis(value, width-w.pdf.GetStringWidth(marker)) + marker
+}
+
+// middleEllipsis shortens text to fit width by replacing its middle with an ellipsis,
+// keeping the start and the end that tell paths and hashes apart
+func (w *Writer) middleEllipsis(text string, width float64) string {
+	if w.pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	// Start from the share of the characters that fits and drop one at a time
+	keep := int(float64(len(runes)) * width / w.pdf.GetStringWidth(text))
+	for n := min(keep, len(runes)-1); n > 0; n-- {
+		short := string(runes[:(n+1)/2]) + "…" + string(runes[len(runes)-n/2:])
+		if w.pdf.GetStringWidth(short) <= width {
+			return short
+		}
+	}
+	return "…"
+}
+
+// writeCellNotes lists the full values of the table's truncated cells below it by their
+// number, except the attached ones
+func (w *Writer) writeCellNotes() {
+	notes := w.cellNotes
+	w.cellNotes = cellNotes{}
+	if len(notes.numbers) == 0 {
+		return
+	}
+	left, _, _, _ := w.pdf.GetMargins()
+	w.pdf.Ln(2)
+	w.setFont(fontBody, "", captionFontSize)
+	for i, value := range notes.values {
+		// Values wrap below themselves, not below their number
+		marker := "[" + w.FormatNumber(i+1) + "] "
+		indent := w.pdf.GetStringWidth(marker)
+		w.pdf.SetX(left)
+		w.pdf.CellFormat(indent, captionLineHeight, marker, "", 0, "L", false, 0, "")
+		w.pdf.MultiCell(w.contentWidth()-indent, captionLineHeight, value, "", "L", false)
+	}
+	w.setFont(fontBody, "", 12)
+}
@@ -0,0 +1,77 @@
+package pdf
+
+import (
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// defaultWatermarkOpacity keeps the content under a watermark readable
+const defaultWatermarkOpacity = 0.15
+
+// Watermark is stamped across every page, over the content
+type Watermark struct {
+	// Text is drawn diagonally from the bottom-left to the top-right corner,
+	// e.g. "DRAFT" or "CONFIDENTIAL"
+	Text string
+	// Image is an image file content (PNG, JPEG or GIF) centered on the page at half
+	// its width; empty for none
+	Image []byte
+	// Opacity is between 0 (invisible) and 1 (opaque), 0.15 if zero
+	Opacity float64
+}
+
+// registerWatermark registers the watermark image, warning about one that can't be used
+func (w *Writer) registerWatermark() {
+	if len(w.theme.Watermark.Image) == 0 {
+		return
+	}
+	name, _, err := w.registerImage("watermark", w.theme.Watermark.Image)
+	if err != nil {
+		w.warnf("watermark image skipped: %v", err)
+		return
+	}
+	w.watermarkImage = name
+}
+
+// drawWatermark stamps the watermark on the current page
+func (w *Writer) drawWatermark() {
+	mark := w.theme.Watermark
+	if mark.Text == "" && w.watermarkImage == "" {
+		return
+	}
+	opacity := mark.Opacity
+	if opacity <= 0 {
+		opacity = defaultWatermarkOpacity
+	}
+	pageWidth, pageHeight := w.pdf.GetPageSize()
+
+	w.pdf.TransformBegin()
+	defer w.pdf.TransformEnd()
+	w.pdf.SetAlpha(min(opacity, 1), "Normal")
+
+	if w.watermarkImage != "" {
+		width := pageWidth / 2
+		info := w.pdf.GetImageInfo(w.watermarkImage)
+		height := width * info.Height() / info.Width()
+		w.pdf.ImageOptions(w.watermarkImage, (pageWidth-width)/2, (pageHeight-height)/2, width, height, false, gofpdf.ImageOptions{}, 0, "")
+	}
+
+	if mark.Text != "" {
+		// Fill about two thirds of the page diagonal
+		diagonal := math.Hypot(pageWidth, pageHeight)
+		w.setFont(fontHeading, "B", 100)
+		size := 100 * diagonal * 0.65 / w.pdf.GetStringWidth(mark.Text)
+		w.setFont(fontHeading, "B", size)
+		_, height := w.pdf.GetFontSize()
+
+		r, g, b := w.pdf.GetTextColor()
+		defer w.pdf.SetTextColor(r, g, b)
+		cx, cy := pageWidth/2, pageHeight/2
+		w.pdf.TransformRotate(math.Atan2(pageHeight, pageWidth)*180/math.Pi, cx, cy)
+		w.setTextColor(128, 128, 128)
+		// Text rather than a cell, which would take a negative x from the right edge;
+		// the baseline sits below the center by about half the cap height
+		w.pdf.Text(cx-w.pdf.GetStringWidth(mark.Text)/2, cy+height*0.35, mark.Text)
+	}
+}
@@ -2,282 +2,460 @@ package pdf
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"report/internal/qr"
+
 	"github.com/alecthomas/chroma/v2"
-	"github.com/alecthomas/chroma/v2/lexers"
-	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/jung-kurt/gofpdf"
 )
 
 type Writer struct {
-	pdf              *gofpdf.Fpdf
-	logoOpt          gofpdf.ImageOptions
-	logoWidth        float64
-	logoHeight       float64
-	tempFiles        []string // Track temp files for cleanup
-	lastHeadingLevel int      // Track last heading level to detect section boundaries
-	lastLevel2Y      float64  // Track Y position of last level 2 heading
-	lastLevel2Page   int      // Track page number of last level 2 heading
-	// PDF metadata
-	author  string
-	date    string
-	project string
-}
-
-func NewWriter() *Writer {
-	p := gofpdf.New("P", "mm", "A4", "")
-	var tempFiles []string
+	pdf             *gofpdf.Fpdf
+	pendingHeadings []Heading // Headings waiting to be placed together with the block that follows
+	theme           Theme
+	fontStyles      map[string]map[string]bool // Registered styles of user-supplied font families
+	coverage        map[string]glyphCoverage   // Characters of the regular variant of each family
+	missingGlyphs   map[rune]bool              // Characters warned about for lack of a glyph
+	warnings        []string
+	baseDir         string                                 // Directory relative image paths are resolved against
+	remoteImages    map[string][]byte                      // Downloaded remote images by URL
+	resolve         func(path, dir string) (string, error) // Maps image paths to files, nil for plain joining
+	anchors         map[string]Anchor                      // Positions of headings rendered so far, by ID
+	layout          map[string]Anchor                      // Heading positions from a previous layout pass
+	bookmarkLevel   int                                    // Outline level of the last bookmark, -1 before the first
+	qrCode          *qr.Code                               // QR code stamped in the footer, nil for none
+	qrURL           string                                 // URL the QR code encodes
+	qrEveryPage     bool                                   // Stamp the QR code on every page, not just the first
+	figures         int                                    // Images and image placeholders placed so far
+	missingImages   []MissingImage                         // Images drawn as a placeholder
+	images          []string                               // Paths and URLs of the images placed, each once
+	fontCredits     []Credit                               // Fonts registered, each once
+	tables          int                                    // Tables placed so far
+	cellNotes       cellNotes                              // Full values of the truncated cells of the table being written
+	pageInfo        map[string]string                      // Properties stamped invisibly on every page, nil for none
+	tagged          bool                                   // Whether the PDF is tagged with its structure
+	signature       *Signature                             // Signature of the PDF, nil for none
+	mergeBefore     [][]byte                               // PDFs whose pages come before the document's
+	mergeAfter      [][]byte                               // PDFs whose pages come after the document's
+	tagLang         string                                 // Language of a tagged PDF
+	structure       []*structElem                          // Ended structure elements in reading order
+	tags            []*structElem                          // Open structure elements, innermost last
+	list            *structElem                            // List the last list items were added to
+	marking         bool                                   // Whether a marked-content sequence is open
+	mcids           []int                                  // Next MCID of each page
+	columns         int                                    // Columns of the body set by SetColumns, 1 or 0 for none
+	inColumns       bool                                   // Whether the body flows in columns, false while a block spans them
+	column          int                                    // Column being written, 0 for the first
+	columnTop       float64                                // Y where the columns start on the current page
+	columnEnds      []float64                              // Y where the content of the columns before the current one ends
+	columnBreak     float64                                // Y where columns but the last break to balance them, 0 for the content bottom
+	forcedColumn    bool                                   // Whether WriteColumnBreak broke a column of the current run
+	columnRuns      []float64                              // Heights of the runs of columns ended so far, see ColumnLayout
+	runHeight       float64                                // Height of the content of the current run of columns on earlier pages
+	balance         []float64                              // Heights of the runs of columns of a layout pass, see SetColumnLayout
+	watermarkImage  string                                 // Registered watermark image, "" for none
+	pageSize        PageSize                               // Paper size of new pages
+	landscape       bool                                   // Orientation of new pages
+	reproducible    bool                                   // Leave the system information out of the footer and sort PDF dictionaries
+	timestamp       time.Time                              // Generation time printed in the footer, zero for the current time
+	info            DocumentInfo                           // PDF metadata
+	numbers         NumberFormat                           // Digit grouping of generated numbers
+	progress        func(pages int, section string)        // Called as pages and sections start, nil for none
+	section         string                                 // Title of the last level 1 or 2 heading
+	head            runningHead                            // Running head of the current page
+	first           FirstPage                              // Header and footer of the current page if it opens the document or a chapter
+	chapterNext     bool                                   // Set while a chapter break adds the pages opening the chapter
+	plan            [][]PlanBlock                          // Blocks written so far, by page
+	block           *planBlock                             // Block being written, nil between blocks
+	planned         int                                    // Blocks numbered so far
+}
+
+// NewWriter starts a document with the theme. It fails with ErrFontLoad if the
+// embedded fonts or the font families of the font mapping can't be loaded.
+func NewWriter(theme Theme) (*Writer, error) {
+	if theme.Page == (PageGeometry{}) {
+		theme.Page = DefaultPageGeometry()
+	}
+	page := theme.Page
+
+	size := theme.PageSize
+	if size == (PageSize{}) {
+		size = pageSizes["a4"]
+	}
+	p := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: orientation(theme.Landscape),
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: size.Width, Ht: size.Height},
+	})
+	w := &Writer{
+		pdf:           p,
+		theme:         theme,
+		anchors:       map[string]Anchor{},
+		coverage:      map[string]glyphCoverage{},
+		missingGlyphs: map[rune]bool{},
+		bookmarkLevel: -1,
+		pageSize:      size,
+		landscape:     theme.Landscape,
+		numbers:       NumberFormatFor(""),
+	}
 
 	// Register embedded fonts - must use custom fonts only, never default fonts
-	// Write TTF to temp files since AddUTF8Font requires file paths
-	// Create temp file for Italic font
-	italicTempFile, err := os.CreateTemp("", "MapleMono-Italic-*.ttf")
-	if err == nil {
-		italicFile := italicTempFile.Name()
-		if _, err := italicTempFile.Write(FontItalic); err == nil {
-			italicTempFile.Close()
-			// Get absolute path to ensure gofpdf can find it
-			italicFile, _ = filepath.Abs(italicFile)
-			// Set font location to the directory containing the font
-			p.SetFontLocation(filepath.Dir(italicFile))
-			p.AddUTF8Font("Mono-Italic", "", filepath.Base(italicFile))
-			tempFiles = append(tempFiles, italicFile)
+	if err := w.registerEmbeddedFonts(); err != nil {
+		return nil, err
+	}
+
+	// Register user-supplied font families
+	if err := w.registerFontFamilies(); err != nil {
+		return nil, err
+	}
+
+	// Set default font to custom font
+	w.setFont(fontBody, "", 12)
+
+	// Set margins and break pages before content reaches the footer band
+	p.SetMargins(page.MarginLeft, page.MarginTop, page.MarginRight)
+	w.resetPageBreak()
+	p.SetAcceptPageBreakFunc(func() bool {
+		if w.nextColumn() {
+			return false
+		}
+		if w.inColumns {
+			// gofpdf keeps the position across the break, so the text goes on in the first column
+			left, _ := w.columnBounds(0)
+			p.SetX(left)
+		}
+		// An earlier break set by breakParagraph only applies to the current page
+		w.resetPageBreak()
+		return true
+	})
+
+	// Register our logo once, the built-in one unless replaced
+	logo := ""
+	logoWidth := theme.Logo.Width
+	if logoWidth <= 0 {
+		logoWidth = page.LogoWidth
+	}
+	switch {
+	case theme.NoLogo:
+	case len(theme.Logo.Data) > 0:
+		name, _, err := w.registerImage("header-logo", theme.Logo.Data)
+		if err != nil {
+			w.warnf("logo skipped: %v", err)
+			logo = w.registerBuiltinLogo()
 		} else {
-			italicTempFile.Close()
-			os.Remove(italicFile)
+			logo = name
 		}
+	default:
+		logo = w.registerBuiltinLogo()
 	}
 
-	// Create temp file for BoldItalic font
-	boldItalicTempFile, err := os.CreateTemp("", "MapleMono-BoldItalic-*.ttf")
-	if err == nil {
-		boldItalicFile := boldItalicTempFile.Name()
-		if _, err := boldItalicTempFile.Write(FontBoldItalic); err == nil {
-			boldItalicTempFile.Close()
-			// Get absolute path
-			boldItalicFile, _ = filepath.Abs(boldItalicFile)
-			// Font location already set above, just add the font
-			p.AddUTF8Font("Mono-BoldItalic", "", filepath.Base(boldItalicFile))
-			tempFiles = append(tempFiles, boldItalicFile)
+	// Register the client logo, shown opposite ours
+	clientLogo := ""
+	clientLogoWidth := theme.ClientLogo.Width
+	if clientLogoWidth <= 0 {
+		clientLogoWidth = 40
+	}
+	if len(theme.ClientLogo.Data) > 0 {
+		name, _, err := w.registerImage("client-logo", theme.ClientLogo.Data)
+		if err != nil {
+			w.warnf("client logo skipped: %v", err)
 		} else {
-			boldItalicTempFile.Close()
-			os.Remove(boldItalicFile)
+			clientLogo = name
 		}
 	}
 
-	// Set default font to custom font
-	p.SetFont("Mono-Italic", "", 12)
+	w.registerWatermark()
 
-	// Set margins: left, top, right
-	p.SetMargins(20, 30, 20)
+	// Set header function to draw the logos on every page
+	p.SetHeaderFunc(func() {
+		w.beginArtifact()
+		defer w.endArtifact(true)
+		w.first = FirstPage{}
+		switch {
+		case p.PageNo() == 1:
+			w.first = theme.Cover
+		case w.chapterNext:
+			w.first = theme.Chapter
+		}
 
-	// Register logo image once
-	r := bytes.NewReader(Logo)
-	opt := gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: true}
-	p.RegisterImageOptionsReader("logo", opt, r)
+		// Pages without a header may start higher up
+		top := page.MarginTop
+		noHeader := w.first.NoHeader || (logo == "" && clientLogo == "" && !theme.RunningHead && w.first.Header == "")
+		if noHeader && page.MarginTopNoHeader > 0 {
+			top = page.MarginTopNoHeader
+		}
+		p.SetTopMargin(top)
+		p.SetY(top)
 
-	// Get logo dimensions for header placement
-	logoWidth := 40.0 // Width in mm
-	logoHeight := 0.0 // Auto height
+		w.breakBlock()
+		if w.inColumns {
+			w.startColumns(top)
+		}
+		w.continueBlock(top)
+		if w.progress != nil {
+			w.progress(p.PageNo(), w.section)
+		}
+		w.head = runningHead{}
+		if w.first.NoHeader {
+			return
+		}
 
-	// Set header function to draw logo on every page
-	p.SetHeaderFunc(func() {
-		// Get page width
 		pageWidth, _ := p.GetPageSize()
-		// Position logo in upper right corner with margin
-		logoX := pageWidth - 20 - logoWidth
-		logoY := 10.0
-		p.ImageOptions("logo", logoX, logoY, logoWidth, logoHeight, false, opt, 0, "")
+		left := page.MarginLeft
+		right := pageWidth - page.MarginRight
+
+		// Our logo in the upper right corner by default, the client logo opposite
+		logoX := right - logoWidth
+		clientLogoX := left
+		switch theme.LogoPosition {
+		case "left":
+			logoX = left
+			clientLogoX = right - clientLogoWidth
+		case "center":
+			logoX = (pageWidth - logoWidth) / 2
+		}
+		var logos []headerImage
+		if logo != "" {
+			p.ImageOptions(logo, logoX, page.HeaderY, logoWidth, 0, false, gofpdf.ImageOptions{}, 0, "")
+			logos = append(logos, w.headerImage(logo, logoX, logoWidth))
+		}
+		if clientLogo != "" {
+			p.ImageOptions(clientLogo, clientLogoX, page.HeaderY, clientLogoWidth, 0, false, gofpdf.ImageOptions{}, 0, "")
+			logos = append(logos, w.headerImage(clientLogo, clientLogoX, clientLogoWidth))
+		}
+		if theme.RunningHead || w.first.Header != "" {
+			w.startRunningHead(left, right, logos)
+		}
 	})
 
-	// Get system metadata for footer
-	systemInfo := getSystemMetadata()
-
-	// Set footer function to display system metadata on every page
+	// Set footer function to display the generation date on every page
 	p.SetFooterFunc(func() {
+		w.beginArtifact()
+		defer w.endArtifact(false)
 		// Use custom font - never default fonts
-		p.SetFont("Mono-Italic", "", 9)
+		w.setFont(fontBody, "", 9)
 
 		// Get page dimensions
 		pageWidth, pageHeight := p.GetPageSize()
 
 		// Position footer text at bottom center
-		footerY := pageHeight - 15.0 // 15mm from bottom
-		footerText := "Report generated on: " + systemInfo + " - " + time.Now().Format("02.01.2006")
+		if !w.first.NoFooter {
+			footerY := pageHeight - page.FooterHeight
+			footerText := w.footerText()
+
+			// Center the text
+			p.SetXY(0, footerY)
+			p.CellFormat(pageWidth, 5, footerText, "", 0, "C", false, 0, "")
+		}
 
-		// Center the text
-		p.SetXY(0, footerY)
-		p.CellFormat(pageWidth, 5, footerText, "", 0, "C", false, 0, "")
+		w.drawQRCode()
+		w.drawWatermark()
+		w.drawRunningHead()
 	})
 
 	// Add first page
 	p.AddPage()
+	if theme.Columns > 1 {
+		w.SetColumns(theme.Columns)
+	}
 
-	return &Writer{
-		pdf:        p,
-		logoOpt:    opt,
-		logoWidth:  logoWidth,
-		logoHeight: logoHeight,
-		tempFiles:  tempFiles,
+	return w, nil
+}
+
+// registerBuiltinLogo registers the embedded logo and returns its image name
+func (w *Writer) registerBuiltinLogo() string {
+	logo := Logo
+	if w.theme.Grayscale {
+		logo = grayPNG(logo)
 	}
+	w.pdf.RegisterImageOptionsReader("builtin-logo", gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: true}, bytes.NewReader(logo))
+	return "builtin-logo"
 }
 
-func (w *Writer) WriteHeading(level int, text string) {
-	if text == "" {
+// Warnings returns the problems encountered while rendering that didn't stop the PDF from being generated
+func (w *Writer) Warnings() []string {
+	return w.warnings
+}
+
+// Figures returns the number of block images rendered so far, counting placeholders of remote images
+func (w *Writer) Figures() int {
+	return w.figures
+}
+
+// Tables returns the number of tables rendered so far
+func (w *Writer) Tables() int {
+	return w.tables
+}
+
+// Warnf records a warning about the document being rendered
+func (w *Writer) Warnf(format string, args ...any) {
+	w.warnf(format, args...)
+}
+
+// warnf records a rendering warning
+func (w *Writer) warnf(format string, args ...any) {
+	w.warnings = append(w.warnings, fmt.Sprintf(format, args...))
+}
+
+// Heading describes a heading to render
+type Heading struct {
+	Level int
+	Text  string
+	// ID is the anchor of the heading, used by the table of contents
+	ID string
+	// Number is the section number shown before the text, e.g. "2.1" or "Appendix A"
+	Number string
+	// Severity adds a colored severity badge before the text,
+	// e.g. for finding titles such as "[High] SQL injection in login form"
+	Severity string
+}
+
+// WriteHeading queues a heading. It is drawn together with the next block,
+// so a heading is never left alone at the bottom of a page. A heading whose level
+// breaks the page in the theme's HeadingBreaks starts a new page, taking the headings
+// directly above it along.
+func (w *Writer) WriteHeading(h Heading) {
+	if h.Text == "" {
 		return
 	}
+	switch policy := w.theme.HeadingBreaks[min(max(h.Level, 1), 6)-1]; policy {
+	case "page", "odd":
+		above := w.pendingHeadings
+		w.pendingHeadings = nil
+		w.WritePageBreak(policy == "odd")
+		w.pendingHeadings = above
+	}
+	w.pendingHeadings = append(w.pendingHeadings, h)
+}
 
-	// Use different font sizes for different heading levels
-	var size float64
-	switch level {
-	case 1:
-		size = 20.0
-	case 2:
-		size = 16.0
-	case 3:
-		size = 14.0
-	case 4:
-		size = 13.0
-	case 5:
-		size = 12.5
-	default:
-		size = 12.0
+// drawHeading draws a heading at the current position
+func (w *Writer) drawHeading(h Heading) {
+	level, text := h.Level, h.Text
+	size := headingSize(level)
+	if h.Number != "" {
+		text = h.Number + " " + text
 	}
 
 	// Use custom font
-	w.pdf.SetFont("Mono-BoldItalic", "", size)
+	w.setFont(fontHeading, "B", size)
 
-	// Check if we need a new page to avoid splitting sections
-	_, y := w.pdf.GetXY()
-	_, pageHeight := w.pdf.GetPageSize()
-	marginBottom := 20.0 // Bottom margin
-	remainingSpace := pageHeight - y - marginBottom
-
-	// Calculate minimum space needed for the heading and its content
-	// Only break when we actually don't have enough space, not just based on position
-	var minSpaceNeeded float64
-
-	if level == 2 {
-		// Level 2: need space for heading + potential level 3 subsection + content
-		minSpaceNeeded = 100.0 // Conservative estimate
-	} else if level == 3 {
-		// Level 3: need space for heading + content
-		minSpaceNeeded = 60.0
-	} else if level == 1 {
-		minSpaceNeeded = 80.0
-	} else {
-		minSpaceNeeded = 40.0
-	}
-
-	// Check if parent level 2 is on same page (for level 3 headings)
-	// If parent is very low on page and we need to break
-	var shouldBreak bool
-	if level == 3 {
-		currentPage := w.pdf.PageNo()
-		if w.lastLevel2Page == currentPage && w.lastLevel2Y > 0 {
-			// Check if we actually need to break
-			needsBreakBySpace := remainingSpace < minSpaceNeeded
-
-			if needsBreakBySpace {
-				// We need to break - check if parent is low on page
-				// If parent is in bottom 50% of page, break to keep them together
-				if w.lastLevel2Y > pageHeight*0.50 {
-					// Parent is low, definitely break to keep parent+child together
-					shouldBreak = true
-				} else if w.lastLevel2Y > pageHeight*0.40 && remainingSpace < minSpaceNeeded*1.2 {
-					// Parent is getting low and space is tight, break
-					shouldBreak = true
-				} else {
-					// Parent is fine, just break if we need space
-					shouldBreak = remainingSpace < minSpaceNeeded
-				}
-			} else {
-				// We have enough space, don't break
-				shouldBreak = false
-			}
-		} else {
-			// No parent on this page, just check space
-			shouldBreak = remainingSpace < minSpaceNeeded
+	// Add spacing before heading (except at the top of a page or column)
+	if w.pdf.GetY() > w.blockTop() {
+		w.pdf.Ln(headingSpaceBefore)
+	}
+
+	// Remember where the heading starts for TOC entries and links
+	w.setAnchor(h.ID)
+	defer w.beginBlock("heading", h.ID, text, true)()
+	defer w.beginTag("H"+strconv.Itoa(min(level, 6)), "")()
+
+	// Outline levels may only deepen one step at a time
+	bookmarkLevel := min(level-1, w.bookmarkLevel+1)
+	w.pdf.Bookmark(text, bookmarkLevel, -1)
+	w.bookmarkLevel = bookmarkLevel
+
+	if level <= 2 {
+		w.section = text
+		if w.head.section == "" {
+			w.head.section = text
 		}
-	} else {
-		// For other levels, only break if we don't have enough space
-		// Use position as a secondary check only when space is borderline
-		shouldBreak = remainingSpace < minSpaceNeeded
-
-		// For level 2, also check position if space is borderline (within 20% of min needed)
-		if level == 2 && remainingSpace < minSpaceNeeded*1.2 {
-			// Space is tight - check if we're in bottom portion of page
-			if y > pageHeight*0.65 {
-				// We're in bottom 35% and space is tight, break
-				shouldBreak = true
-			}
+		if w.progress != nil {
+			w.progress(w.pdf.PageNo(), text)
 		}
 	}
 
-	// Add spacing before heading (except for first heading)
-	if y > 40 { // Not at the very top
-		if shouldBreak {
-			w.pdf.AddPage()
-		} else {
-			w.pdf.Ln(4)
+	// The number goes before the severity badge
+	if h.Severity != "" {
+		if h.Number != "" {
+			w.pdf.CellFormat(w.pdf.GetStringWidth(h.Number+" "), headingLineHeight, h.Number+" ", "", 0, "L", false, 0, "")
 		}
+		w.drawSeverityBadge(h.Severity, headingLineHeight)
+		w.setFont(fontHeading, "B", size)
+		text = h.Text
 	}
 
-	// Track level 2 heading position BEFORE writing (for parent-child relationship)
-	if level == 2 {
-		_, y := w.pdf.GetXY()
-		w.lastLevel2Y = y
-		w.lastLevel2Page = w.pdf.PageNo()
+	if w.theme.RTL || hasRTL([]Span{{Text: text}}) {
+		align := "L"
+		if w.theme.RTL {
+			align = "R"
+		}
+		w.pdf.CellFormat(0, headingLineHeight, visualText(text, w.baseLevel()), "", 1, align, false, 0, "")
+	} else if w.theme.Headings.styled() {
+		w.drawTracked(text, fontHeading, "B", size, headingLineHeight, w.theme.Headings)
+	} else {
+		w.drawRuns(text, fontHeading, "B", size, headingLineHeight, "L")
 	}
-
-	w.pdf.CellFormat(0, 12, text, "", 1, "L", false, 0, "")
-	w.pdf.Ln(3)
-
-	// Track the heading level for content spacing decisions
-	w.lastHeadingLevel = level
+	w.pdf.Ln(headingSpaceAfter)
 }
 
-func (w *Writer) WriteParagraph(text string) {
-	if text == "" {
+func (w *Writer) WriteParagraph(spans []Span) {
+	if spansEmpty(spans) {
 		return
 	}
+	defer w.beginBlock("paragraph", "", "", false)()
+	spans = w.fallbackSpans(spans)
 
-	// Use custom font
-	w.pdf.SetFont("Mono-Italic", "", 12)
+	bidi := w.laidOutFirst(spans)
+	var justified []justifiedLine
+	if (w.theme.Justify || bidi) && justifiable(spans) {
+		justified = w.justifyLines(spans, 12, w.contentWidth())
+	}
+	lines := len(justified)
+	if justified == nil {
+		lines = w.spanLines(spans, 12)
+	}
+	orphans, widows := w.theme.Orphans, w.theme.Widows
+	if orphans <= 0 {
+		orphans = defaultOrphans
+	}
+	if widows <= 0 {
+		widows = defaultWidows
+	}
 
-	// Check if paragraph fits on current page, if not, add page break
-	_, y := w.pdf.GetXY()
-	_, pageHeight := w.pdf.GetPageSize()
-	marginBottom := 20.0
-	remainingSpace := pageHeight - y - marginBottom
-
-	// Estimate height needed for paragraph (rough estimate: 6mm per line, assume 3-4 lines minimum)
-	estimatedHeight := 24.0
-
-	// If this paragraph follows a heading, be more aggressive about page breaks
-	// Check if we're in the bottom portion of the page
-	thresholdY := pageHeight * 0.65 // 65% down the page
-	if w.lastHeadingLevel > 0 {
-		// If we just wrote a heading and we're past threshold or don't have enough space, new page
-		if y > thresholdY || remainingSpace < estimatedHeight+20.0 {
-			w.pdf.AddPage()
-		}
-	} else if remainingSpace < estimatedHeight {
-		w.pdf.AddPage()
+	// Keep the heading with the first lines; the rest may continue on the next page
+	lineHeight := w.theme.Text.LineHeight
+	w.placeBlock(float64(min(lines, orphans)) * lineHeight)
+	w.breakParagraph(lines, lineHeight, orphans, widows)
+
+	switch {
+	case bidi:
+		left, _, _, _ := w.pdf.GetMargins()
+		w.writeBidi(spans, justified, lineHeight, 12, left, w.contentWidth(), w.theme.Justify)
+	case justified != nil:
+		w.writeJustified(spans, justified, lineHeight, 12)
+	default:
+		w.writeSpans(spans, lineHeight, 12)
 	}
+	w.resetPageBreak()
+	w.pdf.Ln(lineHeight)
+	w.pdf.Ln(w.theme.Text.ParagraphSpacing)
+}
 
-	w.pdf.MultiCell(0, 6, text, "", "L", false)
-	w.pdf.Ln(4)
+// WriteCenteredParagraph writes a single-line paragraph centered between the margins,
+// e.g. a display formula; longer ones wrap like WriteParagraph
+func (w *Writer) WriteCenteredParagraph(spans []Span) {
+	if spansEmpty(spans) {
+		return
+	}
+	defer w.beginBlock("paragraph", "", "", false)()
+	spans = w.fallbackSpans(spans)
 
-	// Reset heading level tracking after writing content
-	w.lastHeadingLevel = 0
+	lineHeight := w.theme.Text.LineHeight
+	w.placeBlock(lineHeight)
+	if w.spanLines(spans, 12) == 1 {
+		left, _, _, _ := w.pdf.GetMargins()
+		w.pdf.SetX(left + (w.contentWidth()-w.spansWidth(spans, 12))/2)
+	}
+	w.writeSpans(spans, lineHeight, 12)
+	w.pdf.Ln(lineHeight)
+	w.pdf.Ln(w.theme.Text.ParagraphSpacing)
 }
 
 func (w *Writer) WriteText(text string) {
@@ -285,9 +463,11 @@ func (w *Writer) WriteText(text string) {
 		return
 	}
 
+	w.flushHeadings()
+
 	// Use custom font
-	w.pdf.SetFont("Mono-Italic", "", 12)
-	w.pdf.Write(6, text)
+	w.setFont(fontBody, "", 12)
+	w.pdf.Write(w.theme.Text.LineHeight, text)
 }
 
 func (w *Writer) WriteCode(code string) {
@@ -296,22 +476,9 @@ func (w *Writer) WriteCode(code string) {
 	}
 
 	// Use custom font
-	w.pdf.SetFont("Mono-Italic", "", 11)
-
-	// Check if code block fits on current page
-	_, y := w.pdf.GetXY()
-	_, pageHeight := w.pdf.GetPageSize()
-	marginBottom := 20.0
-	remainingSpace := pageHeight - y - marginBottom
+	w.setFont(fontCode, "", 11)
 
-	// Estimate height needed (rough estimate)
-	estimatedHeight := 20.0
-	if remainingSpace < estimatedHeight {
-		w.pdf.AddPage()
-	}
-
-	w.pdf.SetFillColor(240, 240, 240)
-	w.pdf.MultiCell(0, 6, code, "", "L", true)
+	w.writeCodeLines(plainCodeLines(code), Color{240, 240, 240}, CodeOptions{})
 	w.pdf.Ln(3)
 }
 
@@ -320,15 +487,17 @@ func (w *Writer) WriteInlineCode(code string) {
 		return
 	}
 
+	w.flushHeadings()
+
 	// Save current position
 	x, y := w.pdf.GetXY()
 
 	// Use monospace font for inline code
-	w.pdf.SetFont("Mono-Italic", "", 11)
+	w.setFont(fontCode, "", 11)
 
 	// Light gray background for inline code
-	w.pdf.SetFillColor(245, 245, 245)
-	w.pdf.SetTextColor(0, 0, 0)
+	w.setFillColor(245, 245, 245)
+	w.setTextColor(0, 0, 0)
 
 	// Calculate width of the code text
 	width := w.pdf.GetStringWidth(code) + 4 // Add some padding
@@ -343,11 +512,65 @@ func (w *Writer) WriteInlineCode(code string) {
 	w.pdf.SetXY(x+width, y)
 
 	// Restore to default paragraph font
-	w.pdf.SetFont("Mono-Italic", "", 12)
+	w.setFont(fontBody, "", 12)
+}
+
+// Protection restricts what readers of an encrypted PDF may do
+type Protection struct {
+	// UserPassword must be entered to open the PDF; none if empty
+	UserPassword string
+	// OwnerPassword lifts all restrictions; gofpdf picks a random one if it is empty
+	OwnerPassword string
+	// NoPrint and NoCopy deny printing and copying text; modifying is always denied
+	NoPrint bool
+	NoCopy  bool
+}
+
+// Protect encrypts the PDF with the restrictions of p
+func (w *Writer) Protect(p Protection) {
+	var allowed byte
+	if !p.NoPrint {
+		allowed |= gofpdf.CnProtectPrint
+	}
+	if !p.NoCopy {
+		allowed |= gofpdf.CnProtectCopy
+	}
+	w.pdf.SetProtection(allowed, p.UserPassword, p.OwnerPassword)
+}
+
+// WriteChapterBreak starts a chapter like WritePageBreak, the header and footer of the
+// pages it adds set by the theme's Chapter
+func (w *Writer) WriteChapterBreak(rightHand bool) {
+	w.flushHeadings()
+	w.chapterNext = true
+	w.WritePageBreak(rightHand)
+	w.chapterNext = false
+}
+
+// WritePageBreak starts a new page unless the current one is still empty and in the
+// orientation set by SetLandscape. With rightHand, an extra blank page is inserted if
+// needed so the next page is odd-numbered.
+func (w *Writer) WritePageBreak(rightHand bool) {
+	w.flushHeadings()
+	if w.spanColumns() {
+		defer w.resumeColumns()
+	}
+	_, top, _, _ := w.pdf.GetMargins()
+	pageWidth, pageHeight := w.pdf.GetPageSize()
+	if w.pdf.GetY() > top || (pageWidth > pageHeight) != w.landscape {
+		w.addPage()
+	}
+	if rightHand && w.pdf.PageNo()%2 == 0 {
+		w.addPage()
+	}
 }
 
 // WriteThematicBreak renders a horizontal rule with subtle styling (like Microsoft Word does it)
 func (w *Writer) WriteThematicBreak() {
+	defer w.beginBlock("rule", "", "", false)()
+	w.placeBlock(12)
+	w.beginArtifact()
+	defer w.endArtifact(true)
 	pageWidth, _ := w.pdf.GetPageSize()
 
 	// Add some spacing before the rule
@@ -356,43 +579,112 @@ func (w *Writer) WriteThematicBreak() {
 
 	// Draw a subtle line (like Word's page break indicator)
 	// Use a light gray color
-	w.pdf.SetDrawColor(200, 200, 200)
+	w.setDrawColor(200, 200, 200)
 	w.pdf.SetLineWidth(0.2)
 
 	// Draw line with margins
-	marginLeft := 20.0
-	marginRight := 20.0
+	left, _, right, _ := w.pdf.GetMargins()
 	lineY := y
-	w.pdf.Line(marginLeft, lineY, pageWidth-marginRight, lineY)
+	w.pdf.Line(left, lineY, pageWidth-right, lineY)
 
 	// Add spacing after
 	w.pdf.Ln(6)
 }
 
-func (w *Writer) WriteListItem(text string, marker byte, index int) {
-	if text == "" {
-		return
-	}
-
-	// Use custom font - never default fonts
-	w.pdf.SetFont("Mono-Italic", "", 12)
+// drawSeverityBadge draws a severity badge at the current position, vertically
+// centered in a line of the given height, and moves the cursor past it
+func (w *Writer) drawSeverityBadge(severity string, lineHeight float64) {
+	x, y := w.pdf.GetXY()
+	c := w.theme.color(severity)
+	white := Color{255, 255, 255}
+	label := strings.ToUpper(severity)
+
+	w.setFont(fontHeading, "B", 9)
+	height := 6.0
+	iconSize := 4.0
+	padding := 1.5
+	width := padding + iconSize + padding + w.pdf.GetStringWidth(label) + padding*2
+	top := y + (lineHeight-height)/2
+
+	w.setFillColor(c.R, c.G, c.B)
+	w.pdf.RoundedRect(x, top, width, height, 1.5, "1234", "F")
+	w.drawIcon(iconForKind(severity), x+padding, top+(height-iconSize)/2, iconSize, white, c)
+
+	w.setTextColor(white.R, white.G, white.B)
+	w.pdf.Text(x+padding+iconSize+padding, top+height*0.7, label)
+	w.setTextColor(0, 0, 0)
+
+	w.pdf.SetXY(x+width+3, y)
+}
 
-	// Check if list item fits on current page
-	_, y := w.pdf.GetXY()
-	_, pageHeight := w.pdf.GetPageSize()
-	marginBottom := 20.0
-	remainingSpace := pageHeight - y - marginBottom
+// WriteCallout renders a callout box (e.g. from a `> [!WARNING]` blockquote) with
+// an icon, a title and a background tinted in the theme color of the kind
+func (w *Writer) WriteCallout(kind, text string) {
+	c := w.theme.color(calloutColorKind(kind))
+	title := strings.ToUpper(kind[:1]) + strings.ToLower(kind[1:])
+	defer w.beginBlock("callout", "", "", false)()
+
+	w.setFont(fontBody, "", 11)
+
+	left, _, _, _ := w.pdf.GetMargins()
+	boxWidth := w.contentWidth()
+	padding := 4.0
+	iconSize := 5.0
+	lineHeight := 6.0
+	textX := left + padding + iconSize + 3
+	textWidth := left + boxWidth - padding - textX
+
+	var lines []string
+	if text != "" {
+		lines = w.pdf.SplitText(text, textWidth)
+	}
+	boxHeight := padding*2 + lineHeight*float64(len(lines)+1)
+
+	// Keep the callout on one page
+	w.placeBlock(boxHeight)
+	if column, _, _, _ := w.pdf.GetMargins(); column != left {
+		// The callout moved to another column
+		textX += column - left
+		left = column
+	}
+	y := w.pdf.GetY()
+
+	bg := c.tint(0.9)
+	w.setFillColor(bg.R, bg.G, bg.B)
+	w.pdf.Rect(left, y, boxWidth, boxHeight, "F")
+	w.setFillColor(c.R, c.G, c.B)
+	w.pdf.Rect(left, y, 1.2, boxHeight, "F")
+	w.drawIcon(iconForKind(kind), left+padding, y+padding+(lineHeight-iconSize)/2, iconSize, c, Color{255, 255, 255})
+
+	drawText := func() {
+		w.setFont(fontHeading, "B", 11)
+		w.setTextColor(c.R, c.G, c.B)
+		w.pdf.SetXY(textX, y+padding)
+		w.pdf.CellFormat(textWidth, lineHeight, title, "", 1, "L", false, 0, "")
+
+		w.setFont(fontBody, "", 11)
+		w.setTextColor(0, 0, 0)
+		for _, line := range lines {
+			w.pdf.SetX(textX)
+			w.pdf.CellFormat(textWidth, lineHeight, line, "", 1, "L", false, 0, "")
+		}
+	}
+	// Only a callout taller than a page flows on to the next
+	if _, top, _, _ := w.pdf.GetMargins(); boxHeight <= w.contentBottom()-top {
+		w.keepTogether(drawText)
+	} else {
+		drawText()
+	}
 
-	// Estimate height needed (at least one line: 6mm, but be conservative)
-	estimatedHeight := 10.0
+	w.pdf.SetY(y + boxHeight)
+	w.pdf.Ln(4)
+}
 
-	// If this list item follows a heading, be more aggressive about page breaks
-	thresholdY := pageHeight * 0.70 // 70% down the page
-	if w.lastHeadingLevel > 0 && (y > thresholdY || remainingSpace < estimatedHeight) {
-		w.pdf.AddPage()
-	} else if remainingSpace < estimatedHeight {
-		w.pdf.AddPage()
+func (w *Writer) WriteListItem(spans []Span, marker byte, index int) {
+	if spansEmpty(spans) {
+		return
 	}
+	defer w.beginBlock("list_item", "", "", false)()
 
 	// Determine bullet/number prefix
 	var prefix string
@@ -401,7 +693,7 @@ func (w *Writer) WriteListItem(text string, marker byte, index int) {
 	} else if marker == '.' || marker == ')' {
 		// Ordered list - use number
 		if index > 0 {
-			prefix = fmt.Sprintf("%d. ", index)
+			prefix = w.numbers.Int(index) + ". "
 		} else {
 			prefix = "• " // Fallback if index not provided
 		}
@@ -409,94 +701,35 @@ func (w *Writer) WriteListItem(text string, marker byte, index int) {
 		prefix = "• "
 	}
 
-	// Write bullet and text with proper indentation
-	fullText := prefix + text
-	w.pdf.MultiCell(0, 6, fullText, "", "L", false)
-	w.pdf.Ln(2)
+	// Keep the heading with the first line of the item
+	lineHeight := w.theme.Text.LineHeight
+	w.placeBlock(lineHeight)
 
-	// Reset heading level tracking after writing list content
-	w.lastHeadingLevel = 0
-}
+	// Use custom font - never default fonts
+	w.setFont(fontBody, "", 12)
 
-func (w *Writer) WriteImageBytes(title string, path []byte) {
-	// not implemented — later we can allow inline Base64 images
-	// TODO: implement this
+	// Write bullet and text with proper indentation
+	spans = w.fallbackSpans(spans)
+	if w.laidOutFirst(spans) {
+		w.writeBidiListItem(spans, prefix, lineHeight)
+	} else {
+		w.pdf.Write(lineHeight, prefix)
+		w.writeSpans(spans, lineHeight, 12)
+	}
+	w.pdf.Ln(lineHeight)
+	w.pdf.Ln(w.theme.Text.ListItemSpacing)
 }
 
-func (w *Writer) WriteHighlightedCode(code string, language string) error {
+func (w *Writer) WriteHighlightedCode(code string, language string, opts CodeOptions) error {
 	if code == "" {
 		return nil
 	}
 
 	// Use custom font
-	w.pdf.SetFont("Mono-Italic", "", 11)
-
-	// Check if code block fits on current page
-	_, y := w.pdf.GetXY()
-	_, pageHeight := w.pdf.GetPageSize()
-	marginBottom := 20.0
-	remainingSpace := pageHeight - y - marginBottom
-
-	// Estimate height needed (rough estimate)
-	estimatedHeight := 20.0
-	if remainingSpace < estimatedHeight {
-		w.pdf.AddPage()
-	}
+	w.setFont(fontCode, "", 11)
 
 	// Apply syntax highlighting using chroma
-	return w.renderSyntaxHighlightedCode(code, language)
-}
-
-// renderSyntaxHighlightedCode uses chroma to highlight code and render with colors
-func (w *Writer) renderSyntaxHighlightedCode(code string, language string) error {
-	// Get lexer for the language
-	lexer := lexers.Get(language)
-	if lexer == nil {
-		// Fallback to auto-detection
-		lexer = lexers.Analyse(code)
-	}
-	if lexer == nil {
-		// Ultimate fallback - treat as plain text
-		lexer = lexers.Fallback
-	}
-
-	// Use github-style theme
-	style := styles.Get("github")
-	if style == nil {
-		style = styles.Fallback
-	}
-
-	// Tokenize the code
-	iterator, err := lexer.Tokenise(nil, code)
-	if err != nil {
-		// Fallback to plain text rendering
-		w.pdf.SetTextColor(0, 0, 0)
-		w.pdf.MultiCell(0, 6, code, "", "L", false)
-		return nil
-	}
-
-	// Render each token with appropriate color
-	for {
-		token := iterator()
-		if token == chroma.EOF {
-			break
-		}
-
-		// Get color for token type
-		r, g, b := w.getChromaColor(style, token.Type)
-
-		// Set color
-		w.pdf.SetTextColor(r, g, b)
-
-		// Write token value
-		value := string(token.Value)
-		if value == "\n" {
-			w.pdf.Ln(6)
-		} else {
-			w.pdf.Write(6, value)
-		}
-	}
-
+	w.writeCodeLines(w.highlightedCodeLines(code, language), Color{246, 248, 250}, opts)
 	return nil
 }
 
@@ -538,139 +771,181 @@ func (w *Writer) getChromaColor(style *chroma.Style, tokenType chroma.TokenType)
 	}
 }
 
-// SetMetadata sets the PDF metadata fields
-func (w *Writer) SetMetadata(author, date, project string) {
-	w.author = author
-	w.date = date
-	w.project = project
+// DocumentInfo is the content of the PDF document information dictionary
+type DocumentInfo struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	// Creator is the application the content was created with
+	Creator string
+	// Producer is the application that produced the PDF; gofpdf if empty
+	Producer string
+	// CreationDate is the generation time if zero
+	CreationDate time.Time
+	// Language is the language tag of the document, e.g. "de", in the XMP metadata
+	Language string
+	// Properties are custom fields of the XMP metadata, like the project or version;
+	// names must start with a letter and contain letters, digits, '_', '.' and '-'
+	Properties map[string]string
 }
 
-func (w *Writer) Save(path string) error {
-	// Set PDF metadata before saving
-	if w.author != "" {
-		w.pdf.SetAuthor(w.author, true)
-	}
-	if w.date != "" {
-		w.pdf.SetCreationDate(time.Now())
-		// Note: gofpdf doesn't have a direct SetDate method, but we can use SetTitle to include date info
-	}
-	if w.project != "" {
-		w.pdf.SetTitle(w.project, true)
-		w.pdf.SetSubject(fmt.Sprintf("Project: %s", w.project), true)
-	}
+// SetDocumentInfo sets the PDF document information; empty fields are left out
+func (w *Writer) SetDocumentInfo(info DocumentInfo) {
+	w.info = info
+}
 
-	err := w.pdf.OutputFileAndClose(path)
-	// Clean up temporary font files
-	for _, tempFile := range w.tempFiles {
-		os.Remove(tempFile)
-	}
-	return err
+// Attachment is a file embedded in the PDF, such as the source of the report or its
+// raw data
+type Attachment struct {
+	// Name is the file name shown by PDF readers
+	Name string
+	Data []byte
 }
 
-// getSystemMetadata returns OS-specific system information for the footer
-func getSystemMetadata() string {
-	switch runtime.GOOS {
-	case "darwin":
-		return getMacOSMetadata()
-	case "linux":
-		return getLinuxMetadata()
-	case "windows":
-		return "Microsoft Windows"
-	default:
-		return runtime.GOOS
+// SetAttachments embeds files in the PDF as document attachments
+func (w *Writer) SetAttachments(files []Attachment) {
+	attachments := make([]gofpdf.Attachment, len(files))
+	for i, file := range files {
+		attachments[i] = gofpdf.Attachment{Content: file.Data, Filename: file.Name}
 	}
+	w.pdf.SetAttachments(attachments)
 }
 
-// getMacOSMetadata returns macOS version and Mac model
-func getMacOSMetadata() string {
-	var version, model string
+// SetLanguage formats generated numbers, like page references, table of contents
+// entries and list numbers, in the digit grouping of a language tag such as "de"
+func (w *Writer) SetLanguage(lang string) {
+	w.numbers = NumberFormatFor(lang)
+}
 
-	// Get macOS version using sw_vers
-	if cmd := exec.Command("sw_vers", "-productVersion"); cmd != nil {
-		if output, err := cmd.Output(); err == nil {
-			version = strings.TrimSpace(string(output))
-		}
+// SetProgress registers a function called with the number of pages so far and the
+// title of the current level 1 or 2 heading whenever a page or such a heading starts
+func (w *Writer) SetProgress(progress func(pages int, section string)) {
+	w.progress = progress
+}
+
+// FormatNumber formats a generated number, like a count or a figure number, in the
+// digit grouping of the document language
+func (w *Writer) FormatNumber(n int) string {
+	return w.numbers.Int(n)
+}
+
+// SetTimestamp fixes the generation time printed in the footer and stored in the
+// metadata, e.g. for reproducible output
+func (w *Writer) SetTimestamp(t time.Time) {
+	w.timestamp = t
+}
+
+// SetReproducible makes the same input produce the same bytes on any machine: the
+// footer leaves out the system information and the PDF dictionaries are sorted. The
+// generation time should be fixed with SetTimestamp as well.
+func (w *Writer) SetReproducible(reproducible bool) {
+	w.reproducible = reproducible
+	w.pdf.SetCatalogSort(reproducible)
+}
+
+// now returns the generation time of the document
+func (w *Writer) now() time.Time {
+	if w.timestamp.IsZero() {
+		return time.Now()
 	}
+	return w.timestamp
+}
 
-	// Get Mac model using system_profiler
-	if cmd := exec.Command("system_profiler", "SPHardwareDataType"); cmd != nil {
-		if output, err := cmd.Output(); err == nil {
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "Model Name:") || strings.Contains(line, "Model Identifier:") {
-					parts := strings.Split(line, ":")
-					if len(parts) > 1 {
-						model = strings.TrimSpace(parts[1])
-						// Prefer Model Name over Model Identifier
-						if strings.Contains(line, "Model Name:") {
-							break
-						}
-					}
-				}
-			}
-		}
+func (w *Writer) Save(path string) error {
+	var buf bytes.Buffer
+	if err := w.Output(&buf); err != nil {
+		return err
 	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
 
-	// Fallback if model not found
-	if model == "" {
-		if cmd := exec.Command("sysctl", "-n", "hw.model"); cmd != nil {
-			if output, err := cmd.Output(); err == nil {
-				model = strings.TrimSpace(string(output))
-			}
+// Output writes the PDF to an io.Writer instead of a file
+func (w *Writer) Output(out io.Writer) error {
+	w.flushHeadings()
+	var box signatureBox
+	if w.signature != nil {
+		var err error
+		if box, err = w.placeSignature(); err != nil {
+			return err
 		}
 	}
+	w.applyMetadata()
 
-	if version != "" && model != "" {
-		return fmt.Sprintf("macOS %s %s", version, model)
-	} else if version != "" {
-		return fmt.Sprintf("macOS %s", version)
-	} else if model != "" {
-		return fmt.Sprintf("macOS on %s", model)
+	// The XMP metadata, structure tree, merged pages and page info are added to the finished PDF,
+	// whose content streams page info checksums
+	var buf bytes.Buffer
+	err := w.pdf.Output(&buf)
+	if err != nil {
+		return err
 	}
-	return "macOS"
-}
-
-// getLinuxMetadata returns Linux distribution information
-func getLinuxMetadata() string {
-	// Try to read /etc/os-release first (most common)
-	if data, err := os.ReadFile("/etc/os-release"); err == nil {
-		lines := strings.Split(string(data), "\n")
-		var name, version string
-		for _, line := range lines {
-			if strings.HasPrefix(line, "PRETTY_NAME=") {
-				value := strings.TrimPrefix(line, "PRETTY_NAME=")
-				value = strings.Trim(value, "\"")
-				return value
-			}
-			if strings.HasPrefix(line, "NAME=") {
-				name = strings.TrimPrefix(line, "NAME=")
-				name = strings.Trim(name, "\"")
-			}
-			if strings.HasPrefix(line, "VERSION=") {
-				version = strings.TrimPrefix(line, "VERSION=")
-				version = strings.Trim(version, "\"")
-			}
+	data, err := linkMetadata(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to add XMP metadata: %w", err)
+	}
+	if w.tagged {
+		if data, err = addStructure(data, w.structure, w.tagLang); err != nil {
+			return fmt.Errorf("failed to tag the PDF: %w", err)
 		}
-		if name != "" {
-			if version != "" {
-				return fmt.Sprintf("%s %s", name, version)
-			}
-			return name
+	}
+	if w.mergeBefore != nil || w.mergeAfter != nil {
+		var before int
+		if data, before, err = mergePDFs(data, w.mergeBefore, w.mergeAfter); err != nil {
+			return fmt.Errorf("failed to merge PDFs: %w", err)
+		}
+		box.page += before
+	}
+	if w.pageInfo != nil {
+		if data, err = StampPageInfo(data, w.pageInfo, w.now()); err != nil {
+			return fmt.Errorf("failed to stamp page info: %w", err)
 		}
 	}
+	if w.signature != nil {
+		if data, err = signPDF(data, *w.signature, box, w.now()); err != nil {
+			return fmt.Errorf("failed to sign the PDF: %w", err)
+		}
+	}
+	_, err = out.Write(data)
+	return err
+}
 
-	// Fallback to /etc/issue
-	if data, err := os.ReadFile("/etc/issue"); err == nil {
-		line := strings.TrimSpace(string(data))
-		// Remove escape sequences and newlines
-		line = strings.ReplaceAll(line, "\\n", "")
-		line = strings.ReplaceAll(line, "\\l", "")
-		line = strings.TrimSpace(line)
-		if line != "" {
-			return line
+// applyMetadata sets the PDF metadata, in the document information dictionary and
+// as an XMP packet, before output. The modification date is the
+// generation time, so a fixed timestamp gives reproducible output.
+func (w *Writer) applyMetadata() {
+	info := w.info
+	for _, field := range []struct {
+		value string
+		set   func(string, bool)
+	}{
+		{info.Title, w.pdf.SetTitle},
+		{info.Author, w.pdf.SetAuthor},
+		{info.Subject, w.pdf.SetSubject},
+		{info.Keywords, w.pdf.SetKeywords},
+		{info.Creator, w.pdf.SetCreator},
+		{info.Producer, w.pdf.SetProducer},
+	} {
+		if field.value != "" {
+			field.set(field.value, true)
 		}
 	}
+	created := info.CreationDate
+	if created.IsZero() {
+		created = w.now()
+	}
+	w.pdf.SetCreationDate(created)
+	w.pdf.SetModificationDate(w.now())
+	w.pdf.SetXmpMetadata(xmpPacket(info, created, w.now()))
+}
 
-	// Last resort
-	return "Linux"
+// footerText returns the text of the page footer
+func (w *Writer) footerText() string {
+	date := w.now().Format("02.01.2006")
+	if footer := cmp.Or(w.first.Footer, w.theme.Footer); footer != "" {
+		return strings.ReplaceAll(footer, "{date}", date)
+	}
+	if w.theme.SystemInfo != nil && !w.reproducible {
+		return "Report generated on: " + w.theme.SystemInfo.SystemInfo() + " - " + date
+	}
+	return "Report generated on: " + date
 }
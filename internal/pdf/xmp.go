@@ -0,0 +1,127 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xmpNamespace is the namespace of the custom properties in the XMP metadata
+const xmpNamespace = "https://github.com/dash-soft/goReportCard/xmp/1.0/"
+
+// xmpNameRegex matches the property names that are valid XML element names
+var xmpNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// metadataObjectRegex matches the header of the XMP metadata stream written by gofpdf
+var metadataObjectRegex = regexp.MustCompile(`(?m)^(\d+) 0 obj\n<< /Type /Metadata /Subtype /XML`)
+
+// xmpPacket returns the XMP metadata packet of the document information, with the
+// Dublin Core, XMP basic and PDF schemas document management systems index, and the
+// custom properties in the report namespace
+func xmpPacket(info DocumentInfo, created, modified time.Time) []byte {
+	var b bytes.Buffer
+	element := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "   <%s>%s</%s>\n", name, xmlText(value), name)
+		}
+	}
+	// array writes an ordered (Seq), unordered (Bag) or alternative (Alt) array
+	array := func(name, kind string, values ...string) {
+		if len(values) == 0 || values[0] == "" {
+			return
+		}
+		fmt.Fprintf(&b, "   <%s><rdf:%s>", name, kind)
+		for _, value := range values {
+			if kind == "Alt" {
+				fmt.Fprintf(&b, `<rdf:li xml:lang="x-default">%s</rdf:li>`, xmlText(value))
+			} else {
+				fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", xmlText(value))
+			}
+		}
+		fmt.Fprintf(&b, "</rdf:%s></%s>\n", kind, name)
+	}
+
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\"\n    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n    xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n    xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\"\n")
+	fmt.Fprintf(&b, "    xmlns:report=\"%s\">\n", xmpNamespace)
+
+	element("dc:format", "application/pdf")
+	array("dc:title", "Alt", info.Title)
+	array("dc:creator", "Seq", info.Author)
+	array("dc:description", "Alt", info.Subject)
+	var keywords []string
+	for keyword := range strings.SplitSeq(info.Keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	array("dc:subject", "Bag", keywords...)
+	array("dc:date", "Seq", created.Format(time.RFC3339))
+	array("dc:language", "Bag", info.Language)
+	element("pdf:Keywords", info.Keywords)
+	element("pdf:Producer", info.Producer)
+	element("xmp:CreatorTool", info.Creator)
+	element("xmp:CreateDate", created.Format(time.RFC3339))
+	element("xmp:ModifyDate", modified.Format(time.RFC3339))
+	element("xmp:MetadataDate", modified.Format(time.RFC3339))
+	names := make([]string, 0, len(info.Properties))
+	for name := range info.Properties {
+		if xmpNameRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		element("report:"+name, info.Properties[name])
+	}
+
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// xmlText escapes s as XML character data
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// linkMetadata references the XMP metadata stream gofpdf writes, but doesn't link, from
+// the catalog of a PDF written by Writer. The catalog is gofpdf's last object, so only
+// the offset of the cross-reference table moves and encrypted objects stay untouched.
+func linkMetadata(data []byte) ([]byte, error) {
+	m := metadataObjectRegex.FindSubmatch(data)
+	if m == nil {
+		return data, nil
+	}
+	xref := bytes.LastIndex(data, []byte("\nxref\n"))
+	start := bytes.LastIndex(data, []byte("\nstartxref\n"))
+	if xref < 0 || start < xref {
+		return nil, errors.New("invalid PDF: no cross-reference table")
+	}
+	catalog := bytes.LastIndex(data[:xref], []byte(" 0 obj\n"))
+	end := bytes.LastIndex(data[:xref], []byte(">>"))
+	if catalog < 0 || end < catalog || !bytes.Contains(data[catalog:end], []byte("/Type /Catalog")) {
+		return nil, errors.New("invalid PDF: the catalog isn't the last object")
+	}
+	digits, _, _ := bytes.Cut(data[start+len("\nstartxref\n"):], []byte("\n"))
+	offset, err := strconv.Atoi(string(bytes.TrimSpace(digits)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDF: %w", err)
+	}
+
+	entry := fmt.Appendf(nil, "/Metadata %s 0 R\n", m[1])
+	var out bytes.Buffer
+	out.Write(data[:end])
+	out.Write(entry)
+	out.Write(data[end:start])
+	fmt.Fprintf(&out, "\nstartxref\n%d\n%%%%EOF\n", offset+len(entry))
+	return out.Bytes(), nil
+}
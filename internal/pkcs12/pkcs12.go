@@ -0,0 +1,397 @@
+// Package pkcs12 decodes PKCS #12 files (.p12, .pfx) holding a private key and its
+// certificate chain (RFC 7292), as exported for document signing. Contents encrypted
+// with PBES2 (AES or Triple DES) or the PKCS #12 Triple DES scheme are supported; RC2,
+// used by legacy exports for the certificates, isn't.
+package pkcs12
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+// ErrIncorrectPassword is returned when the password doesn't open the file
+var ErrIncorrectPassword = errors.New("pkcs12: incorrect password")
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBEWithSHA3DES     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHA40BitRC2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidDESEDE3CBC         = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// hashes are the digests of MACs and HMAC pseudorandom functions by algorithm
+var hashes = map[string]func() hash.Hash{
+	"1.3.14.3.2.26":          sha1.New,
+	"2.16.840.1.101.3.4.2.1": sha256.New,
+	"2.16.840.1.101.3.4.2.2": sha512.New384,
+	"2.16.840.1.101.3.4.2.3": sha512.New,
+	"1.2.840.113549.2.7":     sha1.New,
+	"1.2.840.113549.2.9":     sha256.New,
+	"1.2.840.113549.2.10":    sha512.New384,
+	"1.2.840.113549.2.11":    sha512.New,
+}
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	Salt       []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type encryptedData struct {
+	Version int
+	Content encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Algorithm   pkix.AlgorithmIdentifier
+	Data        []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivation pkix.AlgorithmIdentifier
+	Encryption    pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// Decode returns the private key of a PKCS #12 file and its certificate chain, the
+// key's certificate first. The file must hold exactly one private key.
+func Decode(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	var p pfx
+	if err := unmarshal(data, &p); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: not a PKCS #12 file: %w", err)
+	}
+	if !p.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.New("pkcs12: only password integrity is supported, not public-key integrity")
+	}
+	var authSafe []byte
+	if err := unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, err
+	}
+
+	// The MAC tells a wrong password apart from broken contents. Some writers encode an
+	// empty password as no bytes rather than a terminating zero.
+	bmpPassword := bmpString(password)
+	if len(p.MacData.Mac.Digest) > 0 {
+		err := verifyMAC(p.MacData, authSafe, bmpPassword)
+		if err == ErrIncorrectPassword && password == "" {
+			bmpPassword = nil
+			err = verifyMAC(p.MacData, authSafe, bmpPassword)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var contents []contentInfo
+	if err := unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, err
+	}
+	var keys []crypto.Signer
+	var certs []*x509.Certificate
+	for _, content := range contents {
+		var safe []byte
+		switch {
+		case content.ContentType.Equal(oidData):
+			if err := unmarshal(content.Content.Bytes, &safe); err != nil {
+				return nil, nil, err
+			}
+		case content.ContentType.Equal(oidEncryptedData):
+			var encrypted encryptedData
+			if err := unmarshal(content.Content.Bytes, &encrypted); err != nil {
+				return nil, nil, err
+			}
+			var err error
+			if safe, err = decrypt(encrypted.Content.Algorithm, encrypted.Content.Data, password, bmpPassword); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("pkcs12: unsupported content type %s", content.ContentType)
+		}
+
+		var bags []safeBag
+		if err := unmarshal(safe, &bags); err != nil {
+			return nil, nil, err
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidShroudedKeyBag):
+				der := bag.Value.Bytes
+				if bag.ID.Equal(oidShroudedKeyBag) {
+					var info encryptedPrivateKeyInfo
+					if err := unmarshal(der, &info); err != nil {
+						return nil, nil, err
+					}
+					var err error
+					if der, err = decrypt(info.Algorithm, info.Data, password, bmpPassword); err != nil {
+						return nil, nil, err
+					}
+				}
+				key, err := x509.ParsePKCS8PrivateKey(der)
+				if err != nil {
+					return nil, nil, fmt.Errorf("pkcs12: private key: %w", err)
+				}
+				signer, ok := key.(crypto.Signer)
+				if !ok {
+					return nil, nil, fmt.Errorf("pkcs12: unsupported private key %T", key)
+				}
+				keys = append(keys, signer)
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if err := unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, err
+				}
+				if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, fmt.Errorf("pkcs12: certificate: %w", err)
+				}
+				certs = append(certs, cert)
+			}
+		}
+	}
+
+	if len(keys) != 1 {
+		return nil, nil, fmt.Errorf("pkcs12: expected one private key, found %d", len(keys))
+	}
+	type publicKey interface{ Equal(crypto.PublicKey) bool }
+	for i, cert := range certs {
+		if pub, ok := keys[0].Public().(publicKey); ok && pub.Equal(cert.PublicKey) {
+			certs[0], certs[i] = certs[i], certs[0]
+			return keys[0], certs, nil
+		}
+	}
+	return nil, nil, errors.New("pkcs12: no certificate for the private key")
+}
+
+// unmarshal parses DER data that must not be followed by more data
+func unmarshal(data []byte, v any) error {
+	rest, err := asn1.Unmarshal(data, v)
+	if err == nil && len(rest) > 0 {
+		err = errors.New("trailing data")
+	}
+	return err
+}
+
+// bmpString encodes a password as the PKCS #12 key derivation expects: UTF-16 big
+// endian with a terminating zero
+func bmpString(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return append(b, 0, 0)
+}
+
+// verifyMAC checks the integrity of the authenticated safe with the password
+func verifyMAC(mac macData, message, password []byte) error {
+	h, ok := hashes[mac.Mac.Algorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("pkcs12: unsupported MAC algorithm %s", mac.Mac.Algorithm.Algorithm)
+	}
+	m := hmac.New(h, deriveKey(h, mac.Salt, password, mac.Iterations, 3, h().Size()))
+	m.Write(message)
+	if !hmac.Equal(m.Sum(nil), mac.Mac.Digest) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// decrypt decrypts a key or safe with the password, as UTF-8 for PBES2 and as the
+// BMP string for the PKCS #12 scheme
+func decrypt(algorithm pkix.AlgorithmIdentifier, data []byte, password string, bmpPassword []byte) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHA3DES):
+		var params pbeParams
+		if err := unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		key := deriveKey(sha1.New, params.Salt, bmpPassword, params.Iterations, 1, 24)
+		iv = deriveKey(sha1.New, params.Salt, bmpPassword, params.Iterations, 2, 8)
+		var err error
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+	case algorithm.Algorithm.Equal(oidPBES2):
+		var err error
+		if block, iv, err = pbes2Cipher(algorithm, password); err != nil {
+			return nil, err
+		}
+	case algorithm.Algorithm.Equal(oidPBEWithSHA40BitRC2):
+		return nil, errors.New("pkcs12: RC2 encryption isn't supported; export the file with AES, e.g. openssl pkcs12 -export -certpbe AES-256-CBC -keypbe AES-256-CBC")
+	default:
+		return nil, fmt.Errorf("pkcs12: unsupported encryption %s", algorithm.Algorithm)
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 || len(iv) != block.BlockSize() {
+		return nil, errors.New("pkcs12: invalid encrypted data")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	// A wrong password, unless caught by the MAC, shows as broken padding
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > block.BlockSize() || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrIncorrectPassword
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// pbes2Cipher returns the block cipher and IV of PBES2 encryption with a PBKDF2 key
+func pbes2Cipher(algorithm pkix.AlgorithmIdentifier, password string) (cipher.Block, []byte, error) {
+	var params pbes2Params
+	if err := unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+	if !params.KeyDerivation.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("pkcs12: unsupported key derivation %s", params.KeyDerivation.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if err := unmarshal(params.KeyDerivation.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, err
+	}
+	prf := sha1.New
+	if len(kdf.PRF.Algorithm) > 0 {
+		var ok bool
+		if prf, ok = hashes[kdf.PRF.Algorithm.String()]; !ok {
+			return nil, nil, fmt.Errorf("pkcs12: unsupported PBKDF2 function %s", kdf.PRF.Algorithm)
+		}
+	}
+
+	var keyLength int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch scheme := params.Encryption.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength, newCipher = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLength, newCipher = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLength, newCipher = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLength, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, nil, fmt.Errorf("pkcs12: unsupported encryption %s", scheme)
+	}
+	var iv []byte
+	if err := unmarshal(params.Encryption.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, err
+	}
+	key, err := pbkdf2.Key(prf, password, kdf.Salt, kdf.Iterations, keyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := newCipher(key)
+	return block, iv, err
+}
+
+// deriveKey derives n bytes for a purpose id (1 key, 2 IV, 3 MAC key) from the
+// password and salt with the PKCS #12 key derivation function (RFC 7292, B.2)
+func deriveKey(h func() hash.Hash, salt, password []byte, iterations int, id byte, n int) []byte {
+	v := h().BlockSize()
+	// fill concatenates copies of b to a multiple of v bytes
+	fill := func(b []byte) []byte {
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	diversifier := bytes.Repeat([]byte{id}, v)
+	input := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < n {
+		d := h()
+		d.Write(diversifier)
+		d.Write(input)
+		a := d.Sum(nil)
+		for range iterations - 1 {
+			d.Reset()
+			d.Write(a)
+			a = d.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// Each v-byte block of the input is incremented by the output repeated, plus 1
+		b := fill(a)[:v]
+		for j := 0; j < len(input); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(input[j+k]) + int(b[k]) + carry
+				input[j+k], carry = byte(sum), sum>>8
+			}
+		}
+	}
+	return out[:n]
+}
@@ -0,0 +1,76 @@
+// Package sandbox restricts the local files a document may reference to a set of directory trees
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox resolves paths found in documents, such as includes and images,
+// and rejects those outside its root directories
+type Sandbox struct {
+	roots          []string
+	rejectAbsolute bool
+}
+
+// New creates a sandbox allowing files below the given root directories.
+// With rejectAbsolute, absolute paths are refused even inside the roots.
+func New(roots []string, rejectAbsolute bool) (*Sandbox, error) {
+	s := &Sandbox{rejectAbsolute: rejectAbsolute}
+	for _, root := range roots {
+		if root == "" {
+			root = "."
+		}
+		real, err := realPath(root)
+		if err != nil {
+			return nil, fmt.Errorf("asset root %s: %w", root, err)
+		}
+		s.roots = append(s.roots, real)
+	}
+	return s, nil
+}
+
+// Resolve returns the file a path in a document refers to. Relative paths are
+// resolved against dir. Symbolic links are followed before the path is checked,
+// so links can't point out of the roots either.
+func (s *Sandbox) Resolve(path, dir string) (string, error) {
+	if filepath.IsAbs(path) {
+		if s.rejectAbsolute {
+			return "", fmt.Errorf("absolute path %s not allowed", path)
+		}
+	} else {
+		path = filepath.Join(dir, path)
+	}
+
+	real, err := realPath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range s.roots {
+		if rel, err := filepath.Rel(root, real); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return real, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside the allowed directories", path)
+}
+
+// realPath returns the absolute path with symbolic links evaluated. For files that
+// don't exist yet, the links of the existing parent directories are evaluated.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		dir, err := realPath(filepath.Dir(abs))
+		if err != nil {
+			return abs, nil
+		}
+		return filepath.Join(dir, filepath.Base(abs)), nil
+	}
+	return real, err
+}
@@ -0,0 +1,82 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	// root/doc.md, root/img/a.png, root/link-out -> outside, root/link-in -> img,
+	// outside/secret.txt
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "img"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(root, "doc.md"), filepath.Join(root, "img", "a.png"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link-out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "img"), filepath.Join(root, "link-in")); err != nil {
+		t.Fatal(err)
+	}
+	// Resolved paths have their links evaluated, like the roots
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		dir            string
+		rejectAbsolute bool
+		want           string // "" if rejected
+	}{
+		{"relative", "img/a.png", root, false, filepath.Join(realRoot, "img", "a.png")},
+		{"relative from subdirectory", "../doc.md", filepath.Join(root, "img"), false, filepath.Join(realRoot, "doc.md")},
+		{"missing file inside", "img/new.png", root, false, filepath.Join(realRoot, "img", "new.png")},
+		{"root itself", ".", root, false, realRoot},
+		{"dot-dot escape", "../outside/secret.txt", root, false, ""},
+		{"deep dot-dot escape", "img/../../outside/secret.txt", root, false, ""},
+		{"dot-dot escape of missing file", "../outside/missing.txt", root, false, ""},
+		{"symlink escape", "link-out/secret.txt", root, false, ""},
+		{"symlink directory escape", "link-out", root, false, ""},
+		{"symlink inside", "link-in/a.png", root, false, filepath.Join(realRoot, "img", "a.png")},
+		{"absolute inside", filepath.Join(root, "doc.md"), base, false, filepath.Join(realRoot, "doc.md")},
+		{"absolute outside", filepath.Join(outside, "secret.txt"), root, false, ""},
+		{"absolute system file", "/etc/passwd", root, false, ""},
+		{"absolute inside rejected", filepath.Join(root, "doc.md"), root, true, ""},
+		{"relative with absolute rejected", "img/a.png", root, true, filepath.Join(realRoot, "img", "a.png")},
+		{"prefix sibling", "../root-other/x", root, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := New([]string{root}, tt.rejectAbsolute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := box.Resolve(tt.path, tt.dir)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Resolve(%q) = %q, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
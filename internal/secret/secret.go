@@ -0,0 +1,125 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Provider resolves secret names to their values, e.g. from a KMS or secrets manager
+type Provider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+func (f ProviderFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// ErrNotFound is returned when a referenced secret doesn't exist
+var ErrNotFound = errors.New("secret not found")
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"env":  ProviderFunc(fromEnv),
+		"file": ProviderFunc(fromFile),
+		"exec": ProviderFunc(fromCommand),
+	}
+)
+
+// Register makes a provider available under a scheme, so references like
+// `<scheme>:<name>` are resolved by it. Registering an existing scheme replaces it.
+func Register(scheme string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = p
+}
+
+// Resolve returns the value of a secret reference:
+//
+//	env:PDF_PASSWORD         environment variable
+//	file:/run/secrets/pdf    file contents (trailing newline removed)
+//	exec:vault kv get ...    standard output of a command (e.g. a KMS CLI)
+//	<scheme>:<name>          a provider added with Register
+//
+// References without a known scheme are returned unchanged as plaintext values.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	return resolve(ctx, ref, nil)
+}
+
+// ResolveOnly is Resolve limited to the providers of schemes, for references from
+// less trusted sources such as documents: references to other providers are an error.
+func ResolveOnly(ctx context.Context, ref string, schemes ...string) (string, error) {
+	if schemes == nil {
+		schemes = []string{}
+	}
+	return resolve(ctx, ref, schemes)
+}
+
+// resolve resolves a reference with the providers of schemes, or all if nil
+func resolve(ctx context.Context, ref string, schemes []string) (string, error) {
+	scheme, name, found := strings.Cut(ref, ":")
+	if !found {
+		return ref, nil
+	}
+
+	mu.RLock()
+	p, ok := providers[scheme]
+	mu.RUnlock()
+	if !ok {
+		return ref, nil
+	}
+	if schemes != nil && !slices.Contains(schemes, scheme) {
+		return "", fmt.Errorf("%s secrets not allowed here", scheme)
+	}
+
+	value, err := p.Secret(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s secret: %w", scheme, err)
+	}
+	return value, nil
+}
+
+func fromEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+func fromFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func fromCommand(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("empty command")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,65 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("SECRET_TEST_PASSWORD", "from-env")
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	Register("test", ProviderFunc(func(_ context.Context, name string) (string, error) {
+		return "provided-" + name, nil
+	}))
+
+	tests := []struct {
+		name    string
+		ref     string
+		schemes []string // nil for Resolve
+		want    string
+		wantErr error
+	}{
+		{"plaintext", "s3cret", nil, "s3cret", nil},
+		{"unknown scheme", "s3cret:2024", nil, "s3cret:2024", nil},
+		{"env", "env:SECRET_TEST_PASSWORD", nil, "from-env", nil},
+		{"env not set", "env:SECRET_TEST_MISSING", nil, "", ErrNotFound},
+		{"file", "file:" + path, nil, "from-file", nil},
+		{"file missing", "file:" + path + ".missing", nil, "", ErrNotFound},
+		{"exec", "exec:echo from-exec", nil, "from-exec", nil},
+		{"registered", "test:key", nil, "provided-key", nil},
+		{"only env", "env:SECRET_TEST_PASSWORD", []string{"env"}, "from-env", nil},
+		{"only env plaintext", "s3cret", []string{"env"}, "s3cret", nil},
+		{"only env file", "file:" + path, []string{"env"}, "", errAny},
+		{"only env exec", "exec:echo from-exec", []string{"env"}, "", errAny},
+		{"none", "env:SECRET_TEST_PASSWORD", []string{}, "", errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var err error
+			if tt.schemes == nil {
+				got, err = Resolve(context.Background(), tt.ref)
+			} else {
+				got, err = ResolveOnly(context.Background(), tt.ref, tt.schemes...)
+			}
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr == errAny && err == nil, tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// errAny stands for any error in the test table
+var errAny = errors.New("any error")
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"report"
+)
+
+// Config configures the conversion server
+type Config struct {
+	// Options are the conversion options used for every request
+	Options report.Options
+	// Tenants, if set, requires requests to carry the API key of a tenant and renders
+	// them with the tenant's options instead of Options
+	Tenants *Tenants
+	// MaxConcurrent is the number of conversions running at the same time
+	MaxConcurrent int
+	// Timeout bounds the time a request may wait for a slot and render
+	Timeout time.Duration
+	// ImageHosts are the only hosts remote images in documents are downloaded from;
+	// none if empty, so documents can't make the server send requests elsewhere
+	ImageHosts []string
+	// MaxBodySize is the maximum request size in bytes, including uploaded images
+	MaxBodySize int64
+	// ErrorDocuments answers failed and timed out conversions with a one-page
+	// "Rendering failed" PDF and status 200, the error in the X-Render-Error header,
+	// so clients distributing the PDFs don't skip the document
+	ErrorDocuments bool
+}
+
+// Server converts markdown posted to /convert into PDFs
+type Server struct {
+	config Config
+	slots  chan struct{}
+}
+
+// New creates a conversion server
+func New(config Config) *Server {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 4
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 32 << 20
+	}
+	return &Server{
+		config: config,
+		slots:  make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+// Handler returns the HTTP handler of the server:
+//
+//	POST /convert   markdown body, or multipart form with a "markdown" file and images
+//	GET  /healthz   liveness check
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /convert", s.handleConvert)
+	mux.HandleFunc("GET /healthz", func(rw http.ResponseWriter, _ *http.Request) {
+		io.WriteString(rw, "ok\n")
+	})
+	return mux
+}
+
+func (s *Server) handleConvert(rw http.ResponseWriter, r *http.Request) {
+	opts := s.config.Options
+	if s.config.Tenants != nil {
+		tenant, err := s.config.Tenants.tenant(r)
+		if err != nil {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		opts = tenant.Options
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.Timeout)
+	defer cancel()
+
+	r.Body = http.MaxBytesReader(rw, r.Body, s.config.MaxBodySize)
+
+	// Uploaded images are stored next to the markdown so relative paths resolve
+	dir, err := os.MkdirTemp("", "report-serve-*")
+	if err != nil {
+		http.Error(rw, "failed to create work directory", http.StatusInternalServerError)
+		return
+	}
+	// Once the conversion starts, it owns the directory and removes it when it is done
+	started := false
+	defer func() {
+		if !started {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	md, err := readMarkdown(r, dir)
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(rw, err.Error(), status)
+		return
+	}
+
+	// Wait for a free conversion slot
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		http.Error(rw, "server busy, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	type result struct {
+		pdf []byte
+		err error
+	}
+	done := make(chan result, 1)
+	started = true
+	go func() {
+		// The slot is held until rendering finishes, even if the client gave up
+		defer func() { <-s.slots }()
+		defer os.RemoveAll(dir)
+		opts.BaseDir = dir
+		// Documents may only reference the files uploaded with them and images on
+		// allowed hosts
+		opts.AssetRoots = []string{dir}
+		opts.RejectAbsolutePaths = true
+		opts.ImageHosts = append([]string{}, s.config.ImageHosts...)
+		// Downloads and renderers stop once the request times out
+		opts.Context = ctx
+		pdfBytes, err := report.Convert(md, opts)
+		done <- result{pdfBytes, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			s.fail(rw, res.err, md, http.StatusUnprocessableEntity)
+			return
+		}
+		writePDF(rw, res.pdf)
+	case <-ctx.Done():
+		s.fail(rw, errors.New("conversion timed out"), md, http.StatusGatewayTimeout)
+	}
+}
+
+// fail reports a failed conversion with the status, or as an error document if configured
+func (s *Server) fail(rw http.ResponseWriter, err error, md []byte, status int) {
+	if s.config.ErrorDocuments {
+		doc, docErr := report.ErrorDocument(err, "", md)
+		if docErr == nil {
+			// Header values can't span lines
+			summary, _, _ := strings.Cut(err.Error(), "\n")
+			rw.Header().Set("X-Render-Error", summary)
+			writePDF(rw, doc)
+			return
+		}
+	}
+	http.Error(rw, err.Error(), status)
+}
+
+func writePDF(rw http.ResponseWriter, pdf []byte) {
+	rw.Header().Set("Content-Type", "application/pdf")
+	rw.Header().Set("Content-Length", fmt.Sprint(len(pdf)))
+	rw.Write(pdf)
+}
+
+// readMarkdown reads the markdown from the request body, or from the "markdown" part
+// of a multipart form whose other files are saved into dir
+func readMarkdown(r *http.Request, dir string) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		md, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if len(md) == 0 {
+			return nil, errors.New("empty request body")
+		}
+		return md, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid multipart request: %w", err)
+	}
+
+	var md []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart request: %w", err)
+		}
+
+		if part.FormName() == "markdown" {
+			md, err = io.ReadAll(part)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read markdown: %w", err)
+			}
+			continue
+		}
+
+		// Keep only the base name so uploads can't escape the work directory
+		name := filepath.Base(part.FileName())
+		if name == "." || name == string(filepath.Separator) || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if err := saveFile(filepath.Join(dir, name), part); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(md) == 0 {
+		return nil, errors.New(`missing "markdown" form field`)
+	}
+	return md, nil
+}
+
+func saveFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	return nil
+}
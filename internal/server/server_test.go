@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"report"
+)
+
+func TestConvert(t *testing.T) {
+	var requests atomic.Int32
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/slow.png" {
+			// Never answers, so only the timeout ends the conversion
+			<-r.Context().Done()
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer images.Close()
+	imageURL, _ := url.Parse(images.URL)
+
+	tests := []struct {
+		name         string
+		imageHosts   []string
+		markdown     string
+		timeout      time.Duration
+		status       int
+		wantRequests int32
+	}{
+		{"plain document", nil, "# Report\n\nText.\n", time.Minute, http.StatusOK, 0},
+		{"remote image on disallowed host", nil, "![x](" + images.URL + "/secret.png)\n", time.Minute, http.StatusOK, 0},
+		{"remote image on allowed host", []string{imageURL.Hostname()}, "![x](" + images.URL + "/missing.png)\n", time.Minute, http.StatusOK, 1},
+		{"absolute path", nil, "![x](/etc/hostname)\n", time.Minute, http.StatusOK, 0},
+		{"timeout", []string{imageURL.Hostname()}, "![x](" + images.URL + "/slow.png)\n", time.Second, http.StatusGatewayTimeout, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Work directories are created here, so leftovers can be spotted
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			requests.Store(0)
+
+			srv := New(Config{
+				Options:    report.Options{Warn: func(string) {}},
+				Timeout:    tt.timeout,
+				ImageHosts: tt.imageHosts,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(tt.markdown))
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && !strings.HasPrefix(rec.Body.String(), "%PDF-") {
+				t.Errorf("response is not a PDF")
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("image server got %d requests, want %d", got, tt.wantRequests)
+			}
+
+			// The conversion removes its directory once it is done, even after a timeout
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				entries, err := os.ReadDir(tmp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("work directory %s left behind", entries[0].Name())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
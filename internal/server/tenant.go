@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"report"
+)
+
+// Tenant is a brand served by the server, with its own theme, logo, fonts and footer
+type Tenant struct {
+	// Name identifies the tenant in the tenants file
+	Name string
+	// Options are the conversion options of the tenant's documents: the server's
+	// options with the tenant's settings applied
+	Options report.Options
+}
+
+// Tenants maps API keys to the tenants their requests are rendered for
+type Tenants struct {
+	byKey map[string]*Tenant
+}
+
+// tenantConfig is a tenant of the tenants file. Paths are relative to the file.
+type tenantConfig struct {
+	// Keys are the API keys of the tenant's clients
+	Keys            []string          `json:"keys"`
+	Logo            string            `json:"logo"`
+	LogoWidth       float64           `json:"logo_width"`
+	LogoPosition    string            `json:"logo_position"`
+	NoLogo          bool              `json:"no_logo"`
+	ClientLogo      string            `json:"client_logo"`
+	ClientLogoWidth float64           `json:"client_logo_width"`
+	FontsDir        string            `json:"fonts_dir"`
+	BodyFont        string            `json:"body_font"`
+	HeadingFont     string            `json:"heading_font"`
+	CodeFont        string            `json:"code_font"`
+	Footer          string            `json:"footer"`
+	Watermark       string            `json:"watermark"`
+	ColorProfile    string            `json:"color_profile"`
+	Colors          map[string]string `json:"colors"`
+}
+
+// LoadTenants reads a JSON file mapping tenant names to their API keys and settings,
+// like `{"acme": {"keys": ["..."], "logo": "acme/logo.png", "footer": "ACME - {date}"}}`.
+// Each tenant's settings are applied to base once, so requests only look them up.
+func LoadTenants(path string, base report.Options) (*Tenants, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+	var configs map[string]tenantConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid tenants file %s: %w", path, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("invalid tenants file %s: no tenants", path)
+	}
+
+	tenants := &Tenants{byKey: map[string]*Tenant{}}
+	dir := filepath.Dir(path)
+	for name, cfg := range configs {
+		if len(cfg.Keys) == 0 {
+			return nil, fmt.Errorf("tenant %s: no API keys", name)
+		}
+		opts, err := cfg.apply(base, dir)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		tenant := &Tenant{Name: name, Options: opts}
+		for _, key := range cfg.Keys {
+			if key == "" {
+				return nil, fmt.Errorf("tenant %s: empty API key", name)
+			}
+			if other, ok := tenants.byKey[key]; ok {
+				return nil, fmt.Errorf("tenants %s and %s share an API key", other.Name, name)
+			}
+			tenants.byKey[key] = tenant
+		}
+	}
+	return tenants, nil
+}
+
+// apply returns base with the tenant's settings, checking that its files exist
+func (cfg tenantConfig) apply(base report.Options, dir string) (report.Options, error) {
+	opts := base
+	resolve := func(path string) (string, error) {
+		if path == "" {
+			return "", nil
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	var err error
+	if cfg.Logo != "" {
+		if opts.Logo, err = resolve(cfg.Logo); err != nil {
+			return opts, err
+		}
+	}
+	if cfg.ClientLogo != "" {
+		if opts.ClientLogo, err = resolve(cfg.ClientLogo); err != nil {
+			return opts, err
+		}
+	}
+	if cfg.FontsDir != "" {
+		if opts.FontsDir, err = resolve(cfg.FontsDir); err != nil {
+			return opts, err
+		}
+	}
+	if cfg.LogoWidth > 0 {
+		opts.LogoWidth = cfg.LogoWidth
+	}
+	if cfg.LogoPosition != "" {
+		opts.LogoPosition = cfg.LogoPosition
+	}
+	opts.NoLogo = opts.NoLogo || cfg.NoLogo
+	if cfg.ClientLogoWidth > 0 {
+		opts.ClientLogoWidth = cfg.ClientLogoWidth
+	}
+	if cfg.BodyFont != "" {
+		opts.BodyFont = cfg.BodyFont
+	}
+	if cfg.HeadingFont != "" {
+		opts.HeadingFont = cfg.HeadingFont
+	}
+	if cfg.CodeFont != "" {
+		opts.CodeFont = cfg.CodeFont
+	}
+	if cfg.Footer != "" {
+		opts.Footer = cfg.Footer
+	}
+	if cfg.Watermark != "" {
+		opts.Watermark = cfg.Watermark
+	}
+
+	// Tenant colors apply on top of the server's, the inline ones last
+	colors := maps.Clone(base.Colors)
+	if colors == nil {
+		colors = map[string]string{}
+	}
+	if cfg.ColorProfile != "" {
+		profile, err := resolve(cfg.ColorProfile)
+		if err != nil {
+			return opts, err
+		}
+		loaded, err := report.LoadColorProfile(profile)
+		if err != nil {
+			return opts, err
+		}
+		maps.Copy(colors, loaded)
+	}
+	maps.Copy(colors, cfg.Colors)
+	if len(colors) > 0 {
+		opts.Colors = colors
+	}
+	return opts, nil
+}
+
+// tenant returns the tenant of the API key a request carries, in the X-API-Key header
+// or as a bearer token
+func (t *Tenants) tenant(r *http.Request) (*Tenant, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = strings.TrimSpace(token)
+		}
+	}
+	if key == "" {
+		return nil, errors.New("missing API key (X-API-Key header or Authorization: Bearer)")
+	}
+	tenant, ok := t.byKey[key]
+	if !ok {
+		return nil, errors.New("unknown API key")
+	}
+	return tenant, nil
+}
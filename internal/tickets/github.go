@@ -0,0 +1,106 @@
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultGitHubURL = "https://api.github.com"
+	defaultLabel     = "report-finding"
+)
+
+// markerRegex matches the marker identifying the finding of a GitHub issue in its body
+var markerRegex = regexp.MustCompile(`<!-- report-finding: (\S+) -->`)
+
+// github files findings as GitHub issues. Issues carry the label of the configuration
+// and a marker with the finding ID in their body, so later runs update them.
+type github struct {
+	cfg    *Config
+	client *client
+	// issues are the numbers of the issues filed before by finding ID, listed on first use
+	issues map[string]int
+}
+
+type githubIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+}
+
+func (g *github) url(path string) string {
+	base := strings.TrimRight(g.cfg.URL, "/")
+	if base == "" {
+		base = defaultGitHubURL
+	}
+	return base + "/repos/" + g.cfg.Repository + path
+}
+
+func (g *github) label() string {
+	if g.cfg.Label != "" {
+		return g.cfg.Label
+	}
+	return defaultLabel
+}
+
+func (g *github) find(ctx context.Context, t ticket) (string, error) {
+	if g.issues == nil {
+		issues := map[string]int{}
+		for page := 1; ; page++ {
+			var list []githubIssue
+			query := url.Values{"state": {"all"}, "labels": {g.label()}, "per_page": {"100"}, "page": {fmt.Sprint(page)}}
+			if err := g.client.do(ctx, "GET", g.url("/issues?"+query.Encode()), nil, "", &list); err != nil {
+				return "", err
+			}
+			for _, issue := range list {
+				if m := markerRegex.FindStringSubmatch(issue.Body); m != nil {
+					issues[m[1]] = issue.Number
+				}
+			}
+			if len(list) < 100 {
+				break
+			}
+		}
+		g.issues = issues
+	}
+	if number, ok := g.issues[t.finding.ID]; ok {
+		return fmt.Sprint(number), nil
+	}
+	return "", nil
+}
+
+// fields returns the issue fields with the marker and, if labels are set, the tracking label
+func (g *github) fields(t ticket, create bool) map[string]any {
+	fields := make(map[string]any, len(t.fields)+1)
+	for name, value := range t.fields {
+		fields[name] = value
+	}
+	body, _ := fields["body"].(string)
+	fields["body"] = body + fmt.Sprintf("\n\n<!-- report-finding: %s -->", t.finding.ID)
+
+	labels, ok := fields["labels"].([]any)
+	if ok || create {
+		fields["labels"] = append(labels, g.label())
+	}
+	return fields
+}
+
+func (g *github) create(ctx context.Context, t ticket) (string, string, error) {
+	var issue githubIssue
+	if err := g.client.do(ctx, "POST", g.url("/issues"), g.fields(t, true), "", &issue); err != nil {
+		return "", "", err
+	}
+	g.issues[t.finding.ID] = issue.Number
+	return fmt.Sprint(issue.Number), issue.HTMLURL, nil
+}
+
+func (g *github) update(ctx context.Context, key string, t ticket) (string, error) {
+	var issue githubIssue
+	if err := g.client.do(ctx, "PATCH", g.url("/issues/"+key), g.fields(t, false), "", &issue); err != nil {
+		return "", err
+	}
+	return issue.HTMLURL, nil
+}
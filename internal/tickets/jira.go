@@ -0,0 +1,122 @@
+package tickets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// jira files findings as Jira issues. Issues carry a label with the finding ID,
+// so later runs update them, and the pages of the finding as attachment.
+type jira struct {
+	cfg    *Config
+	client *client
+	// attachments are the attachment names of the issues found, by issue key
+	attachments map[string][]string
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Attachment []struct {
+			Filename string `json:"filename"`
+		} `json:"attachment"`
+	} `json:"fields"`
+}
+
+func (j *jira) url(path string) string {
+	return strings.TrimRight(j.cfg.URL, "/") + path
+}
+
+// marker returns the label identifying the issue of a finding
+func (j *jira) marker(t ticket) string {
+	label := j.cfg.Label
+	if label == "" {
+		label = defaultLabel
+	}
+	return label + "-" + t.finding.ID
+}
+
+func (j *jira) find(ctx context.Context, t ticket) (string, error) {
+	jql := fmt.Sprintf("project = %q AND labels = %q", j.cfg.Project, j.marker(t))
+	query := url.Values{"jql": {jql}, "fields": {"attachment"}, "maxResults": {"1"}}
+	var result struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	if err := j.client.do(ctx, "GET", j.url("/rest/api/2/search?"+query.Encode()), nil, "", &result); err != nil {
+		return "", err
+	}
+	if len(result.Issues) == 0 {
+		return "", nil
+	}
+	issue := result.Issues[0]
+	if j.attachments == nil {
+		j.attachments = map[string][]string{}
+	}
+	for _, a := range issue.Fields.Attachment {
+		j.attachments[issue.Key] = append(j.attachments[issue.Key], a.Filename)
+	}
+	return issue.Key, nil
+}
+
+// fields returns the issue fields with the marker label, and the project and type of new issues
+func (j *jira) fields(t ticket, create bool) map[string]any {
+	fields := make(map[string]any, len(t.fields)+3)
+	for name, value := range t.fields {
+		fields[name] = value
+	}
+	labels, _ := fields["labels"].([]any)
+	fields["labels"] = append(labels, j.marker(t))
+	if create {
+		issueType := j.cfg.IssueType
+		if issueType == "" {
+			issueType = "Bug"
+		}
+		fields["project"] = map[string]any{"key": j.cfg.Project}
+		fields["issuetype"] = map[string]any{"name": issueType}
+	}
+	return fields
+}
+
+func (j *jira) create(ctx context.Context, t ticket) (string, string, error) {
+	var issue jiraIssue
+	body := map[string]any{"fields": j.fields(t, true)}
+	if err := j.client.do(ctx, "POST", j.url("/rest/api/2/issue"), body, "", &issue); err != nil {
+		return "", "", err
+	}
+	return issue.Key, j.url("/browse/" + issue.Key), j.attach(ctx, issue.Key, t)
+}
+
+func (j *jira) update(ctx context.Context, key string, t ticket) (string, error) {
+	body := map[string]any{"fields": j.fields(t, false)}
+	if err := j.client.do(ctx, "PUT", j.url("/rest/api/2/issue/"+key), body, "", nil); err != nil {
+		return "", err
+	}
+	return j.url("/browse/" + key), j.attach(ctx, key, t)
+}
+
+// attach uploads the evidence of a ticket, unless the issue already has it
+func (j *jira) attach(ctx context.Context, key string, t ticket) error {
+	if slices.Contains(j.attachments[key], t.evidenceName) {
+		return nil
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", t.evidenceName)
+	if err != nil {
+		return err
+	}
+	part.Write(t.evidence)
+	if err := form.Close(); err != nil {
+		return err
+	}
+	if err := j.client.do(ctx, "POST", j.url("/rest/api/2/issue/"+key+"/attachments"), &body, form.FormDataContentType(), nil); err != nil {
+		return fmt.Errorf("attaching evidence: %w", err)
+	}
+	return nil
+}
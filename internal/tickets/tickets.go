@@ -0,0 +1,373 @@
+// Package tickets files the findings of a rendered report as Jira or GitHub issues,
+// creating new tickets and updating the ones filed by earlier runs
+package tickets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"report/internal/pdf"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Config describes the ticketing system and how findings map to ticket fields.
+//
+//	{
+//	  "system": "jira",
+//	  "url": "https://example.atlassian.net",
+//	  "project": "SEC",
+//	  "user_env": "JIRA_USER",
+//	  "token_env": "JIRA_TOKEN",
+//	  "severities": ["critical", "high"],
+//	  "fields": {
+//	    "summary": "{{.Title}}",
+//	    "priority": {"name": "{{if eq .Severity \"critical\"}}Highest{{else}}High{{end}}"},
+//	    "labels": ["pentest", "severity-{{.Severity}}"]
+//	  }
+//	}
+type Config struct {
+	// System is "github" or "jira"
+	System string `json:"system"`
+	// URL is the API base, e.g. https://example.atlassian.net (default https://api.github.com for GitHub)
+	URL string `json:"url"`
+	// Repository is the GitHub repository as owner/name
+	Repository string `json:"repository"`
+	// Project is the Jira project key and IssueType the type of new issues (default Bug)
+	Project   string `json:"project"`
+	IssueType string `json:"issue_type"`
+	// TokenEnv names the environment variable holding the API token. UserEnv names the one
+	// holding the Jira user; Jira tokens without a user are sent as bearer tokens.
+	TokenEnv string `json:"token_env"`
+	UserEnv  string `json:"user_env"`
+	// Severities limits the findings filed to these severities; all if empty
+	Severities []string `json:"severities"`
+	// Fields maps ticket fields to values. Strings are Go templates over the finding,
+	// and may be nested in lists and objects. They are merged over the default title
+	// and description fields.
+	Fields map[string]any `json:"fields"`
+	// EvidenceDir is where the pages of each finding are saved as PDF for tickets that
+	// can't carry attachments, i.e. GitHub issues (default: next to the report).
+	// Relative paths are resolved against the directory of the configuration.
+	EvidenceDir string `json:"evidence_dir"`
+	// Label marks the tickets filed for findings, so later runs update them (default
+	// "report-finding"). Jira issues carry it suffixed with the finding ID.
+	Label string `json:"label"`
+}
+
+// LoadConfig reads a JSON ticket configuration
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ticket config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid ticket config: %w", err)
+	}
+	switch cfg.System {
+	case "github":
+		if cfg.Repository == "" {
+			return nil, errors.New("invalid ticket config: github needs a repository")
+		}
+	case "jira":
+		if cfg.URL == "" || cfg.Project == "" {
+			return nil, errors.New("invalid ticket config: jira needs a url and a project")
+		}
+	default:
+		return nil, fmt.Errorf("invalid ticket config: unknown system %q (want github or jira)", cfg.System)
+	}
+	if cfg.EvidenceDir != "" && !filepath.IsAbs(cfg.EvidenceDir) {
+		cfg.EvidenceDir = filepath.Join(filepath.Dir(path), cfg.EvidenceDir)
+	}
+	return &cfg, nil
+}
+
+// Finding is a section of the report to file as a ticket
+type Finding struct {
+	// ID is the anchor of the finding, which identifies its ticket across runs
+	ID       string
+	Severity string
+	Title    string
+	// Markdown is the source of the finding below its heading
+	Markdown string
+	// FirstPage and LastPage are the pages of the finding in the report
+	FirstPage, LastPage int
+}
+
+// Result is the outcome of filing one finding
+type Result struct {
+	Finding Finding
+	// Key is the ticket, e.g. SEC-12 or #12, and URL its web page
+	Key string
+	URL string
+	// Created is set for new tickets, as opposed to updated ones
+	Created bool
+	Err     error
+}
+
+// ticketData is what field templates are executed with
+type ticketData struct {
+	Finding
+	// Pages is the page range of the finding, e.g. "page 4" or "pages 4-6"
+	Pages string
+	// Document is the file name of the report and Evidence the one of the extracted pages
+	Document string
+	Evidence string
+}
+
+// ticket is a finding rendered into the fields of a ticket, with its evidence
+type ticket struct {
+	finding      Finding
+	fields       map[string]any
+	evidence     []byte
+	evidenceName string
+}
+
+// system creates and updates tickets in one ticketing system
+type system interface {
+	// find returns the key of the ticket filed for a finding, "" if there is none
+	find(ctx context.Context, t ticket) (string, error)
+	create(ctx context.Context, t ticket) (key, url string, err error)
+	update(ctx context.Context, key string, t ticket) (url string, err error)
+}
+
+// Export files each finding as a ticket, attaching the pages of reportPDF it spans.
+// ReportPath names the report in tickets and locates the evidence of GitHub issues.
+// Requests are sent with httpClient, or a default client if nil; without a timeout
+// of its own they time out after 30 seconds.
+func Export(ctx context.Context, cfg *Config, httpClient *http.Client, findings []Finding, reportPDF []byte, reportPath string) []Result {
+	shared := http.Client{}
+	if httpClient != nil {
+		shared = *httpClient
+	}
+	if shared.Timeout == 0 {
+		shared.Timeout = defaultTimeout
+	}
+
+	var sys system
+	client := &client{http: &shared, token: os.Getenv(cfg.TokenEnv)}
+	if cfg.UserEnv != "" {
+		client.user = os.Getenv(cfg.UserEnv)
+	}
+	if cfg.System == "jira" {
+		// Jira rejects uploads without this header as cross-site requests
+		client.header = http.Header{"X-Atlassian-Token": {"no-check"}}
+		sys = &jira{cfg: cfg, client: client}
+	} else {
+		sys = &github{cfg: cfg, client: client}
+	}
+
+	var results []Result
+	for _, f := range findings {
+		if len(cfg.Severities) > 0 && !slices.Contains(cfg.Severities, f.Severity) {
+			continue
+		}
+		result := Result{Finding: f}
+		t, err := newTicket(cfg, f, reportPDF, reportPath)
+		if err == nil {
+			result.Key, result.URL, result.Created, err = file(ctx, sys, t)
+		}
+		result.Err = err
+		results = append(results, result)
+	}
+	return results
+}
+
+// file creates the ticket of a finding, or updates the one filed before
+func file(ctx context.Context, sys system, t ticket) (key, url string, created bool, err error) {
+	key, err = sys.find(ctx, t)
+	if err != nil {
+		return "", "", false, err
+	}
+	if key == "" {
+		key, url, err = sys.create(ctx, t)
+		return key, url, err == nil, err
+	}
+	url, err = sys.update(ctx, key, t)
+	return key, url, false, err
+}
+
+// newTicket extracts the evidence of a finding and renders its fields
+func newTicket(cfg *Config, f Finding, reportPDF []byte, reportPath string) (ticket, error) {
+	pages := fmt.Sprint(f.FirstPage)
+	data := ticketData{Finding: f, Document: filepath.Base(reportPath), Pages: "page " + pages}
+	if f.LastPage > f.FirstPage {
+		pages += fmt.Sprintf("-%d", f.LastPage)
+		data.Pages = "pages " + pages
+	}
+	name := strings.TrimSuffix(data.Document, filepath.Ext(data.Document))
+	data.Evidence = fmt.Sprintf("%s-%s-pages-%s.pdf", name, f.ID, pages)
+
+	evidence, err := pdf.ExtractPages(reportPDF, f.FirstPage, f.LastPage)
+	if err != nil {
+		return ticket{}, fmt.Errorf("evidence: %w", err)
+	}
+
+	// GitHub issues can't carry attachments, so their evidence is saved for upload elsewhere
+	if cfg.System == "github" {
+		dir := cfg.EvidenceDir
+		if dir == "" {
+			dir = filepath.Dir(reportPath)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return ticket{}, fmt.Errorf("evidence: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, data.Evidence), evidence, 0o644); err != nil {
+			return ticket{}, fmt.Errorf("evidence: %w", err)
+		}
+	}
+
+	fields := defaultFields(cfg.System)
+	for name, value := range cfg.Fields {
+		fields[name] = value
+	}
+	rendered, err := expand(fields, data)
+	if err != nil {
+		return ticket{}, err
+	}
+	return ticket{finding: f, fields: rendered.(map[string]any), evidence: evidence, evidenceName: data.Evidence}, nil
+}
+
+// defaultFields returns the field mapping used where the configuration sets none
+func defaultFields(system string) map[string]any {
+	if system == "jira" {
+		return map[string]any{
+			"summary":     "[{{title .Severity}}] {{.Title}}",
+			"description": "{{.Markdown}}\n\nEvidence: {{.Document}}, {{.Pages}} (attached as {{.Evidence}})",
+		}
+	}
+	return map[string]any{
+		"title": "[{{title .Severity}}] {{.Title}}",
+		"body":  "{{.Markdown}}\n\n---\nEvidence: {{.Document}}, {{.Pages}} ({{.Evidence}})",
+	}
+}
+
+// templateFuncs are the functions available in field templates
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+}
+
+// expand executes the templates in a field value
+func expand(value any, data ticketData) (any, error) {
+	switch v := value.(type) {
+	case string:
+		t, err := template.New("field").Funcs(templateFuncs).Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("field template %q: %w", v, err)
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("field template %q: %w", v, err)
+		}
+		return buf.String(), nil
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			expanded, err := expand(item, data)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = expanded
+		}
+		return list, nil
+	case map[string]any:
+		object := make(map[string]any, len(v))
+		for key, item := range v {
+			expanded, err := expand(item, data)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = expanded
+		}
+		return object, nil
+	default:
+		return v, nil
+	}
+}
+
+// client sends authenticated JSON requests to a ticketing API
+type client struct {
+	http   *http.Client
+	user   string
+	token  string
+	header http.Header // Sent with every request
+}
+
+// do sends a request and decodes the JSON response into out, if given.
+// Body is encoded as JSON unless it is an io.Reader, sent with contentType.
+func (c *client) do(ctx context.Context, method, url string, body any, contentType string, out any) error {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.token)
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// APIs explain rejected fields in the response body
+		if msg := strings.TrimSpace(string(data)); msg != "" && len(msg) < 500 {
+			return fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, msg)
+		}
+		return fmt.Errorf("%s %s: %s", method, req.URL.Path, resp.Status)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s: invalid response: %w", method, req.URL.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestRenderLayout(t *testing.T) {
+	chapters := "# One\n\nText.\n\n<!-- pagebreak -->\n\n# Two\n\nText.\n\n<!-- pagebreak -->\n\n# Three\n\nText.\n"
+	tests := []struct {
+		name string
+		md   string
+		toc  bool
+		// layout is whether the documents need a layout pass of their own, which the
+		// table of contents always does
+		layout bool
+		want   map[string]int // pages of the headings
+	}{
+		{"no table of contents", chapters, false, false, map[string]int{"one": 1, "two": 2, "three": 3}},
+		{"table of contents", chapters, true, false, map[string]int{"one": 2, "two": 3, "three": 4}},
+		{"page reference", "See page {{page-of: #three}}.\n\n" + chapters, false, true, map[string]int{"one": 1, "two": 2, "three": 3}},
+		{"internal link", "[Three](#three)\n\n" + chapters, false, true, map[string]int{"three": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{TOC: tt.toc}
+			p, err := prepare(opts, source{md: []byte(tt.md)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := needsLayout(p.docs); got != tt.layout {
+				t.Errorf("needs layout %v, want %v", got, tt.layout)
+			}
+
+			w, err := render(opts, source{md: []byte(tt.md)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			anchors := w.Anchors()
+			for id, page := range tt.want {
+				if got := anchors[id].Page; got != page {
+					t.Errorf("%s on page %d, want %d (anchors %v)", id, got, page, slices.Sorted(maps.Keys(anchors)))
+				}
+			}
+		})
+	}
+}
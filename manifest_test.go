@@ -0,0 +1,54 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRecordDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		output   string // relative to the manifest's directory
+		want     string
+	}{
+		{"new digest", "intro.md\nbody.md\n", "report.pdf",
+			"intro.md\nbody.md\n# sha256: abc  report.pdf\n"},
+		{"no trailing newline", "intro.md", "report.pdf",
+			"intro.md\n# sha256: abc  report.pdf\n"},
+		{"replaces the earlier digest", "# sha256: old  report.pdf\nintro.md\n", "report.pdf",
+			"# sha256: abc  report.pdf\nintro.md\n"},
+		{"keeps the digests of other outputs", "intro.md\n# sha256: old  other.pdf\n", "out/report.pdf",
+			"intro.md\n# sha256: old  other.pdf\n# sha256: abc  out/report.pdf\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "inputs.txt")
+			if err := os.WriteFile(path, []byte(tt.manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := RecordDigest(path, filepath.Join(dir, tt.output), "abc"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("manifest is %q, want %q", got, tt.want)
+			}
+
+			// The digest isn't taken for an input
+			files, err := LoadManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if slices.ContainsFunc(files, func(f string) bool { return filepath.Ext(f) != ".md" }) {
+				t.Errorf("manifest %q lists %v", got, files)
+			}
+		})
+	}
+}
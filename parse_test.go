@@ -0,0 +1,40 @@
+package report
+
+import (
+	"testing"
+
+	"report/internal/sandbox"
+)
+
+func TestParseNFC(t *testing.T) {
+	box, err := sandbox.New([]string{t.TempDir()}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"ascii", "Plain text\n", "Plain text\n"},
+		{"precomposed", "Citro\u00ebn\n", "Citro\u00ebn\n"},
+		{"combining diaeresis", "Citroe\u0308n\n", "Citro\u00ebn\n"},
+		{"combining ring", "A\u030angstro\u0308m\n", "\u00c5ngstr\u00f6m\n"},
+		{"singleton", "\u212b\n", "\u00c5\n"},
+		{"marks reordered", "a\u0302\u0323\n", "\u1ead\n"},
+		{"hangul jamo", "\u1112\u1161\u11ab\n", "\ud55c\n"},
+		{"mark without base", "\u0308x\n", "\u0308x\n"},
+		{"windows line endings", "e\u0301\r\n", "\u00e9\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parse(source{md: []byte(tt.md)}, Options{}, box)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(doc.src); got != tt.want {
+				t.Errorf("got %+q, want %+q", got, tt.want)
+			}
+		})
+	}
+}
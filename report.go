@@ -0,0 +1,1783 @@
+// Package report converts Markdown documents to PDF reports.
+//
+// It is the library form of the report command:
+//
+//	pdfBytes, err := report.Convert(md, report.Options{Typographer: true})
+package report
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"report/internal/diagram"
+	"report/internal/fetch"
+	"report/internal/latex"
+	"report/internal/markdown"
+	"report/internal/pdf"
+	"report/internal/pkcs12"
+	"report/internal/sandbox"
+	"report/internal/secret"
+
+	"github.com/yuin/goldmark/ast"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Options configures a conversion
+type Options struct {
+	// Typographer replaces straight quotes, dashes and ellipses with their typographic forms
+	Typographer bool
+
+	// FontsDir is a directory of TTF font families named <Family>-<Style>.ttf
+	FontsDir string
+	// BodyFont, HeadingFont and CodeFont select font families from FontsDir.
+	// Empty or unknown families use the embedded Maple Mono.
+	BodyFont    string
+	HeadingFont string
+	CodeFont    string
+	// FallbackFont lists font families from FontsDir, separated by commas, tried in
+	// order for the characters of body text, code and headings their font lacks,
+	// e.g. a CJK font next to a Latin one
+	FallbackFont string
+	// EmojiFont is a font family from FontsDir with monochrome emoji glyphs for the
+	// emoji of body text, code and headings
+	EmojiFont string
+
+	// Logo is an image file replacing our logo in the page header. Documents can set it
+	// with __logo__, or hide the logo with `__logo__: none`; the option takes precedence.
+	Logo string
+	// LogoWidth is the width of the logo in millimeters (default 40), or __logo_width__
+	// in the document
+	LogoWidth float64
+	// NoLogo leaves our logo out of the page header
+	NoLogo bool
+	// LogoPosition places our logo in the page header: "right" (default), "left" or
+	// "center", or __logo_position__ in the document. The client logo takes the
+	// opposite corner.
+	LogoPosition string
+	// Watermark is text stamped diagonally across every page, e.g. "DRAFT" or
+	// "CONFIDENTIAL", or __watermark__ in the document
+	Watermark string
+	// WatermarkImage is an image file stamped in the middle of every page, or
+	// __watermark_image__ in the document (relative to it)
+	WatermarkImage string
+	// WatermarkOpacity is the opacity of the watermark from 0 to 1 (default 0.15),
+	// or __watermark_opacity__ in the document
+	WatermarkOpacity float64
+	// ClientLogo is an image file shown top-left in the page header, opposite our logo.
+	// Documents can set it with __client_logo__; the option takes precedence.
+	ClientLogo string
+	// ClientLogoWidth is the width of the client logo in millimeters (default 40),
+	// or __client_logo_width__ in the document
+	ClientLogoWidth float64
+	// RunningHead shows the title of the current level 1 or 2 section in the page
+	// header, opposite the logo: the first section starting on a page, or else the
+	// one it continues
+	RunningHead bool
+	// CoverPage leaves parts of the header and footer out of the first page, like the
+	// different first page of word processors: "no-header", "no-footer", "plain" for
+	// both, or "same" (default)
+	CoverPage string
+	// ChapterPage does the same for the first page of each chapter, where an input file
+	// after the first starts. ChapterHeader is text shown in its header in place of the
+	// running head, ChapterFooter replaces its footer text; {date} stands for the
+	// generation date in both.
+	ChapterPage   string
+	ChapterHeader string
+	ChapterFooter string
+	// QRCode stamps a QR code linking to the canonical URL of the document, the
+	// __url__ metadata variable, in the bottom-right corner: "cover" of the first
+	// page, "footer" of every page, or "none" (default)
+	QRCode string
+	// PageInfo stamps every page with invisible metadata for archiving systems that
+	// detect page substitution: its page number, a SHA-256 checksum of its content,
+	// the __document_id__ of the document as DocumentID, and PageProperties. It is
+	// implied by PageProperties.
+	PageInfo bool
+	// PageProperties are custom properties stamped on every page with PageInfo, e.g.
+	// a retention class; they override DocumentID
+	PageProperties map[string]string
+	// Accessible writes a tagged PDF for screen readers: headings, paragraphs, lists,
+	// code, tables and images are structure elements in reading order, images carry
+	// their alt text and the document the language of __lang__
+	Accessible bool
+
+	// ShrinkLimit is the smallest scale applied to tables, code blocks and images
+	// slightly too large for the page (default 0.8); 1 disables shrinking.
+	// Scaling is reported through Warn.
+	ShrinkLimit float64
+
+	// Orphans and Widows are the minimum numbers of lines of a paragraph left at the bottom
+	// of a page and carried over to the top of the next (default 2; 1 disables)
+	Orphans int
+	Widows  int
+
+	// LineNumbers prints line numbers next to code blocks. Blocks can
+	// override it in their info string: ```go {linenos=false}
+	LineNumbers bool
+	// DecodeCodeEntities decodes HTML entities such as &lt; in code blocks, left by
+	// content exported from HTML, with a warning per block changed. Blocks can
+	// override it in their info string: ```xml {decode-entities=false}
+	DecodeCodeEntities bool
+	// CodeWrapMarker marks the continuation of long code lines wrapped at the right margin
+	CodeWrapMarker bool
+
+	// PageSize is the paper size: "A4" (default), "A3", "A5", "Letter", "Legal" or the
+	// width and height in millimeters like "170x240"
+	PageSize string
+	// Orientation is "portrait" (default) or "landscape". Pages following a
+	// `<!-- pagebreak landscape -->` or `<!-- pagebreak portrait -->` directive turn
+	// regardless, e.g. for wide tables.
+	Orientation string
+	// Columns sets the body text in 2 or 3 columns, with headings of levels 1 and 2,
+	// wide tables and images spanning them; 0 falls back to the `__columns__`
+	// variable. `<!-- columns 2 -->` and `<!-- columns 1 -->` directives switch
+	// sections in and out of columns regardless.
+	Columns int
+	// MarginBottom is the distance in millimeters from the bottom edge where content
+	// breaks to a new page (default 20). It never reaches into the 15 mm footer band.
+	MarginBottom float64
+	// MarginLeft, MarginRight and MarginTop are the other page margins in millimeters
+	// (default 20, 20 and 30); MarginTopNoHeader is the top margin of pages without a
+	// header, e.g. with NoLogo or a plain CoverPage (default MarginTop)
+	MarginLeft        float64
+	MarginRight       float64
+	MarginTop         float64
+	MarginTopNoHeader float64
+	// LineHeight is the line height of body text in millimeters (default 6),
+	// ParagraphSpacing and ListItemSpacing the space after paragraphs and list items
+	// (default 4 and 2)
+	LineHeight       float64
+	ParagraphSpacing float64
+	ListItemSpacing  float64
+	// Justify sets paragraphs flush with both margins, breaking words at their soft
+	// hyphens (U+00AD, &shy;)
+	Justify bool
+	// Direction is "rtl" to set paragraphs, list items and headings right to left, for
+	// Arabic or Hebrew documents, or "ltr" (default); documents set it with
+	// __direction__. Right-to-left words in left-to-right text are reordered either way.
+	Direction string
+	// Hyphenation is a TeX hyphenation pattern file, or a directory of hyph-utf8
+	// pattern files of which the one for __lang__ is used, for breaking words in
+	// justified paragraphs. It implies Justify; patterns that can't be loaded are
+	// skipped with a warning.
+	Hyphenation string
+
+	// Footer replaces the footer text, with {date} standing for the generation date,
+	// overriding `__footer__`
+	Footer string
+	// FooterSystemInfo names the operating system and machine in the footer, except
+	// with Reproducible
+	FooterSystemInfo bool
+	// SystemInfo selects the system information FooterSystemInfo names: "host"
+	// (default) for the operating system and machine, "ci" for the pipeline and runner
+	// of the CI service, falling back to the host outside CI, or "static" for
+	// SystemInfoText. "ci" and "static" imply FooterSystemInfo.
+	SystemInfo     string
+	SystemInfoText string
+	// SystemInfoProvider, if set, supplies the system information instead of
+	// SystemInfo and implies FooterSystemInfo
+	SystemInfoProvider SystemInfoProvider
+
+	// HeadingTracking is the space added between the characters of headings in
+	// thousandths of an em, e.g. 50; negative values tighten them
+	HeadingTracking float64
+	// HeadingSmallCaps sets the lowercase letters of headings as smaller capitals
+	HeadingSmallCaps bool
+
+	// Grayscale converts all text, backgrounds, syntax highlighting and images to gray
+	// for cheap printing
+	Grayscale bool
+	// Colors overrides theme colors by name with hex colors like "#0055a4", e.g. to
+	// match corporate brand colors; see LoadColorProfile for the names
+	Colors map[string]string
+
+	// TOC inserts a table of contents at the start of the document,
+	// unless a `[TOC]` paragraph places it elsewhere
+	TOC bool
+	// TOCDepth is the deepest heading level listed in the table of contents (default 3)
+	TOCDepth int
+	// TOCTitle is the title of the table of contents (default "Contents")
+	TOCTitle string
+	// ListOfFigures and ListOfTables insert a "List of Figures" and a "List of Tables"
+	// with page numbers after the table of contents, unless `[LOF]` and `[LOT]`
+	// paragraphs place them elsewhere. Either numbers figures and tables as with
+	// NumberFigures.
+	ListOfFigures bool
+	ListOfTables  bool
+	// NumberHeadings prefixes headings with section numbers (1, 1.1, ...), also in the
+	// table of contents and bookmarks. Headings marked `{-}` or `{.unnumbered}` are
+	// skipped, and from a top-level heading marked `{.appendix}` or following an
+	// `<!-- appendix -->` line on, top-level headings are lettered (Appendix A, A.1,
+	// ...). A single level-1 heading opening the document is its title and stays
+	// unnumbered. Links to a numbered heading without text or with just "Section",
+	// like `[Section](#scope)`, show its number.
+	NumberHeadings bool
+	// NumberHeadingsFrom is the heading level numbering starts at, e.g. 2 to leave
+	// level-1 headings unnumbered (default: below a document title, else 1)
+	NumberHeadingsFrom int
+	// NumberSeparator joins the levels of section numbers, e.g. "-" for 1-1 (default ".")
+	NumberSeparator string
+	// ShiftHeadings moves all headings down by this many levels, or up if negative,
+	// e.g. 1 to nest a document authored with H1 sections under a title; levels
+	// stop at H1 and H6
+	ShiftHeadings int
+	// NumberFigures numbers block images and diagrams ("Figure 1: <alt text>") and
+	// tables ("Table 1: <caption>") in their captions. Links like `[Figure](#id)` to
+	// a numbered figure or table show its number.
+	NumberFigures bool
+
+	// FileBreak separates the files of a multi-file conversion: "page" (default)
+	// starts each file on a new page, "odd" on a right-hand page and "none"
+	// continues on the same page
+	FileBreak string
+	// HeadingBreaks sets the page break before the headings of a level, e.g.
+	// {1: "page", 2: "odd"}: "page" starts each on a new page, "odd" on the next
+	// right-hand page with a blank page inserted for duplex printing, and "auto"
+	// (default) only keeps it together with the following block. Headings directly
+	// above a breaking heading move along with it.
+	HeadingBreaks map[int]string
+
+	// BaseDir is the directory relative image and include paths are resolved against.
+	// ConvertFile defaults it to the directory of the input file.
+	BaseDir string
+
+	// UnsupportedHTML is "warn" to report raw HTML tags outside the supported subset
+	// (<br>, <b>, <i>, <u>, <sub>, <sup>, <img>, <table> and their common aliases)
+	// through Warn; "ignore" (default) drops them silently. Their content is kept.
+	UnsupportedHTML string
+
+	// AssetRoots are the directory trees documents may include files and load images from.
+	// By default these are the directories of the input files (BaseDir for Convert).
+	// Paths escaping them, e.g. with ../ or symbolic links, are rejected.
+	AssetRoots []string
+	// RejectAbsolutePaths refuses absolute include and image paths in documents even
+	// within AssetRoots, e.g. for documents from untrusted sources
+	RejectAbsolutePaths bool
+
+	// Offline skips downloading remote images; they are shown as a placeholder box with their URL
+	Offline bool
+	// ImageHosts, if not nil, are the only hosts remote images are downloaded from, also
+	// after redirects, e.g. for documents from untrusted sources; images on other hosts
+	// are shown as a placeholder box and reported through Warn. An empty list allows none.
+	ImageHosts []string
+	// Strict fails the conversion for images that can't be loaded, which are
+	// otherwise shown as a placeholder box with their path and reported through Warn.
+	// Remote images left out by Offline don't count.
+	Strict bool
+	// FetchConcurrency is the maximum number of remote images downloaded in parallel (default 4)
+	FetchConcurrency int
+	// FetchRetries is the number of retries of a failed download, with exponential backoff
+	FetchRetries int
+	// Context cancels the conversion, e.g. when a request times out: downloads,
+	// diagram and formula renderers stop, and the conversion fails before its next
+	// render pass. context.Background() if nil.
+	Context context.Context
+	// HTTPClient sends all outbound requests: remote image downloads and diagram
+	// servers. Use NewHTTPClient for proxies, custom CAs and TLS settings, and share
+	// the client between conversions to reuse its connections. http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	// Mermaid selects how ```mermaid blocks are rendered: "mmdc" (default) runs the
+	// Mermaid CLI, "kroki" sends them to a Kroki server and "none" shows their source.
+	// Diagrams that fail to render are reported through Warn and shown as code.
+	Mermaid string
+	// KrokiURL is the Kroki server used by the kroki renderer (default https://kroki.io)
+	KrokiURL string
+	// PlantUML selects how ```plantuml blocks are rendered: "jar" (default) runs
+	// PlantUMLJar with java, "server" sends them to PlantUMLServer, "kroki" to the
+	// Kroki server and "none" shows their source
+	PlantUML string
+	// PlantUMLServer is the PlantUML server used by the server renderer
+	// (default http://localhost:8080)
+	PlantUMLServer string
+	// PlantUMLJar is the path of plantuml.jar used by the jar renderer (default plantuml.jar)
+	PlantUMLJar string
+	// DiagramCacheDir, if set, keeps rendered diagrams keyed on the hash of their
+	// source, so unchanged diagrams aren't rendered again by later conversions
+	DiagramCacheDir string
+	// Math selects how `$...$` and `$$...$$` formulas are rendered: "latex" (default)
+	// runs latex and dvipng, "unicode" sets simple formulas like `$x^2 + y_i$` as text
+	// with Unicode characters and italic variables, "none" shows their source.
+	// Formulas that fail to render are reported through Warn and shown as their source.
+	Math string
+
+	// TemplateEnv lists the environment variables templates of Render and documents
+	// expanded with ExpandVars may read with env; with ExpandVars they are variables too
+	TemplateEnv []string
+	// ExpandVars executes each document as a Go text/template before it is parsed, so
+	// one report can be reused across projects and dates: `{{client}}` and `{{.client}}`
+	// stand for the variable client from Vars, the metadata variables (`__client__:`)
+	// or the environment variables listed in TemplateEnv, in that order. Unknown
+	// variables are an error. The functions of Render are available. Setting Vars
+	// implies it.
+	ExpandVars bool
+	// Vars are the values of template variables, overriding metadata variables
+	Vars map[string]string
+	// Data, if set, is the data documents are executed against as templates, e.g. test
+	// results loaded with LoadData, so `{{range .tests}}` can build tables and sections
+	// from it. Dot is Data instead of the variables, which stay available as {{name}}.
+	// Setting it implies ExpandVars.
+	Data any
+
+	// Schema, if set, lists the metadata variables the document must provide
+	Schema *Schema
+
+	// Draft adds review aids to the PDF: CriticMarkup comments `{>> ... <<}` and
+	// marked-up changes, which are otherwise left out and accepted, and the
+	// degradation report
+	Draft bool
+	// Colophon appends a page listing the embedded fonts with their copyright and
+	// license, the images and header images used, and the software that produced the
+	// PDF with its version and license, for contracts requiring asset attribution
+	Colophon bool
+	// Attach are files embedded in the PDF as attachments, like the report's source or
+	// raw data, in addition to those of `__attachments__`
+	Attach []string
+	// Prepend and Append are existing PDFs, like a cover or signed forms and vendor
+	// datasheets, whose pages are merged before and after the document's. The
+	// document's page numbers don't count them. A PDF with merged pages can't be
+	// protected.
+	Prepend []string
+	Append  []string
+	// Attributions are the credits of the document's assets in the colophon, by path
+	// or URL as written in the document or file name, e.g. from LoadAttributions
+	Attributions map[string]string
+	// Finalize produces the deliverable: it drops the review aids of Draft and a "DRAFT"
+	// watermark, and protects the PDF like Protect. Its only annotations are its links,
+	// so there is nothing to flatten: merged pages and signatures, which may bring form
+	// fields, can't be finalized
+	Finalize bool
+
+	// Protect encrypts the PDF so it can't be modified without OwnerPassword. It is
+	// implied by the other protection options and by the document setting
+	// `__protect__: true` or `__password__`.
+	Protect bool
+	// UserPassword must be entered to open the PDF, overriding `__password__`.
+	// The passwords may be secret references like `env:PDF_PASSWORD`, see secret.Resolve;
+	// `__password__` may only reference environment variables, and no secrets at all
+	// with RejectAbsolutePaths.
+	UserPassword string
+	// OwnerPassword unlocks a protected PDF for editing; a random one if empty
+	OwnerPassword string
+	// NoPrint and NoCopy deny printing and copying text from a protected PDF
+	NoPrint bool
+	NoCopy  bool
+
+	// Sign is a PKCS #12 file (.p12, .pfx) whose key digitally signs the PDF, opened
+	// with SignPassword, which may be a secret reference. A signed PDF can't be protected.
+	Sign         string
+	SignPassword string
+	// SignPage is the page of the visible signature box, in the bottom right corner;
+	// 0 puts it after the content on the last page
+	SignPage int
+
+	// Timestamp is the generation time printed in the footer and stored in the PDF
+	// metadata, the current time if zero; a fixed timestamp makes output reproducible
+	Timestamp time.Time
+	// Reproducible makes the same input produce a byte-identical PDF on any machine:
+	// the footer leaves out the operating system, and the generation time is
+	// Timestamp, or else the SOURCE_DATE_EPOCH environment variable, or else
+	// 1970-01-01. A protected PDF also needs an OwnerPassword.
+	Reproducible bool
+
+	// Warn is called for problems that don't stop the PDF from being generated
+	Warn func(message string)
+	// Degraded is called after rendering with the constructs that were rendered
+	// in a reduced form or dropped, if any
+	Degraded func(items []Degradation)
+	// Findings is called after rendering with the sections under severity headings
+	// and the pages they span, if any, e.g. to file them as tickets
+	Findings func(findings []Finding)
+	// Progress, if set, is called as pages and level 1 or 2 headings start, to show
+	// how far the rendering of a long document has got
+	Progress func(Progress)
+	// TableProgress is called while large data tables from ```csv and ```tsv fences are
+	// written, every 10000 rows and when a table is finished, with the table's source
+	// file, or its line for inline data, and the number of rows written
+	TableProgress func(source string, rows int)
+}
+
+// Progress reports how far the rendering of a document has got
+type Progress struct {
+	// Pages is the number of pages started so far
+	Pages int
+	// Section is the title of the current level 1 or 2 heading, "" before the first
+	Section string
+}
+
+// Degradation is a construct the PDF renders in a reduced form or drops
+type Degradation = markdown.Degradation
+
+// SystemInfoProvider supplies the system information named in the footer
+type SystemInfoProvider = pdf.SystemInfoProvider
+
+// SecretProvider resolves secret names to their values, e.g. from a KMS or secrets manager
+type SecretProvider = secret.Provider
+
+// RegisterSecretProvider makes a provider resolve the password references
+// `<scheme>:<name>`, next to the built-in env:, file: and exec:
+func RegisterSecretProvider(scheme string, p SecretProvider) {
+	secret.Register(scheme, p)
+}
+
+// Schema describes required metadata variables and their types, see LoadSchema
+type Schema = markdown.Schema
+
+// LoadSchema reads a metadata schema from a JSON Schema file
+func LoadSchema(path string) (*Schema, error) {
+	return markdown.LoadSchema(path)
+}
+
+// LoadColorProfile reads theme colors from a JSON object mapping color names to hex
+// colors, for Options.Colors:
+//
+//	{"link": "#0055a4", "critical": "#c00000", "info": "#0055a4"}
+//
+// The names are the callout and severity kinds (info, warning, critical, check, high,
+// medium, low), link, and the review colors comment, insertion and deletion.
+func LoadColorProfile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read color profile: %w", err)
+	}
+	var colors map[string]string
+	if err := json.Unmarshal(data, &colors); err != nil {
+		return nil, fmt.Errorf("invalid color profile %s: %w", path, err)
+	}
+	return colors, nil
+}
+
+// Convert renders a Markdown document to PDF and returns the PDF bytes
+func Convert(md []byte, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ConvertTo(&buf, md, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertTo renders a Markdown document to PDF and streams it to out,
+// e.g. an HTTP response or os.Stdout
+func ConvertTo(out io.Writer, md []byte, opts Options) error {
+	w, err := render(opts, source{md: md, baseDir: opts.BaseDir})
+	if err != nil {
+		return err
+	}
+
+	if err := w.Output(out); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// ConvertFile renders the Markdown file at in and saves the PDF to out
+func ConvertFile(in, out string, opts Options) error {
+	return ConvertFiles([]string{in}, out, opts)
+}
+
+// ConvertFiles renders the Markdown files in order as one document and saves the PDF to out.
+// Each file starts on a new page as configured by Options.FileBreak, and relative image
+// paths are resolved against the directory of their file unless Options.BaseDir is set.
+func ConvertFiles(in []string, out string, opts Options) error {
+	sources, err := readSources(in, opts)
+	if err != nil {
+		return err
+	}
+
+	w, err := render(opts, sources...)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Save(out); err != nil {
+		return fmt.Errorf("failed to save PDF: %w", err)
+	}
+	return nil
+}
+
+// ConvertFilesTo renders the Markdown files in order as one document and streams the PDF to out
+func ConvertFilesTo(out io.Writer, in []string, opts Options) error {
+	sources, err := readSources(in, opts)
+	if err != nil {
+		return err
+	}
+
+	w, err := render(opts, sources...)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Output(out); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// Estimate is the expected size of a document
+type Estimate struct {
+	Pages   int
+	Figures int
+	Tables  int
+}
+
+// EstimateFiles runs the layout pass over the Markdown files without writing a PDF and
+// returns the expected page, figure and table counts. Nothing is downloaded: remote images
+// count as their placeholder box and remote diagrams are laid out as code, so documents
+// with large remote images may come out longer.
+func EstimateFiles(in []string, opts Options) (Estimate, error) {
+	sources, err := readSources(in, opts)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	opts.Offline = true
+	p, err := prepare(opts, sources...)
+	if err != nil {
+		return Estimate{}, err
+	}
+	w, err := p.pass(opts, nil)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	if opts.Warn != nil {
+		for _, warning := range w.Warnings() {
+			opts.Warn(warning)
+		}
+	}
+	return Estimate{Pages: w.PageCount(), Figures: w.Figures(), Tables: w.Tables()}, nil
+}
+
+// Plan is the layout of a rendered document: the blocks of content on each page with
+// their type and position, for tools overlaying annotations or comparing pages
+type Plan = pdf.Plan
+
+// PlanFiles renders the Markdown files in order as one document like ConvertFiles and
+// returns its layout instead of the PDF
+func PlanFiles(in []string, opts Options) (Plan, error) {
+	sources, err := readSources(in, opts)
+	if err != nil {
+		return Plan{}, err
+	}
+	w, err := render(opts, sources...)
+	if err != nil {
+		return Plan{}, err
+	}
+	return w.Plan(), nil
+}
+
+// LoadManifest reads a manifest listing input files, one per line.
+// Blank lines and lines starting with # are ignored, and relative paths
+// are resolved against the directory of the manifest.
+func LoadManifest(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		files = append(files, line)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no input files", path)
+	}
+	return files, nil
+}
+
+// RecordDigest records the SHA-256 of a finalized output in the manifest of its
+// inputs, as a `# sha256: <digest>  <output>` line that LoadManifest skips like any
+// comment. The output is relative to the manifest where possible, and an earlier
+// digest of the same output is replaced.
+func RecordDigest(path, output, digest string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if abs, err := filepath.Abs(output); err == nil {
+		if dir, err := filepath.Abs(filepath.Dir(path)); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				output = filepath.ToSlash(rel)
+			} else {
+				output = abs
+			}
+		}
+	}
+
+	entry := fmt.Sprintf("# sha256: %s  %s", digest, output)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	replaced := false
+	for i, line := range lines {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), "# sha256: ")
+		if _, name, found := strings.Cut(rest, "  "); ok && found && name == output {
+			lines[i], replaced = entry, true
+		}
+	}
+	if !replaced {
+		lines = append(lines, entry)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Check validates a Markdown document without rendering it: the metadata is checked
+// against opts.Schema and the document is parsed
+func Check(md []byte, opts Options) error {
+	s := source{md: md, baseDir: opts.BaseDir}
+	box, err := newSandbox(opts, s)
+	if err != nil {
+		return err
+	}
+	_, err = parse(s, opts, box)
+	return err
+}
+
+// Formats lists the output formats documents can be checked against with Preflight
+func Formats() []string {
+	return markdown.Formats()
+}
+
+// Preflight checks a Markdown document like Check and lists the constructs the output
+// format can't fully reproduce and what happens to them, before anything is rendered
+func Preflight(md []byte, format string, opts Options) ([]Degradation, error) {
+	caps, ok := markdown.FormatCapabilities(format)
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(Formats(), ", "))
+	}
+	s := source{md: md, baseDir: opts.BaseDir}
+	box, err := newSandbox(opts, s)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parse(s, opts, box)
+	if err != nil {
+		return nil, err
+	}
+	return markdown.FindDegradations(doc.root, doc.src, caps), nil
+}
+
+// DumpAST writes the parsed syntax tree of a document with source positions, for debugging
+// why a construct renders unexpectedly. Format is "json" or "yaml". Positions refer to
+// the source after includes are expanded and line endings normalized.
+func DumpAST(out io.Writer, md []byte, format string, opts Options) error {
+	s := source{md: md, baseDir: opts.BaseDir}
+	box, err := newSandbox(opts, s)
+	if err != nil {
+		return err
+	}
+	doc, err := parse(s, opts, box)
+	if err != nil {
+		return err
+	}
+
+	dump := markdown.DumpAST(doc.root, doc.src)
+	switch format {
+	case "json":
+		return dump.WriteJSON(out)
+	case "yaml":
+		return dump.WriteYAML(out)
+	default:
+		return fmt.Errorf("unknown AST format %q (want json or yaml)", format)
+	}
+}
+
+// source is one Markdown input of a conversion
+type source struct {
+	// name identifies the input in error messages of multi-file conversions
+	name    string
+	md      []byte
+	baseDir string
+}
+
+// readSources reads the input files of a conversion
+func readSources(paths []string, opts Options) ([]source, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no input files")
+	}
+
+	sources := make([]source, 0, len(paths))
+	for _, path := range paths {
+		md, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read markdown file: %w", err)
+		}
+		baseDir := opts.BaseDir
+		if baseDir == "" {
+			baseDir = filepath.Dir(path)
+		}
+		sources = append(sources, source{name: path, md: md, baseDir: baseDir})
+	}
+	return sources, nil
+}
+
+// document is a normalized and parsed Markdown document
+type document struct {
+	name    string
+	src     []byte
+	meta    markdown.Metadata
+	root    ast.Node
+	baseDir string
+}
+
+// parse normalizes the Markdown source, expands includes, validates its metadata and parses it
+func parse(s source, opts Options, box *sandbox.Sandbox) (*document, error) {
+	mdContent := string(s.md)
+
+	// Normalize line endings to LF to ensure consistent parsing across platforms
+	mdContent = strings.ReplaceAll(mdContent, "\r\n", "\n")
+
+	// Splice in included files, relative to the including file
+	mdContent, err := markdown.ExpandIncludes(mdContent, s.name, s.baseDir, box.Resolve)
+	if err != nil {
+		return nil, err
+	}
+	if opts.expandsVars() {
+		mdContent, err = expandVars(mdContent, s, templateVars(opts, s), opts, box)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mdContent = markdown.ShiftHeadings(mdContent, opts.ShiftHeadings)
+
+	// Normalize to NFC so combining diacritics are measured and rendered as single glyphs
+	mdContent = norm.NFC.String(mdContent)
+	// gofpdf only sets characters of the Basic Multilingual Plane, which leaves out most emoji
+	mdContent = markdown.ReplaceAstral(mdContent)
+
+	// Extract __author__, __date__, __project__, __lang__ etc. from the content
+	meta := markdown.ExtractMetadata(mdContent)
+	if opts.Schema != nil {
+		if err := opts.Schema.Validate(meta, mdContent); err != nil {
+			return nil, fmt.Errorf("metadata validation failed:\n%w", err)
+		}
+	}
+
+	// Convert back to []byte for parsing (using normalized content)
+	src := []byte(mdContent)
+
+	// Parse markdown AST
+	root, err := markdown.ParseMarkdown(src, markdown.Options{
+		Typographer: opts.Typographer,
+		Lang:        meta["lang"],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("markdown parsing error: %w", err)
+	}
+
+	// Type check: ensure root is an AST document
+	if _, ok := root.(*ast.Document); !ok {
+		return nil, errors.New("parsed markdown root node is not a Document")
+	}
+
+	return &document{src: src, meta: meta, root: root, baseDir: s.baseDir}, nil
+}
+
+// parseSources parses the inputs of a conversion and returns their combined metadata.
+// Metadata variables may be set in any file; the first occurrence wins.
+func parseSources(sources []source, opts Options, box *sandbox.Sandbox) ([]*document, markdown.Metadata, error) {
+	if len(sources) == 1 {
+		doc, err := parse(sources[0], opts, box)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*document{doc}, doc.meta, nil
+	}
+
+	// The schema applies to the combined metadata, not to each file
+	schema := opts.Schema
+	opts.Schema = nil
+	// Variables are shared by all files, like metadata
+	if opts.expandsVars() {
+		opts.ExpandVars = true
+		opts.Vars = templateVars(opts, sources...)
+	}
+
+	docs := make([]*document, 0, len(sources))
+	meta := markdown.Metadata{}
+	for _, s := range sources {
+		doc, err := parse(s, opts, box)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+		doc.name = s.name
+		for key, value := range doc.meta {
+			if _, ok := meta[key]; !ok {
+				meta[key] = value
+			}
+		}
+		docs = append(docs, doc)
+	}
+
+	// Lines are reported for variables of the first file, where metadata usually lives
+	if schema != nil {
+		if err := schema.Validate(meta, string(docs[0].src)); err != nil {
+			return nil, nil, fmt.Errorf("metadata validation failed:\n%w", err)
+		}
+	}
+	return docs, meta, nil
+}
+
+// prepared is a conversion ready for its render passes
+type prepared struct {
+	docs      []*document
+	meta      markdown.Metadata
+	theme     pdf.Theme
+	box       *sandbox.Sandbox
+	degraded  []Degradation
+	assets    assets
+	signature *pdf.Signature
+	// protection is the protection of the PDF, nil if it isn't protected
+	protection *pdf.Protection
+	// columns is where the runs of columns of the layout pass end, for balancing them
+	columns pdf.ColumnLayout
+}
+
+// pass renders the documents once, with the heading positions of an earlier pass if any
+func (p *prepared) pass(opts Options, layout map[string]pdf.Anchor) (*pdf.Writer, error) {
+	return renderPass(p.docs, p.meta, p.theme, opts, p.box, p.degraded, p.assets, p.signature, p.protection, layout, p.columns)
+}
+
+// render runs the conversion pipeline and returns the writer holding the finished document
+func render(opts Options, sources ...source) (*pdf.Writer, error) {
+	if opts.Finalize {
+		opts.Draft = false
+	}
+	if opts.Reproducible && opts.Timestamp.IsZero() {
+		timestamp, err := sourceDateEpoch()
+		if err != nil {
+			return nil, err
+		}
+		opts.Timestamp = timestamp
+	}
+	p, err := prepare(opts, sources...)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.canceled(); err != nil {
+		return nil, err
+	}
+
+	// Page numbers in the table of contents and lists, page references, links to later
+	// headings and balanced columns need a layout pass first
+	var layout map[string]pdf.Anchor
+	if opts.TOC || opts.ListOfFigures || opts.ListOfTables || p.theme.Columns > 1 || needsLayout(p.docs) {
+		// Progress is reported for the final pass only
+		layoutOpts := opts
+		layoutOpts.TableProgress = nil
+		layoutOpts.Progress = nil
+		w, err := p.pass(layoutOpts, nil)
+		if err != nil {
+			return nil, err
+		}
+		layout = w.Anchors()
+		p.columns = w.ColumnLayout()
+		if err := opts.canceled(); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := p.pass(opts, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Warn != nil {
+		for _, warning := range w.Warnings() {
+			opts.Warn(warning)
+		}
+	}
+	if opts.Strict {
+		if err := missingImages(w, opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Degraded != nil && len(p.degraded) > 0 {
+		opts.Degraded(p.degraded)
+	}
+	if opts.Findings != nil {
+		if found := findingPages(p.docs, w, p.theme); len(found) > 0 {
+			opts.Findings(found)
+		}
+	}
+
+	return w, nil
+}
+
+// context returns the context of the conversion
+func (opts Options) context() context.Context {
+	if opts.Context != nil {
+		return opts.Context
+	}
+	return context.Background()
+}
+
+// canceled returns an error once the context of the conversion is canceled
+func (opts Options) canceled() error {
+	if err := opts.context().Err(); err != nil {
+		return fmt.Errorf("conversion canceled: %w", err)
+	}
+	return nil
+}
+
+// sourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH environment variable
+// for reproducible builds, or else the Unix epoch
+func sourceDateEpoch() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", epoch)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// missingImages returns an error wrapping the errors of the images of a rendering that
+// couldn't be loaded, except for remote images left out on purpose, or nil if all loaded
+func missingImages(w *pdf.Writer, opts Options) error {
+	var paths []string
+	var errs []error
+	for _, image := range w.MissingImages() {
+		if (opts.Offline && pdf.IsRemote(image.Path)) || slices.Contains(paths, image.Path) {
+			continue
+		}
+		paths = append(paths, image.Path)
+		errs = append(errs, fmt.Errorf("image %s: %w", image.Path, image.Err))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("images could not be loaded:\n%w", errors.Join(errs...))
+}
+
+// prepare validates the options, parses the sources and produces the assets shared by all render passes
+func prepare(opts Options, sources ...source) (*prepared, error) {
+	for level, policy := range opts.HeadingBreaks {
+		if level < 1 || level > 6 {
+			return nil, fmt.Errorf("heading break level %d out of range 1-6", level)
+		}
+		switch policy {
+		case "", "auto", "page", "odd":
+		default:
+			return nil, fmt.Errorf("unknown heading break %q (want page, odd or auto)", policy)
+		}
+	}
+	switch opts.FileBreak {
+	case "", "page", "odd", "none":
+	default:
+		return nil, fmt.Errorf("unknown file break %q (want page, odd or none)", opts.FileBreak)
+	}
+	switch opts.UnsupportedHTML {
+	case "", "ignore", "warn":
+	default:
+		return nil, fmt.Errorf("unknown unsupported HTML mode %q (want ignore or warn)", opts.UnsupportedHTML)
+	}
+	switch opts.Mermaid {
+	case "", "mmdc", "kroki", "none":
+	default:
+		return nil, fmt.Errorf("unknown Mermaid renderer %q (want mmdc, kroki or none)", opts.Mermaid)
+	}
+	switch opts.PlantUML {
+	case "", "jar", "server", "kroki", "none":
+	default:
+		return nil, fmt.Errorf("unknown PlantUML renderer %q (want jar, server, kroki or none)", opts.PlantUML)
+	}
+	switch opts.QRCode {
+	case "", "none", "cover", "footer":
+	default:
+		return nil, fmt.Errorf("unknown QR code placement %q (want cover, footer or none)", opts.QRCode)
+	}
+	for _, style := range []string{opts.CoverPage, opts.ChapterPage} {
+		switch style {
+		case "", "same", "no-header", "no-footer", "plain":
+		default:
+			return nil, fmt.Errorf("unknown first page style %q (want same, no-header, no-footer or plain)", style)
+		}
+	}
+	switch opts.Direction {
+	case "", "ltr", "rtl":
+	default:
+		return nil, fmt.Errorf("unknown direction %q (want ltr or rtl)", opts.Direction)
+	}
+	switch opts.Orientation {
+	case "", "portrait", "landscape":
+	default:
+		return nil, fmt.Errorf("unknown orientation %q (want portrait or landscape)", opts.Orientation)
+	}
+	switch opts.SystemInfo {
+	case "", "host", "ci":
+	case "static":
+		if opts.SystemInfoText == "" {
+			return nil, errors.New("static system information needs a text")
+		}
+	default:
+		return nil, fmt.Errorf("unknown system information %q (want host, ci or static)", opts.SystemInfo)
+	}
+	if opts.MarginBottom < 0 {
+		return nil, fmt.Errorf("invalid bottom margin %g (want millimeters of at least 0)", opts.MarginBottom)
+	}
+	for _, length := range []struct {
+		name  string
+		value float64
+	}{
+		{"left margin", opts.MarginLeft},
+		{"right margin", opts.MarginRight},
+		{"top margin", opts.MarginTop},
+		{"top margin without header", opts.MarginTopNoHeader},
+		{"line height", opts.LineHeight},
+		{"paragraph spacing", opts.ParagraphSpacing},
+		{"list item spacing", opts.ListItemSpacing},
+	} {
+		if length.value < 0 {
+			return nil, fmt.Errorf("invalid %s %g (want millimeters of at least 0)", length.name, length.value)
+		}
+	}
+	switch opts.LogoPosition {
+	case "", "right", "left", "center":
+	default:
+		return nil, fmt.Errorf("unknown logo position %q (want right, left or center)", opts.LogoPosition)
+	}
+	if opts.NumberHeadingsFrom < 0 || opts.NumberHeadingsFrom > 6 {
+		return nil, fmt.Errorf("heading numbering level %d out of range 1-6", opts.NumberHeadingsFrom)
+	}
+	if opts.Columns < 0 || opts.Columns > 3 {
+		return nil, fmt.Errorf("columns %d out of range 1-3", opts.Columns)
+	}
+	if opts.WatermarkOpacity < 0 || opts.WatermarkOpacity > 1 {
+		return nil, fmt.Errorf("watermark opacity %g out of range 0-1", opts.WatermarkOpacity)
+	}
+	switch opts.Math {
+	case "", "latex", "unicode", "none":
+	default:
+		return nil, fmt.Errorf("unknown math renderer %q (want latex, unicode or none)", opts.Math)
+	}
+
+	box, err := newSandbox(opts, sources...)
+	if err != nil {
+		return nil, err
+	}
+	docs, meta, err := parseSources(sources, opts, box)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare theme with user-supplied fonts
+	theme, err := opts.theme()
+	if err != nil {
+		return nil, err
+	}
+	if err := setLogo(&theme, opts, meta, docs[0].baseDir, box); err != nil {
+		return nil, err
+	}
+	theme.ClientLogo = clientLogo(opts, meta, docs[0].baseDir, box)
+	switch direction := cmp.Or(opts.Direction, strings.ToLower(meta["direction"])); direction {
+	case "", "ltr":
+	case "rtl":
+		theme.RTL = true
+	default:
+		return nil, fmt.Errorf("unknown __direction__ %q (want ltr or rtl)", direction)
+	}
+	theme.Columns = opts.Columns
+	if theme.Columns == 0 && meta["columns"] != "" {
+		theme.Columns, err = strconv.Atoi(meta["columns"])
+		if err != nil || theme.Columns < 1 || theme.Columns > 3 {
+			return nil, fmt.Errorf("invalid __columns__ %q (want 1, 2 or 3)", meta["columns"])
+		}
+	}
+	if opts.Hyphenation != "" {
+		theme.Hyphenation, err = pdf.LoadHyphenation(opts.Hyphenation, meta["lang"])
+		if err != nil && opts.Warn != nil {
+			opts.Warn(fmt.Sprintf("hyphenation skipped: %v", err))
+		}
+	}
+	theme.Footer = cmp.Or(opts.Footer, meta["footer"])
+	theme.Watermark = watermark(opts, meta, docs[0].baseDir, box)
+	if opts.Finalize && strings.EqualFold(strings.TrimSpace(theme.Watermark.Text), "draft") {
+		theme.Watermark.Text = ""
+	}
+	signature, err := loadSignature(opts)
+	if err != nil {
+		return nil, err
+	}
+	protection, err := protection(opts, meta)
+	if err != nil {
+		return nil, err
+	}
+	prepend, err := mergedPDFs(opts.Prepend)
+	if err != nil {
+		return nil, err
+	}
+	appended, err := mergedPDFs(opts.Append)
+	if err != nil {
+		return nil, err
+	}
+	assets := assets{
+		images:      remoteImages(docs, opts),
+		diagrams:    renderDiagrams(docs, opts),
+		formulas:    renderFormulas(docs, opts),
+		attachments: attachments(opts, meta, docs[0].baseDir, box),
+		prepend:     prepend,
+		append:      appended,
+	}
+
+	var degraded []Degradation
+	caps, _ := markdown.FormatCapabilities("pdf")
+	for _, doc := range docs {
+		for _, d := range markdown.FindDegradations(doc.root, doc.src, caps) {
+			d.File = doc.name
+			degraded = append(degraded, d)
+		}
+	}
+
+	return &prepared{docs: docs, meta: meta, theme: theme, box: box, degraded: degraded, assets: assets, signature: signature, protection: protection}, nil
+}
+
+// setLogo configures our logo in theme from the options or the document metadata.
+// A replacement that can't be read is skipped with a warning, keeping the built-in logo.
+func setLogo(theme *pdf.Theme, opts Options, meta markdown.Metadata, baseDir string, box *sandbox.Sandbox) error {
+	theme.LogoPosition = opts.LogoPosition
+	if theme.LogoPosition == "" {
+		theme.LogoPosition = meta["logo_position"]
+		switch theme.LogoPosition {
+		case "", "right", "left", "center":
+		default:
+			return fmt.Errorf("unknown __logo_position__ %q (want right, left or center)", theme.LogoPosition)
+		}
+	}
+
+	theme.NoLogo = opts.NoLogo || (opts.Logo == "" && meta["logo"] == "none")
+	if theme.NoLogo {
+		return nil
+	}
+	theme.Logo.Width = opts.LogoWidth
+	if theme.Logo.Width == 0 {
+		theme.Logo.Width, _ = strconv.ParseFloat(meta["logo_width"], 64)
+	}
+	theme.Logo.Data = logoFile(opts.Logo, meta["logo"], "logo", opts, baseDir, box)
+	return nil
+}
+
+// clientLogo loads the client logo named by the options or the document metadata.
+// A logo that can't be read is skipped with a warning.
+func clientLogo(opts Options, meta markdown.Metadata, baseDir string, box *sandbox.Sandbox) pdf.HeaderLogo {
+	data := logoFile(opts.ClientLogo, meta["client_logo"], "client logo", opts, baseDir, box)
+	if data == nil {
+		return pdf.HeaderLogo{}
+	}
+
+	width := opts.ClientLogoWidth
+	if width == 0 {
+		width, _ = strconv.ParseFloat(meta["client_logo_width"], 64)
+	}
+	return pdf.HeaderLogo{Data: data, Width: width}
+}
+
+// watermark configures the watermark from the options or the document metadata.
+// An image that can't be read is skipped with a warning.
+func watermark(opts Options, meta markdown.Metadata, baseDir string, box *sandbox.Sandbox) pdf.Watermark {
+	mark := pdf.Watermark{Text: opts.Watermark, Opacity: opts.WatermarkOpacity}
+	if mark.Text == "" {
+		mark.Text = meta["watermark"]
+	}
+	if mark.Opacity == 0 {
+		mark.Opacity, _ = strconv.ParseFloat(meta["watermark_opacity"], 64)
+	}
+	mark.Image = logoFile(opts.WatermarkImage, meta["watermark_image"], "watermark image", opts, baseDir, box)
+	return mark
+}
+
+// logoFile reads the logo or other header image at path, or else at the path set by
+// the document, which is relative to the document and confined like images. It returns nil without a logo,
+// warning about one that can't be read.
+func logoFile(path, documentPath, what string, opts Options, baseDir string, box *sandbox.Sandbox) []byte {
+	warn := func(err error) {
+		if opts.Warn != nil {
+			opts.Warn(fmt.Sprintf("%s skipped: %v", what, err))
+		}
+	}
+	if path == "" && documentPath != "" {
+		resolved, err := box.Resolve(documentPath, baseDir)
+		if err != nil {
+			warn(err)
+			return nil
+		}
+		path = resolved
+	}
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		warn(err)
+		return nil
+	}
+	return data
+}
+
+// attachments reads the files of Attach and those listed, comma-separated, in
+// `__attachments__`, which are relative to the document and confined like images.
+// Files that can't be read are skipped with a warning.
+func attachments(opts Options, meta markdown.Metadata, baseDir string, box *sandbox.Sandbox) []pdf.Attachment {
+	var files []pdf.Attachment
+	add := func(path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if opts.Warn != nil {
+				opts.Warn(fmt.Sprintf("attachment skipped: %v", err))
+			}
+			return
+		}
+		files = append(files, pdf.Attachment{Name: filepath.Base(path), Data: data})
+	}
+	for _, path := range opts.Attach {
+		add(path)
+	}
+	for path := range strings.SplitSeq(meta["attachments"], ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		resolved, err := box.Resolve(path, baseDir)
+		if err != nil {
+			if opts.Warn != nil {
+				opts.Warn(fmt.Sprintf("attachment skipped: %v", err))
+			}
+			continue
+		}
+		add(resolved)
+	}
+	return files
+}
+
+// mergedPDFs reads the PDFs of Prepend or Append, checking their pages can be merged
+func mergedPDFs(paths []string) ([][]byte, error) {
+	var files [][]byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PDF to merge: %w", err)
+		}
+		if err := pdf.CheckImport(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		files = append(files, data)
+	}
+	return files, nil
+}
+
+// assets are the images produced and files read once for all render passes
+type assets struct {
+	// images are the downloaded remote images by URL
+	images map[string][]byte
+	// diagrams are the rendered diagram fences
+	diagrams map[diagram.Diagram][]byte
+	// formulas are the rendered math formulas
+	formulas map[latex.Formula]latex.Image
+	// attachments are the files attached to the PDF
+	attachments []pdf.Attachment
+	// prepend and append are the PDFs merged before and after the document
+	prepend, append [][]byte
+}
+
+// remoteImages downloads the remote images of the documents once for all render passes.
+// Images that fail to download are reported through Warn and rendered as placeholders.
+func remoteImages(docs []*document, opts Options) map[string][]byte {
+	if opts.Offline {
+		return nil
+	}
+
+	var urls []string
+	for _, doc := range docs {
+		urls = append(urls, markdown.RemoteImages(doc.root)...)
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	results := fetch.All(opts.context(), urls, fetch.Options{
+		Concurrency: opts.FetchConcurrency,
+		Retries:     opts.FetchRetries,
+		Client:      opts.HTTPClient,
+		Hosts:       opts.ImageHosts,
+	})
+	images := make(map[string][]byte, len(results))
+	for _, url := range urls {
+		res := results[url]
+		if res.Err != nil {
+			if opts.Warn != nil {
+				opts.Warn(fmt.Sprintf("image %s: %v", url, res.Err))
+			}
+			delete(results, url) // warn once per URL
+			continue
+		}
+		if res.Data != nil {
+			images[url] = res.Data
+		}
+	}
+	return images
+}
+
+// renderDiagrams renders the diagram fences of the documents once for all render passes.
+// Diagrams that fail to render are reported through Warn and rendered as code.
+func renderDiagrams(docs []*document, opts Options) map[diagram.Diagram][]byte {
+	renderers := map[string]string{"mermaid": opts.Mermaid, "plantuml": opts.PlantUML}
+	var diagrams []diagram.Diagram
+	offline := map[string]bool{}
+	for _, doc := range docs {
+		for _, d := range markdown.Diagrams(doc.root, doc.src) {
+			switch renderers[d.Kind] {
+			case "none":
+				continue
+			case "kroki", "server":
+				if opts.Offline {
+					// Warn once per kind below
+					offline[d.Kind] = true
+					continue
+				}
+			}
+			diagrams = append(diagrams, d)
+		}
+	}
+	if opts.Warn != nil {
+		for _, kind := range []string{"mermaid", "plantuml"} {
+			if offline[kind] {
+				opts.Warn(fmt.Sprintf("%s diagrams shown as code: the %s renderer is not available offline", kind, renderers[kind]))
+			}
+		}
+	}
+	if len(diagrams) == 0 {
+		return nil
+	}
+
+	results := diagram.RenderAll(opts.context(), diagrams, diagram.Options{
+		Renderers:      renderers,
+		KrokiURL:       opts.KrokiURL,
+		PlantUMLServer: opts.PlantUMLServer,
+		PlantUMLJar:    opts.PlantUMLJar,
+		CacheDir:       opts.DiagramCacheDir,
+		Client:         opts.HTTPClient,
+	})
+	images := make(map[diagram.Diagram][]byte, len(results))
+	for _, d := range diagrams {
+		res, ok := results[d]
+		if !ok {
+			continue
+		}
+		if res.Err != nil {
+			if opts.Warn != nil {
+				opts.Warn(fmt.Sprintf("%s diagram shown as code: %v", d.Kind, res.Err))
+			}
+		} else {
+			images[d] = res.PNG
+		}
+		delete(results, d) // warn once per diagram
+	}
+	return images
+}
+
+// renderFormulas renders the math formulas of the documents once for all render passes.
+// Formulas that fail to render are reported through Warn and shown as their source.
+func renderFormulas(docs []*document, opts Options) map[latex.Formula]latex.Image {
+	if opts.Math == "none" || opts.Math == "unicode" {
+		return nil
+	}
+
+	var formulas []latex.Formula
+	for _, doc := range docs {
+		formulas = append(formulas, markdown.Formulas(doc.root)...)
+	}
+	if len(formulas) == 0 {
+		return nil
+	}
+
+	results := latex.RenderAll(opts.context(), formulas, latex.Options{})
+	images := make(map[latex.Formula]latex.Image, len(results))
+	missing := false
+	for _, f := range formulas {
+		res, ok := results[f]
+		if !ok {
+			continue
+		}
+		switch {
+		case errors.Is(res.Err, latex.ErrNotFound):
+			missing = true
+		case res.Err != nil:
+			if opts.Warn != nil {
+				opts.Warn(fmt.Sprintf("formula %s shown as source: %v", f.Source, res.Err))
+			}
+		default:
+			images[f] = res.Image
+		}
+		delete(results, f) // warn once per formula
+	}
+	if missing && opts.Warn != nil {
+		opts.Warn(fmt.Sprintf("formulas shown as source: %v", latex.ErrNotFound))
+	}
+	return images
+}
+
+// newSandbox confines the files documents refer to to opts.AssetRoots,
+// or to the directories of the sources
+func newSandbox(opts Options, sources ...source) (*sandbox.Sandbox, error) {
+	roots := opts.AssetRoots
+	if len(roots) == 0 {
+		for _, s := range sources {
+			roots = append(roots, s.baseDir)
+		}
+	}
+	return sandbox.New(roots, opts.RejectAbsolutePaths)
+}
+
+// needsLayout reports whether the documents refer to positions only known after layout
+func needsLayout(docs []*document) bool {
+	for _, doc := range docs {
+		if markdown.HasListMarker(doc.root, doc.src) || markdown.HasPageRefs(doc.src) || markdown.HasInternalLinks(doc.root) || markdown.HasColumns(doc.src) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPass renders the documents once, using the heading positions and column ends of
+// a previous pass if given
+func renderPass(docs []*document, meta markdown.Metadata, theme pdf.Theme, opts Options, box *sandbox.Sandbox, degraded []Degradation, assets assets, signature *pdf.Signature, protection *pdf.Protection, layout map[string]pdf.Anchor, columns pdf.ColumnLayout) (*pdf.Writer, error) {
+	// Prepare PDF writer
+	w, err := pdf.NewWriter(theme)
+	if err != nil {
+		return nil, err
+	}
+	w.SetLayout(layout)
+	w.SetColumnLayout(columns)
+	w.SetRemoteImages(assets.images)
+	w.SetPathResolver(box.Resolve)
+	w.SetTimestamp(opts.Timestamp)
+	w.SetReproducible(opts.Reproducible)
+	w.SetLanguage(meta["lang"])
+	if opts.Progress != nil {
+		w.SetProgress(func(pages int, section string) {
+			opts.Progress(Progress{Pages: pages, Section: section})
+		})
+	}
+	if protection != nil {
+		if opts.PageInfo || len(opts.PageProperties) > 0 {
+			// Page info is stamped after gofpdf encrypts the document, which would leave it unreadable
+			return nil, errors.New("page info can't be stamped on a protected PDF")
+		}
+		if opts.Accessible {
+			// Likewise the structure tree, which is added after the document is written
+			return nil, errors.New("an accessible PDF can't be protected")
+		}
+		if signature != nil {
+			if opts.Finalize {
+				return nil, errors.New("a finalized PDF can't be signed, as its signature field would stay editable")
+			}
+			return nil, errors.New("a signed PDF can't be protected")
+		}
+		if len(assets.prepend) > 0 || len(assets.append) > 0 {
+			if opts.Finalize {
+				// Their annotations and form fields would need flattening
+				return nil, errors.New("a finalized PDF can't have merged pages, whose annotations aren't flattened")
+			}
+			// Merging renumbers the objects, which gofpdf encrypts by number
+			return nil, errors.New("a PDF with merged pages can't be protected")
+		}
+		if opts.Reproducible && protection.OwnerPassword == "" {
+			return nil, errors.New("a reproducible protected PDF needs an owner password, which is random otherwise")
+		}
+		w.Protect(*protection)
+	}
+
+	// Set PDF metadata
+	w.SetDocumentInfo(documentInfo(meta))
+	w.SetAttachments(assets.attachments)
+	w.SetMerged(assets.prepend, assets.append)
+	if opts.QRCode == "cover" || opts.QRCode == "footer" {
+		if meta["url"] == "" {
+			w.Warnf("QR code skipped: the document sets no __url__")
+		} else if err := w.SetQRCode(meta["url"], opts.QRCode == "footer"); err != nil {
+			w.Warnf("QR code skipped: %v", err)
+		}
+	}
+
+	if opts.Accessible {
+		w.SetTagged(meta["lang"])
+	}
+	if signature != nil {
+		if err := w.SetSignature(*signature); err != nil {
+			return nil, err
+		}
+	}
+	if opts.PageInfo || len(opts.PageProperties) > 0 {
+		properties := map[string]string{}
+		if meta["document_id"] != "" {
+			properties["DocumentID"] = meta["document_id"]
+		}
+		maps.Copy(properties, opts.PageProperties)
+		if err := w.SetPageInfo(properties); err != nil {
+			return nil, err
+		}
+	}
+
+	parts := make([]markdown.Part, len(docs))
+	for i, doc := range docs {
+		parts[i] = markdown.Part{Root: doc.root, Src: doc.src, BaseDir: doc.baseDir}
+	}
+
+	// Render markdown → PDF
+	err = markdown.RenderParts(parts, w, markdown.RenderOptions{
+		TOC:                opts.TOC,
+		TOCDepth:           opts.TOCDepth,
+		TOCTitle:           opts.TOCTitle,
+		ListOfFigures:      opts.ListOfFigures,
+		ListOfTables:       opts.ListOfTables,
+		NumberHeadings:     opts.NumberHeadings,
+		NumberFrom:         opts.NumberHeadingsFrom,
+		NumberSeparator:    opts.NumberSeparator,
+		NumberFigures:      opts.NumberFigures,
+		TableProgress:      opts.TableProgress,
+		Draft:              opts.Draft,
+		PartBreak:          opts.FileBreak,
+		LineNumbers:        opts.LineNumbers,
+		DecodeCodeEntities: opts.DecodeCodeEntities,
+		UnsupportedHTML:    opts.UnsupportedHTML,
+		Diagrams:           assets.diagrams,
+		Formulas:           assets.formulas,
+		UnicodeMath:        opts.Math == "unicode",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRender, err)
+	}
+
+	if opts.Draft && len(degraded) > 0 {
+		writeDegradationAppendix(w, degraded)
+	}
+	if opts.Colophon {
+		w.WriteColophon(colophon(w, theme, meta, opts))
+	}
+	return w, nil
+}
+
+// creator is the application named as creator in the PDF metadata
+const creator = "Report Generator"
+
+// xmpProperties are the metadata variables written to the XMP metadata as custom
+// properties, for document management systems
+var xmpProperties = []string{"project", "version", "classification", "document_id", "url"}
+
+// documentInfo returns the PDF metadata from the document metadata. The creation date
+// is __date__ if it is a date; a period like "Q3 2024" leaves the generation time.
+func documentInfo(meta markdown.Metadata) pdf.DocumentInfo {
+	info := pdf.DocumentInfo{
+		Title:    meta["title"],
+		Author:   meta["author"],
+		Subject:  meta["subject"],
+		Keywords: meta["keywords"],
+		Creator:  creator,
+		Language: meta["lang"],
+	}
+	for _, name := range xmpProperties {
+		if meta[name] != "" {
+			if info.Properties == nil {
+				info.Properties = map[string]string{}
+			}
+			info.Properties[name] = meta[name]
+		}
+	}
+	if info.Title == "" {
+		info.Title = meta["project"]
+	}
+	if info.Subject == "" && meta["project"] != "" {
+		info.Subject = "Project: " + meta["project"]
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, meta["date"]); err == nil {
+			info.CreationDate = t
+			break
+		}
+	}
+	return info
+}
+
+// loadSignature reads the signing key and certificates of Sign, nil if not signing
+func loadSignature(opts Options) (*pdf.Signature, error) {
+	if opts.Sign == "" {
+		return nil, nil
+	}
+	if opts.SignPage < 0 {
+		return nil, fmt.Errorf("invalid signature page %d", opts.SignPage)
+	}
+	data, err := os.ReadFile(opts.Sign)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing certificate: %w", err)
+	}
+	password, err := secret.Resolve(opts.context(), opts.SignPassword)
+	if err != nil {
+		return nil, fmt.Errorf("signing certificate password: %w", err)
+	}
+	key, chain, err := pkcs12.Decode(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.Sign, err)
+	}
+	return &pdf.Signature{Key: key, Chain: chain, Page: opts.SignPage}, nil
+}
+
+// protection returns the protection of the PDF from the options or the document
+// metadata with its password references resolved, nil if it isn't protected
+func protection(opts Options, meta markdown.Metadata) (*pdf.Protection, error) {
+	ctx := opts.context()
+	p := pdf.Protection{NoPrint: opts.NoPrint, NoCopy: opts.NoCopy}
+	var err error
+	if p.UserPassword, err = secret.Resolve(ctx, opts.UserPassword); err != nil {
+		return nil, fmt.Errorf("user password: %w", err)
+	}
+	if p.OwnerPassword, err = secret.Resolve(ctx, opts.OwnerPassword); err != nil {
+		return nil, fmt.Errorf("owner password: %w", err)
+	}
+	if p.UserPassword == "" && meta["password"] != "" {
+		// Documents must not run commands or read files, and untrusted ones
+		// must not read the environment either
+		schemes := []string{"env"}
+		if opts.RejectAbsolutePaths {
+			schemes = []string{}
+		}
+		if p.UserPassword, err = secret.ResolveOnly(ctx, meta["password"], schemes...); err != nil {
+			return nil, fmt.Errorf("__password__: %w", err)
+		}
+	}
+	protect, _ := strconv.ParseBool(meta["protect"])
+	if !protect && !opts.Protect && !opts.Finalize && p == (pdf.Protection{}) {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// systemInfo returns the provider of the system information named in the footer,
+// nil for none
+func (opts Options) systemInfo() SystemInfoProvider {
+	switch {
+	case opts.SystemInfoProvider != nil:
+		return opts.SystemInfoProvider
+	case opts.SystemInfo == "ci":
+		return pdf.CIInfo{}
+	case opts.SystemInfo == "static":
+		return pdf.StaticInfo(opts.SystemInfoText)
+	case opts.FooterSystemInfo:
+		return pdf.HostInfo{}
+	default:
+		return nil
+	}
+}
+
+// firstPage returns the header and footer of a first page in the given style, with
+// the text replacing its header and footer
+func firstPage(style, header, footer string) pdf.FirstPage {
+	return pdf.FirstPage{
+		NoHeader: style == "no-header" || style == "plain",
+		NoFooter: style == "no-footer" || style == "plain",
+		Header:   header,
+		Footer:   footer,
+	}
+}
+
+// theme builds the PDF theme from the options
+func (opts Options) theme() (pdf.Theme, error) {
+	theme := pdf.DefaultTheme()
+	if opts.FontsDir != "" {
+		families, err := pdf.LoadFontDir(opts.FontsDir)
+		if err != nil {
+			return theme, fmt.Errorf("failed to load fonts: %w", err)
+		}
+		theme.FontFamilies = families
+	}
+	if opts.PageSize != "" {
+		size, err := pdf.ParsePageSize(opts.PageSize)
+		if err != nil {
+			return theme, err
+		}
+		theme.PageSize = size
+	}
+	theme.Landscape = opts.Orientation == "landscape"
+	if opts.MarginBottom > 0 {
+		theme.Page.MarginBottom = opts.MarginBottom
+	}
+	theme.Page.MarginLeft = cmp.Or(opts.MarginLeft, theme.Page.MarginLeft)
+	theme.Page.MarginRight = cmp.Or(opts.MarginRight, theme.Page.MarginRight)
+	theme.Page.MarginTop = cmp.Or(opts.MarginTop, theme.Page.MarginTop)
+	theme.Page.MarginTopNoHeader = opts.MarginTopNoHeader
+	if size, err := pdf.ParsePageSize(cmp.Or(opts.PageSize, "A4")); err == nil && min(size.Width, size.Height)-theme.Page.MarginLeft-theme.Page.MarginRight < 50 {
+		return theme, fmt.Errorf("margins %g and %g leave less than 50 mm for the content", theme.Page.MarginLeft, theme.Page.MarginRight)
+	}
+	theme.Text.LineHeight = cmp.Or(opts.LineHeight, theme.Text.LineHeight)
+	theme.Text.ParagraphSpacing = cmp.Or(opts.ParagraphSpacing, theme.Text.ParagraphSpacing)
+	theme.Text.ListItemSpacing = cmp.Or(opts.ListItemSpacing, theme.Text.ListItemSpacing)
+	theme.SystemInfo = opts.systemInfo()
+	theme.Headings = pdf.HeadingTypography{Tracking: opts.HeadingTracking, SmallCaps: opts.HeadingSmallCaps}
+	for level, policy := range opts.HeadingBreaks {
+		theme.HeadingBreaks[level-1] = policy
+	}
+	theme.CodeWrapMarker = opts.CodeWrapMarker
+	theme.RunningHead = opts.RunningHead
+	theme.Justify = opts.Justify || opts.Hyphenation != ""
+	theme.Cover = firstPage(opts.CoverPage, "", "")
+	theme.Chapter = firstPage(opts.ChapterPage, opts.ChapterHeader, opts.ChapterFooter)
+	theme.Grayscale = opts.Grayscale
+	for name, value := range opts.Colors {
+		if _, ok := theme.Colors[name]; !ok {
+			return theme, fmt.Errorf("unknown theme color %q (want one of %s)", name, strings.Join(slices.Sorted(maps.Keys(theme.Colors)), ", "))
+		}
+		c, err := pdf.ParseColor(value)
+		if err != nil {
+			return theme, fmt.Errorf("theme color %s: %w", name, err)
+		}
+		theme.Colors[name] = c
+	}
+	theme.ShrinkLimit = opts.ShrinkLimit
+	theme.Orphans = opts.Orphans
+	theme.Widows = opts.Widows
+	theme.Fonts = pdf.FontMapping{
+		Body:     opts.BodyFont,
+		Heading:  opts.HeadingFont,
+		Code:     opts.CodeFont,
+		Fallback: fontList(opts.FallbackFont),
+		Emoji:    opts.EmojiFont,
+	}
+	return theme, nil
+}
+
+// fontList splits a comma-separated list of font families
+func fontList(s string) []string {
+	var families []string
+	for family := range strings.SplitSeq(s, ",") {
+		if family = strings.TrimSpace(family); family != "" {
+			families = append(families, family)
+		}
+	}
+	return families
+}
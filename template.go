@@ -0,0 +1,322 @@
+package report
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"report/internal/markdown"
+	"report/internal/sandbox"
+)
+
+// Render expands templateMD as a Go text/template with data, then converts the
+// resulting Markdown to PDF. Referencing a missing map key is an error, so
+// incomplete payloads fail instead of rendering "<no value>".
+//
+// Templates can use the functions dateFormat, upper, lower, markdownTable,
+// include, env and sha256, described in the README. Without opts, the default
+// Options are used.
+func Render(templateMD string, data any, opts ...Options) ([]byte, error) {
+	var o Options
+	switch len(opts) {
+	case 0:
+	case 1:
+		o = opts[0]
+	default:
+		return nil, fmt.Errorf("Render takes at most one Options, got %d", len(opts))
+	}
+	md, err := expandTemplate(templateMD, data, o)
+	if err != nil {
+		return nil, err
+	}
+	return Convert(md, o)
+}
+
+// expandTemplate executes a Markdown template against data
+func expandTemplate(templateMD string, data any, opts Options) ([]byte, error) {
+	// Included files are confined like includes and images of the document
+	box, err := newSandbox(opts, source{baseDir: opts.BaseDir})
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("report").Option("missingkey=error").Funcs(templateFuncs(opts, box, opts.BaseDir)).Parse(templateMD)
+	if err != nil {
+		return nil, fmt.Errorf("template parse error: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("template execution error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// templateFuncs returns the functions available to templates, including files relative
+// to baseDir within box. Their names and behavior are part of the API, so templates
+// stay portable between versions.
+func templateFuncs(opts Options, box *sandbox.Sandbox, baseDir string) template.FuncMap {
+	return template.FuncMap{
+		"dateFormat":    dateFormat,
+		"upper":         strings.ToUpper,
+		"lower":         strings.ToLower,
+		"markdownTable": markdownTable,
+		"include": func(path string) (string, error) {
+			resolved, err := box.Resolve(path, baseDir)
+			if err != nil {
+				return "", fmt.Errorf("include %s: %w", path, err)
+			}
+			data, err := os.ReadFile(resolved)
+			if err != nil {
+				return "", fmt.Errorf("include %s: %w", path, err)
+			}
+			return string(data), nil
+		},
+		"env": func(name string) (string, error) {
+			if !slices.Contains(opts.TemplateEnv, name) {
+				return "", fmt.Errorf("environment variable %s is not in the allowlist", name)
+			}
+			return os.Getenv(name), nil
+		},
+		"sha256": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+	}
+}
+
+// builtinTemplateFuncs are the functions of text/template that variables don't shadow
+var builtinTemplateFuncs = []string{"and", "call", "eq", "ge", "gt", "html", "index", "js", "le", "len", "lt", "ne", "not", "or", "print", "printf", "println", "slice", "urlquery"}
+
+// identifierRegex matches the variable names usable as {{name}} in templates
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateVars returns the values of the variables of a document template: Vars, then
+// the metadata variables of the sources (the first occurrence wins), then the
+// environment variables allowed by TemplateEnv
+func templateVars(opts Options, sources ...source) map[string]string {
+	vars := map[string]string{}
+	for _, name := range opts.TemplateEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			vars[name] = value
+		}
+	}
+	for _, s := range slices.Backward(sources) {
+		maps.Copy(vars, markdown.ExtractMetadata(string(s.md)))
+	}
+	maps.Copy(vars, opts.Vars)
+	return vars
+}
+
+// expandsVars reports whether documents are expanded as templates
+func (opts Options) expandsVars() bool {
+	return opts.ExpandVars || len(opts.Vars) > 0 || opts.Data != nil
+}
+
+// expandVars executes a document as a template of its variables, where {{client}} and
+// {{.client}} both stand for the variable client, or of opts.Data if set. A variable
+// named like a function is only available in the second form. Fenced code blocks, code
+// spans and page references are left as they are.
+func expandVars(md string, s source, vars map[string]string, opts Options, box *sandbox.Sandbox) (string, error) {
+	funcs := templateFuncs(opts, box, s.baseDir)
+	for name, value := range vars {
+		if _, taken := funcs[name]; !taken && !slices.Contains(builtinTemplateFuncs, name) && identifierRegex.MatchString(name) {
+			funcs[name] = func() string { return value }
+		}
+	}
+	// Code and page references are set aside, so their braces aren't parsed as actions
+	masked, literals := maskLiterals(md)
+	name := cmp.Or(s.name, "document")
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(masked)
+	if err != nil {
+		return "", fmt.Errorf("template parse error: %w", err)
+	}
+	var data any = vars
+	if opts.Data != nil {
+		data = opts.Data
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("template execution error: %w", err)
+	}
+	return literals.Replace(buf.String()), nil
+}
+
+// maskLiterals replaces the code and page references of a document with placeholders,
+// and returns the replacer putting them back
+func maskLiterals(md string) (string, *strings.Replacer) {
+	var masked strings.Builder
+	var pairs []string
+	last := 0
+	for _, r := range markdown.Literals(md) {
+		literal := md[r[0]:r[1]]
+		if !strings.Contains(literal, "{{") {
+			continue
+		}
+		placeholder := fmt.Sprintf("\x00%d\x00", len(pairs)/2)
+		masked.WriteString(md[last:r[0]])
+		masked.WriteString(placeholder)
+		pairs = append(pairs, placeholder, literal)
+		last = r[1]
+	}
+	masked.WriteString(md[last:])
+	return masked.String(), strings.NewReplacer(pairs...)
+}
+
+// LoadData reads the data of documents expanded as templates. A .csv or .tsv file is a
+// list of rows keyed by the header row, anything else is JSON. JSON numbers keep the
+// digits of the file, so 1000000 isn't printed as 1e+06.
+func LoadData(path string) (any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv":
+		reader := csv.NewReader(f)
+		if strings.EqualFold(filepath.Ext(path), ".tsv") {
+			reader.Comma = '\t'
+		}
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid data file %s: %w", path, err)
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("invalid data file %s: no header row", path)
+		}
+		rows := make([]map[string]string, 0, len(records)-1)
+		for _, record := range records[1:] {
+			row := map[string]string{}
+			for i, name := range records[0] {
+				if i < len(record) {
+					row[name] = record[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	default:
+		decoder := json.NewDecoder(f)
+		decoder.UseNumber()
+		var data any
+		if err := decoder.Decode(&data); err != nil {
+			return nil, fmt.Errorf("invalid data file %s: %w", path, err)
+		}
+		return data, nil
+	}
+}
+
+// dateLayouts are the layouts dateFormat parses string dates with
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// dateFormat formats a time.Time, or a date string in RFC 3339 or YYYY-MM-DD form,
+// with a Go time layout: {{ .Date | dateFormat "2 January 2006" }}
+func dateFormat(layout string, date any) (string, error) {
+	switch d := date.(type) {
+	case time.Time:
+		return d.Format(layout), nil
+	case *time.Time:
+		return d.Format(layout), nil
+	case string:
+		for _, l := range dateLayouts {
+			if t, err := time.Parse(l, d); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+		return "", fmt.Errorf("can't parse date %q", d)
+	default:
+		return "", fmt.Errorf("unsupported date type %T", date)
+	}
+}
+
+// markdownTable renders a slice as a Markdown table. Without columns, the elements
+// are slices of cells and the first one is the header. With columns, the elements
+// are structs or maps and the columns name their fields or keys:
+// {{ markdownTable .Findings "ID" "Title" "Severity" }}
+func markdownTable(rows any, columns ...string) (string, error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("expected a slice, got %T", rows)
+	}
+
+	var table [][]string
+	if len(columns) > 0 {
+		table = append(table, columns)
+	}
+	for i := 0; i < v.Len(); i++ {
+		row := reflect.Indirect(v.Index(i))
+		if row.Kind() == reflect.Interface {
+			row = reflect.Indirect(row.Elem())
+		}
+
+		var cells []string
+		switch {
+		case len(columns) == 0 && (row.Kind() == reflect.Slice || row.Kind() == reflect.Array):
+			for j := 0; j < row.Len(); j++ {
+				cells = append(cells, fmt.Sprint(row.Index(j).Interface()))
+			}
+		case len(columns) > 0 && row.Kind() == reflect.Map && row.Type().Key().Kind() == reflect.String:
+			for _, column := range columns {
+				cell := row.MapIndex(reflect.ValueOf(column).Convert(row.Type().Key()))
+				cells = append(cells, tableCell(cell))
+			}
+		case len(columns) > 0 && row.Kind() == reflect.Struct:
+			for _, column := range columns {
+				field := row.FieldByName(column)
+				if !field.IsValid() {
+					return "", fmt.Errorf("%s has no field %s", row.Type(), column)
+				}
+				cells = append(cells, tableCell(field))
+			}
+		default:
+			return "", fmt.Errorf("unsupported row type %s", row.Type())
+		}
+		table = append(table, cells)
+	}
+	if len(table) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, row := range table {
+		width = max(width, len(row))
+	}
+	for i, row := range table {
+		b.WriteString("|")
+		for j := 0; j < width; j++ {
+			cell := ""
+			if j < len(row) {
+				cell = strings.ReplaceAll(strings.ReplaceAll(row[j], "|", `\|`), "\n", " ")
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("| --- ", width) + "|\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// tableCell formats a field or map value of a markdownTable row; missing values are empty
+func tableCell(v reflect.Value) string {
+	if !v.IsValid() || !v.CanInterface() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
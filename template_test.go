@@ -0,0 +1,57 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"client": "ACME"}
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"variable", "For {{client}} and {{.client}}\n", "For ACME and ACME\n"},
+		{"page reference", "{{client}} is on page {{page-of: #intro}}\n", "ACME is on page {{page-of: #intro}}\n"},
+		{"code span", "Write `{{client}}` to get {{client}}\n", "Write `{{client}}` to get ACME\n"},
+		{"fenced code", "{{client}}\n\n```yaml\nname: {{ .Values.name }}\n```\n", "ACME\n\n```yaml\nname: {{ .Values.name }}\n```\n"},
+		{"unbalanced braces in code", "`{{` opens an action for {{client}}\n", "`{{` opens an action for ACME\n"},
+		{"action around code", "{{if .client}}`{{x}}`{{end}}\n", "`{{x}}`\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandVars(tt.md, source{}, vars, Options{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := map[string]string{"Number": "42"}
+	tests := []struct {
+		name    string
+		opts    []Options
+		wantErr bool
+	}{
+		{"default options", nil, false},
+		{"options", []Options{{Typographer: true}}, false},
+		{"too many options", []Options{{}, {}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf, err := Render("# Invoice {{.Number}}\n", data, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+				t.Error("result is not a PDF")
+			}
+		})
+	}
+}